@@ -0,0 +1,131 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workload_classifier
+
+import (
+	"fmt"
+	"github.com/packagewjx/workload-classifier/internal/server"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+const FlagMigrateTo = "to"
+
+var (
+	dbMysqlHost string
+	migrateTo   int
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "数据库结构管理相关指令",
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "升级或回滚数据库结构",
+	Long: "默认将数据库结构升级到本程序支持的最新版本。通过to指定版本时，升级或回滚到该版本，指定为0将回滚所有迁移并删除所有数据表。\n" +
+		"服务器启动时也会自动升级数据库结构，但数据库结构比服务器支持的更新时，服务器将拒绝启动。\n",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, err := newSchemaMigrator()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = migrator.Close()
+		}()
+
+		if migrateTo < 0 {
+			err = migrator.Up()
+		} else {
+			err = migrator.MigrateTo(uint(migrateTo))
+		}
+		if err != nil {
+			return err
+		}
+
+		version, err := migrator.CurrentVersion()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("数据库结构版本为%d\n", version)
+		return nil
+	},
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "查看数据库结构版本与各个迁移的执行情况",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, err := newSchemaMigrator()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = migrator.Close()
+		}()
+
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		version, err := migrator.CurrentVersion()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("数据库结构版本为%d\n\n", version)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "版本\t名称\t执行时间")
+		for _, status := range statuses {
+			appliedAt := "未执行"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			name := status.Name
+			if status.Unknown {
+				name += "（本程序不支持）"
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, name, appliedAt)
+		}
+		return w.Flush()
+	},
+}
+
+func newSchemaMigrator() (*server.SchemaMigrator, error) {
+	host := dbMysqlHost
+	if host == "" {
+		host = server.MysqlHostFromEnv()
+	}
+	migrator, err := server.NewSchemaMigrator(host)
+	if err != nil {
+		return nil, errors.Wrap(err, "连接数据库出错")
+	}
+	return migrator, nil
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbStatusCmd)
+
+	dbCmd.PersistentFlags().StringVar(&dbMysqlHost, FlagMysqlHost, "",
+		"Mysql服务器主机端口，格式为：host:port。若为空，则读取环境变量MYSQL_SERVICE_HOST与MYSQL_SERVICE_PORT取得")
+	dbMigrateCmd.Flags().IntVar(&migrateTo, FlagMigrateTo, -1,
+		"目标版本。小于0表示本程序支持的最新版本")
+}
@@ -0,0 +1,150 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workload_classifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/internal/server"
+	server2 "github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const FlagDryRunJson = "json"
+
+var (
+	dryRunMysqlHost string
+	dryRunDuration  time.Duration
+	dryRunNumClass  uint
+	dryRunNumRound  uint
+	dryRunMinDays   uint
+	dryRunCoverage  float64
+	dryRunSamples   uint64
+	dryRunJson      bool
+)
+
+var reClusterCmd = &cobra.Command{
+	Use:   "recluster",
+	Short: "再聚类相关指令",
+}
+
+var reClusterDryRunCmd = &cobra.Command{
+	Use:   "dry-run",
+	Short: "使用数据库中的数据试运行再聚类，不修改数据库",
+	Long: "以指定的类别数量与迭代次数对保留时间内的数据执行完整的再聚类流程，输出聚类质量、类别中心的移动以及分类会发生变化的应用。\n" +
+		"提议的类别与当前类别按中心距离一一对应，应用所属的提议类别对应的不是当前类别时视为分类发生变化。\n" +
+		"运行中的服务器也可以通过POST /recluster/dryrun试运行。\n",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host := dryRunMysqlHost
+		if host == "" {
+			host = server.MysqlHostFromEnv()
+		}
+		dao, err := server.NewDao(host)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = dao.Close()
+		}()
+
+		dryRun, err := server.DryRunReCluster(context.Background(), dao, &server.ServerConfig{
+			MetricDuration:     dryRunDuration,
+			NumClass:           dryRunNumClass,
+			NumRound:           dryRunNumRound,
+			MinObservedDays:    dryRunMinDays,
+			MinSectionCoverage: dryRunCoverage,
+			MinSampleCount:     dryRunSamples,
+		}, &server2.ReClusterParams{})
+		if err != nil {
+			return err
+		}
+
+		if dryRunJson {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(dryRun)
+		}
+		printDryRun(dryRun)
+		return nil
+	},
+}
+
+func printDryRun(dryRun *server2.ReClusterDryRun) {
+	summary := dryRun.Summary
+	fmt.Printf("类别数量%d，迭代次数%d\n", dryRun.Params.NumClass, dryRun.Params.NumRound)
+	fmt.Printf("共%d个应用，%d个参与计算类别中心，数据不足的应用数量为%v\n", summary.NumApps, summary.NumClustered, summary.Ineligible)
+	fmt.Printf("距离平方和为%f，平均距离为%f\n", summary.Inertia, summary.MeanDistance)
+	fmt.Printf("%d个应用分类将发生变化，%d个不变，%d个为新应用\n\n", len(dryRun.Changes), dryRun.NumUnchanged, dryRun.NumNewApps)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "提议类别\t对应当前类别\t中心移动距离\t应用数量")
+	for _, shift := range dryRun.CenterShifts {
+		matched := "新增"
+		if shift.MatchedClassId != 0 {
+			matched = fmt.Sprint(shift.MatchedClassId)
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%f\t%d\n", shift.ClassId, matched, shift.Distance, shift.NumApps)
+	}
+	_ = w.Flush()
+	if len(dryRun.RemovedClasses) > 0 {
+		fmt.Printf("将被删除的当前类别：%v\n", dryRun.RemovedClasses)
+	}
+
+	if len(dryRun.Changes) == 0 {
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "名称空间\t名称\t当前类别\t提议类别\t备注")
+	for _, change := range dryRun.Changes {
+		note := ""
+		if change.Provisional {
+			note += "暂定 "
+		}
+		if change.Pinned {
+			note += "已固定"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", change.Namespace, change.Name,
+			change.CurrentClassId, change.ProposedClassId, note)
+	}
+	_ = w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(reClusterCmd)
+	reClusterCmd.AddCommand(reClusterDryRunCmd)
+
+	reClusterDryRunCmd.Flags().StringVar(&dryRunMysqlHost, FlagMysqlHost, "",
+		"Mysql服务器主机端口，格式为：host:port。若为空，则读取环境变量MYSQL_SERVICE_HOST与MYSQL_SERVICE_PORT取得")
+	reClusterDryRunCmd.Flags().DurationVarP(&dryRunDuration, FlagMetricsDuration, "d", server.DefaultMetricDuration,
+		"使用的数据的时间范围，应与服务器的duration参数一致")
+	reClusterDryRunCmd.Flags().UintVarP(&dryRunNumClass, FlagNumClass, "c", server.DefaultNumClass,
+		"聚类类别数量")
+	reClusterDryRunCmd.Flags().UintVarP(&dryRunNumRound, FlagNumRound, "r", server.DefaultNumRound,
+		"聚类迭代次数")
+	reClusterDryRunCmd.Flags().UintVar(&dryRunMinDays, FlagMinDays, server.DefaultMinObservedDays,
+		"应用参与计算类别中心所需的最少有数据的天数")
+	reClusterDryRunCmd.Flags().Float64Var(&dryRunCoverage, FlagMinCoverage, server.DefaultMinSectionCoverage,
+		"应用参与计算类别中心所需的有数据的Section的最小比例")
+	reClusterDryRunCmd.Flags().Uint64Var(&dryRunSamples, FlagMinSamples, server.DefaultMinSampleCount,
+		"应用参与计算类别中心所需的最少样本数量")
+	reClusterDryRunCmd.Flags().BoolVar(&dryRunJson, FlagDryRunJson, false,
+		"以JSON格式输出完整结果，包括提议的类别中心")
+}
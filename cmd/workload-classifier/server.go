@@ -18,29 +18,104 @@ package workload_classifier
 import (
 	"github.com/packagewjx/workload-classifier/internal/server"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"time"
 )
 
 const (
 	FlagPort            = "port"
+	FlagGrpcPort        = "grpc-port"
 	FlagScrapeInterval  = "interval"
 	FlagMetricsDuration = "duration"
 	FlagReClusterTime   = "re-cluster-time"
+	FlagReClusterCron   = "re-cluster-schedule"
+	FlagReClusterEvery  = "re-cluster-interval"
+	FlagReClusterJitter = "re-cluster-jitter"
+	FlagTimeZone        = "time-zone"
 	FlagNumRound        = "round"
 	FlagNumClass        = "class"
 	FlagCenterFile      = "center-file"
+	FlagStorage         = "storage"
 	FlagMysqlHost       = "mysql-host"
+	FlagLeaderElect     = "leader-elect"
+	FlagLeaseNamespace  = "leader-elect-namespace"
+	FlagLeaseName       = "leader-elect-name"
+	FlagLeaseIdentity   = "leader-elect-identity"
+	FlagShutdownGrace   = "shutdown-grace-period"
+	FlagMinDays         = "min-observed-days"
+	FlagMinCoverage     = "min-section-coverage"
+	FlagMinSamples      = "min-sample-count"
+	FlagWebhook         = "class-change-webhook"
+	FlagWebhookRetries  = "class-change-webhook-retries"
+	FlagTLSCertFile     = "tls-cert-file"
+	FlagTLSKeyFile      = "tls-private-key-file"
+	FlagTokenAuthFile   = "token-auth-file"
+	FlagTokenReview     = "authentication-token-review"
+	FlagAdminUsers      = "admin-users"
+	FlagAdminGroups     = "admin-groups"
+	FlagIngestUsers     = "ingest-users"
+	FlagIngestGroups    = "ingest-groups"
+	FlagIncludeNs       = "scrape-include-namespaces"
+	FlagExcludeNs       = "scrape-exclude-namespaces"
+	FlagLabelSelector   = "scrape-label-selector"
+	FlagOwnerKinds      = "scrape-owner-kinds"
+	FlagAppIdentity     = "app-identity"
+	FlagAppIdentityKey  = "app-identity-key"
+	FlagClusterName     = "cluster-name"
+	FlagKubeconfig      = "kubeconfig"
+	FlagKubeContexts    = "kube-contexts"
+	FlagIngestRate      = "ingest-rate-limit"
+	FlagIngestBurst     = "ingest-burst"
+)
+
+// 获取监控数据的过滤规则也可以在配置文件中设置，命令行参数优先
+const (
+	ConfigIncludeNs     = "scrape.includeNamespaces"
+	ConfigExcludeNs     = "scrape.excludeNamespaces"
+	ConfigLabelSelector = "scrape.labelSelector"
+	ConfigOwnerKinds    = "scrape.ownerKinds"
 )
 
 var (
 	port            uint16
+	grpcPort        uint16
 	scrapeInterval  time.Duration
 	metricsDuration time.Duration
 	reClusterTime   time.Duration
+	reClusterCron   string
+	reClusterEvery  time.Duration
+	reClusterJitter time.Duration
+	timeZone        string
 	numRound        uint
 	numClass        uint
 	centerFile      string
+	storage         string
 	mysqlHost       string
+	leaderElect     bool
+	leaseNamespace  string
+	leaseName       string
+	leaseIdentity   string
+	shutdownGrace   time.Duration
+	minDays         uint
+	minCoverage     float64
+	minSamples      uint64
+	webhook         string
+	webhookRetries  uint
+	tlsCertFile     string
+	tlsKeyFile      string
+	tokenAuthFile   string
+	tokenReview     bool
+	adminUsers      []string
+	adminGroups     []string
+	ingestUsers     []string
+	ingestGroups    []string
+	appIdentity     string
+	appIdentityKey  string
+	clusterName     string
+	kubeconfig      string
+	kubeContexts    []string
+	ingestRate      float64
+	ingestBurst     int
 )
 
 // serverCmd represents the server command
@@ -48,18 +123,61 @@ var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "负载分类服务器",
 	Long: "本服务器将从Kubernetes的metrics server中每隔一段时间（通过interval指定）获取一次监控数据，并保存记录。\n" +
-		"监控数据仅保留最近一段时间（通过duration设置）。服务器每天定时（通过re-cluster-time设置）聚类，更新分类数据，\n" +
+		"监控数据仅保留最近一段时间（通过duration设置）。服务器定时（通过re-cluster-time或re-cluster-schedule设置）聚类，更新分类数据，\n" +
 		"以确保数据反映近期的真实情况。用户可以通过本服务器提供的接口获取应用属于哪个类别的数据。\n",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		server, err := server.NewServer(&server.ServerConfig{
 			MetricDuration:       metricsDuration,
 			Port:                 port,
+			GrpcPort:             grpcPort,
 			ScrapeInterval:       scrapeInterval,
 			ReClusterTime:        reClusterTime,
+			ReClusterSchedule:    reClusterCron,
+			ReClusterInterval:    reClusterEvery,
+			ReClusterJitter:      reClusterJitter,
+			TimeZone:             timeZone,
 			NumClass:             numClass,
 			NumRound:             numRound,
 			InitialCenterCsvFile: centerFile,
+			Storage:              storage,
 			MysqlHost:            mysqlHost,
+			ShutdownGracePeriod:  shutdownGrace,
+			MinObservedDays:      minDays,
+			MinSectionCoverage:   minCoverage,
+			MinSampleCount:       minSamples,
+
+			ClassChangeWebhook:        webhook,
+			ClassChangeWebhookRetries: webhookRetries,
+
+			LeaderElect:             leaderElect,
+			LeaderElectionNamespace: leaseNamespace,
+			LeaderElectionName:      leaseName,
+			LeaderElectionIdentity:  leaseIdentity,
+
+			TLSCertFile:   tlsCertFile,
+			TLSKeyFile:    tlsKeyFile,
+			TokenAuthFile: tokenAuthFile,
+			TokenReview:   tokenReview,
+			AdminUsers:    adminUsers,
+			AdminGroups:   adminGroups,
+			IngestUsers:   ingestUsers,
+			IngestGroups:  ingestGroups,
+
+			ScrapeFilter: server.ScrapeFilter{
+				IncludeNamespaces: viper.GetStringSlice(ConfigIncludeNs),
+				ExcludeNamespaces: viper.GetStringSlice(ConfigExcludeNs),
+				LabelSelector:     viper.GetString(ConfigLabelSelector),
+				OwnerKinds:        viper.GetStringSlice(ConfigOwnerKinds),
+			},
+			AppIdentity:    appIdentity,
+			AppIdentityKey: appIdentityKey,
+
+			ClusterName:  clusterName,
+			Kubeconfig:   kubeconfig,
+			KubeContexts: kubeContexts,
+
+			IngestRateLimit: ingestRate,
+			IngestBurst:     ingestBurst,
 		})
 		if err != nil {
 			return err
@@ -74,18 +192,96 @@ func init() {
 
 	serverCmd.Flags().Uint16VarP(&port, FlagPort, "p", server.DefaultPort,
 		"服务端口号")
+	serverCmd.Flags().Uint16Var(&grpcPort, FlagGrpcPort, server.DefaultGrpcPort,
+		"gRPC API端口号，为0则不提供gRPC API")
 	serverCmd.Flags().DurationVarP(&scrapeInterval, FlagScrapeInterval, "i", server.DefaultScrapeInterval,
 		"获取监控数据的间隔，至少为15s")
 	serverCmd.Flags().DurationVarP(&metricsDuration, FlagMetricsDuration, "d", server.DefaultMetricDuration,
 		"保存数据的时间，至少为1天")
 	serverCmd.Flags().DurationVarP(&reClusterTime, FlagReClusterTime, "t", server.DefaultReClusterTime,
-		"每天定时跑聚类算法的时间，值应该小于24小时")
+		"每天定时跑聚类算法的时间，值应该小于24小时。仅在未设置re-cluster-schedule与re-cluster-interval时使用")
+	serverCmd.Flags().StringVar(&reClusterCron, FlagReClusterCron, "",
+		"再聚类的cron表达式，如\"30 1 * * *\"，也支持@daily、@every 6h等形式")
+	serverCmd.Flags().DurationVar(&reClusterEvery, FlagReClusterEvery, 0,
+		"再聚类的固定间隔，不能与re-cluster-schedule同时设置")
+	serverCmd.Flags().DurationVar(&reClusterJitter, FlagReClusterJitter, 0,
+		"每次再聚类时增加的随机延迟的上限")
+	serverCmd.Flags().StringVar(&timeZone, FlagTimeZone, "",
+		"计算再聚类时间所使用的时区，如Asia/Shanghai。为空则使用本地时区")
 	serverCmd.Flags().UintVarP(&numRound, FlagNumRound, "r", server.DefaultNumRound,
 		"聚类迭代次数")
 	serverCmd.Flags().UintVarP(&numClass, FlagNumClass, "c", server.DefaultNumClass,
 		"聚类类别数量")
 	serverCmd.Flags().StringVarP(&centerFile, FlagCenterFile, "f", "",
-		"初始中心文件。若不为空，则启动时将会读取此文件并作为各个类别的数据，此过程将删除旧有数据。若为空，则使用原类数据")
+		"初始中心文件，可以是CSV格式的中心数据，也可以是state export导出的归档。若不为空，则启动时将会读取此文件并作为各个类别的数据，此过程将删除旧有数据；启用leader选举时，数据库中已有类别数据则使用原类数据。若为空，则使用原类数据")
+	serverCmd.Flags().StringVar(&storage, FlagStorage, server.DefaultStorage,
+		"数据存储方式，可选mysql或memory。memory将数据保存在内存中，进程退出后丢失，仅用于测试与演示")
 	serverCmd.Flags().StringVar(&mysqlHost, FlagMysqlHost, "",
 		"Mysql服务器主机端口，格式为：host:port。若为空，则读取环境变量MYSQL_SERVICE_HOST与MYSQL_SERVICE_PORT取得")
+	serverCmd.Flags().BoolVar(&leaderElect, FlagLeaderElect, false,
+		"启用基于Lease的leader选举。启用后可运行多个副本，只有leader获取监控数据与再聚类")
+	serverCmd.Flags().StringVar(&leaseNamespace, FlagLeaseNamespace, server.DefaultLeaderElectionNamespace,
+		"leader选举所用Lease所在的名称空间")
+	serverCmd.Flags().StringVar(&leaseName, FlagLeaseName, server.DefaultLeaderElectionName,
+		"leader选举所用Lease的名称")
+	serverCmd.Flags().StringVar(&leaseIdentity, FlagLeaseIdentity, "",
+		"本实例参与选举的标识。若为空，则读取环境变量POD_NAME，仍为空则使用主机名")
+	serverCmd.Flags().DurationVar(&shutdownGrace, FlagShutdownGrace, server.DefaultShutdownGracePeriod,
+		"收到退出信号后等待正在进行的数据获取与再聚类完成的最长时间，超时后将中止并回滚未完成的数据库操作")
+	serverCmd.Flags().UintVar(&minDays, FlagMinDays, server.DefaultMinObservedDays,
+		"应用参与计算类别中心所需的最少有数据的天数，数据不足的应用只暂定分类")
+	serverCmd.Flags().Float64Var(&minCoverage, FlagMinCoverage, server.DefaultMinSectionCoverage,
+		"应用参与计算类别中心所需的有数据的Section占一天中所有Section的最小比例，范围为0到1")
+	serverCmd.Flags().Uint64Var(&minSamples, FlagMinSamples, server.DefaultMinSampleCount,
+		"应用参与计算类别中心所需的保留时间内的最少样本数量")
+	serverCmd.Flags().StringVar(&webhook, FlagWebhook, "",
+		"再聚类后有应用分类发生变化时，将变化以JSON格式POST到此地址。为空则不通知")
+	serverCmd.Flags().UintVar(&webhookRetries, FlagWebhookRetries, server.DefaultWebhookRetries,
+		"分类变化通知失败后的最大重试次数，重试间隔从1秒开始每次加倍")
+	serverCmd.Flags().StringVar(&tlsCertFile, FlagTLSCertFile, "",
+		"HTTP与gRPC API使用的TLS证书文件，与tls-private-key-file同时设置时启用TLS，文件更新后自动重新加载")
+	serverCmd.Flags().StringVar(&tlsKeyFile, FlagTLSKeyFile, "",
+		"TLS证书的私钥文件")
+	serverCmd.Flags().StringVar(&tokenAuthFile, FlagTokenAuthFile, "",
+		"静态token文件，每行为\"token,用户名,权限\"，权限为read、ingest或admin。设置后API需要bearer token认证")
+	serverCmd.Flags().BoolVar(&tokenReview, FlagTokenReview, false,
+		"通过Kubernetes TokenReview验证bearer token。通过认证的用户具有read权限")
+	serverCmd.Flags().StringSliceVar(&adminUsers, FlagAdminUsers, nil,
+		"通过TokenReview认证后具有admin权限的用户，如system:serviceaccount:default:admin")
+	serverCmd.Flags().StringSliceVar(&adminGroups, FlagAdminGroups, nil,
+		"通过TokenReview认证后，其成员具有admin权限的组")
+	serverCmd.Flags().StringSliceVar(&ingestUsers, FlagIngestUsers, nil,
+		"通过TokenReview认证后具有ingest权限的用户，如system:serviceaccount:monitoring:prometheus")
+	serverCmd.Flags().StringSliceVar(&ingestGroups, FlagIngestGroups, nil,
+		"通过TokenReview认证后，其成员具有ingest权限的组")
+	serverCmd.Flags().StringVar(&appIdentity, FlagAppIdentity, server.DefaultAppIdentity,
+		"确定Pod所属应用的策略，可选owner、label、annotation或name-prefix")
+	serverCmd.Flags().StringVar(&appIdentityKey, FlagAppIdentityKey, "",
+		"label与annotation策略中作为应用名称的标签或注解的键，如app.kubernetes.io/name")
+	serverCmd.Flags().StringVar(&clusterName, FlagClusterName, "",
+		"服务器所在集群的名称。为空则查询该集群的应用时不需要指定集群")
+	serverCmd.Flags().StringVar(&kubeconfig, FlagKubeconfig, "",
+		"kube-contexts所在的kubeconfig文件。为空则使用KUBECONFIG环境变量或~/.kube/config")
+	serverCmd.Flags().StringSliceVar(&kubeContexts, FlagKubeContexts, nil,
+		"同时从kubeconfig中这些context对应的集群获取监控数据，每项为context或集群名称=context，前者集群名称与context相同")
+	serverCmd.Flags().Float64Var(&ingestRate, FlagIngestRate, server.DefaultIngestRateLimit,
+		"每个来源每秒可以推送的监控数据样本数，来源为认证的用户或客户端地址。为0则不限制速率")
+	serverCmd.Flags().IntVar(&ingestBurst, FlagIngestBurst, server.DefaultIngestBurst,
+		"每个来源可以突发推送的样本数，也是单次推送的样本数上限")
+	serverCmd.Flags().StringSlice(FlagIncludeNs, nil,
+		"只获取这些名称空间中应用的监控数据，支持*等通配符。为空则包含所有名称空间")
+	serverCmd.Flags().StringSlice(FlagExcludeNs, nil,
+		"不获取这些名称空间中应用的监控数据，支持通配符，优先于scrape-include-namespaces")
+	serverCmd.Flags().String(FlagLabelSelector, "",
+		"只获取标签满足此选择器的Pod的监控数据，如\"app.kubernetes.io/part-of!=ci\"")
+	serverCmd.Flags().StringSlice(FlagOwnerKinds, nil,
+		"只获取由这些种类的负载创建的Pod的监控数据，可选ReplicaSet、DaemonSet与StatefulSet。为空则包含全部")
+	for key, flag := range map[string]string{
+		ConfigIncludeNs:     FlagIncludeNs,
+		ConfigExcludeNs:     FlagExcludeNs,
+		ConfigLabelSelector: FlagLabelSelector,
+		ConfigOwnerKinds:    FlagOwnerKinds,
+	} {
+		_ = viper.BindPFlag(key, serverCmd.Flags().Lookup(flag))
+	}
 }
@@ -0,0 +1,131 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package workload_classifier
+
+import (
+	"fmt"
+	"github.com/packagewjx/workload-classifier/internal/server"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+const (
+	FlagStateFile      = "file"
+	FlagIncludeMetrics = "include-metrics"
+	FlagStateNumClass  = "class"
+)
+
+var (
+	stateMysqlHost      string
+	stateFile           string
+	stateIncludeMetrics bool
+	stateNumClass       uint
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "导出与导入分类器状态",
+	Long: "分类器状态包括类别中心、应用分类结果，以及可选的原始监控数据，保存为tar.gz归档，可用于备份或在集群之间迁移。\n" +
+		"归档可以直接用作server命令的center-file，此时只读取其中的类别中心。运行中的服务器也可以通过/admin/state导出与导入。\n",
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "将数据库中的分类器状态导出为归档",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dao, err := newStateDao()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = dao.Close()
+		}()
+
+		fout, err := os.Create(stateFile)
+		if err != nil {
+			return errors.Wrap(err, "创建输出文件错误")
+		}
+		manifest, err := server.ExportState(dao, fout, &server.StateExportOptions{IncludeMetrics: stateIncludeMetrics})
+		if err != nil {
+			_ = fout.Close()
+			_ = os.Remove(stateFile)
+			return err
+		}
+		if err := fout.Close(); err != nil {
+			return errors.Wrap(err, "写入输出文件错误")
+		}
+
+		fmt.Printf("已导出%d个类别、%d个应用分类与%d条监控数据\n",
+			len(manifest.ClassIds), manifest.NumAppClasses, manifest.NumMetrics)
+		return nil
+	},
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "从归档导入分类器状态，替换数据库中的类别中心",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fin, err := os.Open(stateFile)
+		if err != nil {
+			return errors.Wrap(err, "打开输入文件错误")
+		}
+		defer func() {
+			_ = fin.Close()
+		}()
+
+		dao, err := newStateDao()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = dao.Close()
+		}()
+
+		manifest, err := server.ImportState(dao, fin, &server.StateImportOptions{NumClass: stateNumClass})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("已导入%d个类别、%d个应用分类与%d条监控数据\n",
+			len(manifest.ClassIds), manifest.NumAppClasses, manifest.NumMetrics)
+		return nil
+	},
+}
+
+func newStateDao() (server.Dao, error) {
+	host := stateMysqlHost
+	if host == "" {
+		host = server.MysqlHostFromEnv()
+	}
+	return server.NewDao(host)
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateExportCmd)
+	stateCmd.AddCommand(stateImportCmd)
+
+	stateCmd.PersistentFlags().StringVar(&stateMysqlHost, FlagMysqlHost, "",
+		"Mysql服务器主机端口，格式为：host:port。若为空，则读取环境变量MYSQL_SERVICE_HOST与MYSQL_SERVICE_PORT取得")
+	stateCmd.PersistentFlags().StringVarP(&stateFile, FlagStateFile, "f", "",
+		"归档文件路径")
+	_ = stateCmd.MarkPersistentFlagRequired(FlagStateFile)
+	stateExportCmd.Flags().BoolVar(&stateIncludeMetrics, FlagIncludeMetrics, false,
+		"是否导出原始监控数据")
+	stateImportCmd.Flags().UintVarP(&stateNumClass, FlagStateNumClass, "c", 0,
+		"要求归档中的类别数量等于此值，与服务器的class参数保持一致。为0时不检查")
+}
@@ -3,12 +3,21 @@ module github.com/packagewjx/workload-classifier
 go 1.15
 
 require (
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/golang/protobuf v1.4.2
+	github.com/golang/snappy v0.0.4
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/packagewjx/kmeanspp v0.0.0-20200923123036-b78845c23250
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/spf13/cobra v1.0.0
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
+	google.golang.org/grpc v1.27.0
+	google.golang.org/protobuf v1.24.0
 	gorm.io/driver/mysql v1.0.2
 	gorm.io/gorm v1.20.2
 	k8s.io/api v0.19.2
@@ -1,13 +1,25 @@
 package server
 
 import (
+	"context"
+	"github.com/packagewjx/workload-classifier/internal/preprocess"
+	"github.com/packagewjx/workload-classifier/internal/utils"
 	"github.com/packagewjx/workload-classifier/pkg/core"
 	"github.com/packagewjx/workload-classifier/pkg/server"
-	"reflect"
+	"math"
+	"time"
 )
 
 func (s *serverImpl) QueryAppCharacteristics(appName server.AppName) (*server.AppCharacteristics, error) {
 	s.logger.Printf("接收到查询名称空间为%s，名称为%s的请求\n", appName.Namespace, appName.Name)
+	pin, err := s.dao.QueryAppPin(&appName)
+	if err == nil {
+		return s.pinnedCharacteristics(appName, pin)
+	} else if err != server.ErrAppNotPinned {
+		s.logger.Printf("查询固定分类失败，原因为：%v\n", err)
+		return nil, err
+	}
+
 	appClass, err := s.dao.QueryAppClassByApp(&appName)
 	if err == server.ErrAppNotFound || err == server.ErrAppNotClassified {
 		return nil, err
@@ -15,39 +27,137 @@ func (s *serverImpl) QueryAppCharacteristics(appName server.AppName) (*server.Ap
 		s.logger.Printf("查询AppClass失败，原因为：%v\n", err)
 		return nil, err
 	}
+	return s.classifiedCharacteristics(appName, appClass)
+}
 
+// 按应用当前的分类计算运行特征
+func (s *serverImpl) classifiedCharacteristics(appName server.AppName, appClass *server.AppClass) (*server.AppCharacteristics, error) {
 	metric, err := s.dao.QueryClassMetricsByClassId(appClass.ClassId)
 	if err != nil {
 		s.logger.Printf("查询ClassMetrics时出错，ClassID为%d，错误为：%v", appClass.ClassId, err)
 		return nil, err
 	}
 
-	result := &server.AppCharacteristics{
+	return &server.AppCharacteristics{
+		AppName:         appName,
+		SectionData:     server.ScaleClassMetrics(metric, appClass.CpuMax, appClass.MemMax),
+		ClassId:         appClass.ClassId,
+		Distance:        appClass.Distance,
+		RunnerUpClassId: appClass.RunnerUpClassId,
+		Confidence:      appClass.Confidence,
+		Provisional:     appClass.Provisional,
+	}, nil
+}
+
+func (s *serverImpl) QueryAppProfile(appName server.AppName) (*server.AppProfile, error) {
+	s.logger.Printf("接收到查询名称空间为%s，名称为%s的应用画像的请求\n", appName.Namespace, appName.Name)
+	sections, err := s.dao.QueryAppSectionRollup(&appName, s.metricsFromDay())
+	if err == server.ErrAppNotFound {
+		return nil, err
+	} else if err != nil {
+		s.logger.Printf("查询应用汇总数据失败，原因为：%v\n", err)
+		return nil, err
+	}
+
+	// 保留时间覆盖的天数内，每个Section应有的样本数量
+	days := math.Ceil(float64(s.config.MetricDuration) / float64(24*time.Hour))
+	expected := days * float64(core.SectionLength) / s.config.ScrapeInterval.Seconds()
+
+	result := &server.AppProfile{
 		AppName:     appName,
-		SectionData: make([]*core.SectionData, len(metric.Data)),
-	}
-
-	typ := reflect.TypeOf(core.SectionData{})
-	for i, datum := range metric.Data {
-		classVal := reflect.ValueOf(datum).Elem()
-		sectionData := &core.SectionData{}
-		appVal := reflect.ValueOf(sectionData).Elem()
-
-		for fi := 0; fi < classVal.NumField(); fi++ {
-			field := typ.Field(fi)
-			if field.Name[:3] == "Cpu" {
-				appVal.FieldByName(field.Name).SetFloat(classVal.FieldByName(field.Name).Float() * float64(appClass.CpuMax))
-			} else /*Mem*/ {
-				appVal.FieldByName(field.Name).SetFloat(classVal.FieldByName(field.Name).Float() * float64(appClass.MemMax))
-			}
+		SectionData: make([]*core.SectionData, core.NumSections),
+		SampleCount: make([]uint64, core.NumSections),
+		Coverage:    make([]float64, core.NumSections),
+	}
+	hasData := false
+	for i, section := range sections {
+		if section == nil || section.count == 0 {
+			continue
+		}
+		hasData = true
+		result.SectionData[i] = section.sectionData()
+		result.SampleCount[i] = section.count
+		if expected > 0 {
+			result.Coverage[i] = math.Min(1, float64(section.count)/expected)
 		}
+	}
 
-		result.SectionData[i] = sectionData
+	appClass, err := s.dao.QueryAppClassByApp(&appName)
+	if err == server.ErrAppNotClassified {
+		return result, nil
+	} else if err != nil {
+		s.logger.Printf("查询AppClass失败，原因为：%v\n", err)
+		return nil, err
+	}
+	result.Classified = true
+	result.ClassId = appClass.ClassId
+	if !hasData {
+		return result, nil
 	}
 
+	metric, err := s.dao.QueryClassMetricsByClassId(appClass.ClassId)
+	if err != nil {
+		s.logger.Printf("查询ClassMetrics时出错，ClassID为%d，错误为：%v", appClass.ClassId, err)
+		return nil, err
+	}
+	// 与再聚类相同，使用预处理后的数据与类别中心比较
+	datum := rollupsToWorkloadData(appName, sections)
+	preprocess.Default().Preprocess(datum)
+	distance := euclideanDistance(utils.SectionDataToFloatArray(datum.Data), utils.SectionDataToFloatArray(metric.Data))
+	result.Distance = &distance
+
+	return result, nil
+}
+
+func (s *serverImpl) ReCluster() error {
+	if !s.IsLeader() {
+		return server.ErrNotLeader
+	}
+	// 不阻塞请求，避免在再聚类线程未运行（正在再聚类或正在退出）时请求无法结束
+	select {
+	case s.executeReCluster <- struct{}{}:
+		return nil
+	default:
+		s.logger.Println("再聚类线程未在等待，拒绝本次再聚类请求")
+		return server.ErrReClusterBusy
+	}
+}
+
+func (s *serverImpl) QueryReClusterSchedule() (*server.ReClusterSchedule, error) {
+	result := &server.ReClusterSchedule{
+		Schedule: s.scheduleSpec,
+		TimeZone: s.location.String(),
+	}
+	if next := s.nextReClusterTime(); !next.IsZero() {
+		result.Next = next.In(s.location)
+	}
 	return result, nil
 }
 
-func (s *serverImpl) ReCluster() {
-	s.executeReCluster <- struct{}{}
+func (s *serverImpl) QueryReClusterSummary() (*server.ReClusterSummary, error) {
+	summary := s.lastReClusterSummary()
+	if summary == nil {
+		return nil, server.ErrReClusterNotRun
+	}
+	return summary, nil
+}
+
+func (s *serverImpl) QueryAppClassHistory(appName server.AppName) ([]*server.AppClassHistory, error) {
+	s.logger.Printf("接收到查询名称空间为%s，名称为%s的应用分类历史的请求\n", appName.Namespace, appName.Name)
+	history, err := s.dao.QueryAppClassHistory(&appName)
+	if err != nil {
+		s.logger.Printf("查询应用分类历史失败，原因为：%v\n", err)
+		return nil, err
+	}
+	if len(history) == 0 {
+		// 区分没有历史的应用与不存在的应用
+		if _, err := s.dao.QueryAppClassByApp(&appName); err == server.ErrAppNotFound {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+func (s *serverImpl) ReClusterDryRun(params *server.ReClusterParams) (*server.ReClusterDryRun, error) {
+	return s.dryRunReCluster(context.Background(), params)
 }
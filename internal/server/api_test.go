@@ -1,18 +1,24 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/packagewjx/workload-classifier/pkg/core"
 	"github.com/packagewjx/workload-classifier/pkg/server"
 	"github.com/stretchr/testify/assert"
 	"log"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestServerImpl_QueryAppCharacteristics(t *testing.T) {
+	requireTestFile(t, "../../test/csv/centers.csv")
 	// 数据准备
-	dao, _ := NewDao("127.0.0.1:3306")
+	dao := NewMemoryDao()
 	s := &serverImpl{
 		config: &ServerConfig{
 			MetricDuration:       0,
@@ -26,6 +32,7 @@ func TestServerImpl_QueryAppCharacteristics(t *testing.T) {
 		},
 		dao:              dao,
 		logger:           log.New(os.Stdout, "", 0),
+		metrics:          newServerMetrics(),
 		executeReCluster: nil,
 	}
 
@@ -83,7 +90,7 @@ func TestServerImpl_QueryAppCharacteristics(t *testing.T) {
 		assert.FailNow(t, "造数据错误")
 	}
 
-	err = s.reCluster()
+	err = s.reCluster(context.Background())
 	if !assert.NoError(t, err) {
 		assert.FailNow(t, "聚类错误")
 	}
@@ -116,3 +123,99 @@ func TestServerImpl_QueryAppCharacteristics(t *testing.T) {
 	})
 
 }
+
+func TestServerImpl_QueryAppProfile(t *testing.T) {
+	dao := NewMemoryDao()
+	s := &serverImpl{
+		config: &ServerConfig{
+			MetricDuration: 7 * 24 * time.Hour,
+			ScrapeInterval: time.Minute,
+			NumClass:       DefaultNumClass,
+			NumRound:       DefaultNumRound,
+		},
+		dao:     dao,
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+
+	// 昨天的前4个Section，每个Section有15个样本
+	appName := server.AppName{Name: "profile", Namespace: "test"}
+	base := (uint64(time.Now().Unix())/core.DayLength - 1) * core.DayLength
+	arr := make([]*server.AppPodMetrics, 0)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 15; j++ {
+			arr = append(arr, &server.AppPodMetrics{
+				AppName:   appName,
+				Timestamp: base + uint64(i*core.SectionLength+60*j),
+				Cpu:       float32(i + 1),
+				Mem:       float32(j + 1),
+			})
+		}
+	}
+	assert.NoError(t, dao.SaveAllAppPodMetrics(arr))
+
+	_, err := s.QueryAppProfile(server.AppName{Name: "none", Namespace: "test"})
+	assert.Equal(t, server.ErrAppNotFound, err)
+
+	// 未分类的应用只返回自身的数据
+	profile, err := s.QueryAppProfile(appName)
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "查询应用画像失败")
+	}
+	assert.False(t, profile.Classified)
+	assert.Nil(t, profile.Distance)
+	assert.Equal(t, core.NumSections, len(profile.SectionData))
+	assert.Equal(t, float32(3), profile.SectionData[2].CpuMax)
+	assert.Equal(t, float32(15), profile.SectionData[2].MemMax)
+	assert.Equal(t, uint64(15), profile.SampleCount[0])
+	assert.InDelta(t, float64(15)/(7*15), profile.Coverage[0], 1e-9)
+	assert.Nil(t, profile.SectionData[4])
+	assert.Equal(t, uint64(0), profile.SampleCount[4])
+	assert.Equal(t, float64(0), profile.Coverage[4])
+
+	// 已分类的应用返回与类别中心的距离
+	center := &server.ClassMetrics{ClassId: 1, Data: make([]*core.SectionData, core.NumSections)}
+	for i := range center.Data {
+		center.Data[i] = &core.SectionData{}
+	}
+	assert.NoError(t, dao.SaveClassMetrics(center))
+	confidence := 0.8
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1, CpuMax: 4, MemMax: 15,
+		Distance: 0.1, RunnerUpClassId: 2, RunnerUpDistance: 0.5, Confidence: &confidence}))
+	profile, err = s.QueryAppProfile(appName)
+	assert.NoError(t, err)
+	assert.True(t, profile.Classified)
+	assert.Equal(t, uint(1), profile.ClassId)
+	if assert.NotNil(t, profile.Distance) {
+		assert.True(t, *profile.Distance > 0)
+	}
+
+	// 类别画像返回分类的距离与置信度
+	characteristics, err := s.QueryAppCharacteristics(appName)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), characteristics.ClassId)
+	assert.Equal(t, 0.1, characteristics.Distance)
+	assert.Equal(t, uint(2), characteristics.RunnerUpClassId)
+	if assert.NotNil(t, characteristics.Confidence) {
+		assert.Equal(t, confidence, *characteristics.Confidence)
+	}
+
+	// HTTP接口
+	handler := s.buildServer().Handler
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/namespaces/test/appprofile/profile", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	decoded := &server.AppProfile{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), decoded))
+	assert.Equal(t, profile.SampleCount, decoded.SampleCount)
+	assert.Nil(t, decoded.SectionData[4])
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/namespaces/test/appprofile/none", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// 原有的接口不受影响
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/namespaces/test/appcharacteristics/profile", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+)
+
+// AppID缓存的默认容量
+const DefaultAppIdCacheSize = 50000
+
+type appIdEntry struct {
+	key string
+	id  uint
+}
+
+// 并发安全的AppID缓存。超过容量时淘汰最久未使用的记录
+type appIdCache struct {
+	lock     sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // 越靠前越近使用
+}
+
+func newAppIdCache(capacity int) *appIdCache {
+	if capacity <= 0 {
+		capacity = DefaultAppIdCacheSize
+	}
+	return &appIdCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *appIdCache) get(key string) (uint, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return 0, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*appIdEntry).id, true
+}
+
+func (c *appIdCache) add(key string, id uint) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*appIdEntry).id = id
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&appIdEntry{key: key, id: id})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*appIdEntry).key)
+	}
+}
+
+func (c *appIdCache) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
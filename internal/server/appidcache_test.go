@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestAppIdCache(t *testing.T) {
+	cache := newAppIdCache(3)
+	cache.add("a", 1)
+	cache.add("b", 2)
+	cache.add("c", 3)
+
+	id, ok := cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, uint(1), id)
+
+	// 超过容量时淘汰最久未使用的b
+	cache.add("d", 4)
+	assert.Equal(t, 3, cache.len())
+	_, ok = cache.get("b")
+	assert.False(t, ok)
+	for key, expected := range map[string]uint{"a": 1, "c": 3, "d": 4} {
+		id, ok := cache.get(key)
+		assert.True(t, ok)
+		assert.Equal(t, expected, id)
+	}
+
+	// 更新已有记录
+	cache.add("a", 10)
+	id, _ = cache.get("a")
+	assert.Equal(t, uint(10), id)
+	assert.Equal(t, 3, cache.len())
+
+	assert.Equal(t, DefaultAppIdCacheSize, newAppIdCache(0).capacity)
+}
+
+func TestAppIdCache_Concurrent(t *testing.T) {
+	const capacity = 100
+	cache := newAppIdCache(capacity)
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("app-%d", (g*1000+i)%300)
+				if id, ok := cache.get(key); ok {
+					assert.Equal(t, uint((g*1000+i)%300), id)
+				} else {
+					cache.add(key, uint((g*1000+i)%300))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.Equal(t, capacity, cache.len())
+}
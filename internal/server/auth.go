@@ -0,0 +1,365 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authenticationclient "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 静态token文件中的权限
+const (
+	PermissionRead   = "read"   // 查询分类结果
+	PermissionIngest = "ingest" // 在read的基础上，可以推送监控数据
+	PermissionAdmin  = "admin"  // 在ingest的基础上，可以再聚类、固定分类与导入导出状态
+)
+
+// 调用API所需的权限
+type permission int
+
+const (
+	permissionNone   permission = iota // 不需要认证，用于存活检查、就绪检查与监控指标
+	permissionRead                     // 需要read、ingest或admin权限
+	permissionIngest                   // 需要ingest或admin权限
+	permissionAdmin                    // 需要admin权限
+)
+
+const (
+	tokenReviewCacheTTL         = time.Minute      // 通过认证的TokenReview结果的缓存时间
+	tokenReviewNegativeCacheTTL = 10 * time.Second // 未通过认证的TokenReview结果的缓存时间
+	tokenReviewCacheSize        = 1000
+	tokenReviewTimeout          = 10 * time.Second
+)
+
+// 需要admin权限的gRPC方法
+var grpcAdminMethods = map[string]bool{
+	"/workloadclassifier.v1.WorkloadClassifier/ReCluster":       true,
+	"/workloadclassifier.v1.WorkloadClassifier/ReClusterDryRun": true,
+}
+
+// 通过认证的调用者
+type caller struct {
+	name   string
+	ingest bool
+	admin  bool
+}
+
+type callerKey struct{}
+
+func withCaller(ctx context.Context, c *caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// 未启用认证时返回nil
+func callerFromContext(ctx context.Context) *caller {
+	c, _ := ctx.Value(callerKey{}).(*caller)
+	return c
+}
+
+type authenticator interface {
+	// token无效时返回nil, nil，无法完成认证时返回错误
+	authenticate(ctx context.Context, token string) (*caller, error)
+}
+
+// 按配置创建认证器，未配置任何认证方式时返回nil，此时不进行认证
+func newAuthenticator(config *ServerConfig, logger *log.Logger) (authenticator, error) {
+	authenticators := make(unionAuthenticator, 0, 2)
+	if config.TokenAuthFile != "" {
+		a, err := newStaticTokenAuthenticator(config.TokenAuthFile, logger)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if config.TokenReview {
+		clientSet, err := newKubernetesClientSet()
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, newTokenReviewAuthenticator(clientSet.AuthenticationV1().TokenReviews(),
+			config.AdminUsers, config.AdminGroups, config.IngestUsers, config.IngestGroups))
+	}
+	if len(authenticators) == 0 {
+		return nil, nil
+	}
+	return authenticators, nil
+}
+
+// 依次尝试各个认证器，使用第一个认证通过的结果
+type unionAuthenticator []authenticator
+
+func (u unionAuthenticator) authenticate(ctx context.Context, token string) (*caller, error) {
+	for _, a := range u {
+		c, err := a.authenticate(ctx, token)
+		if c != nil || err != nil {
+			return c, err
+		}
+	}
+	return nil, nil
+}
+
+// 从文件读取token，文件被修改后重新加载。文件每行为"token,用户名,权限"，忽略空行与#开头的行
+type staticTokenAuthenticator struct {
+	path   string
+	logger *log.Logger
+
+	lock     sync.Mutex
+	detector *fileChangeDetector
+	tokens   map[[sha256.Size]byte]*caller
+}
+
+func newStaticTokenAuthenticator(path string, logger *log.Logger) (*staticTokenAuthenticator, error) {
+	a := &staticTokenAuthenticator{
+		path:     path,
+		logger:   logger,
+		detector: newFileChangeDetector(path),
+	}
+	if err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *staticTokenAuthenticator) reload() error {
+	content, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return errors.Wrap(err, "读取token文件出错")
+	}
+	tokens, err := parseTokenFile(content)
+	if err != nil {
+		return err
+	}
+	a.tokens = tokens
+	return nil
+}
+
+func parseTokenFile(content []byte) (map[[sha256.Size]byte]*caller, error) {
+	tokens := make(map[[sha256.Size]byte]*caller)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("token文件第%d行格式错误，应为token,用户名,权限", lineNum)
+		}
+		token, name, perm := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2])
+		if token == "" || name == "" {
+			return nil, fmt.Errorf("token文件第%d行的token或用户名为空", lineNum)
+		}
+		if perm != PermissionRead && perm != PermissionIngest && perm != PermissionAdmin {
+			return nil, fmt.Errorf("token文件第%d行的权限%s不合法，应为%s、%s或%s", lineNum, perm,
+				PermissionRead, PermissionIngest, PermissionAdmin)
+		}
+		tokens[sha256.Sum256([]byte(token))] = &caller{name: name, ingest: perm == PermissionIngest, admin: perm == PermissionAdmin}
+	}
+	return tokens, scanner.Err()
+}
+
+func (a *staticTokenAuthenticator) authenticate(_ context.Context, token string) (*caller, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.detector.changed(time.Now()) {
+		// 加载失败时继续使用原有的token
+		if err := a.reload(); err != nil {
+			a.logger.Printf("重新加载token文件出错，继续使用原有token：%v\n", err)
+		}
+	}
+	return a.tokens[sha256.Sum256([]byte(token))], nil
+}
+
+type tokenReviewCacheEntry struct {
+	caller *caller
+	expire time.Time
+}
+
+// 通过Kubernetes的TokenReview验证token，结果缓存一段时间。AdminUsers中的用户与AdminGroups中的组的成员具有admin权限，
+// IngestUsers中的用户与IngestGroups中的组的成员具有ingest权限，其余为read权限
+type tokenReviewAuthenticator struct {
+	client       authenticationclient.TokenReviewInterface
+	adminUsers   map[string]bool
+	adminGroups  map[string]bool
+	ingestUsers  map[string]bool
+	ingestGroups map[string]bool
+
+	lock  sync.Mutex
+	cache map[[sha256.Size]byte]*tokenReviewCacheEntry
+}
+
+func newTokenReviewAuthenticator(client authenticationclient.TokenReviewInterface,
+	adminUsers, adminGroups, ingestUsers, ingestGroups []string) *tokenReviewAuthenticator {
+	return &tokenReviewAuthenticator{
+		client:       client,
+		adminUsers:   stringSet(adminUsers),
+		adminGroups:  stringSet(adminGroups),
+		ingestUsers:  stringSet(ingestUsers),
+		ingestGroups: stringSet(ingestGroups),
+		cache:        make(map[[sha256.Size]byte]*tokenReviewCacheEntry),
+	}
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+func (a *tokenReviewAuthenticator) authenticate(ctx context.Context, token string) (*caller, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	a.lock.Lock()
+	entry, ok := a.cache[key]
+	a.lock.Unlock()
+	if ok && now.Before(entry.expire) {
+		return entry.caller, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tokenReviewTimeout)
+	defer cancel()
+	review, err := a.client.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "TokenReview请求出错")
+	}
+
+	var c *caller
+	ttl := tokenReviewNegativeCacheTTL
+	if review.Status.Authenticated {
+		user := review.Status.User
+		c = &caller{name: user.Username, admin: a.adminUsers[user.Username], ingest: a.ingestUsers[user.Username]}
+		for _, group := range user.Groups {
+			c.admin = c.admin || a.adminGroups[group]
+			c.ingest = c.ingest || a.ingestGroups[group]
+		}
+		ttl = tokenReviewCacheTTL
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if len(a.cache) >= tokenReviewCacheSize {
+		for k, e := range a.cache {
+			if !now.Before(e.expire) {
+				delete(a.cache, k)
+			}
+		}
+		if len(a.cache) >= tokenReviewCacheSize {
+			a.cache = make(map[[sha256.Size]byte]*tokenReviewCacheEntry)
+		}
+	}
+	a.cache[key] = &tokenReviewCacheEntry{caller: c, expire: now.Add(ttl)}
+	return c, nil
+}
+
+// 解析"Bearer token"形式的Authorization头，格式不符时返回空字符串
+func bearerToken(header string) string {
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+// 检查调用者的权限。没有token或token无效时返回codes.Unauthenticated，权限不足时返回codes.PermissionDenied
+func (s *serverImpl) checkPermission(ctx context.Context, token string, required permission) (*caller, error) {
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "缺少token")
+	}
+	c, err := s.authenticator.authenticate(ctx, token)
+	if err != nil {
+		s.logger.Printf("认证出错：%v\n", err)
+		return nil, status.Error(codes.Unavailable, "暂时无法认证")
+	} else if c == nil {
+		return nil, status.Error(codes.Unauthenticated, "token无效")
+	}
+	if required == permissionAdmin && !c.admin {
+		return nil, status.Errorf(codes.PermissionDenied, "用户%s没有admin权限", c.name)
+	}
+	if required == permissionIngest && !c.ingest && !c.admin {
+		return nil, status.Errorf(codes.PermissionDenied, "用户%s没有ingest权限", c.name)
+	}
+	return c, nil
+}
+
+// 启用认证时，要求调用者具有required权限，并将调用者保存在请求的context中
+func (s *serverImpl) authorize(required permission, handler http.HandlerFunc) http.HandlerFunc {
+	if s.authenticator == nil || required == permissionNone {
+		return handler
+	}
+	return func(writer http.ResponseWriter, request *http.Request) {
+		c, err := s.checkPermission(request.Context(), bearerToken(request.Header.Get("Authorization")), required)
+		if err != nil {
+			st := status.Convert(err)
+			switch st.Code() {
+			case codes.Unauthenticated:
+				writer.Header().Set("WWW-Authenticate", `Bearer realm="workload-classifier"`)
+				http.Error(writer, st.Message(), http.StatusUnauthorized)
+			case codes.PermissionDenied:
+				http.Error(writer, st.Message(), http.StatusForbidden)
+			default:
+				http.Error(writer, st.Message(), http.StatusServiceUnavailable)
+			}
+			return
+		}
+		handler(writer, request.WithContext(withCaller(request.Context(), c)))
+	}
+}
+
+func grpcMethodPermission(fullMethod string) permission {
+	if grpcAdminMethods[fullMethod] {
+		return permissionAdmin
+	}
+	return permissionRead
+}
+
+// 从gRPC请求的authorization元数据中取得token并检查权限
+func (s *serverImpl) authorizeGrpc(ctx context.Context, fullMethod string) (context.Context, error) {
+	if s.authenticator == nil {
+		return ctx, nil
+	}
+	token := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = bearerToken(values[0])
+		}
+	}
+	c, err := s.checkPermission(ctx, token, grpcMethodPermission(fullMethod))
+	if err != nil {
+		return nil, err
+	}
+	return withCaller(ctx, c), nil
+}
+
+func (s *serverImpl) authUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authorizeGrpc(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *serverImpl) authStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, err := s.authorizeGrpc(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTokenFile(t *testing.T, path, content string) {
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+}
+
+func TestServerImpl_Authorize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	tokenFile := filepath.Join(dir, "tokens.csv")
+	writeTokenFile(t, tokenFile, "# token,用户名,权限\nreader-token,reader,read\ningest-token,pusher,ingest\n\nadmin-token,admin,admin\n")
+
+	logger := log.New(os.Stdout, "", 0)
+	auth, err := newAuthenticator(&ServerConfig{TokenAuthFile: tokenFile}, logger)
+	assert.NoError(t, err)
+	s := &serverImpl{
+		config:           &ServerConfig{},
+		dao:              NewMemoryDao(),
+		logger:           logger,
+		metrics:          newServerMetrics(),
+		executeReCluster: make(chan struct{}),
+		authenticator:    auth,
+	}
+	handler := s.buildServer().Handler
+	request := func(method, path, token string) int {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+
+	// 存活检查与监控指标不需要认证
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/livez", ""))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/metrics", ""))
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/classification", ""))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/classification", "unknown"))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/classification", "reader-token"))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/namespaces/test/appcharacteristics/app", ""))
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/namespaces/test/appcharacteristics/app", "reader-token"))
+
+	// 再聚类与管理API需要admin权限
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/recluster", "reader-token"))
+	// 通过认证，但本实例不是leader
+	assert.Equal(t, http.StatusServiceUnavailable, request(http.MethodGet, "/recluster", "admin-token"))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/admin/pins", "reader-token"))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/admin/pins", "admin-token"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(s.metrics.apiRequests.WithLabelValues("/recluster", "403")))
+
+	// 推送监控数据需要ingest或admin权限，ingest权限包含read权限
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/ingest", "reader-token"))
+	assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodGet, "/ingest", "ingest-token"))
+	assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodGet, "/ingest/remote-write", "admin-token"))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/classification", "ingest-token"))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/recluster", "ingest-token"))
+
+	// 文件修改后重新加载
+	writeTokenFile(t, tokenFile, "new-token,reader,read\n")
+	auth.(unionAuthenticator)[0].(*staticTokenAuthenticator).detector.interval = 0
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/classification", "new-token"))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/classification", "reader-token"))
+
+	// 加载失败时继续使用原有的token
+	writeTokenFile(t, tokenFile, "broken\n")
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/classification", "new-token"))
+}
+
+func TestParseTokenFile(t *testing.T) {
+	_, err := parseTokenFile([]byte("token,user\n"))
+	assert.Error(t, err)
+	_, err = parseTokenFile([]byte("token,user,write\n"))
+	assert.Error(t, err)
+	_, err = parseTokenFile([]byte(",user,read\n"))
+	assert.Error(t, err)
+	tokens, err := parseTokenFile([]byte(" token , user , admin \n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tokens))
+}
+
+func TestServerImpl_AuthorizeGrpc(t *testing.T) {
+	file, err := ioutil.TempFile("", "tokens")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+	writeTokenFile(t, file.Name(), "reader-token,reader,read\n")
+	logger := log.New(os.Stdout, "", 0)
+	auth, err := newStaticTokenAuthenticator(file.Name(), logger)
+	assert.NoError(t, err)
+	s := &serverImpl{logger: logger, authenticator: auth}
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	_, err = s.authorizeGrpc(context.Background(), "/workloadclassifier.v1.WorkloadClassifier/ListClasses")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	ctx, err := s.authorizeGrpc(withToken("reader-token"), "/workloadclassifier.v1.WorkloadClassifier/ListClasses")
+	assert.NoError(t, err)
+	assert.Equal(t, "reader", callerFromContext(ctx).name)
+	_, err = s.authorizeGrpc(withToken("reader-token"), "/workloadclassifier.v1.WorkloadClassifier/ReCluster")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	clientSet := fake.NewSimpleClientset()
+	reviews := 0
+	clientSet.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch review.Spec.Token {
+		case "sa-token":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:default:scheduler",
+				Groups:   []string{"system:serviceaccounts"},
+			}}
+		case "prometheus-token":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:monitoring:prometheus",
+				Groups:   []string{"system:serviceaccounts"},
+			}}
+		case "ops-token":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{
+				Username: "alice",
+				Groups:   []string{"ops"},
+			}}
+		}
+		return true, review, nil
+	})
+	a := newTokenReviewAuthenticator(clientSet.AuthenticationV1().TokenReviews(), nil, []string{"ops"},
+		[]string{"system:serviceaccount:monitoring:prometheus"}, nil)
+
+	c, err := a.authenticate(context.Background(), "sa-token")
+	assert.NoError(t, err)
+	assert.Equal(t, "system:serviceaccount:default:scheduler", c.name)
+	assert.False(t, c.admin)
+	assert.False(t, c.ingest)
+	c, err = a.authenticate(context.Background(), "prometheus-token")
+	assert.NoError(t, err)
+	assert.True(t, c.ingest)
+	assert.False(t, c.admin)
+	c, err = a.authenticate(context.Background(), "ops-token")
+	assert.NoError(t, err)
+	assert.True(t, c.admin)
+	c, err = a.authenticate(context.Background(), "bad-token")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	// 结果被缓存
+	_, _ = a.authenticate(context.Background(), "sa-token")
+	_, _ = a.authenticate(context.Background(), "bad-token")
+	assert.Equal(t, 4, reviews)
+}
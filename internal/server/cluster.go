@@ -0,0 +1,146 @@
+package server
+
+import (
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"net/http"
+	"strings"
+)
+
+// 查询接口中指定集群的参数。查询单个应用时为空表示服务器所在的集群，查询列表时为空表示所有集群
+const clusterParam = "cluster"
+
+// 集群名称的格式与标签值相同，最长63个字符
+func validateClusterName(cluster string) error {
+	if errs := validation.IsValidLabelValue(cluster); len(errs) != 0 {
+		return fmt.Errorf("集群名称%q不合法：%s", cluster, strings.Join(errs, "；"))
+	}
+	return nil
+}
+
+// 返回请求的集群参数
+func clusterFromRequest(request *http.Request) (string, error) {
+	cluster := request.URL.Query().Get(clusterParam)
+	if err := validateClusterName(cluster); err != nil {
+		return "", err
+	}
+	return cluster, nil
+}
+
+// 由路径中的名称空间与名称以及集群参数组成应用名称
+func appNameFromRequest(request *http.Request, namespace, name string) (server.AppName, error) {
+	cluster, err := clusterFromRequest(request)
+	if err != nil {
+		return server.AppName{}, err
+	}
+	return server.AppName{Name: name, Namespace: namespace, Cluster: cluster}, nil
+}
+
+// cluster为空时包含所有集群的应用
+func appInCluster(appName server.AppName, cluster string) bool {
+	return cluster == "" || appName.Cluster == cluster
+}
+
+// 只保留cluster中的应用分类，类别中心为各集群共用，全部保留
+func filterSnapshotByCluster(snapshot *server.ClassificationSnapshot, cluster string) {
+	if cluster == "" {
+		return
+	}
+	apps := make([]*server.AppAssignment, 0)
+	for _, app := range snapshot.Apps {
+		if appInCluster(app.AppName, cluster) {
+			apps = append(apps, app)
+		}
+	}
+	snapshot.Apps = apps
+	pins := make([]*server.AppPin, 0)
+	for _, pin := range snapshot.Pins {
+		if appInCluster(pin.AppName, cluster) {
+			pins = append(pins, pin)
+		}
+	}
+	snapshot.Pins = pins
+}
+
+// 其他集群的应用分类与固定分类的变化不推送，类别中心的变化与BOOKMARK总是推送
+func eventInCluster(event *server.ClassificationEvent, cluster string) bool {
+	switch {
+	case event.AppAssignment != nil:
+		return appInCluster(event.AppAssignment.AppName, cluster)
+	case event.AppPin != nil:
+		return appInCluster(event.AppPin.AppName, cluster)
+	default:
+		return true
+	}
+}
+
+// 获取监控数据的集群
+type clusterSource struct {
+	name   string // 集群名称，即应用的AppName.Cluster
+	url    string // api server的地址
+	client *http.Client
+}
+
+// 服务器所在的集群通过KubeApiServerProxyUrl访问，其他集群来自kubeconfig中的context
+func (s *serverImpl) clusterSources() []*clusterSource {
+	local := &clusterSource{name: s.clusterName, url: s.apiServerUrl, client: http.DefaultClient}
+	return append([]*clusterSource{local}, s.remoteClusters...)
+}
+
+// KubeContexts的一项，格式为"context"或"集群名称=context"，前者集群名称与context相同
+type kubeContext struct {
+	cluster string
+	context string
+}
+
+// 解析并检查KubeContexts，集群名称不能为空，且不能与服务器所在集群的名称localCluster重复
+func parseKubeContexts(entries []string, localCluster string) ([]kubeContext, error) {
+	result := make([]kubeContext, 0, len(entries))
+	clusters := map[string]bool{localCluster: true}
+	for _, entry := range entries {
+		c := kubeContext{cluster: entry, context: entry}
+		if i := strings.Index(entry, "="); i >= 0 {
+			c.cluster, c.context = entry[:i], entry[i+1:]
+		}
+		if c.cluster == "" || c.context == "" {
+			return nil, fmt.Errorf("kubeconfig context %q的格式错误，应为context或集群名称=context", entry)
+		}
+		if err := validateClusterName(c.cluster); err != nil {
+			return nil, err
+		}
+		if clusters[c.cluster] {
+			return nil, fmt.Errorf("集群名称%q重复", c.cluster)
+		}
+		clusters[c.cluster] = true
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// 由kubeconfig中的context创建获取监控数据的集群。kubeconfig为空时使用KUBECONFIG环境变量或~/.kube/config
+func newKubeconfigClusters(kubeconfig string, contexts []kubeContext) ([]*clusterSource, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	result := make([]*clusterSource, 0, len(contexts))
+	for _, c := range contexts {
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: c.context}
+		restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+		if err != nil {
+			return nil, errors.Wrapf(err, "读取kubeconfig context %s出错", c.context)
+		}
+		transport, err := rest.TransportFor(restConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "创建集群%s的连接出错", c.cluster)
+		}
+		result = append(result, &clusterSource{
+			name:   c.cluster,
+			url:    strings.TrimSuffix(restConfig.Host, "/"),
+			client: &http.Client{Transport: transport, Timeout: restConfig.Timeout},
+		})
+	}
+	return result, nil
+}
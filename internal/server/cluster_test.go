@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/client"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseKubeContexts(t *testing.T) {
+	contexts, err := parseKubeContexts([]string{"east", "west=admin@west"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []kubeContext{{cluster: "east", context: "east"}, {cluster: "west", context: "admin@west"}}, contexts)
+
+	for _, entries := range [][]string{
+		{"=west"},
+		{"west="},
+		{"bad name=west"},
+		{"east", "east=other"},
+		{"local=west"},
+	} {
+		_, err := parseKubeContexts(entries, "local")
+		assert.Error(t, err, "%v", entries)
+	}
+}
+
+func TestServerImpl_Cluster(t *testing.T) {
+	dao := NewMemoryDao()
+	saveTestPatternCenters(t, dao)
+	local := server.AppName{Name: "web", Namespace: "test"}
+	east := server.AppName{Name: "web", Namespace: "test", Cluster: "east"}
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: local, ClassId: 1, CpuMax: 1, MemMax: 1}))
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: east, ClassId: 2, CpuMax: 1, MemMax: 1}))
+	s := &serverImpl{
+		config:  &ServerConfig{NumClass: 2},
+		dao:     dao,
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+	httpServer := httptest.NewServer(s.buildServer().Handler)
+	defer httpServer.Close()
+
+	// 不合法的集群名称
+	for _, path := range []string{
+		"/namespaces/test/appcharacteristics/web",
+		"/namespaces/test/appprofile/web",
+		"/classification",
+		"/admin/pins",
+	} {
+		response, err := http.Get(httpServer.URL + path + "?cluster=bad%20name")
+		if assert.NoError(t, err) {
+			_ = response.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode, path)
+		}
+	}
+
+	srv, _ := s.buildGrpcServer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	defer srv.Stop()
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "连接gRPC服务器出错")
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	httpApi, err := client.NewApiClientWithConfig(&client.ClientConfig{BaseUrl: httpServer.URL})
+	assert.NoError(t, err)
+	eastHttpApi, err := client.NewApiClientWithConfig(&client.ClientConfig{BaseUrl: httpServer.URL, Cluster: "east"})
+	assert.NoError(t, err)
+	grpcApi := client.NewGrpcApiClient(conn)
+
+	for _, c := range []struct {
+		name   string
+		all    server.API
+		inEast server.API
+	}{
+		{"http", httpApi, eastHttpApi},
+		{"grpc", grpcApi, grpcApi.ForCluster("east")},
+	} {
+		// 名称相同的应用在不同集群中分别分类
+		characteristics, err := c.all.QueryAppCharacteristics(local)
+		if assert.NoError(t, err, c.name) {
+			assert.Equal(t, local, characteristics.AppName, c.name)
+			assert.Equal(t, uint(1), characteristics.ClassId, c.name)
+		}
+		characteristics, err = c.all.QueryAppCharacteristics(east)
+		if assert.NoError(t, err, c.name) {
+			assert.Equal(t, east, characteristics.AppName, c.name)
+			assert.Equal(t, uint(2), characteristics.ClassId, c.name)
+		}
+		_, err = c.all.QueryAppCharacteristics(server.AppName{Name: "web", Namespace: "test", Cluster: "west"})
+		assert.Error(t, err, c.name)
+
+		// 不指定集群时快照包含所有集群的应用，类别中心总是全部返回
+		snapshot, err := c.all.QueryClassificationSnapshot()
+		if assert.NoError(t, err, c.name) {
+			assert.Equal(t, 2, len(snapshot.Apps), c.name)
+		}
+		snapshot, err = c.inEast.QueryClassificationSnapshot()
+		if assert.NoError(t, err, c.name) && assert.Equal(t, 1, len(snapshot.Apps), c.name) {
+			assert.Equal(t, east, snapshot.Apps[0].AppName, c.name)
+			assert.Equal(t, 2, len(snapshot.Centers), c.name)
+		}
+	}
+
+	// 只推送指定集群的应用分类变化
+	events := make([]*server.ClassificationEvent, 0)
+	version := uint64(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = grpcApi.ForCluster("east").Watch(ctx, &version, func(event *server.ClassificationEvent) error {
+		events = append(events, event)
+		if len(events) == 3 {
+			return fmt.Errorf("结束")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "结束")
+	for _, event := range events {
+		if event.AppAssignment != nil {
+			assert.Equal(t, east, event.AppAssignment.AppName)
+		}
+	}
+}
+
+func TestServerImpl_ScrapePodMetrics_Clusters(t *testing.T) {
+	localServer, _ := newPodsApiServer([]corev1.Pod{testPod("default", "web-1", KindReplicaSet, "web-abc", nil)})
+	defer localServer.Close()
+	authorization := ""
+	// kubeconfig中的凭据只通过TLS连接发送
+	plainServer, eastMux := newPodsApiServer([]corev1.Pod{testPod("default", "web-1", KindReplicaSet, "web-abc", nil)})
+	plainServer.Close()
+	eastServer := httptest.NewTLSServer(eastMux)
+	defer eastServer.Close()
+	eastMux.HandleFunc(ReplicaSetListPath, func(writer http.ResponseWriter, request *http.Request) {
+		authorization = request.Header.Get("Authorization")
+		http.NotFound(writer, request)
+	})
+	westServer := httptest.NewServer(http.NotFoundHandler())
+	westServer.Close()
+
+	kubeconfig, err := ioutil.TempFile("", "kubeconfig")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.Remove(kubeconfig.Name())
+	}()
+	_, err = fmt.Fprintf(kubeconfig, `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster: {server: %q, insecure-skip-tls-verify: true}
+- name: west
+  cluster: {server: %q}
+users:
+- name: admin
+  user: {token: secret}
+contexts:
+- name: admin@east
+  context: {cluster: east, user: admin}
+- name: admin@west
+  context: {cluster: west, user: admin}
+`, eastServer.URL, westServer.URL)
+	assert.NoError(t, err)
+	assert.NoError(t, kubeconfig.Close())
+
+	contexts, err := parseKubeContexts([]string{"east=admin@east", "west=admin@west"}, "")
+	assert.NoError(t, err)
+	remoteClusters, err := newKubeconfigClusters(kubeconfig.Name(), contexts)
+	assert.NoError(t, err)
+	s := &serverImpl{logger: log.New(os.Stdout, "", 0), apiServerUrl: localServer.URL, remoteClusters: remoteClusters}
+
+	// 无法访问的集群被跳过，不影响其他集群
+	result, err := s.scrapePodMetrics(context.Background())
+	assert.NoError(t, err)
+	apps := make([]server.AppName, 0)
+	for _, m := range result {
+		apps = append(apps, m.AppName)
+	}
+	assert.ElementsMatch(t, []server.AppName{
+		{Name: "web-abc", Namespace: "default"},
+		{Name: "web-abc", Namespace: "default", Cluster: "east"},
+	}, apps)
+	assert.Equal(t, "Bearer secret", authorization)
+
+	// 所有集群都无法访问时返回错误
+	localServer.Close()
+	eastServer.Close()
+	_, err = s.scrapePodMetrics(context.Background())
+	assert.Error(t, err)
+}
+
+func TestServerImpl_ScrapePodMetrics_FailedClusterExclusions(t *testing.T) {
+	pods := []corev1.Pod{
+		testPod("default", "web-1", KindReplicaSet, "web-abc", map[string]string{"tier": "web"}),
+		testPod("default", "batch-1", KindReplicaSet, "batch-abc", map[string]string{"tier": "batch"}),
+	}
+	localServer, _ := newPodsApiServer(pods)
+	defer localServer.Close()
+	eastServer, _ := newPodsApiServer(pods)
+	filter, err := newScrapeFilter(&ScrapeFilter{LabelSelector: "tier=web"})
+	assert.NoError(t, err)
+	s := &serverImpl{
+		logger:         log.New(os.Stdout, "", 0),
+		apiServerUrl:   localServer.URL,
+		remoteClusters: []*clusterSource{{name: "east", url: eastServer.URL, client: http.DefaultClient}},
+		scrapeFilter:   filter,
+	}
+	localBatch := server.AppName{Name: "batch-abc", Namespace: "default"}
+	eastBatch := server.AppName{Name: "batch-abc", Namespace: "default", Cluster: "east"}
+
+	_, err = s.scrapePodMetrics(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, filter.appExcluded(localBatch))
+	assert.True(t, filter.appExcluded(eastBatch))
+
+	// 无法访问的集群保留上次获取时被排除的应用
+	eastServer.Close()
+	_, err = s.scrapePodMetrics(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, filter.appExcluded(localBatch))
+	assert.True(t, filter.appExcluded(eastBatch))
+	assert.False(t, filter.appExcluded(server.AppName{Name: "web-abc", Namespace: "default", Cluster: "east"}))
+}
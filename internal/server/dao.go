@@ -1,51 +1,107 @@
 package server
 
 import (
+	"context"
 	"crypto/md5"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/packagewjx/workload-classifier/pkg/core"
 	"github.com/packagewjx/workload-classifier/pkg/server"
 	"github.com/pkg/errors"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 	"log"
 	"os"
+	"sort"
+	"time"
 )
 
+// 修改类别中心与应用分类的方法会在同一事务中追加分类变化记录，供watch使用
 type UpdateDao interface {
 	SaveClassMetrics(c *server.ClassMetrics) error
 	SaveAppClass(a *server.AppClass) error
+	// 删除应用的分类，应用没有分类时不做任何操作
+	RemoveAppClass(appName *server.AppName) error
 	SaveAllAppPodMetrics(arr []*server.AppPodMetrics) error
 
-	// 永久删除timestamp之前的数据
+	// 永久删除timestamp之前的数据，以及timestamp所在的日期之前的汇总数据
 	RemoveAppPodMetricsBefore(timestamp uint64) error
+	// 为有监控数据但没有汇总数据的应用重新生成汇总数据，返回处理的应用数量
+	BackfillSectionRollups() (int, error)
 	// 删除所有存在的ClassMetrics
 	RemoveAllClassMetrics() error
+	// 保存手动固定的分类，替换应用已有的固定
+	SaveAppPin(pin *server.AppPin) error
+	// 取消固定，应用没有被固定时返回ErrAppNotPinned
+	RemoveAppPin(appName *server.AppName) error
+	SaveAppPinAudit(audit *server.AppPinAudit) error
+	// 追加应用分类的变化历史
+	SaveAppClassHistory(history []*server.AppClassHistory) error
+	// 删除before之前的分类变化记录。最新的一条总是保留，使最新的ResourceVersion不会倒退
+	RemoveClassificationEventsBefore(before time.Time) error
 }
 
 type QueryDao interface {
 	QueryClassMetricsByClassId(classId uint) (*server.ClassMetrics, error)
 	QueryAllClassMetrics() ([]*server.ClassMetrics, error)
 	QueryAppClassByApp(appName *server.AppName) (*server.AppClass, error)
+	// 查询各类别包含的应用数量，键为ClassID
+	QueryClassMemberCount() (map[uint]int, error)
+	// 查询最近一次保存类别数据的时间，没有类别数据时返回零值
+	QueryLastClassifyTime() (time.Time, error)
+	// 依次对每个应用调用fc，sections为合并了fromDay及之后各天汇总的各Section数据，长度为core.NumSections，
+	// 没有数据的Section为nil
+	ForEachAppSectionRollup(fromDay uint64, fc func(appName server.AppName, sections []*sectionRollup) error) error
+	// 查询单个应用合并了fromDay及之后各天汇总的各Section数据，格式与ForEachAppSectionRollup相同。应用不存在时返回ErrAppNotFound
+	QueryAppSectionRollup(appName *server.AppName, fromDay uint64) ([]*sectionRollup, error)
+	// 按写入顺序分页读取监控数据。cursor为上一页返回的游标，首页为0。返回至多limit条数据与下一页的游标，
+	// 没有更多数据时返回空数组
+	QueryAppPodMetricsPage(cursor uint, limit int) ([]*server.AppPodMetrics, uint, error)
+	// 依次对每个已分类的应用调用fc，顺序不确定
+	ForEachAppClass(fc func(class *server.AppClass) error) error
+	// 应用不存在或没有被固定时返回ErrAppNotPinned
+	QueryAppPin(appName *server.AppName) (*server.AppPin, error)
+	// 返回所有固定的分类，按应用的名称空间与名称排序
+	QueryAllAppPins() ([]*server.AppPin, error)
+	// 返回应用的所有审计记录，按时间先后排序
+	QueryAppPinAudits(appName *server.AppName) ([]*server.AppPinAudit, error)
+	// 返回应用分类的变化历史，按时间先后排序
+	QueryAppClassHistory(appName *server.AppName) ([]*server.AppClassHistory, error)
+	// 按ResourceVersion递增的顺序返回afterVersion之后的至多limit条分类变化
+	QueryClassificationEvents(afterVersion uint64, limit int) ([]*server.ClassificationEvent, error)
+	// 返回保留的分类变化记录中最早与最新的ResourceVersion，没有记录时均为0
+	QueryClassificationVersions() (oldest, latest uint64, err error)
 }
 
 type Dao interface {
-	DB() *gorm.DB
+	// 返回使用ctx执行数据库操作的Dao。ctx结束后，未完成的操作将会中止
+	WithContext(ctx context.Context) Dao
+	// 在事务中执行fc，fc返回错误时回滚
+	Transaction(fc func(tx Dao) error) error
+	// 检查数据库连接是否可用
+	Ping() error
+	// 关闭数据库连接
+	Close() error
 	UpdateDao
 	QueryDao
 }
 
 type daoImpl struct {
-	db       *gorm.DB
-	appIdMap map[string]uint
-	keyFunc  func(appName *server.AppName) string
-	logger   *log.Logger
+	db     *gorm.DB
+	appIds *appIdCache
+	// 事务中查询或创建的AppID，在事务提交后才加入appIds，避免缓存被回滚的记录。不在事务中时为nil
+	pendingAppIds map[string]uint
+	keyFunc       func(appName *server.AppName) string
+	logger        *log.Logger
 }
 
 var _ Dao = &daoImpl{}
 
-func NewDao(host string) (Dao, error) {
+func openDatabase(host string) (*gorm.DB, error) {
 	databaseURL := fmt.Sprintf("root:wujunxian@tcp(%s)/metrics?charset=utf8mb4&parseTime=True&loc=Local",
 		host)
 	db, err := gorm.Open(mysql.Open(databaseURL), &gorm.Config{
@@ -56,38 +112,50 @@ func NewDao(host string) (Dao, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "连接数据库错误")
 	}
+	return db, nil
+}
 
-	// 转换为单一字符串的函数
-	keyFunc := func(appName *server.AppName) string {
-		sum := md5.Sum([]byte(appName.Name + appName.Namespace))
-		return string(sum[:])
+func NewDao(host string) (Dao, error) {
+	db, err := openDatabase(host)
+	if err != nil {
+		return nil, err
 	}
 
-	// 创建表格等
-	err = db.AutoMigrate(&AppPodMetricsDO{}, &AppClassDO{}, &ClassSectionMetricsDO{}, &AppDo{})
+	keyFunc := appNameKey
+
+	// 升级数据库结构。数据库结构比本程序支持的更新时拒绝启动
+	err = newSchemaMigrator(db).Up()
 	if err != nil {
-		return nil, errors.Wrap(err, "创建表格时出现异常")
+		return nil, errors.Wrap(err, "迁移数据库结构时出现异常")
 	}
 
-	// 读取AppID
-	appIdMap := make(map[string]uint)
+	// 读取最近创建的应用的AppID，预热缓存
+	appIds := newAppIdCache(DefaultAppIdCacheSize)
 	appRecords := make([]*AppDo, 0)
-	err = db.Find(&appRecords).Error
+	err = db.Order("id desc").Limit(DefaultAppIdCacheSize).Find(&appRecords).Error
 	if err != nil {
 		return nil, errors.Wrap(err, "读取应用记录时出错")
 	}
-	for _, record := range appRecords {
-		appIdMap[keyFunc(&record.AppName)] = record.ID
+	// 倒序加入，使最近创建的应用最后被淘汰
+	for i := len(appRecords) - 1; i >= 0; i-- {
+		appIds.add(keyFunc(&appRecords[i].AppName), appRecords[i].ID)
 	}
 
 	return &daoImpl{
-		db:       db,
-		appIdMap: make(map[string]uint),
-		keyFunc:  keyFunc,
-		logger:   log.New(os.Stdout, "Dao: ", log.LstdFlags|log.Lshortfile|log.Lmsgprefix),
+		db:      db,
+		appIds:  appIds,
+		keyFunc: keyFunc,
+		logger:  log.New(os.Stdout, "Dao: ", log.LstdFlags|log.Lshortfile|log.Lmsgprefix),
 	}, nil
 }
 
+// 将应用名称转换为单一字符串。各字段带长度前缀，避免不同集群或命名空间的字段拼接后相同
+func appNameKey(appName *server.AppName) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%d:%s%d:%s%d:%s", len(appName.Cluster), appName.Cluster,
+		len(appName.Namespace), appName.Namespace, len(appName.Name), appName.Name)))
+	return string(sum[:])
+}
+
 func (d *daoImpl) SaveClassMetrics(c *server.ClassMetrics) error {
 	if c.ClassId == 0 {
 		return fmt.Errorf("ClassId不能为0")
@@ -104,7 +172,12 @@ func (d *daoImpl) SaveClassMetrics(c *server.ClassMetrics) error {
 
 	d.logger.Printf("正在插入ClassID为%d的ClassMetrics", c.ClassId)
 
-	return d.db.Save(doarr).Error
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(doarr).Error; err != nil {
+			return err
+		}
+		return saveClassificationEvent(tx, server.EventTypeModified, server.EventKindClassMetrics, c)
+	})
 }
 
 func (d *daoImpl) SaveAppClass(a *server.AppClass) error {
@@ -122,8 +195,18 @@ func (d *daoImpl) SaveAppClass(a *server.AppClass) error {
 	dest.ClassId = a.ClassId
 	dest.MemMax = a.MemMax
 	dest.CpuMax = a.CpuMax
+	dest.Distance = a.Distance
+	dest.RunnerUpClassId = a.RunnerUpClassId
+	dest.RunnerUpDistance = a.RunnerUpDistance
+	dest.Confidence = a.Confidence
+	dest.Provisional = a.Provisional
 
-	err = d.db.Save(dest).Error
+	err = d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(dest).Error; err != nil {
+			return err
+		}
+		return saveClassificationEvent(tx, server.EventTypeModified, server.EventKindAppAssignment, server.NewAppAssignment(a))
+	})
 
 	if err != nil {
 		return errors.Wrap(err, "保存AppClassDO出错，AppID为%d，ClassID为%d")
@@ -132,64 +215,324 @@ func (d *daoImpl) SaveAppClass(a *server.AppClass) error {
 	return nil
 }
 
+func (d *daoImpl) RemoveAppClass(appName *server.AppName) error {
+	appId, err := d.queryAppId(appName, false)
+	if err == server.ErrAppNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		// AppId有唯一索引，软删除后无法再次保存分类
+		result := tx.Unscoped().Where("app_id = ?", appId).Delete(&AppClassDO{})
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "删除应用分类出错")
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return saveClassificationEvent(tx, server.EventTypeDeleted, server.EventKindAppAssignment, &server.AppAssignment{AppName: *appName})
+	})
+}
+
+// 每条语句最多写入的记录数，避免超过数据库对单条语句占位符数量的限制
+const appPodMetricsBatchSize = 1000
+
+// 监控数据写入事务因死锁被回滚时的最大重试次数
+const saveDeadlockRetries = 3
+
+const (
+	mysqlErrDuplicateEntry = 1062
+	mysqlErrDeadlock       = 1213
+)
+
+func isMySQLError(err error, number uint16) bool {
+	var mysqlErr *mysqldriver.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == number
+}
+
+// 使用数据库原生的upsert批量写入，已存在的记录将更新CPU与内存数据
 func (d *daoImpl) SaveAllAppPodMetrics(arr []*server.AppPodMetrics) error {
-	const MaxOneRun = 5000
+	if len(arr) == 0 {
+		return nil
+	}
 
-	newDo := make([]*AppPodMetricsDO, 0, len(arr))
-	oldDo := make([]*AppPodMetricsDO, 0, len(arr))
+	appNames := make([]*server.AppName, len(arr))
+	for i, metrics := range arr {
+		appNames[i] = &metrics.AppName
+	}
+	appIds, err := d.queryAppIds(appNames)
+	if err != nil {
+		return err
+	}
+
+	// 同一批数据中应用与时间戳相同的数据只保留最后一条，部分数据库不允许一条语句多次更新同一行
+	type recordKey struct {
+		appId     uint
+		timestamp uint64
+	}
+	index := make(map[recordKey]int, len(arr))
+	records := make([]*AppPodMetricsDO, 0, len(arr))
 	for _, metrics := range arr {
-		id, err := d.queryAppId(&metrics.AppName, true)
+		do := &AppPodMetricsDO{
+			AppId:     appIds[d.keyFunc(&metrics.AppName)],
+			Timestamp: metrics.Timestamp,
+			Cpu:       metrics.Cpu,
+			Mem:       metrics.Mem,
+		}
+		key := recordKey{appId: do.AppId, timestamp: do.Timestamp}
+		if i, ok := index[key]; ok {
+			records[i] = do
+		} else {
+			index[key] = len(records)
+			records = append(records, do)
+		}
+	}
+
+	d.logger.Printf("写入%d条AppPodMetrics到数据库", len(records))
+
+	upsert := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "app_id"}, {Name: "timestamp"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cpu", "mem", "updated_at", "deleted_at"}),
+	}
+	save := func() error {
+		return d.db.Transaction(func(tx *gorm.DB) error {
+			for i := 0; i < len(records); i += appPodMetricsBatchSize {
+				end := i + appPodMetricsBatchSize
+				if end > len(records) {
+					end = len(records)
+				}
+				err := tx.Clauses(upsert).Create(records[i:end]).Error
+				if err != nil {
+					return errors.Wrap(err, fmt.Sprintf("写入第%d到%d条AppPodMetrics出错", i, end))
+				}
+			}
+			return updateSectionRollups(tx, records)
+		})
+	}
+	// 获取监控数据与推送的数据可能同时写入同一Section的汇总数据，加锁读取时可能发生死锁，此时整个事务被回滚，可以重试。
+	// 在外层事务中时死锁会回滚外层事务，只能由调用者处理
+	for attempt := 0; ; attempt++ {
+		err = save()
+		if d.pendingAppIds != nil || attempt >= saveDeadlockRetries || !isMySQLError(err, mysqlErrDeadlock) {
+			return err
+		}
+		d.logger.Printf("写入AppPodMetrics时发生死锁，第%d次重试\n", attempt+1)
+	}
+}
+
+var rollupUpsert = clause.OnConflict{
+	Columns: []clause.Column{{Name: "app_id"}, {Name: "day"}, {Name: "section_num"}},
+	DoUpdates: clause.AssignmentColumns([]string{"count", "cpu_sum", "cpu_min", "cpu_max", "cpu_sketch",
+		"mem_sum", "mem_min", "mem_max", "mem_sketch", "last_timestamp", "updated_at"}),
+}
+
+// 将监控数据合并到对应的汇总数据中
+func updateSectionRollups(tx *gorm.DB, records []*AppPodMetricsDO) error {
+	keySet := make(map[rollupKey]struct{})
+	for _, record := range records {
+		keySet[rollupKeyOf(record.AppId, record.Timestamp)] = struct{}{}
+	}
+	keys := make([]rollupKey, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+
+	existing := make(map[rollupKey]*sectionRollup, len(keys))
+	for i := 0; i < len(keys); i += appPodMetricsBatchSize {
+		end := i + appPodMetricsBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		appIds := make(map[uint]struct{})
+		days := make(map[uint64]struct{})
+		sectionNums := make(map[uint]struct{})
+		for _, key := range keys[i:end] {
+			appIds[key.appId] = struct{}{}
+			days[key.day] = struct{}{}
+			sectionNums[key.sectionNum] = struct{}{}
+		}
+		// 加锁读取，避免同时写入同一Section的事务互相覆盖合并的结果
+		dos := make([]*AppSectionRollupDO, 0)
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("app_id IN ? AND day IN ? AND section_num IN ?",
+			uintSetToSlice(appIds), uint64SetToSlice(days), uintSetToSlice(sectionNums)).Find(&dos).Error
+		if err != nil {
+			return errors.Wrap(err, "查询汇总数据出错")
+		}
+		for _, do := range dos {
+			key := rollupKey{appId: do.AppId, day: do.Day, sectionNum: do.SectionNum}
+			if _, ok := keySet[key]; !ok {
+				continue
+			}
+			r, err := rollupFromDO(do)
+			if err != nil {
+				return errors.Wrap(err, fmt.Sprintf("AppID为%d，日期为%d，第%d个Section的汇总数据有误", do.AppId, do.Day, do.SectionNum))
+			}
+			existing[key] = r
+		}
+	}
+
+	changed, stale := rollupAppPodMetrics(existing, records)
+	if len(stale) > 0 {
+		raw, err := queryStaleSectionRecords(tx, stale)
 		if err != nil {
 			return err
 		}
+		rebuildSectionRollups(existing, changed, stale, raw)
+	}
+	return saveSectionRollups(tx, changed)
+}
 
-		do := &AppPodMetricsDO{}
-		err = d.db.First(do, &AppPodMetricsDO{
-			AppId:     id,
-			Timestamp: metrics.Timestamp,
-		}).Error
-
-		do.Mem = metrics.Mem
-		do.Cpu = metrics.Cpu
-		if err == nil {
-			oldDo = append(oldDo, do)
-		} else if err == gorm.ErrRecordNotFound {
-			do.Timestamp = metrics.Timestamp
-			do.AppId = id
-			newDo = append(newDo, do)
+// 查询需要重新汇总的Section中的原始数据，每个应用查询一次，结果可能包含其他Section的数据
+func queryStaleSectionRecords(tx *gorm.DB, stale map[rollupKey]struct{}) ([]*AppPodMetricsDO, error) {
+	type timeRange struct {
+		start, end uint64
+	}
+	ranges := make(map[uint]*timeRange)
+	for key := range stale {
+		start, end := key.timeRange()
+		if r, ok := ranges[key.appId]; !ok {
+			ranges[key.appId] = &timeRange{start: start, end: end}
 		} else {
-			return errors.Wrap(err, fmt.Sprintf("查询AppPodMetrics出错，名称为%s，名称空间为%s，时间戳%d",
-				metrics.Name, metrics.Namespace, metrics.Timestamp))
+			if start < r.start {
+				r.start = start
+			}
+			if end > r.end {
+				r.end = end
+			}
 		}
 	}
 
-	d.logger.Printf("插入%d条新的AppPodMetrics到数据库", len(newDo))
-
-	for i := 0; i < len(newDo); i += MaxOneRun {
-		end := i + MaxOneRun
-		if end > len(newDo) {
-			end = len(newDo)
+	result := make([]*AppPodMetricsDO, 0)
+	for appId, r := range ranges {
+		records := make([]*AppPodMetricsDO, 0)
+		err := tx.Where("app_id = ? AND timestamp >= ? AND timestamp < ?", appId, r.start, r.end).Find(&records).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "查询需要重新汇总的监控数据出错")
 		}
-		err := d.db.Create(newDo[i:end]).Error
+		result = append(result, records...)
+	}
+	return result, nil
+}
+
+func saveSectionRollups(tx *gorm.DB, rollups map[rollupKey]*sectionRollup) error {
+	dos := make([]*AppSectionRollupDO, 0, len(rollups))
+	for key, r := range rollups {
+		do, err := r.toDO(key)
 		if err != nil {
 			return err
 		}
+		dos = append(dos, do)
 	}
+	// 按主键顺序写入，减少并发写入时的死锁
+	sort.Slice(dos, func(i, j int) bool {
+		if dos[i].AppId != dos[j].AppId {
+			return dos[i].AppId < dos[j].AppId
+		}
+		if dos[i].Day != dos[j].Day {
+			return dos[i].Day < dos[j].Day
+		}
+		return dos[i].SectionNum < dos[j].SectionNum
+	})
 
-	d.logger.Printf("更新数据库%d条AppPodMetrcis", len(oldDo))
-	for _, do := range oldDo {
-		d.db.Updates(do)
+	for i := 0; i < len(dos); i += appPodMetricsBatchSize {
+		end := i + appPodMetricsBatchSize
+		if end > len(dos) {
+			end = len(dos)
+		}
+		err := tx.Clauses(rollupUpsert).Create(dos[i:end]).Error
+		if err != nil {
+			return errors.Wrap(err, "写入汇总数据出错")
+		}
 	}
-
 	return nil
 }
 
+func uintSetToSlice(set map[uint]struct{}) []uint {
+	result := make([]uint, 0, len(set))
+	for v := range set {
+		result = append(result, v)
+	}
+	return result
+}
+
+func uint64SetToSlice(set map[uint64]struct{}) []uint64 {
+	result := make([]uint64, 0, len(set))
+	for v := range set {
+		result = append(result, v)
+	}
+	return result
+}
+
 func (d *daoImpl) RemoveAppPodMetricsBefore(timestamp uint64) error {
-	return d.db.Model(&AppPodMetricsDO{}).Unscoped().Where("timestamp < ?", timestamp).Delete(&AppPodMetricsDO{}).Error
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&AppPodMetricsDO{}).Unscoped().Where("timestamp < ?", timestamp).Delete(&AppPodMetricsDO{}).Error
+		if err != nil {
+			return err
+		}
+		// 汇总数据以天为单位，只删除timestamp所在日期之前的数据
+		return tx.Where("day < ?", timestamp/core.DayLength).Delete(&AppSectionRollupDO{}).Error
+	})
+}
+
+// 每次回填汇总数据的应用数量
+const backfillBatchSize = 100
+
+func (d *daoImpl) BackfillSectionRollups() (int, error) {
+	appIds := make([]uint, 0)
+	err := d.db.Model(&AppPodMetricsDO{}).Distinct("app_id").
+		Where("app_id NOT IN (?)", d.db.Model(&AppSectionRollupDO{}).Distinct("app_id")).
+		Order("app_id").Pluck("app_id", &appIds).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "查询没有汇总数据的应用出错")
+	}
+	if len(appIds) == 0 {
+		return 0, nil
+	}
+
+	d.logger.Printf("正在为%d个应用回填汇总数据\n", len(appIds))
+	for i := 0; i < len(appIds); i += backfillBatchSize {
+		end := i + backfillBatchSize
+		if end > len(appIds) {
+			end = len(appIds)
+		}
+		err := d.db.Transaction(func(tx *gorm.DB) error {
+			records := make([]*AppPodMetricsDO, 0)
+			err := tx.Where("app_id IN ?", appIds[i:end]).Find(&records).Error
+			if err != nil {
+				return errors.Wrap(err, "读取监控数据出错")
+			}
+			rollups, _ := rollupAppPodMetrics(make(map[rollupKey]*sectionRollup), records)
+			return saveSectionRollups(tx, rollups)
+		})
+		if err != nil {
+			return i, errors.Wrap(err, "回填汇总数据出错")
+		}
+	}
+	return len(appIds), nil
 }
 
 func (d *daoImpl) RemoveAllClassMetrics() error {
-	return d.db.Model(&ClassSectionMetricsDO{}).Where("1 = 1").Delete(&ClassSectionMetricsDO{}).Error
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		// 每个类别都有第0个Section的数据
+		classIds := make([]uint, 0)
+		err := tx.Model(&ClassSectionMetricsDO{}).Where("section_num = ?", 0).Order("id asc").Pluck("id", &classIds).Error
+		if err != nil {
+			return errors.Wrap(err, "查询已有的类别出错")
+		}
+		err = tx.Model(&ClassSectionMetricsDO{}).Where("1 = 1").Delete(&ClassSectionMetricsDO{}).Error
+		if err != nil {
+			return err
+		}
+		for _, classId := range classIds {
+			err = saveClassificationEvent(tx, server.EventTypeDeleted, server.EventKindClassMetrics, &server.ClassMetrics{ClassId: classId})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (d *daoImpl) QueryClassMetricsByClassId(classId uint) (*server.ClassMetrics, error) {
@@ -200,6 +543,9 @@ func (d *daoImpl) QueryClassMetricsByClassId(classId uint) (*server.ClassMetrics
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("查询ClassSectionMetricsDO出错，classID为%d", classId))
 	}
+	if len(doarr) == 0 {
+		return nil, server.ErrClassNotFound
+	}
 
 	// 检查数据是否正常
 	for i := 0; i < len(doarr); i++ {
@@ -240,12 +586,21 @@ func (d *daoImpl) QueryAppClassByApp(appName *server.AppName) (*server.AppClass,
 		return nil, errors.Wrap(err, "查询AppClass时出错")
 	}
 
+	return appClassFromDO(*appName, record), nil
+}
+
+func appClassFromDO(appName server.AppName, record *AppClassDO) *server.AppClass {
 	return &server.AppClass{
-		AppName: *appName,
-		ClassId: record.ClassId,
-		CpuMax:  record.CpuMax,
-		MemMax:  record.MemMax,
-	}, nil
+		AppName:          appName,
+		ClassId:          record.ClassId,
+		CpuMax:           record.CpuMax,
+		MemMax:           record.MemMax,
+		Distance:         record.Distance,
+		RunnerUpClassId:  record.RunnerUpClassId,
+		RunnerUpDistance: record.RunnerUpDistance,
+		Confidence:       record.Confidence,
+		Provisional:      record.Provisional,
+	}
 }
 
 func (d *daoImpl) QueryAllClassMetrics() ([]*server.ClassMetrics, error) {
@@ -275,43 +630,693 @@ func (d *daoImpl) QueryAllClassMetrics() ([]*server.ClassMetrics, error) {
 	return result, nil
 }
 
+func (d *daoImpl) QueryClassMemberCount() (map[uint]int, error) {
+	rows := make([]*struct {
+		ClassId uint
+		Count   int
+	}, 0)
+	err := d.db.Model(&AppClassDO{}).Select("class_id, COUNT(*) AS count").Group("class_id").Scan(&rows).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "查询各类别应用数量出错")
+	}
+
+	result := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		result[row.ClassId] = row.Count
+	}
+	return result, nil
+}
+
+func (d *daoImpl) QueryLastClassifyTime() (time.Time, error) {
+	last := sql.NullTime{}
+	err := d.db.Model(&ClassSectionMetricsDO{}).Select("MAX(updated_at)").Row().Scan(&last)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "查询类别数据更新时间出错")
+	}
+	return last.Time, nil
+}
+
+func (d *daoImpl) QueryAppPodMetricsPage(cursor uint, limit int) ([]*server.AppPodMetrics, uint, error) {
+	records := make([]*AppPodMetricsDO, 0, limit)
+	err := d.db.Where("id > ?", cursor).Order("id ASC").Limit(limit).Find(&records).Error
+	if err != nil {
+		return nil, cursor, errors.Wrap(err, "读取数据库AppPodMetrics时出错")
+	}
+	if len(records) == 0 {
+		return []*server.AppPodMetrics{}, cursor, nil
+	}
+
+	idSet := make(map[uint]struct{})
+	for _, record := range records {
+		idSet[record.AppId] = struct{}{}
+	}
+	apps := make([]*AppDo, 0, len(idSet))
+	err = d.db.Where("id IN ?", uintSetToSlice(idSet)).Find(&apps).Error
+	if err != nil {
+		return nil, cursor, errors.Wrap(err, "查询AppName出错")
+	}
+	appNames := make(map[uint]server.AppName, len(apps))
+	for _, app := range apps {
+		appNames[app.ID] = app.AppName
+	}
+
+	result := make([]*server.AppPodMetrics, len(records))
+	for i, record := range records {
+		result[i] = &server.AppPodMetrics{
+			AppName:   appNames[record.AppId],
+			Timestamp: record.Timestamp,
+			Cpu:       record.Cpu,
+			Mem:       record.Mem,
+		}
+	}
+	return result, records[len(records)-1].ID, nil
+}
+
+// 每次读取分类数据的数量
+const appClassReadBatchSize = 1000
+
+func (d *daoImpl) ForEachAppClass(fc func(class *server.AppClass) error) error {
+	lastId := uint(0)
+	for {
+		records := make([]*AppClassDO, 0, appClassReadBatchSize)
+		err := d.db.Where("id > ?", lastId).Order("id ASC").Limit(appClassReadBatchSize).Find(&records).Error
+		if err != nil {
+			return errors.Wrap(err, "读取AppClass出错")
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		idSet := make(map[uint]struct{}, len(records))
+		for _, record := range records {
+			idSet[record.AppId] = struct{}{}
+		}
+		apps := make([]*AppDo, 0, len(idSet))
+		err = d.db.Where("id IN ?", uintSetToSlice(idSet)).Find(&apps).Error
+		if err != nil {
+			return errors.Wrap(err, "查询AppName出错")
+		}
+		appNames := make(map[uint]server.AppName, len(apps))
+		for _, app := range apps {
+			appNames[app.ID] = app.AppName
+		}
+
+		for _, record := range records {
+			appName, ok := appNames[record.AppId]
+			if !ok {
+				continue
+			}
+			if err := fc(appClassFromDO(appName, record)); err != nil {
+				return err
+			}
+		}
+		if len(records) < appClassReadBatchSize {
+			return nil
+		}
+		lastId = records[len(records)-1].ID
+	}
+}
+
+// 每次读取汇总数据的应用数量
+const rollupReadBatchSize = 100
+
+func (d *daoImpl) ForEachAppSectionRollup(fromDay uint64, fc func(appName server.AppName, sections []*sectionRollup) error) error {
+	appIds := make([]uint, 0)
+	err := d.db.Model(&AppSectionRollupDO{}).Distinct("app_id").Where("day >= ?", fromDay).
+		Order("app_id").Pluck("app_id", &appIds).Error
+	if err != nil {
+		return errors.Wrap(err, "查询有汇总数据的应用出错")
+	}
+
+	for i := 0; i < len(appIds); i += rollupReadBatchSize {
+		end := i + rollupReadBatchSize
+		if end > len(appIds) {
+			end = len(appIds)
+		}
+		batch := appIds[i:end]
+
+		apps := make([]*AppDo, 0, len(batch))
+		err := d.db.Where("id IN ?", batch).Find(&apps).Error
+		if err != nil {
+			return errors.Wrap(err, "查询AppName出错")
+		}
+		appNames := make(map[uint]server.AppName, len(apps))
+		for _, app := range apps {
+			appNames[app.ID] = app.AppName
+		}
+
+		dos := make([]*AppSectionRollupDO, 0)
+		err = d.db.Where("app_id IN ? AND day >= ?", batch, fromDay).Find(&dos).Error
+		if err != nil {
+			return errors.Wrap(err, "读取汇总数据出错")
+		}
+		sections, err := mergeRollupDOs(dos)
+		if err != nil {
+			return err
+		}
+
+		for _, appId := range batch {
+			appName, ok := appNames[appId]
+			if !ok || sections[appId] == nil {
+				// 应用记录已被删除，或者读取期间汇总数据已被删除
+				continue
+			}
+			if err := fc(appName, sections[appId]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *daoImpl) QueryAppSectionRollup(appName *server.AppName, fromDay uint64) ([]*sectionRollup, error) {
+	appId, err := d.queryAppId(appName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	dos := make([]*AppSectionRollupDO, 0)
+	err = d.db.Where("app_id = ? AND day >= ?", appId, fromDay).Find(&dos).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "读取汇总数据出错")
+	}
+	sections, err := mergeRollupDOs(dos)
+	if err != nil {
+		return nil, err
+	}
+	if sections[appId] == nil {
+		return make([]*sectionRollup, core.NumSections), nil
+	}
+	return sections[appId], nil
+}
+
+// 按应用合并各天的汇总数据，返回的map以AppID为键，值的长度为core.NumSections
+func mergeRollupDOs(dos []*AppSectionRollupDO) (map[uint][]*sectionRollup, error) {
+	sections := make(map[uint][]*sectionRollup)
+	for _, do := range dos {
+		r, err := rollupFromDO(do)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("AppID为%d，日期为%d，第%d个Section的汇总数据有误", do.AppId, do.Day, do.SectionNum))
+		}
+		arr, ok := sections[do.AppId]
+		if !ok {
+			arr = make([]*sectionRollup, core.NumSections)
+			sections[do.AppId] = arr
+		}
+		if arr[do.SectionNum] == nil {
+			arr[do.SectionNum] = r
+		} else {
+			arr[do.SectionNum].merge(r)
+		}
+	}
+	return sections, nil
+}
+
+var appPinUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "app_id"}},
+	DoUpdates: clause.AssignmentColumns([]string{"class_id", "profile", "reason", "created_by", "created_at"}),
+}
+
+func (d *daoImpl) SaveAppPin(pin *server.AppPin) error {
+	appId, err := d.queryAppId(&pin.AppName, true)
+	if err != nil {
+		return err
+	}
+	do := &AppPinDO{
+		AppId:     appId,
+		ClassId:   pin.ClassId,
+		Reason:    pin.Reason,
+		CreatedBy: pin.CreatedBy,
+		CreatedAt: pin.CreatedAt,
+	}
+	if pin.Profile != nil {
+		if do.Profile, err = json.Marshal(pin.Profile); err != nil {
+			return errors.Wrap(err, "序列化自定义运行特征出错")
+		}
+	}
+	err = d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(appPinUpsert).Create(do).Error; err != nil {
+			return err
+		}
+		return saveClassificationEvent(tx, server.EventTypeModified, server.EventKindAppPin, pin)
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("保存名称空间%s，名称为%s的应用的固定分类出错", pin.Namespace, pin.Name))
+	}
+	return nil
+}
+
+func (d *daoImpl) RemoveAppPin(appName *server.AppName) error {
+	appId, err := d.queryAppId(appName, false)
+	if err == server.ErrAppNotFound {
+		return server.ErrAppNotPinned
+	} else if err != nil {
+		return err
+	}
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("app_id = ?", appId).Delete(&AppPinDO{})
+		if result.Error != nil {
+			return errors.Wrap(result.Error, "删除固定分类出错")
+		}
+		if result.RowsAffected == 0 {
+			return server.ErrAppNotPinned
+		}
+		return saveClassificationEvent(tx, server.EventTypeDeleted, server.EventKindAppPin, &server.AppPin{AppName: *appName})
+	})
+}
+
+func (d *daoImpl) SaveAppPinAudit(audit *server.AppPinAudit) error {
+	err := d.db.Create(&AppPinAuditDO{
+		Name:      audit.Name,
+		Namespace: audit.Namespace,
+		Cluster:   audit.Cluster,
+		Action:    audit.Action,
+		ClassId:   audit.ClassId,
+		Custom:    audit.Custom,
+		Reason:    audit.Reason,
+		Actor:     audit.Actor,
+		CreatedAt: audit.Time,
+	}).Error
+	if err != nil {
+		return errors.Wrap(err, "保存固定分类审计记录出错")
+	}
+	return nil
+}
+
+func (d *daoImpl) QueryAppPin(appName *server.AppName) (*server.AppPin, error) {
+	appId, err := d.queryAppId(appName, false)
+	if err == server.ErrAppNotFound {
+		return nil, server.ErrAppNotPinned
+	} else if err != nil {
+		return nil, err
+	}
+	do := &AppPinDO{}
+	err = d.db.Where("app_id = ?", appId).First(do).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, server.ErrAppNotPinned
+	} else if err != nil {
+		return nil, errors.Wrap(err, "查询固定分类出错")
+	}
+	return appPinFromDO(*appName, do)
+}
+
+func (d *daoImpl) QueryAllAppPins() ([]*server.AppPin, error) {
+	dos := make([]*AppPinDO, 0)
+	if err := d.db.Find(&dos).Error; err != nil {
+		return nil, errors.Wrap(err, "查询固定分类出错")
+	}
+	if len(dos) == 0 {
+		return []*server.AppPin{}, nil
+	}
+	idSet := make(map[uint]struct{}, len(dos))
+	for _, do := range dos {
+		idSet[do.AppId] = struct{}{}
+	}
+	apps := make([]*AppDo, 0, len(idSet))
+	if err := d.db.Where("id IN ?", uintSetToSlice(idSet)).Find(&apps).Error; err != nil {
+		return nil, errors.Wrap(err, "查询AppName出错")
+	}
+	appNames := make(map[uint]server.AppName, len(apps))
+	for _, app := range apps {
+		appNames[app.ID] = app.AppName
+	}
+
+	result := make([]*server.AppPin, 0, len(dos))
+	for _, do := range dos {
+		appName, ok := appNames[do.AppId]
+		if !ok {
+			continue
+		}
+		pin, err := appPinFromDO(appName, do)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, pin)
+	}
+	sortAppPins(result)
+	return result, nil
+}
+
+func (d *daoImpl) QueryAppPinAudits(appName *server.AppName) ([]*server.AppPinAudit, error) {
+	dos := make([]*AppPinAuditDO, 0)
+	err := d.db.Where("cluster = ? AND namespace = ? AND name = ?", appName.Cluster, appName.Namespace, appName.Name).
+		Order("id").Find(&dos).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "查询固定分类审计记录出错")
+	}
+	result := make([]*server.AppPinAudit, len(dos))
+	for i, do := range dos {
+		result[i] = &server.AppPinAudit{
+			AppName: server.AppName{Name: do.Name, Namespace: do.Namespace, Cluster: do.Cluster},
+			Action:  do.Action,
+			ClassId: do.ClassId,
+			Custom:  do.Custom,
+			Reason:  do.Reason,
+			Actor:   do.Actor,
+			Time:    do.CreatedAt,
+		}
+	}
+	return result, nil
+}
+
+func (d *daoImpl) SaveAppClassHistory(history []*server.AppClassHistory) error {
+	if len(history) == 0 {
+		return nil
+	}
+	dos := make([]*AppClassHistoryDO, len(history))
+	for i, h := range history {
+		dos[i] = &AppClassHistoryDO{
+			Name:            h.Name,
+			Namespace:       h.Namespace,
+			Cluster:         h.Cluster,
+			ClassId:         h.ClassId,
+			PreviousClassId: h.PreviousClassId,
+			Distance:        h.Distance,
+			Confidence:      h.Confidence,
+			Provisional:     h.Provisional,
+			CreatedAt:       h.Time,
+		}
+	}
+	if err := d.db.Create(&dos).Error; err != nil {
+		return errors.Wrap(err, "保存应用分类历史出错")
+	}
+	return nil
+}
+
+func (d *daoImpl) QueryAppClassHistory(appName *server.AppName) ([]*server.AppClassHistory, error) {
+	dos := make([]*AppClassHistoryDO, 0)
+	err := d.db.Where("cluster = ? AND namespace = ? AND name = ?", appName.Cluster, appName.Namespace, appName.Name).
+		Order("id").Find(&dos).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "查询应用分类历史出错")
+	}
+	result := make([]*server.AppClassHistory, len(dos))
+	for i, do := range dos {
+		result[i] = &server.AppClassHistory{
+			AppName:         server.AppName{Name: do.Name, Namespace: do.Namespace, Cluster: do.Cluster},
+			ClassId:         do.ClassId,
+			PreviousClassId: do.PreviousClassId,
+			Distance:        do.Distance,
+			Confidence:      do.Confidence,
+			Provisional:     do.Provisional,
+			Time:            do.CreatedAt,
+		}
+	}
+	return result, nil
+}
+
+// 追加一条分类变化记录，ResourceVersion由自增ID分配。
+// 固定分类可以由任意副本修改，并发提交的事务可能使较小的ID较晚可见，导致watch跳过该记录，
+// 因此先锁定最新的记录，使写入分类变化的事务依次提交
+func saveClassificationEvent(tx *gorm.DB, typ, kind string, object interface{}) error {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return errors.Wrap(err, "序列化分类变化出错")
+	}
+	latest := make([]*ClassificationEventDO, 0, 1)
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Order("id desc").Limit(1).Find(&latest).Error
+	if err != nil {
+		return errors.Wrap(err, "锁定分类变化记录出错")
+	}
+	err = tx.Create(&ClassificationEventDO{Type: typ, Kind: kind, Object: data}).Error
+	if err != nil {
+		return errors.Wrap(err, "保存分类变化记录出错")
+	}
+	return nil
+}
+
+func classificationEventFromDO(do *ClassificationEventDO) (*server.ClassificationEvent, error) {
+	event := &server.ClassificationEvent{
+		Type:            do.Type,
+		Kind:            do.Kind,
+		ResourceVersion: do.ID,
+	}
+	var err error
+	switch do.Kind {
+	case server.EventKindClassMetrics:
+		event.ClassMetrics = &server.ClassMetrics{}
+		err = json.Unmarshal(do.Object, event.ClassMetrics)
+	case server.EventKindAppAssignment:
+		event.AppAssignment = &server.AppAssignment{}
+		err = json.Unmarshal(do.Object, event.AppAssignment)
+	case server.EventKindAppPin:
+		event.AppPin = &server.AppPin{}
+		err = json.Unmarshal(do.Object, event.AppPin)
+	default:
+		err = fmt.Errorf("未知的对象种类%s", do.Kind)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("解析ResourceVersion为%d的分类变化出错", do.ID))
+	}
+	return event, nil
+}
+
+func (d *daoImpl) QueryClassificationEvents(afterVersion uint64, limit int) ([]*server.ClassificationEvent, error) {
+	dos := make([]*ClassificationEventDO, 0)
+	err := d.db.Where("id > ?", afterVersion).Order("id asc").Limit(limit).Find(&dos).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "查询分类变化记录出错")
+	}
+	result := make([]*server.ClassificationEvent, len(dos))
+	for i, do := range dos {
+		result[i], err = classificationEventFromDO(do)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (d *daoImpl) QueryClassificationVersions() (uint64, uint64, error) {
+	versions := &struct {
+		Oldest uint64
+		Latest uint64
+	}{}
+	err := d.db.Model(&ClassificationEventDO{}).
+		Select("COALESCE(MIN(id), 0) AS oldest, COALESCE(MAX(id), 0) AS latest").Scan(versions).Error
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "查询分类变化记录的版本出错")
+	}
+	return versions.Oldest, versions.Latest, nil
+}
+
+func (d *daoImpl) RemoveClassificationEventsBefore(before time.Time) error {
+	_, latest, err := d.QueryClassificationVersions()
+	if err != nil {
+		return err
+	}
+	err = d.db.Where("created_at < ? AND id < ?", before, latest).Delete(&ClassificationEventDO{}).Error
+	if err != nil {
+		return errors.Wrap(err, "删除过期的分类变化记录出错")
+	}
+	return nil
+}
+
+func appPinFromDO(appName server.AppName, do *AppPinDO) (*server.AppPin, error) {
+	pin := &server.AppPin{
+		AppName:   appName,
+		ClassId:   do.ClassId,
+		Reason:    do.Reason,
+		CreatedBy: do.CreatedBy,
+		CreatedAt: do.CreatedAt,
+	}
+	if len(do.Profile) > 0 {
+		if err := json.Unmarshal(do.Profile, &pin.Profile); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("名称空间%s，名称为%s的应用的自定义运行特征有误", appName.Namespace, appName.Name))
+		}
+	}
+	return pin, nil
+}
+
+func sortAppPins(pins []*server.AppPin) {
+	sort.Slice(pins, func(i, j int) bool {
+		return pins[i].AppName.Less(pins[j].AppName)
+	})
+}
+
 // 根据AppName和namespace查询AppID，若不存在，则创建一条记录。
 func (d *daoImpl) queryAppId(appName *server.AppName, createIfNil bool) (uint, error) {
 	key := d.keyFunc(appName)
-	id, ok := d.appIdMap[key]
+	id, ok := d.cachedAppId(key)
 	if ok {
 		return id, nil
 	}
 
 	d.logger.Printf("缓存中没有找到名称为%s，命名空间为%s的ID记录，将从数据库中获取\n", appName.Name, appName.Namespace)
 
+	// 结构体条件会忽略零值，集群为空时也需要作为条件
 	app := &AppDo{}
-	err := d.db.First(app, &AppDo{
-		AppName: server.AppName{
-			Name:      appName.Name,
-			Namespace: appName.Namespace,
-		},
-	}).Error
+	err := d.db.Where("cluster = ? AND namespace = ? AND name = ?", appName.Cluster, appName.Namespace, appName.Name).
+		First(app).Error
 	if err == gorm.ErrRecordNotFound {
 		if !createIfNil {
 			d.logger.Printf("数据库中不存在名称为%s，命名空间为%s的ID记录\n", appName.Name, appName.Namespace)
 			return 0, server.ErrAppNotFound
 		}
 		d.logger.Printf("数据库中不存在名称为%s，命名空间为%s的ID记录，将创建\n", appName.Name, appName.Namespace)
-		app.Name = appName.Name
-		app.Namespace = appName.Namespace
+		app.AppName = *appName
 		err = d.db.Create(app).Error
+		if isMySQLError(err, mysqlErrDuplicateEntry) {
+			// 其他请求或副本同时创建了该应用
+			app = &AppDo{}
+			err = d.db.Where("cluster = ? AND namespace = ? AND name = ?", appName.Cluster, appName.Namespace, appName.Name).
+				First(app).Error
+		}
 	}
 
 	if err != nil {
 		return 0, errors.Wrap(err, fmt.Sprintf("从数据库中查询或创建App记录出错。名称为%s，命名空间为%s", appName.Name, appName.Namespace))
 	}
 
-	d.appIdMap[key] = app.ID
+	d.cacheAppId(key, app.ID)
 
 	return app.ID, nil
 }
 
-func (d *daoImpl) DB() *gorm.DB {
-	return d.db
+func (d *daoImpl) cachedAppId(key string) (uint, bool) {
+	if id, ok := d.pendingAppIds[key]; ok {
+		return id, true
+	}
+	return d.appIds.get(key)
+}
+
+func (d *daoImpl) cacheAppId(key string, id uint) {
+	if d.pendingAppIds != nil {
+		d.pendingAppIds[key] = id
+	} else {
+		d.appIds.add(key, id)
+	}
+}
+
+// 批量查询AppID，不存在的应用将被创建。返回的map以keyFunc的结果为键
+func (d *daoImpl) queryAppIds(appNames []*server.AppName) (map[string]uint, error) {
+	result := make(map[string]uint, len(appNames))
+	missing := make(map[string]*server.AppName)
+	for _, appName := range appNames {
+		key := d.keyFunc(appName)
+		if id, ok := d.cachedAppId(key); ok {
+			result[key] = id
+		} else {
+			missing[key] = appName
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	d.logger.Printf("缓存中没有%d个应用的ID记录，将从数据库中获取\n", len(missing))
+	missingNames := make([]*server.AppName, 0, len(missing))
+	for _, appName := range missing {
+		missingNames = append(missingNames, appName)
+	}
+	for i := 0; i < len(missingNames); i += appPodMetricsBatchSize {
+		end := i + appPodMetricsBatchSize
+		if end > len(missingNames) {
+			end = len(missingNames)
+		}
+		names := make([]string, 0, end-i)
+		namespaces := make([]string, 0, end-i)
+		clusters := make([]string, 0, end-i)
+		for _, appName := range missingNames[i:end] {
+			names = append(names, appName.Name)
+			namespaces = append(namespaces, appName.Namespace)
+			clusters = append(clusters, appName.Cluster)
+		}
+		records := make([]*AppDo, 0)
+		err := d.db.Where("name IN ? AND namespace IN ? AND cluster IN ?", names, namespaces, clusters).Find(&records).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "批量查询App记录出错")
+		}
+		for _, record := range records {
+			key := d.keyFunc(&record.AppName)
+			if _, ok := missing[key]; ok {
+				result[key] = record.ID
+				d.cacheAppId(key, record.ID)
+				delete(missing, key)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	d.logger.Printf("数据库中不存在%d个应用的ID记录，将创建\n", len(missing))
+	newApps := make([]*AppDo, 0, len(missing))
+	for _, appName := range missing {
+		newApps = append(newApps, &AppDo{AppName: *appName})
+	}
+	for i := 0; i < len(newApps); i += appPodMetricsBatchSize {
+		end := i + appPodMetricsBatchSize
+		if end > len(newApps) {
+			end = len(newApps)
+		}
+		err := d.db.Create(newApps[i:end]).Error
+		if isMySQLError(err, mysqlErrDuplicateEntry) {
+			// 其他请求或副本同时创建了其中的应用，逐个查询或创建
+			for _, app := range newApps[i:end] {
+				id, err := d.queryAppId(&app.AppName, true)
+				if err != nil {
+					return nil, err
+				}
+				app.ID = id
+			}
+		} else if err != nil {
+			return nil, errors.Wrap(err, "批量创建App记录出错")
+		}
+	}
+	for _, app := range newApps {
+		key := d.keyFunc(&app.AppName)
+		result[key] = app.ID
+		d.cacheAppId(key, app.ID)
+	}
+
+	return result, nil
+}
+
+func (d *daoImpl) WithContext(ctx context.Context) Dao {
+	return &daoImpl{
+		db:            d.db.WithContext(ctx),
+		appIds:        d.appIds,
+		pendingAppIds: d.pendingAppIds,
+		keyFunc:       d.keyFunc,
+		logger:        d.logger,
+	}
+}
+
+func (d *daoImpl) Transaction(fc func(tx Dao) error) error {
+	pending := make(map[string]uint)
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		return fc(&daoImpl{
+			db:            tx,
+			appIds:        d.appIds,
+			pendingAppIds: pending,
+			keyFunc:       d.keyFunc,
+			logger:        d.logger,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// 事务已提交，事务中的AppID可以加入外层的缓存
+	for key, id := range pending {
+		d.cacheAppId(key, id)
+	}
+	return nil
+}
+
+func (d *daoImpl) Ping() error {
+	db, err := d.db.DB()
+	if err != nil {
+		return errors.Wrap(err, "获取数据库连接出错")
+	}
+	return db.PingContext(d.db.Statement.Context)
+}
+
+func (d *daoImpl) Close() error {
+	db, err := d.db.DB()
+	if err != nil {
+		return errors.Wrap(err, "获取数据库连接出错")
+	}
+	return db.Close()
 }
@@ -8,25 +8,48 @@ import (
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"reflect"
+	"sync"
 	"testing"
 )
 
 var testHost = "127.0.0.1:3306"
 
-func init() {
-	db, err := gorm.Open(mysql.Open(fmt.Sprintf("root:wujunxian@tcp(%s)/metrics?charset=utf8mb4&parseTime=True&loc=Local", testHost)), &gorm.Config{})
-	if err != nil {
-		panic(err)
+var (
+	mysqlOnce sync.Once
+	mysqlErr  error
+)
+
+// 需要Mysql的测试先调用此函数，首次调用时清空测试数据库。无法连接Mysql时跳过测试
+func requireMySQL(tb testing.TB) {
+	mysqlOnce.Do(func() {
+		db, err := gorm.Open(mysql.Open(fmt.Sprintf("root:wujunxian@tcp(%s)/metrics?charset=utf8mb4&parseTime=True&loc=Local", testHost)), &gorm.Config{})
+		if err != nil {
+			mysqlErr = err
+			return
+		}
+
+		s, _ := db.DB()
+		defer func() {
+			_ = s.Close()
+		}()
+		_, _ = s.Exec("DELETE FROM app_class_dos")
+		_, _ = s.Exec("DELETE FROM app_dos")
+		_, _ = s.Exec("DELETE FROM app_pod_metrics_dos")
+		_, _ = s.Exec("DELETE FROM class_section_metrics_dos")
+		_, _ = s.Exec("DELETE FROM app_section_rollup_dos")
+	})
+	if mysqlErr != nil {
+		tb.Skipf("无法连接Mysql，跳过测试：%v", mysqlErr)
 	}
+}
 
-	s, _ := db.DB()
-	_, _ = s.Exec("DELETE FROM app_class_dos")
-	_, _ = s.Exec("DELETE FROM app_dos")
-	_, _ = s.Exec("DELETE FROM app_pod_metrics_dos")
-	_, _ = s.Exec("DELETE FROM class_section_metrics_dos")
+func testAppId(impl *daoImpl, appName *server.AppName) uint {
+	id, _ := impl.appIds.get(impl.keyFunc(appName))
+	return id
 }
 
 func TestNewDao(t *testing.T) {
+	requireMySQL(t)
 	db, _ := gorm.Open(mysql.Open(fmt.Sprintf("root:wujunxian@tcp(%s)/metrics?charset=utf8mb4&parseTime=True&loc=Local", testHost)), &gorm.Config{})
 	db.Create(&AppDo{
 		Model: gorm.Model{ID: 1000},
@@ -36,11 +59,118 @@ func TestNewDao(t *testing.T) {
 		},
 	})
 
-	_, err := NewDao(testHost)
+	dao, err := NewDao(testHost)
+	assert.NoError(t, err)
+
+	// 启动时从数据库读取已有的AppID
+	impl := dao.(*daoImpl)
+	id, ok := impl.appIds.get(impl.keyFunc(&server.AppName{Name: "haha", Namespace: "test"}))
+	assert.True(t, ok)
+	assert.Equal(t, uint(1000), id)
+}
+
+func TestAppNameKey(t *testing.T) {
+	// 字段拼接后相同的应用名称不能得到相同的键
+	assert.NotEqual(t, appNameKey(&server.AppName{Name: "web", Namespace: "prodeu"}),
+		appNameKey(&server.AppName{Name: "web", Namespace: "prod", Cluster: "eu"}))
+	assert.NotEqual(t, appNameKey(&server.AppName{Name: "ab", Namespace: "c", Cluster: "x"}),
+		appNameKey(&server.AppName{Name: "a", Namespace: "bc", Cluster: "x"}))
+	assert.Equal(t, appNameKey(&server.AppName{Name: "web", Namespace: "prod", Cluster: "eu"}),
+		appNameKey(&server.AppName{Name: "web", Namespace: "prod", Cluster: "eu"}))
+}
+
+func TestDaoImpl_QueryAppId_Clusters(t *testing.T) {
+	requireMySQL(t)
+	dao, _ := NewDao(testHost)
+	impl := dao.(*daoImpl)
+	local := &server.AppName{Name: "collide", Namespace: "prodeu"}
+	remote := &server.AppName{Name: "collide", Namespace: "prod", Cluster: "eu"}
+
+	localId, err := impl.queryAppId(local, true)
+	assert.NoError(t, err)
+	remoteId, err := impl.queryAppId(remote, true)
+	assert.NoError(t, err)
+	assert.NotEqual(t, localId, remoteId)
+
+	ids, err := impl.queryAppIds([]*server.AppName{local, remote})
+	assert.NoError(t, err)
+	assert.Equal(t, localId, ids[impl.keyFunc(local)])
+	assert.Equal(t, remoteId, ids[impl.keyFunc(remote)])
+
+	err = dao.SaveAppClass(&server.AppClass{AppName: *local, ClassId: 1})
+	assert.NoError(t, err)
+	err = dao.SaveAppClass(&server.AppClass{AppName: *remote, ClassId: 2})
+	assert.NoError(t, err)
+	class, err := dao.QueryAppClassByApp(local)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), class.ClassId)
+	class, err = dao.QueryAppClassByApp(remote)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), class.ClassId)
+}
+
+func TestDaoImpl_QueryAppId_Concurrent(t *testing.T) {
+	requireMySQL(t)
+	dao, _ := NewDao(testHost)
+	impl := dao.(*daoImpl)
+
+	// 先创建应用记录，再由多个goroutine同时查询，结果应一致
+	const numGoroutine = 8
+	const numApp = 20
+	expected := make([]uint, numApp)
+	for i := 0; i < numApp; i++ {
+		expected[i], _ = impl.queryAppId(&server.AppName{Name: fmt.Sprintf("concurrent-%d", i), Namespace: "test"}, true)
+	}
+
+	// 缓存容量小于应用数量，查询时将不断淘汰与从数据库读取
+	impl.appIds = newAppIdCache(numApp / 4)
+	results := make([][]uint, numGoroutine)
+	wg := sync.WaitGroup{}
+	for g := 0; g < numGoroutine; g++ {
+		results[g] = make([]uint, numApp)
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < numApp; i++ {
+				appName := &server.AppName{Name: fmt.Sprintf("concurrent-%d", i), Namespace: "test"}
+				results[g][i], _ = impl.queryAppId(appName, false)
+				_, _ = dao.QueryAppClassByApp(appName)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for g := 0; g < numGoroutine; g++ {
+		assert.Equal(t, expected, results[g])
+	}
+}
+
+func TestDaoImpl_Transaction_AppIdCache(t *testing.T) {
+	requireMySQL(t)
+	dao, _ := NewDao(testHost)
+	impl := dao.(*daoImpl)
+	rolledBack := &server.AppName{Name: "rollback", Namespace: "test"}
+	committed := &server.AppName{Name: "commit", Namespace: "test"}
+
+	// 回滚的事务中创建的AppID不会进入缓存
+	_ = dao.Transaction(func(tx Dao) error {
+		err := tx.SaveAppClass(&server.AppClass{AppName: *rolledBack, ClassId: 1})
+		assert.NoError(t, err)
+		return fmt.Errorf("rollback")
+	})
+	_, ok := impl.appIds.get(impl.keyFunc(rolledBack))
+	assert.False(t, ok)
+
+	err := dao.Transaction(func(tx Dao) error {
+		return tx.SaveAppClass(&server.AppClass{AppName: *committed, ClassId: 1})
+	})
 	assert.NoError(t, err)
+	_, ok = impl.appIds.get(impl.keyFunc(committed))
+	assert.True(t, ok)
 }
 
 func TestDao_SaveAllAppPodMetrics(t *testing.T) {
+	requireMySQL(t)
 	arr := make([]*server.AppPodMetrics, 10)
 	for i := 0; i < len(arr); i++ {
 		arr[i] = &server.AppPodMetrics{
@@ -65,7 +195,7 @@ func TestDao_SaveAllAppPodMetrics(t *testing.T) {
 
 	for _, metrics := range arr {
 		dest := &AppPodMetricsDO{}
-		impl.db.Where(&AppPodMetricsDO{AppId: impl.appIdMap[impl.keyFunc(&metrics.AppName)], Timestamp: metrics.Timestamp}).First(dest)
+		impl.db.Where(&AppPodMetricsDO{AppId: testAppId(impl, &metrics.AppName), Timestamp: metrics.Timestamp}).First(dest)
 		assert.Equal(t, uint64(10000), dest.Timestamp)
 	}
 
@@ -83,13 +213,92 @@ func TestDao_SaveAllAppPodMetrics(t *testing.T) {
 
 	for _, metrics := range arr {
 		dest := &AppPodMetricsDO{}
-		impl.db.Where(&AppPodMetricsDO{AppId: impl.appIdMap[impl.keyFunc(&metrics.AppName)], Timestamp: metrics.Timestamp}).First(dest)
+		impl.db.Where(&AppPodMetricsDO{AppId: testAppId(impl, &metrics.AppName), Timestamp: metrics.Timestamp}).First(dest)
 		assert.Equal(t, uint64(10000), dest.Timestamp)
 		assert.Equal(t, float32(100), dest.Cpu)
 	}
 }
 
+func TestDaoImpl_SaveAllAppPodMetrics_Duplicate(t *testing.T) {
+	requireMySQL(t)
+	dao, _ := NewDao(testHost)
+	appName := server.AppName{Name: "duplicate", Namespace: "test"}
+
+	// 同一批数据中相同应用与时间戳的数据，以最后一条为准
+	err := dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+		{AppName: appName, Timestamp: 20000, Cpu: 1, Mem: 1},
+		{AppName: appName, Timestamp: 20000, Cpu: 2, Mem: 2},
+		{AppName: appName, Timestamp: 20060, Cpu: 3, Mem: 3},
+	})
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "保存AppPodMetrics失败")
+	}
+
+	impl := dao.(*daoImpl)
+	records := make([]*AppPodMetricsDO, 0)
+	impl.db.Where(&AppPodMetricsDO{AppId: testAppId(impl, &appName)}).Order("timestamp asc").Find(&records)
+	if !assert.Equal(t, 2, len(records)) {
+		assert.FailNow(t, "保存的记录数量错误")
+	}
+	assert.Equal(t, float32(2), records[0].Cpu)
+	assert.Equal(t, float32(3), records[1].Cpu)
+
+	// 空数据不会出错
+	assert.NoError(t, dao.SaveAllAppPodMetrics(nil))
+}
+
+// 模拟每次获取10000个应用的监控数据
+func BenchmarkDaoImpl_SaveAllAppPodMetrics(b *testing.B) {
+	requireMySQL(b)
+	const numSamples = 10000
+	dao, err := NewDao(testHost)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	arr := make([]*server.AppPodMetrics, numSamples)
+	for i := 0; i < numSamples; i++ {
+		arr[i] = &server.AppPodMetrics{
+			AppName: server.AppName{
+				Name:      fmt.Sprintf("bench-%d", i),
+				Namespace: "bench",
+			},
+		}
+	}
+	// 预先创建应用记录，只衡量写入监控数据的开销
+	if err := dao.SaveAllAppPodMetrics(arr); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("insert", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i, metrics := range arr {
+				metrics.Timestamp = uint64(n+1) * 60
+				metrics.Cpu = float32(i)
+			}
+			if err := dao.SaveAllAppPodMetrics(arr); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("update", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for _, metrics := range arr {
+				metrics.Timestamp = 60
+				metrics.Cpu = float32(n)
+			}
+			if err := dao.SaveAllAppPodMetrics(arr); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	dao.(*daoImpl).db.Unscoped().Where("1 = 1").Delete(&AppPodMetricsDO{})
+}
+
 func TestDaoImpl_SaveAppClass(t *testing.T) {
+	requireMySQL(t)
 	dao, _ := NewDao(testHost)
 
 	/*
@@ -113,7 +322,7 @@ func TestDaoImpl_SaveAppClass(t *testing.T) {
 	impl := dao.(*daoImpl)
 	for _, class := range arr {
 		dest := &AppClassDO{}
-		impl.db.Where(&AppClassDO{AppId: impl.appIdMap[impl.keyFunc(&class.AppName)]}).First(dest)
+		impl.db.Where(&AppClassDO{AppId: testAppId(impl, &class.AppName)}).First(dest)
 		assert.Equal(t, class.ClassId, dest.ClassId)
 		assert.Equal(t, class.CpuMax, dest.CpuMax)
 		assert.Equal(t, class.MemMax, dest.MemMax)
@@ -131,7 +340,7 @@ func TestDaoImpl_SaveAppClass(t *testing.T) {
 		assert.NoError(t, err)
 
 		dest := &AppClassDO{}
-		impl.db.Where(&AppClassDO{AppId: impl.appIdMap[impl.keyFunc(&class.AppName)]}).First(dest)
+		impl.db.Where(&AppClassDO{AppId: testAppId(impl, &class.AppName)}).First(dest)
 		assert.Equal(t, class.ClassId, dest.ClassId)
 		assert.Equal(t, class.CpuMax, dest.CpuMax)
 		assert.Equal(t, class.MemMax, dest.MemMax)
@@ -152,6 +361,7 @@ func TestDaoImpl_SaveAppClass(t *testing.T) {
 }
 
 func TestDaoImpl_SaveClassMetrics(t *testing.T) {
+	requireMySQL(t)
 	c := &server.ClassMetrics{
 		ClassId: 10,
 		Data:    make([]*core.SectionData, core.NumSections),
@@ -187,6 +397,7 @@ func TestDaoImpl_SaveClassMetrics(t *testing.T) {
 }
 
 func TestDaoImpl_RemoveAppPodMetricsBefore(t *testing.T) {
+	requireMySQL(t)
 	dao, _ := NewDao(testHost)
 	size := 10000
 	arr := make([]*server.AppPodMetrics, size)
@@ -223,7 +434,178 @@ func TestDaoImpl_RemoveAppPodMetricsBefore(t *testing.T) {
 	}
 }
 
+func TestDaoImpl_SectionRollup(t *testing.T) {
+	requireMySQL(t)
+	dao, _ := NewDao(testHost)
+	impl := dao.(*daoImpl)
+	appName := server.AppName{Name: "rollup", Namespace: "test"}
+	day := uint64(100)
+	base := day * core.DayLength
+
+	// 分两次写入，第二次包含重复的时间戳，以及第二天同一Section的数据
+	err := dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+		{AppName: appName, Timestamp: base, Cpu: 1, Mem: 10},
+		{AppName: appName, Timestamp: base + 60, Cpu: 3, Mem: 30},
+	})
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "保存AppPodMetrics失败")
+	}
+	err = dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+		{AppName: appName, Timestamp: base + 60, Cpu: 3, Mem: 30},
+		{AppName: appName, Timestamp: base + core.DayLength, Cpu: 5, Mem: 50},
+	})
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "保存AppPodMetrics失败")
+	}
+
+	dos := make([]*AppSectionRollupDO, 0)
+	impl.db.Where(&AppSectionRollupDO{AppId: testAppId(impl, &appName)}).Order("day asc").Find(&dos)
+	if !assert.Equal(t, 2, len(dos)) {
+		assert.FailNow(t, "汇总记录数量错误")
+	}
+	assert.Equal(t, uint64(2), dos[0].Count)
+	assert.Equal(t, float64(4), dos[0].CpuSum)
+	assert.Equal(t, uint64(1), dos[1].Count)
+
+	// 读取时合并多天的汇总
+	found := false
+	err = dao.ForEachAppSectionRollup(day, func(name server.AppName, sections []*sectionRollup) error {
+		if name != appName {
+			return nil
+		}
+		found = true
+		assert.Equal(t, core.NumSections, len(sections))
+		data := sections[0].sectionData()
+		assert.Equal(t, float32(3), data.CpuAvg)
+		assert.Equal(t, float32(5), data.CpuMax)
+		assert.Equal(t, float32(10), data.MemMin)
+		assert.Nil(t, sections[1])
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	// 删除过期数据时，按天删除汇总
+	err = dao.RemoveAppPodMetricsBefore(base + core.DayLength)
+	assert.NoError(t, err)
+	dos = make([]*AppSectionRollupDO, 0)
+	impl.db.Where(&AppSectionRollupDO{AppId: testAppId(impl, &appName)}).Find(&dos)
+	if assert.Equal(t, 1, len(dos)) {
+		assert.Equal(t, day+1, dos[0].Day)
+	}
+}
+
+func TestDaoImpl_SaveAllAppPodMetrics_Concurrent(t *testing.T) {
+	requireMySQL(t)
+	// 多个Dao模拟多个副本，各自的AppID缓存为空，同时首次写入同一个新应用同一Section的数据
+	const numWriter = 8
+	const perWriter = 5
+	daos := make([]Dao, numWriter)
+	for i := range daos {
+		dao, err := NewDao(testHost)
+		if !assert.NoError(t, err) {
+			assert.FailNow(t, "连接数据库失败")
+		}
+		daos[i] = dao
+	}
+	appName := server.AppName{Name: "concurrent-save", Namespace: "test"}
+	base := uint64(200) * core.DayLength
+
+	wg := sync.WaitGroup{}
+	errs := make([]error, numWriter)
+	for w := 0; w < numWriter; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			arr := make([]*server.AppPodMetrics, perWriter)
+			for i := range arr {
+				arr[i] = &server.AppPodMetrics{AppName: appName, Timestamp: base + uint64(w*perWriter+i), Cpu: 1, Mem: 1}
+			}
+			errs[w] = daos[w].SaveAllAppPodMetrics(arr)
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	impl := daos[0].(*daoImpl)
+	appId, err := impl.queryAppId(&appName, false)
+	assert.NoError(t, err)
+	dos := make([]*AppSectionRollupDO, 0)
+	impl.db.Where(&AppSectionRollupDO{AppId: appId}).Find(&dos)
+	if assert.Equal(t, 1, len(dos)) {
+		assert.Equal(t, uint64(numWriter*perWriter), dos[0].Count)
+		assert.Equal(t, float64(numWriter*perWriter), dos[0].CpuSum)
+	}
+}
+
+func TestDaoImpl_BackfillSectionRollups(t *testing.T) {
+	requireMySQL(t)
+	dao, _ := NewDao(testHost)
+	impl := dao.(*daoImpl)
+	appName := server.AppName{Name: "backfill", Namespace: "test"}
+	err := dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+		{AppName: appName, Timestamp: 200 * core.DayLength, Cpu: 1, Mem: 1},
+		{AppName: appName, Timestamp: 200*core.DayLength + core.SectionLength, Cpu: 2, Mem: 2},
+	})
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "保存AppPodMetrics失败")
+	}
+
+	// 模拟升级前没有汇总数据的情况
+	appId := testAppId(impl, &appName)
+	impl.db.Where("app_id = ?", appId).Delete(&AppSectionRollupDO{})
+
+	_, err = dao.BackfillSectionRollups()
+	assert.NoError(t, err)
+	var count int64
+	impl.db.Model(&AppSectionRollupDO{}).Where("app_id = ?", appId).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	// 已有汇总的应用不会再次回填
+	n, err := dao.BackfillSectionRollups()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestSchemaMigrator(t *testing.T) {
+	requireMySQL(t)
+	dao, _ := NewDao(testHost)
+	impl := dao.(*daoImpl)
+	migrator := newSchemaMigrator(impl.db)
+
+	version, err := migrator.CurrentVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, latestSchemaVersion(), version)
+
+	// 回滚后再升级
+	assert.NoError(t, migrator.MigrateTo(1))
+	assert.False(t, impl.db.Migrator().HasTable(&AppSectionRollupDO{}))
+	assert.True(t, impl.db.Migrator().HasTable(&AppDo{}))
+	assert.NoError(t, migrator.Up())
+	assert.True(t, impl.db.Migrator().HasTable(&AppSectionRollupDO{}))
+	assert.Error(t, migrator.MigrateTo(latestSchemaVersion()+1))
+
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), len(statuses))
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt)
+	}
+
+	// 数据库结构比本程序更新时拒绝启动
+	newer := &SchemaMigrationDO{Version: latestSchemaVersion() + 1, Name: "newer"}
+	impl.db.Create(newer)
+	defer impl.db.Delete(newer)
+	_, err = NewDao(testHost)
+	assert.Error(t, err)
+	statuses, _ = migrator.Status()
+	assert.True(t, statuses[len(statuses)-1].Unknown)
+}
+
 func TestDaoImpl_QueryClassMetricsByClassId(t *testing.T) {
+	requireMySQL(t)
 	dao, _ := NewDao(testHost)
 	classId := uint(10)
 	c := &server.ClassMetrics{
@@ -261,7 +643,7 @@ func TestDaoImpl_QueryClassMetricsByClassId(t *testing.T) {
 		查询不存在的记录
 	*/
 	_, err = dao.QueryClassMetricsByClassId(1000)
-	assert.Error(t, err)
+	assert.Equal(t, server.ErrClassNotFound, err)
 
 	/*
 		查询带缺漏的数据
@@ -299,6 +681,7 @@ func TestDaoImpl_QueryClassMetricsByClassId(t *testing.T) {
 }
 
 func TestDaoImpl_QueryAppClassIdByApp(t *testing.T) {
+	requireMySQL(t)
 	dao, _ := NewDao(testHost)
 
 	db := dao.(*daoImpl).db
@@ -338,6 +721,7 @@ func TestDaoImpl_QueryAppClassIdByApp(t *testing.T) {
 }
 
 func TestDaoImpl_RemoveAllClassMetrics(t *testing.T) {
+	requireMySQL(t)
 	dao, _ := NewDao(testHost)
 	db := dao.(*daoImpl).db
 	for i := 0; i < 10; i++ {
@@ -360,8 +744,9 @@ func TestDaoImpl_RemoveAllClassMetrics(t *testing.T) {
 }
 
 func TestDaoImpl_QueryAllClassMetrics(t *testing.T) {
+	requireMySQL(t)
 	dao, _ := NewDao(testHost)
-	err := dao.DB().Delete(&ClassSectionMetricsDO{}, "1 = 1").Error
+	err := dao.(*daoImpl).db.Delete(&ClassSectionMetricsDO{}, "1 = 1").Error
 	if err != nil {
 		assert.FailNow(t, "删除数据失败")
 	}
@@ -403,3 +788,12 @@ func TestDaoImpl_QueryAllClassMetrics(t *testing.T) {
 		}
 	}
 }
+
+func TestDaoImpl_Contract(t *testing.T) {
+	requireMySQL(t)
+	dao, err := NewDao(testHost)
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "DAO创建失败")
+	}
+	testDaoContract(t, dao)
+}
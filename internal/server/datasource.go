@@ -1,94 +1,76 @@
 package server
 
 import (
-	"container/ring"
 	. "github.com/packagewjx/workload-classifier/internal/datasource"
-	"github.com/pkg/errors"
-	"gorm.io/gorm"
+	"github.com/packagewjx/workload-classifier/pkg/server"
 	"io"
 )
 
 const onetimeReadSize = 500
 
-func NewDatabaseDatasource(db *gorm.DB) MetricDataSource {
-	return &dbDatasource{
-		lastId: 0,
-		db:     db,
-		buffer: ring.New(onetimeReadSize),
-	}
+// 按写入顺序遍历Dao中的所有监控数据，每次从Dao读取batchSize条
+type AppPodMetricsIterator struct {
+	dao       QueryDao
+	cursor    uint
+	batchSize int
+	buffer    []*server.AppPodMetrics
+	done      bool
 }
 
-type dbDatasource struct {
-	lastId uint
-	db     *gorm.DB
-	buffer *ring.Ring
+func NewAppPodMetricsIterator(dao QueryDao, batchSize int) *AppPodMetricsIterator {
+	if batchSize <= 0 {
+		batchSize = onetimeReadSize
+	}
+	return &AppPodMetricsIterator{
+		dao:       dao,
+		batchSize: batchSize,
+	}
 }
 
-func (d *dbDatasource) Load() (*ContainerMetric, error) {
-	val := d.buffer.Value
-	if val == nil {
-		err := d.doLoad()
+// 返回下一条数据，所有数据读取完毕后返回io.EOF
+func (it *AppPodMetricsIterator) Next() (*server.AppPodMetrics, error) {
+	if len(it.buffer) == 0 {
+		if it.done {
+			return nil, io.EOF
+		}
+		metrics, next, err := it.dao.QueryAppPodMetricsPage(it.cursor, it.batchSize)
 		if err != nil {
 			return nil, err
 		}
-		val = d.buffer.Value
+		if len(metrics) < it.batchSize {
+			it.done = true
+		}
+		if len(metrics) == 0 {
+			return nil, io.EOF
+		}
+		it.cursor = next
+		it.buffer = metrics
 	}
 
-	d.buffer.Value = nil
-	d.buffer = d.buffer.Next()
-	return val.(*ContainerMetric), nil
+	metrics := it.buffer[0]
+	it.buffer = it.buffer[1:]
+	return metrics, nil
 }
 
-func (d *dbDatasource) doLoad() error {
-	result := []*AppPodMetricsDO{}
-	err := d.db.Limit(onetimeReadSize).Order("id ASC").Where("id > ?", d.lastId).Find(&result).Error
-	if err != nil {
-		return errors.Wrap(err, "读取数据库AppPodMetrics时出错")
-	}
-	if len(result) == 0 {
-		// 所有数据读取完毕
-		return io.EOF
-	}
-
-	idMap, err := queryContainerId(d.db, result)
-	if err != nil {
-		return err
-	}
-
-	d.lastId = result[len(result)-1].ID
-	buf := d.buffer
-
-	for _, do := range result {
-		buf.Value = &ContainerMetric{
-			ContainerId: idMap[do.AppId],
-			Cpu:         do.Cpu,
-			Mem:         do.Mem,
-			Timestamp:   do.Timestamp,
-		}
-		buf = buf.Next()
+func NewDaoDatasource(dao QueryDao) MetricDataSource {
+	return &daoDatasource{
+		iterator: NewAppPodMetricsIterator(dao, onetimeReadSize),
 	}
+}
 
-	return nil
+type daoDatasource struct {
+	iterator *AppPodMetricsIterator
 }
 
-func queryContainerId(db *gorm.DB, arr []*AppPodMetricsDO) (map[uint]string, error) {
-	idSet := map[uint]struct{}{}
-	for _, do := range arr {
-		idSet[do.AppId] = struct{}{}
-	}
-	idList := make([]uint, 0, len(idSet))
-	for id := range idSet {
-		idList = append(idList, id)
-	}
-	appDos := make([]*AppDo, 0, len(idList))
-	err := db.Where("id IN ?", idList).Find(&appDos).Error
+func (d *daoDatasource) Load() (*ContainerMetric, error) {
+	metrics, err := d.iterator.Next()
 	if err != nil {
-		return nil, errors.Wrap(err, "查询AppName出错")
-	}
-
-	result := make(map[uint]string)
-	for _, do := range appDos {
-		result[do.ID] = do.AppName.ContainerId()
+		return nil, err
 	}
-	return result, nil
+	return &ContainerMetric{
+		ContainerId: metrics.AppName.ContainerId(),
+		Cpu:         metrics.Cpu,
+		Mem:         metrics.Mem,
+		Timestamp:   metrics.Timestamp,
+	}, nil
 }
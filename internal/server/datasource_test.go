@@ -5,13 +5,12 @@ import (
 	"github.com/packagewjx/workload-classifier/pkg/core"
 	"github.com/packagewjx/workload-classifier/pkg/server"
 	"github.com/stretchr/testify/assert"
-	"math"
+	"io"
 	"testing"
 )
 
-func TestDbDatasource_Load(t *testing.T) {
-	dao, _ := NewDao(testHost)
-	_ = dao.RemoveAppPodMetricsBefore(math.MaxUint64)
+func TestDaoDatasource_Load(t *testing.T) {
+	dao := NewMemoryDao()
 	const sectionSize = 10
 	testData := make([]*server.AppPodMetrics, 0, core.NumSections*sectionSize)
 	for i := 0; i < core.NumSections; i++ {
@@ -29,7 +28,7 @@ func TestDbDatasource_Load(t *testing.T) {
 	}
 	_ = dao.SaveAllAppPodMetrics(testData)
 
-	datasource := NewDatabaseDatasource(dao.DB())
+	datasource := NewDaoDatasource(dao)
 	var r *ContainerMetric
 	var err error
 	for r, err = datasource.Load(); err == nil; r, err = datasource.Load() {
@@ -40,7 +39,7 @@ func TestDbDatasource_Load(t *testing.T) {
 	}
 
 	// 使用Reader来测试是否有问题
-	ds := NewDatabaseDatasource(dao.DB())
+	ds := NewDaoDatasource(dao)
 	reader := NewDataSourceRawDataReader(ds)
 	data, err := reader.Read()
 	assert.NoError(t, err)
@@ -55,3 +54,30 @@ func TestDbDatasource_Load(t *testing.T) {
 		assert.Equal(t, float32(550), datum.MemSum)
 	}
 }
+
+func TestAppPodMetricsIterator(t *testing.T) {
+	dao := NewMemoryDao()
+	appName := server.AppName{Name: "iterator", Namespace: "test"}
+	testData := make([]*server.AppPodMetrics, 25)
+	for i := range testData {
+		testData[i] = &server.AppPodMetrics{AppName: appName, Timestamp: uint64(i), Cpu: float32(i), Mem: float32(i)}
+	}
+	_ = dao.SaveAllAppPodMetrics(testData)
+
+	// 每页的数量不能整除数据总量
+	it := NewAppPodMetricsIterator(dao, 10)
+	for i := range testData {
+		metrics, err := it.Next()
+		if !assert.NoError(t, err) {
+			assert.FailNow(t, "读取数据出错")
+		}
+		assert.Equal(t, testData[i], metrics)
+	}
+	_, err := it.Next()
+	assert.Equal(t, io.EOF, err)
+	_, err = it.Next()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewAppPodMetricsIterator(NewMemoryDao(), 10).Next()
+	assert.Equal(t, io.EOF, err)
+}
@@ -26,6 +26,12 @@ type AppClassDO struct {
 	ClassId uint
 	CpuMax  float32
 	MemMax  float32
+
+	Distance         float64
+	RunnerUpClassId  uint
+	RunnerUpDistance float64
+	Confidence       *float64
+	Provisional      bool
 }
 
 type ClassSectionMetricsDO struct {
@@ -36,3 +42,70 @@ type ClassSectionMetricsDO struct {
 	DeletedAt  gorm.DeletedAt `gorm:"index"`
 	core.SectionData
 }
+
+// 每个应用每天每个Section的监控数据汇总，在保存监控数据时增量更新
+type AppSectionRollupDO struct {
+	AppId         uint   `gorm:"primaryKey;autoIncrement:false"`
+	Day           uint64 `gorm:"primaryKey;autoIncrement:false"` // 时间戳除以core.DayLength
+	SectionNum    uint   `gorm:"primaryKey;autoIncrement:false"`
+	Count         uint64
+	CpuSum        float64
+	CpuMin        float64
+	CpuMax        float64
+	CpuSketch     []byte
+	MemSum        float64
+	MemMin        float64
+	MemMax        float64
+	MemSketch     []byte
+	LastTimestamp uint64 // 已汇总的数据中最新的时间戳，不晚于此时间戳的数据不会再次汇总
+	UpdatedAt     time.Time
+}
+
+// 手动固定的应用分类。取消固定时直接删除记录，审计信息保存在AppPinAuditDO中
+type AppPinDO struct {
+	ID        uint `gorm:"primarykey"`
+	AppId     uint `gorm:"uniqueIndex"`
+	ClassId   uint
+	Profile   []byte // JSON编码的自定义运行特征，为空表示固定到ClassId
+	Reason    string `gorm:"type:VARCHAR(1024)"`
+	CreatedBy string `gorm:"type:VARCHAR(256)"`
+	CreatedAt time.Time
+}
+
+// 固定分类的审计记录。直接保存应用名称，应用记录被删除后仍然可以查询。
+// 同一应用有多条记录，因此不能嵌入带唯一索引的server.AppName
+type AppPinAuditDO struct {
+	ID        uint   `gorm:"primarykey"`
+	Name      string `gorm:"index:pin_audit_app;type:VARCHAR(256)"`
+	Namespace string `gorm:"index:pin_audit_app;type:VARCHAR(256)"`
+	Cluster   string `gorm:"index:pin_audit_app;type:VARCHAR(64);not null;default:''"`
+	Action    string `gorm:"type:VARCHAR(16)"`
+	ClassId   uint
+	Custom    bool
+	Reason    string `gorm:"type:VARCHAR(1024)"`
+	Actor     string `gorm:"type:VARCHAR(256)"`
+	CreatedAt time.Time
+}
+
+// 应用分类的变化历史，只追加不修改。与AppPinAuditDO相同，直接保存应用名称
+type AppClassHistoryDO struct {
+	ID              uint   `gorm:"primarykey"`
+	Name            string `gorm:"index:class_history_app;type:VARCHAR(256)"`
+	Namespace       string `gorm:"index:class_history_app;type:VARCHAR(256)"`
+	Cluster         string `gorm:"index:class_history_app;type:VARCHAR(64);not null;default:''"`
+	ClassId         uint
+	PreviousClassId uint
+	Distance        float64
+	Confidence      *float64
+	Provisional     bool
+	CreatedAt       time.Time
+}
+
+// 类别中心与应用分类的变化记录，ID即watch使用的ResourceVersion。Object为事件对象的JSON
+type ClassificationEventDO struct {
+	ID        uint64 `gorm:"primarykey"`
+	Type      string `gorm:"type:VARCHAR(16)"`
+	Kind      string `gorm:"type:VARCHAR(16)"`
+	Object    []byte
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/internal/utils"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// 使用dao中的数据试运行再聚类，不修改数据库。config中只使用保留时间、类别数量、迭代次数与数据要求，供命令行使用
+func DryRunReCluster(ctx context.Context, dao Dao, config *ServerConfig, params *server.ReClusterParams) (*server.ReClusterDryRun, error) {
+	s := &serverImpl{
+		config: config,
+		dao:    dao,
+		logger: log.New(os.Stderr, "workload dry-run: ", log.LstdFlags|log.Lmsgprefix),
+	}
+	return s.dryRunReCluster(ctx, params)
+}
+
+// 补全试运行的参数，为0的参数使用服务器的配置
+func (s *serverImpl) dryRunParams(params *server.ReClusterParams) server.ReClusterParams {
+	result := server.ReClusterParams{NumClass: s.config.NumClass, NumRound: s.config.NumRound}
+	if params != nil && params.NumClass != 0 {
+		result.NumClass = params.NumClass
+	}
+	if params != nil && params.NumRound != 0 {
+		result.NumRound = params.NumRound
+	}
+	return result
+}
+
+// 以params中的参数对当前数据执行完整的再聚类流程，返回提议的类别中心、聚类质量以及与当前分类的差异
+func (s *serverImpl) dryRunReCluster(ctx context.Context, params *server.ReClusterParams) (*server.ReClusterDryRun, error) {
+	effective := s.dryRunParams(params)
+	if effective.NumClass == 0 || effective.NumRound == 0 {
+		return nil, fmt.Errorf("类别数量与迭代次数必须大于0")
+	}
+	s.logger.Printf("试运行再聚类开始，类别数量为%d，迭代次数为%d\n", effective.NumClass, effective.NumRound)
+
+	dao := s.dao.WithContext(ctx)
+	// 在聚类前读取当前的结果，避免与同时进行的再聚类的结果混合
+	currentCenters, err := dao.QueryAllClassMetrics()
+	if err != nil {
+		return nil, errors.Wrap(err, "查询类别中心时出错")
+	}
+	currentClasses := make(map[server.AppName]uint)
+	err = dao.ForEachAppClass(func(class *server.AppClass) error {
+		currentClasses[class.AppName] = class.ClassId
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "查询应用分类时出错")
+	}
+	pins, err := dao.QueryAllAppPins()
+	if err != nil {
+		return nil, errors.Wrap(err, "查询固定分类时出错")
+	}
+	pinned := make(map[server.AppName]bool, len(pins))
+	for _, pin := range pins {
+		pinned[pin.AppName] = true
+	}
+
+	start := time.Now()
+	result, err := s.computeReCluster(ctx, effective.NumClass, effective.NumRound)
+	if err != nil {
+		return nil, err
+	}
+
+	dryRun := &server.ReClusterDryRun{
+		Params:         effective,
+		Summary:        result.summary(start, time.Now()),
+		Centers:        result.centers,
+		CenterShifts:   make([]*server.CenterShift, len(result.centers)),
+		RemovedClasses: make([]uint, 0),
+		Changes:        make([]*server.AppClassChange, 0),
+	}
+
+	matched, distances := matchCenters(result.centers, currentCenters)
+	numApps := make(map[uint]int)
+	for _, class := range result.appClasses {
+		numApps[class.ClassId]++
+
+		current, ok := currentClasses[class.AppName]
+		if !ok {
+			dryRun.NumNewApps++
+		} else if matched[class.ClassId] == current {
+			dryRun.NumUnchanged++
+		} else {
+			dryRun.Changes = append(dryRun.Changes, &server.AppClassChange{
+				AppName:         class.AppName,
+				CurrentClassId:  current,
+				ProposedClassId: class.ClassId,
+				MatchedClassId:  matched[class.ClassId],
+				Provisional:     class.Provisional,
+				Pinned:          pinned[class.AppName],
+			})
+		}
+	}
+	sort.Slice(dryRun.Changes, func(i, j int) bool {
+		return dryRun.Changes[i].AppName.Less(dryRun.Changes[j].AppName)
+	})
+
+	for i, center := range result.centers {
+		dryRun.CenterShifts[i] = &server.CenterShift{
+			ClassId:        center.ClassId,
+			MatchedClassId: matched[center.ClassId],
+			Distance:       distances[center.ClassId],
+			NumApps:        numApps[center.ClassId],
+		}
+	}
+	used := make(map[uint]bool, len(matched))
+	for _, current := range matched {
+		used[current] = true
+	}
+	for _, center := range currentCenters {
+		if !used[center.ClassId] {
+			dryRun.RemovedClasses = append(dryRun.RemovedClasses, center.ClassId)
+		}
+	}
+	sort.Slice(dryRun.RemovedClasses, func(i, j int) bool { return dryRun.RemovedClasses[i] < dryRun.RemovedClasses[j] })
+
+	s.logger.Printf("试运行再聚类结束，%d个应用分类将发生变化，%d个不变，%d个为新应用\n",
+		len(dryRun.Changes), dryRun.NumUnchanged, dryRun.NumNewApps)
+	return dryRun, nil
+}
+
+// 将提议的类别与当前类别按中心距离一一对应，每次选取距离最近的一对。返回提议类别对应的当前类别与两者中心的距离，
+// 提议的类别多于当前类别时，多出的类别没有对应
+func matchCenters(proposed, current []*server.ClassMetrics) (map[uint]uint, map[uint]float64) {
+	type pair struct {
+		proposed uint
+		current  uint
+		distance float64
+	}
+	pairs := make([]pair, 0, len(proposed)*len(current))
+	for _, p := range proposed {
+		pData := utils.SectionDataToFloatArray(p.Data)
+		for _, c := range current {
+			pairs = append(pairs, pair{
+				proposed: p.ClassId,
+				current:  c.ClassId,
+				distance: euclideanDistance(pData, utils.SectionDataToFloatArray(c.Data)),
+			})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].distance < pairs[j].distance })
+
+	matched := make(map[uint]uint)
+	distances := make(map[uint]float64)
+	used := make(map[uint]bool)
+	for _, p := range pairs {
+		if _, ok := matched[p.proposed]; ok || used[p.current] {
+			continue
+		}
+		matched[p.proposed] = p.current
+		distances[p.proposed] = p.distance
+		used[p.current] = true
+	}
+	return matched, distances
+}
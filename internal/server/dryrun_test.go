@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+func collectClassMetrics(t *testing.T, dao Dao) []*server.ClassMetrics {
+	result, err := dao.QueryAllClassMetrics()
+	assert.NoError(t, err)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ClassId < result[j].ClassId
+	})
+	return result
+}
+
+func TestMatchCenters(t *testing.T) {
+	center := func(classId uint, value float32) *server.ClassMetrics {
+		metrics := &server.ClassMetrics{ClassId: classId, Data: make([]*core.SectionData, core.NumSections)}
+		for i := range metrics.Data {
+			metrics.Data[i] = &core.SectionData{CpuAvg: value}
+		}
+		return metrics
+	}
+	current := []*server.ClassMetrics{center(1, 0), center(2, 1)}
+	proposed := []*server.ClassMetrics{center(1, 1.1), center(2, 0.1), center(3, 0.9)}
+
+	matched, distances := matchCenters(proposed, current)
+	assert.Equal(t, map[uint]uint{1: 2, 2: 1}, matched, "距离最近的一对优先对应")
+	assert.InDelta(t, 0.1*math.Sqrt(core.NumSections), distances[2], 1e-5)
+	_, ok := matched[3]
+	assert.False(t, ok, "多出的类别没有对应")
+}
+
+func TestServerImpl_ReClusterDryRun(t *testing.T) {
+	dao := NewMemoryDao()
+	s := &serverImpl{
+		config: &ServerConfig{
+			MetricDuration:  7 * 24 * time.Hour,
+			NumClass:        2,
+			NumRound:        DefaultNumRound,
+			MinObservedDays: 1,
+		},
+		dao:     dao,
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+	saveTestPatternCenters(t, dao)
+	yesterday := uint64(time.Now().Unix())/core.DayLength - 1
+	for i := 0; i < 6; i++ {
+		appName := server.AppName{Name: fmt.Sprintf("app-%d", i), Namespace: "test"}
+		assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(appName, i%2, yesterday, 1, core.NumSections, 2)))
+	}
+	assert.NoError(t, s.reCluster(context.Background()))
+
+	centers := collectClassMetrics(t, dao)
+	classes := collectAppClasses(t, dao)
+
+	// 参数与当前一致时分类不变
+	dryRun, err := s.ReClusterDryRun(&server.ReClusterParams{})
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "试运行出错")
+	}
+	assert.Equal(t, server.ReClusterParams{NumClass: 2, NumRound: DefaultNumRound}, dryRun.Params)
+	assert.Equal(t, 6, dryRun.Summary.NumApps)
+	assert.Equal(t, 2, len(dryRun.Centers))
+	assert.Equal(t, 6, dryRun.NumUnchanged)
+	assert.Equal(t, 0, len(dryRun.Changes))
+	assert.Equal(t, 0, len(dryRun.RemovedClasses))
+	for _, shift := range dryRun.CenterShifts {
+		assert.NotZero(t, shift.MatchedClassId)
+		assert.Equal(t, 3, shift.NumApps)
+	}
+
+	// 当前分类与提议不同的应用，以及新应用
+	app1 := server.AppName{Name: "app-1", Namespace: "test"}
+	class1, _ := dao.QueryAppClassByApp(&app1)
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: app1, ClassId: 3 - class1.ClassId}))
+	assert.NoError(t, dao.SaveAppPin(&server.AppPin{AppName: app1, ClassId: 1, Reason: "测试"}))
+	newApp := server.AppName{Name: "new", Namespace: "test"}
+	assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(newApp, 0, yesterday, 1, core.NumSections, 2)))
+	centers = collectClassMetrics(t, dao)
+	classes = collectAppClasses(t, dao)
+
+	dryRun, err = s.ReClusterDryRun(&server.ReClusterParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, dryRun.NumUnchanged)
+	assert.Equal(t, 1, dryRun.NumNewApps)
+	if assert.Equal(t, 1, len(dryRun.Changes)) {
+		change := dryRun.Changes[0]
+		assert.Equal(t, app1, change.AppName)
+		assert.Equal(t, 3-class1.ClassId, change.CurrentClassId)
+		assert.Equal(t, class1.ClassId, change.MatchedClassId)
+		assert.True(t, change.Pinned)
+	}
+
+	// 增加类别数量
+	dryRun, err = s.ReClusterDryRun(&server.ReClusterParams{NumClass: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), dryRun.Params.NumClass)
+	assert.Equal(t, 3, len(dryRun.Centers))
+	unmatched := 0
+	for _, shift := range dryRun.CenterShifts {
+		if shift.MatchedClassId == 0 {
+			unmatched++
+		}
+	}
+	assert.Equal(t, 1, unmatched)
+
+	// 减少类别数量
+	dryRun, err = s.ReClusterDryRun(&server.ReClusterParams{NumClass: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(dryRun.RemovedClasses))
+
+	// 试运行不修改数据库
+	assert.Equal(t, centers, collectClassMetrics(t, dao))
+	assert.Equal(t, classes, collectAppClasses(t, dao))
+
+	handler := s.buildServer().Handler
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/recluster/dryrun", bytes.NewReader([]byte(`{"numRound":5}`))))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	result := &server.ReClusterDryRun{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), result))
+	assert.Equal(t, server.ReClusterParams{NumClass: 2, NumRound: 5}, result.Params)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/recluster/dryrun", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/recluster/dryrun", bytes.NewReader([]byte("bad"))))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/recluster/dryrun", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+)
+
+// 应用参与计算类别中心的数据要求。数据过少的应用经过插值后几乎是一条直线，会使类别中心偏离真实的负载
+const (
+	DefaultMinObservedDays    = 1
+	DefaultMinSectionCoverage = 0.5
+	DefaultMinSampleCount     = 60
+)
+
+// 检查应用的汇总数据是否满足config中的数据要求。满足时返回空字符串，否则返回第一个不满足的要求，
+// 为server.Ineligible开头的常量。有数据的天数为各Section中有数据的天数的最大值
+func checkEligibility(sections []*sectionRollup, config *ServerConfig) string {
+	days := uint64(0)
+	covered := 0
+	samples := uint64(0)
+	for _, section := range sections {
+		if section == nil || section.count == 0 {
+			continue
+		}
+		covered++
+		samples += section.count
+		if section.days > days {
+			days = section.days
+		}
+	}
+
+	if days < uint64(config.MinObservedDays) {
+		return server.IneligibleObservedDays
+	}
+	if float64(covered)/core.NumSections < config.MinSectionCoverage {
+		return server.IneligibleSectionCoverage
+	}
+	if samples < config.MinSampleCount {
+		return server.IneligibleSampleCount
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckEligibility(t *testing.T) {
+	config := &ServerConfig{MinObservedDays: 2, MinSectionCoverage: 0.5, MinSampleCount: 100}
+
+	// 每个Section的汇总覆盖days天，每天perDay个样本
+	makeSections := func(numSections int, days, perDay uint64) []*sectionRollup {
+		sections := make([]*sectionRollup, core.NumSections)
+		for i := 0; i < numSections; i++ {
+			sections[i] = newSectionRollup()
+			for d := uint64(0); d < days; d++ {
+				day := newSectionRollup()
+				for j := uint64(0); j < perDay; j++ {
+					day.add(d*core.DayLength+j, 1, 1)
+				}
+				sections[i].merge(day)
+			}
+		}
+		return sections
+	}
+
+	assert.Equal(t, "", checkEligibility(makeSections(core.NumSections, 2, 1), config))
+	assert.Equal(t, server.IneligibleObservedDays, checkEligibility(makeSections(core.NumSections, 1, 10), config))
+	assert.Equal(t, server.IneligibleSectionCoverage, checkEligibility(makeSections(core.NumSections/2-1, 2, 10), config))
+	assert.Equal(t, server.IneligibleSampleCount, checkEligibility(makeSections(core.NumSections/2, 2, 1), config))
+	assert.Equal(t, server.IneligibleObservedDays, checkEligibility(make([]*sectionRollup, core.NumSections), config))
+
+	// 零值不检查
+	assert.Equal(t, "", checkEligibility(makeSections(1, 1, 1), &ServerConfig{}))
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"path"
+	"sync"
+)
+
+// 负载或Pod上设置为"true"时，不获取其监控数据，也不对其分类
+const ExcludeAnnotation = "workload-classifier.io/exclude"
+
+const (
+	ReplicaSetListPath  = "/apis/apps/v1/replicasets"
+	DaemonSetListPath   = "/apis/apps/v1/daemonsets"
+	StatefulSetListPath = "/apis/apps/v1/statefulsets"
+)
+
+// 负载种类与获取其列表的路径
+var workloadListPaths = map[string]string{
+	KindReplicaSet:  ReplicaSetListPath,
+	KindDaemonSet:   DaemonSetListPath,
+	KindStatefulSet: StatefulSetListPath,
+}
+
+// 获取监控数据时包含与排除应用的规则，同时满足所有规则的Pod才会被获取。排除优先于包含
+type ScrapeFilter struct {
+	IncludeNamespaces []string // 只包含这些名称空间，支持*等通配符。为空则包含所有名称空间
+	ExcludeNamespaces []string // 排除这些名称空间，支持通配符
+	LabelSelector     string   // Pod的标签选择器，如"app.kubernetes.io/part-of!=ci"。为空则不按标签过滤
+	OwnerKinds        []string // 只包含由这些种类的负载创建的Pod，为空则包含ReplicaSet、DaemonSet与StatefulSet，以及能确定应用的直接部署的Pod
+}
+
+func (f *ScrapeFilter) validate() error {
+	for _, pattern := range append(append([]string{}, f.IncludeNamespaces...), f.ExcludeNamespaces...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("名称空间的通配符格式错误：%s", pattern)
+		}
+	}
+	if _, err := labels.Parse(f.LabelSelector); err != nil {
+		return errors.Wrap(err, "标签选择器格式错误")
+	}
+	for _, kind := range f.OwnerKinds {
+		if _, ok := workloadListPaths[kind]; !ok {
+			return fmt.Errorf("不支持的负载种类%s，应为%s、%s或%s", kind, KindReplicaSet, KindDaemonSet, KindStatefulSet)
+		}
+	}
+	return nil
+}
+
+// 解析后的ScrapeFilter。为nil时包含所有应用
+type scrapeFilter struct {
+	includeNamespaces []string
+	excludeNamespaces []string
+	selector          labels.Selector
+	ownerKinds        map[string]bool // 未限制负载种类时包含空字符串，即直接部署的Pod
+
+	// 最近一次获取监控数据时被排除的应用，再聚类时同样排除。只有leader获取监控数据与再聚类，不需要在副本间同步
+	lock     sync.RWMutex
+	excluded map[server.AppName]bool
+	// 第一次记录被排除的应用后关闭
+	synced     chan struct{}
+	syncedOnce sync.Once
+}
+
+func newScrapeFilter(config *ScrapeFilter) (*scrapeFilter, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	selector, _ := labels.Parse(config.LabelSelector)
+	f := &scrapeFilter{
+		includeNamespaces: config.IncludeNamespaces,
+		excludeNamespaces: config.ExcludeNamespaces,
+		selector:          selector,
+		ownerKinds:        make(map[string]bool),
+		excluded:          make(map[server.AppName]bool),
+		synced:            make(chan struct{}),
+	}
+	kinds := config.OwnerKinds
+	if len(kinds) == 0 {
+		kinds = []string{KindReplicaSet, KindDaemonSet, KindStatefulSet, ""}
+	}
+	for _, kind := range kinds {
+		f.ownerKinds[kind] = true
+	}
+	return f, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *scrapeFilter) namespaceIncluded(namespace string) bool {
+	if f == nil {
+		return true
+	}
+	if matchAny(f.excludeNamespaces, namespace) {
+		return false
+	}
+	return len(f.includeNamespaces) == 0 || matchAny(f.includeNamespaces, namespace)
+}
+
+// kind为空表示直接部署的Pod
+func (f *scrapeFilter) ownerKindIncluded(kind string) bool {
+	if f == nil {
+		return true
+	}
+	return f.ownerKinds[kind]
+}
+
+// 需要获取列表以检查注解的负载种类
+func (f *scrapeFilter) workloadKinds() []string {
+	result := make([]string, 0, len(workloadListPaths))
+	for _, kind := range []string{KindReplicaSet, KindDaemonSet, KindStatefulSet} {
+		if f.ownerKindIncluded(kind) {
+			result = append(result, kind)
+		}
+	}
+	return result
+}
+
+// 检查Pod的名称空间、标签与注解。负载的注解由调用者检查
+func (f *scrapeFilter) podIncluded(pod *corev1.Pod) bool {
+	if hasExcludeAnnotation(pod.Annotations) || !f.namespaceIncluded(pod.Namespace) {
+		return false
+	}
+	return f == nil || f.selector.Matches(labels.Set(pod.Labels))
+}
+
+func hasExcludeAnnotation(annotations map[string]string) bool {
+	return annotations[ExcludeAnnotation] == "true"
+}
+
+// 记录最近一次获取监控数据时被排除的应用。failedClusters中的集群本次获取失败，保留上次获取时记录的该集群的应用
+func (f *scrapeFilter) setExcluded(apps map[server.AppName]bool, failedClusters map[string]bool) {
+	if f == nil {
+		return
+	}
+	f.lock.Lock()
+	for appName := range f.excluded {
+		if failedClusters[appName.Cluster] {
+			apps[appName] = true
+		}
+	}
+	f.excluded = apps
+	f.lock.Unlock()
+	f.syncedOnce.Do(func() { close(f.synced) })
+}
+
+// 返回的channel在本实例第一次获取监控数据并记录被排除的应用后关闭。为nil时不排除任何应用，返回已关闭的channel
+func (f *scrapeFilter) excludedSynced() <-chan struct{} {
+	if f == nil {
+		closed := make(chan struct{})
+		close(closed)
+		return closed
+	}
+	return f.synced
+}
+
+// 应用是否被排除。标签、负载种类与注解只能在获取监控数据时检查，因此本实例获取监控数据之前只按名称空间判断。
+// 再聚类会等待excludedSynced，试运行则不等待
+func (f *scrapeFilter) appExcluded(appName server.AppName) bool {
+	if f == nil {
+		return false
+	}
+	if !f.namespaceIncluded(appName.Namespace) {
+		return true
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.excluded[appName]
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestScrapeFilter(t *testing.T) {
+	_, err := newScrapeFilter(&ScrapeFilter{ExcludeNamespaces: []string{"ci-["}})
+	assert.Error(t, err)
+	_, err = newScrapeFilter(&ScrapeFilter{LabelSelector: "tier in (ci"})
+	assert.Error(t, err)
+	_, err = newScrapeFilter(&ScrapeFilter{OwnerKinds: []string{"Deployment"}})
+	assert.Error(t, err)
+
+	f, err := newScrapeFilter(&ScrapeFilter{
+		IncludeNamespaces: []string{"prod-*", "ci-*"},
+		ExcludeNamespaces: []string{"ci-*"},
+		LabelSelector:     "tier!=batch",
+		OwnerKinds:        []string{KindReplicaSet, KindStatefulSet},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, f.namespaceIncluded("prod-web"))
+	assert.False(t, f.namespaceIncluded("ci-1"), "排除优先于包含")
+	assert.False(t, f.namespaceIncluded("kube-system"))
+	assert.False(t, f.ownerKindIncluded(KindDaemonSet))
+	assert.False(t, f.ownerKindIncluded(""), "限制负载种类时不包含直接部署的Pod")
+	assert.Equal(t, []string{KindReplicaSet, KindStatefulSet}, f.workloadKinds())
+
+	pod := func(namespace string, labels, annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Labels: labels, Annotations: annotations}}
+	}
+	assert.True(t, f.podIncluded(pod("prod-web", map[string]string{"tier": "web"}, nil)))
+	assert.False(t, f.podIncluded(pod("prod-web", map[string]string{"tier": "batch"}, nil)))
+	assert.False(t, f.podIncluded(pod("prod-web", nil, map[string]string{ExcludeAnnotation: "true"})))
+
+	// 获取监控数据之前只按名称空间判断
+	app := server.AppName{Name: "app", Namespace: "prod-web"}
+	assert.False(t, f.appExcluded(app))
+	assert.True(t, f.appExcluded(server.AppName{Name: "app", Namespace: "ci-1"}))
+	select {
+	case <-f.excludedSynced():
+		assert.Fail(t, "获取监控数据之前不应同步")
+	default:
+	}
+	f.setExcluded(map[server.AppName]bool{app: true}, nil)
+	assert.True(t, f.appExcluded(app))
+	<-f.excludedSynced()
+
+	// 没有过滤规则时包含所有应用，但仍然检查注解
+	var none *scrapeFilter
+	assert.True(t, none.namespaceIncluded("kube-system"))
+	assert.Equal(t, 3, len(none.workloadKinds()))
+	assert.True(t, none.ownerKindIncluded(""))
+	assert.False(t, none.podIncluded(pod("default", nil, map[string]string{ExcludeAnnotation: "true"})))
+	assert.False(t, none.appExcluded(app))
+	<-none.excludedSynced()
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/classifierpb"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"sort"
+	"time"
+)
+
+const maxBatchQuerySize = 1000 // 一次批量查询的最大应用数量
+
+// 通过gRPC提供与HTTP相同的API
+type grpcServer struct {
+	classifierpb.UnimplementedWorkloadClassifierServer
+
+	s    *serverImpl
+	stop context.Context // 结束时关闭所有watch
+}
+
+var _ classifierpb.WorkloadClassifierServer = &grpcServer{}
+
+// 返回的cancel用于在关闭服务器前结束所有watch，否则GracefulStop会一直等待
+func (s *serverImpl) buildGrpcServer() (*grpc.Server, context.CancelFunc) {
+	stop, cancel := context.WithCancel(context.Background())
+	// 先记录指标再认证，使认证失败的请求也被记录
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return s.metrics.unaryInterceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.authUnaryInterceptor(ctx, req, info, handler)
+			})
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return s.metrics.streamInterceptor(srv, stream, info, func(srv interface{}, stream grpc.ServerStream) error {
+				return s.authStreamInterceptor(srv, stream, info, handler)
+			})
+		}),
+	}
+	if s.certificates != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(s.certificates.tlsConfig())))
+	}
+	srv := grpc.NewServer(options...)
+	classifierpb.RegisterWorkloadClassifierServer(srv, &grpcServer{s: s, stop: stop})
+	return srv, cancel
+}
+
+// 将API的错误转换为对应的gRPC状态
+func grpcError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case server.ErrAppNotFound, server.ErrReClusterNotRun:
+		return status.Error(codes.NotFound, err.Error())
+	case server.ErrAppNotClassified:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case server.ErrResourceVersionExpired:
+		return status.Error(codes.OutOfRange, err.Error())
+	case server.ErrNotLeader:
+		return status.Error(codes.Unavailable, err.Error())
+	case server.ErrReClusterBusy:
+		return status.Error(codes.Aborted, err.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func (g *grpcServer) QueryAppCharacteristics(_ context.Context, in *classifierpb.AppName) (*classifierpb.AppCharacteristics, error) {
+	characteristics, err := g.s.QueryAppCharacteristics(classifierpb.ToAppName(in))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return classifierpb.FromAppCharacteristics(characteristics), nil
+}
+
+func (g *grpcServer) BatchQueryAppCharacteristics(ctx context.Context, in *classifierpb.BatchQueryRequest) (*classifierpb.BatchQueryResponse, error) {
+	if len(in.Apps) > maxBatchQuerySize {
+		return nil, status.Errorf(codes.InvalidArgument, "一次最多查询%d个应用，现在为%d个", maxBatchQuerySize, len(in.Apps))
+	}
+	response := &classifierpb.BatchQueryResponse{Results: make([]*classifierpb.BatchQueryResult, len(in.Apps))}
+	for i, app := range in.Apps {
+		if ctx.Err() != nil {
+			return nil, grpcError(ctx.Err())
+		}
+		result := &classifierpb.BatchQueryResult{App: app}
+		characteristics, err := g.s.QueryAppCharacteristics(classifierpb.ToAppName(app))
+		if err != nil {
+			st := status.Convert(grpcError(err))
+			result.Code = int32(st.Code())
+			result.Message = st.Message()
+		} else {
+			result.Characteristics = classifierpb.FromAppCharacteristics(characteristics)
+		}
+		response.Results[i] = result
+	}
+	return response, nil
+}
+
+func (g *grpcServer) QueryAppProfile(_ context.Context, in *classifierpb.AppName) (*classifierpb.AppProfile, error) {
+	profile, err := g.s.QueryAppProfile(classifierpb.ToAppName(in))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return classifierpb.FromAppProfile(profile), nil
+}
+
+func (g *grpcServer) QueryAppClassHistory(_ context.Context, in *classifierpb.AppName) (*classifierpb.AppClassHistoryList, error) {
+	history, err := g.s.QueryAppClassHistory(classifierpb.ToAppName(in))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	list := &classifierpb.AppClassHistoryList{History: make([]*classifierpb.AppClassHistory, len(history))}
+	for i, h := range history {
+		list.History[i] = classifierpb.FromAppClassHistory(h)
+	}
+	return list, nil
+}
+
+func (g *grpcServer) ListClasses(ctx context.Context, _ *emptypb.Empty) (*classifierpb.ClassList, error) {
+	metrics, err := g.s.dao.WithContext(ctx).QueryAllClassMetrics()
+	if err != nil {
+		g.s.logger.Printf("查询所有类别中心出错：%v\n", err)
+		return nil, grpcError(err)
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].ClassId < metrics[j].ClassId
+	})
+	return &classifierpb.ClassList{Classes: classifierpb.FromClassMetricsList(metrics)}, nil
+}
+
+func (g *grpcServer) ReCluster(_ context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	if err := g.s.ReCluster(); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (g *grpcServer) QueryReClusterSchedule(_ context.Context, _ *emptypb.Empty) (*classifierpb.ReClusterSchedule, error) {
+	schedule, err := g.s.QueryReClusterSchedule()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return classifierpb.FromReClusterSchedule(schedule), nil
+}
+
+func (g *grpcServer) QueryReClusterSummary(_ context.Context, _ *emptypb.Empty) (*classifierpb.ReClusterSummary, error) {
+	summary, err := g.s.QueryReClusterSummary()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return classifierpb.FromReClusterSummary(summary), nil
+}
+
+// 客户端取消请求后中止试运行
+func (g *grpcServer) ReClusterDryRun(ctx context.Context, in *classifierpb.ReClusterParams) (*classifierpb.ReClusterDryRun, error) {
+	dryRun, err := g.s.dryRunReCluster(ctx, classifierpb.ToReClusterParams(in))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, grpcError(ctx.Err())
+		}
+		return nil, grpcError(err)
+	}
+	return classifierpb.FromReClusterDryRun(dryRun), nil
+}
+
+func (g *grpcServer) QueryClassificationSnapshot(_ context.Context, in *classifierpb.ClassificationSnapshotRequest) (*classifierpb.ClassificationSnapshot, error) {
+	snapshot, err := g.s.QueryClassificationSnapshot()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	filterSnapshotByCluster(snapshot, in.Cluster)
+	return classifierpb.FromClassificationSnapshot(snapshot), nil
+}
+
+// 持续时间与HTTP的watch相同，客户端设置了更短的deadline时以客户端为准
+func (g *grpcServer) Watch(in *classifierpb.WatchRequest, stream classifierpb.WorkloadClassifier_WatchServer) error {
+	timeout := DefaultWatchTimeout
+	if deadline, ok := stream.Context().Deadline(); ok {
+		timeout = time.Until(deadline)
+		if timeout > maxWatchTimeout {
+			timeout = maxWatchTimeout
+		}
+	}
+	ctx, cancel := context.WithTimeout(stream.Context(), timeout)
+	defer cancel()
+	dao := g.s.dao.WithContext(ctx)
+
+	var version *uint64
+	if in.ResourceVersion != nil {
+		version = &in.ResourceVersion.Value
+	}
+	start, err := watchStartVersion(dao, version)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	send := func(event *server.ClassificationEvent) error {
+		if !eventInCluster(event, in.Cluster) {
+			return nil
+		}
+		return stream.Send(classifierpb.FromClassificationEvent(event))
+	}
+	err = streamClassificationEvents(ctx, g.stop, dao, start, send, func() {})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			// 发送出错，连接已经断开
+			return err
+		}
+		return status.Error(codes.Unavailable, fmt.Sprintf("查询分类变化出错：%v", err))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/client"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestServerImpl_Grpc(t *testing.T) {
+	dao := NewMemoryDao()
+	s := &serverImpl{
+		config:  &ServerConfig{},
+		dao:     dao,
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+	saveTestPatternCenters(t, dao)
+	app1 := server.AppName{Name: "app-1", Namespace: "test"}
+	app2 := server.AppName{Name: "app-2", Namespace: "test"}
+	confidence := 0.8
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: app1, ClassId: 1, CpuMax: 2, MemMax: 4, Confidence: &confidence}))
+	// app2有数据但尚未分类
+	assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{{AppName: app2, Timestamp: 1}}))
+
+	srv, stopWatches := s.buildGrpcServer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	defer srv.Stop()
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "连接gRPC服务器出错")
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	api := client.NewGrpcApiClient(conn)
+
+	// 与直接调用的结果相同
+	expected, err := s.QueryAppCharacteristics(app1)
+	assert.NoError(t, err)
+	characteristics, err := api.QueryAppCharacteristics(app1)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, characteristics)
+	_, err = api.QueryAppCharacteristics(server.AppName{Name: "unknown", Namespace: "test"})
+	assert.Equal(t, server.ErrAppNotFound, err)
+	_, err = api.QueryAppCharacteristics(app2)
+	assert.Equal(t, server.ErrAppNotClassified, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(s.metrics.apiRequests.WithLabelValues(
+		"/workloadclassifier.v1.WorkloadClassifier/QueryAppCharacteristics", "NotFound")))
+
+	// 单个应用出错不影响其他应用
+	results, err := api.BatchQueryAppCharacteristics(context.Background(),
+		[]server.AppName{app2, app1, {Name: "unknown", Namespace: "test"}})
+	assert.NoError(t, err)
+	if assert.Equal(t, 3, len(results)) {
+		assert.Equal(t, server.ErrAppNotClassified, results[0].Err)
+		assert.NoError(t, results[1].Err)
+		assert.Equal(t, app1, results[1].AppName)
+		assert.Equal(t, expected, results[1].Characteristics)
+		assert.Equal(t, server.ErrAppNotFound, results[2].Err)
+	}
+	_, err = api.BatchQueryAppCharacteristics(context.Background(), make([]server.AppName, maxBatchQuerySize+1))
+	assert.Error(t, err)
+
+	classes, err := api.ListClasses(context.Background())
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(classes)) {
+		assert.Equal(t, uint(1), classes[0].ClassId)
+		assert.Equal(t, uint(2), classes[1].ClassId)
+	}
+
+	_, err = api.QueryReClusterSummary()
+	assert.Equal(t, server.ErrReClusterNotRun, err)
+
+	snapshot, err := api.QueryClassificationSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), snapshot.ResourceVersion)
+	assert.Equal(t, 1, len(snapshot.Apps))
+
+	// 从头开始watch，收到已有的全部变化
+	stopErr := fmt.Errorf("停止")
+	version := uint64(0)
+	received := make([]*server.ClassificationEvent, 0)
+	err = api.Watch(context.Background(), &version, func(event *server.ClassificationEvent) error {
+		received = append(received, event)
+		if len(received) == 3 {
+			return stopErr
+		}
+		return nil
+	})
+	assert.Equal(t, stopErr, err)
+	assert.Equal(t, uint64(3), received[2].ResourceVersion)
+	assert.Equal(t, server.EventKindAppAssignment, received[2].Kind)
+	assert.Equal(t, app1, received[2].AppAssignment.AppName)
+	assert.Equal(t, confidence, *received[2].AppAssignment.Confidence)
+
+	assert.NoError(t, dao.RemoveClassificationEventsBefore(time.Now().Add(time.Hour)))
+	version = 1
+	err = api.Watch(context.Background(), &version, func(event *server.ClassificationEvent) error {
+		return nil
+	})
+	assert.Equal(t, server.ErrResourceVersionExpired, err)
+
+	// 关闭服务器时结束watch
+	done := make(chan error, 1)
+	go func() {
+		done <- api.Watch(context.Background(), nil, func(event *server.ClassificationEvent) error {
+			return nil
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	stopWatches()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "watch没有结束")
+	}
+}
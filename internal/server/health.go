@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 就绪检查中每项检查的超时时间
+const readinessCheckTimeout = 3 * time.Second
+
+// 超过多少个获取周期没有成功获取监控数据时，认为监控数据获取已停滞
+const scrapeStaleIntervals = 3
+
+const (
+	readinessStatusOk   = "ok"
+	readinessStatusFail = "fail"
+)
+
+type readinessCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type readinessReport struct {
+	Status string            `json:"status"`
+	Checks []*readinessCheck `json:"checks"`
+}
+
+// 记录监控数据获取线程的状态，用于判断数据是否新鲜
+type scrapeStatus struct {
+	lock        sync.RWMutex
+	started     time.Time // 本实例开始获取监控数据的时间，未在获取时为零值
+	lastSuccess time.Time // 最近一次成功获取并保存监控数据的时间
+}
+
+func (s *scrapeStatus) start(t time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.started = t
+	s.lastSuccess = time.Time{}
+}
+
+func (s *scrapeStatus) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.started = time.Time{}
+}
+
+func (s *scrapeStatus) success(t time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastSuccess = t
+}
+
+func (s *scrapeStatus) get() (started, lastSuccess time.Time) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.started, s.lastSuccess
+}
+
+// 依次执行各项就绪检查。任意一项失败时，本实例不应该再接收调度器的请求
+func (s *serverImpl) checkReadiness(ctx context.Context) *readinessReport {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) (string, error)
+	}{
+		{"database", s.checkDatabase},
+		{"classMetrics", s.checkClassMetrics},
+		{"scrape", s.checkScrapeFreshness},
+	}
+
+	report := &readinessReport{
+		Status: readinessStatusOk,
+		Checks: make([]*readinessCheck, 0, len(checks)),
+	}
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+		message, err := c.check(checkCtx)
+		cancel()
+
+		result := &readinessCheck{
+			Name:    c.name,
+			Status:  readinessStatusOk,
+			Message: message,
+		}
+		if err != nil {
+			result.Status = readinessStatusFail
+			result.Message = err.Error()
+			report.Status = readinessStatusFail
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+func (s *serverImpl) checkDatabase(ctx context.Context) (string, error) {
+	if err := s.dao.WithContext(ctx).Ping(); err != nil {
+		return "", fmt.Errorf("无法连接数据库：%v", err)
+	}
+	return "", nil
+}
+
+// 所有类别的数据都完整时，才能正确回答应用特征的查询
+func (s *serverImpl) checkClassMetrics(ctx context.Context) (string, error) {
+	classMetrics, err := s.dao.WithContext(ctx).QueryAllClassMetrics()
+	if err != nil {
+		return "", fmt.Errorf("查询类别数据出错：%v", err)
+	}
+	if len(classMetrics) == 0 {
+		return "", fmt.Errorf("没有类别数据")
+	}
+	if len(classMetrics) < int(s.config.NumClass) {
+		return "", fmt.Errorf("类别数据只有%d个，应为%d个", len(classMetrics), s.config.NumClass)
+	}
+	for _, c := range classMetrics {
+		if len(c.Data) != core.NumSections {
+			return "", fmt.Errorf("ClassID为%d的数据不是%d个", c.ClassId, core.NumSections)
+		}
+		for i, datum := range c.Data {
+			if datum == nil {
+				return "", fmt.Errorf("ClassID为%d的数据缺少第%d个Section", c.ClassId, i)
+			}
+		}
+	}
+	return fmt.Sprintf("共%d个类别", len(classMetrics)), nil
+}
+
+// 只有正在获取监控数据的leader才检查数据是否新鲜
+func (s *serverImpl) checkScrapeFreshness(_ context.Context) (string, error) {
+	started, lastSuccess := s.scrapeStatus.get()
+	if started.IsZero() {
+		return "本实例未在获取监控数据", nil
+	}
+
+	tolerance := scrapeStaleIntervals * s.config.ScrapeInterval
+	if lastSuccess.IsZero() {
+		if time.Since(started) > tolerance {
+			return "", fmt.Errorf("自%s开始获取监控数据以来没有成功过", started.Format(time.RFC3339))
+		}
+		return "等待第一次获取监控数据", nil
+	}
+	if age := time.Since(lastSuccess); age > tolerance {
+		return "", fmt.Errorf("最近一次成功获取监控数据在%s之前，超过了%s", age.Round(time.Second), tolerance)
+	}
+	return fmt.Sprintf("最近一次成功获取监控数据于%s", lastSuccess.Format(time.RFC3339)), nil
+}
+
+func (s *serverImpl) handleReadiness(writer http.ResponseWriter, request *http.Request) {
+	report := s.checkReadiness(request.Context())
+	marshal, err := json.Marshal(report)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	if report.Status != readinessStatusOk {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = writer.Write(marshal)
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func newHealthTestServer(dao Dao) *serverImpl {
+	return &serverImpl{
+		config: &ServerConfig{
+			ScrapeInterval: time.Minute,
+			NumClass:       2,
+		},
+		dao:     dao,
+		logger:  log.New(os.Stdout, "TestHealth: ", log.LstdFlags),
+		metrics: newServerMetrics(),
+	}
+}
+
+func newCompleteClassMetrics(classId uint) *server.ClassMetrics {
+	c := &server.ClassMetrics{ClassId: classId, Data: make([]*core.SectionData, core.NumSections)}
+	for i := range c.Data {
+		c.Data[i] = &core.SectionData{}
+	}
+	return c
+}
+
+func checkStatus(report *readinessReport, name string) string {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check.Status
+		}
+	}
+	return ""
+}
+
+func TestServerImpl_CheckReadiness(t *testing.T) {
+	dao := newFakeDao()
+	s := newHealthTestServer(dao)
+
+	// 没有类别数据
+	report := s.checkReadiness(context.Background())
+	assert.Equal(t, readinessStatusFail, report.Status)
+	assert.Equal(t, readinessStatusOk, checkStatus(report, "database"))
+	assert.Equal(t, readinessStatusFail, checkStatus(report, "classMetrics"))
+	assert.Equal(t, readinessStatusOk, checkStatus(report, "scrape"))
+
+	// 类别数量不足
+	_ = dao.SaveClassMetrics(newCompleteClassMetrics(1))
+	report = s.checkReadiness(context.Background())
+	assert.Equal(t, readinessStatusFail, checkStatus(report, "classMetrics"))
+
+	// 类别数据不完整
+	incomplete := newCompleteClassMetrics(2)
+	incomplete.Data[10] = nil
+	_ = dao.SaveClassMetrics(incomplete)
+	report = s.checkReadiness(context.Background())
+	assert.Equal(t, readinessStatusFail, checkStatus(report, "classMetrics"))
+
+	_ = dao.SaveClassMetrics(newCompleteClassMetrics(2))
+	report = s.checkReadiness(context.Background())
+	assert.Equal(t, readinessStatusOk, report.Status)
+
+	// 数据库不可用
+	dao.state.pingErr = fmt.Errorf("connection refused")
+	report = s.checkReadiness(context.Background())
+	assert.Equal(t, readinessStatusFail, report.Status)
+	assert.Equal(t, readinessStatusFail, checkStatus(report, "database"))
+}
+
+func TestServerImpl_CheckScrapeFreshness(t *testing.T) {
+	s := newHealthTestServer(newFakeDao())
+	now := time.Now()
+
+	// 未在获取监控数据
+	_, err := s.checkScrapeFreshness(context.Background())
+	assert.NoError(t, err)
+
+	// 刚开始获取
+	s.scrapeStatus.start(now)
+	_, err = s.checkScrapeFreshness(context.Background())
+	assert.NoError(t, err)
+
+	// 开始后一直没有成功
+	s.scrapeStatus.start(now.Add(-time.Hour))
+	_, err = s.checkScrapeFreshness(context.Background())
+	assert.Error(t, err)
+
+	s.scrapeStatus.success(now)
+	_, err = s.checkScrapeFreshness(context.Background())
+	assert.NoError(t, err)
+
+	// 数据过期
+	s.scrapeStatus.success(now.Add(-scrapeStaleIntervals*s.config.ScrapeInterval - time.Second))
+	_, err = s.checkScrapeFreshness(context.Background())
+	assert.Error(t, err)
+
+	s.scrapeStatus.stop()
+	_, err = s.checkScrapeFreshness(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestServerImpl_HandleReadiness(t *testing.T) {
+	dao := newFakeDao()
+	s := newHealthTestServer(dao)
+	handler := s.buildServer().Handler
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	report := &readinessReport{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), report))
+	assert.Equal(t, readinessStatusFail, report.Status)
+	assert.Equal(t, 3, len(report.Checks))
+
+	_ = dao.SaveClassMetrics(newCompleteClassMetrics(1))
+	_ = dao.SaveClassMetrics(newCompleteClassMetrics(2))
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// 存活检查不受数据库状态影响
+	dao.state.pingErr = fmt.Errorf("connection refused")
+	for _, path := range []string{"/livez", "/healthz"} {
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+}
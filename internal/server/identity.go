@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"strings"
+)
+
+// 确定Pod所属应用的策略
+const (
+	AppIdentityOwner      = "owner"       // 应用为创建Pod的ReplicaSet、DaemonSet或StatefulSet，直接部署的Pod不属于任何应用
+	AppIdentityLabel      = "label"       // 应用名称为Pod上指定标签的值，没有该标签的Pod按owner策略确定
+	AppIdentityAnnotation = "annotation"  // 应用名称为Pod上指定注解的值，没有该注解的Pod按owner策略确定
+	AppIdentityNamePrefix = "name-prefix" // 按owner策略确定，直接部署的Pod的应用名称为去掉生成的后缀后的Pod名称
+
+	DefaultAppIdentity = AppIdentityOwner
+)
+
+// 由Pod确定其所属的应用
+type AppIdentifier interface {
+	// 返回Pod所属的应用，Pod不属于任何应用时返回false
+	Identify(pod *corev1.Pod) (server.AppName, bool)
+}
+
+// 根据策略创建AppIdentifier。key为label与annotation策略所用的标签或注解的键，其他策略不能设置
+func NewAppIdentifier(strategy, key string) (AppIdentifier, error) {
+	switch strategy {
+	case "", AppIdentityOwner, AppIdentityNamePrefix:
+		if key != "" {
+			return nil, fmt.Errorf("只有%s与%s策略需要设置键", AppIdentityLabel, AppIdentityAnnotation)
+		}
+		if strategy == AppIdentityNamePrefix {
+			return namePrefixIdentifier{}, nil
+		}
+		return ownerIdentifier{}, nil
+	case AppIdentityLabel, AppIdentityAnnotation:
+		if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+			return nil, fmt.Errorf("%s策略的键%q不合法：%s", strategy, key, strings.Join(errs, "；"))
+		}
+		if strategy == AppIdentityLabel {
+			return &metadataIdentifier{key: key, values: func(pod *corev1.Pod) map[string]string { return pod.Labels }}, nil
+		}
+		return &metadataIdentifier{key: key, values: func(pod *corev1.Pod) map[string]string { return pod.Annotations }}, nil
+	default:
+		return nil, fmt.Errorf("不支持的应用识别策略%s，应为%s、%s、%s或%s", strategy,
+			AppIdentityOwner, AppIdentityLabel, AppIdentityAnnotation, AppIdentityNamePrefix)
+	}
+}
+
+// 返回创建Pod的ReplicaSet、DaemonSet或StatefulSet，没有时返回nil
+func workloadOwner(pod *corev1.Pod) *metav1.OwnerReference {
+	for i := range pod.OwnerReferences {
+		switch pod.OwnerReferences[i].Kind {
+		case KindDaemonSet, KindStatefulSet, KindReplicaSet:
+			return &pod.OwnerReferences[i]
+		}
+	}
+	return nil
+}
+
+type ownerIdentifier struct{}
+
+func (ownerIdentifier) Identify(pod *corev1.Pod) (server.AppName, bool) {
+	owner := workloadOwner(pod)
+	if owner == nil {
+		return server.AppName{}, false
+	}
+	return server.AppName{Name: owner.Name, Namespace: pod.Namespace}, true
+}
+
+type metadataIdentifier struct {
+	key    string
+	values func(pod *corev1.Pod) map[string]string
+}
+
+func (m *metadataIdentifier) Identify(pod *corev1.Pod) (server.AppName, bool) {
+	// 注解的值可以是任意字符串，只使用符合标签值格式的值作为应用名称
+	value := m.values(pod)[m.key]
+	if value == "" || len(validation.IsValidLabelValue(value)) != 0 {
+		return ownerIdentifier{}.Identify(pod)
+	}
+	return server.AppName{Name: value, Namespace: pod.Namespace}, true
+}
+
+type namePrefixIdentifier struct{}
+
+func (namePrefixIdentifier) Identify(pod *corev1.Pod) (server.AppName, bool) {
+	if appName, ok := (ownerIdentifier{}).Identify(pod); ok {
+		return appName, true
+	}
+	return server.AppName{Name: namePrefix(pod.Name), Namespace: pod.Namespace}, true
+}
+
+// Kubernetes生成名称后缀与pod-template-hash所用的字符，不含元音
+const generatedNameAlphabet = "bcdfghjklmnpqrstvwxz2456789"
+
+// 去掉Pod名称末尾生成的部分，如"backup-27183910-x7k2p"为"backup"，"web-0"为"web"。至少保留第一段
+func namePrefix(podName string) string {
+	segments := strings.Split(podName, "-")
+	end := len(segments)
+	for end > 1 && generatedSegment(segments[end-1]) {
+		end--
+	}
+	return strings.Join(segments[:end], "-")
+}
+
+// 全部为数字（序号或时间戳），或者是至少5个字符的随机串
+func generatedSegment(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if strings.Trim(segment, "0123456789") == "" {
+		return true
+	}
+	return len(segment) >= 5 && strings.Trim(segment, generatedNameAlphabet) == ""
+}
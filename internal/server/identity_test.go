@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestNewAppIdentifier(t *testing.T) {
+	for _, c := range []struct {
+		strategy, key string
+		valid         bool
+	}{
+		{"", "", true},
+		{AppIdentityOwner, "", true},
+		{AppIdentityNamePrefix, "", true},
+		{AppIdentityLabel, "app.kubernetes.io/name", true},
+		{AppIdentityAnnotation, "example.com/app", true},
+		{AppIdentityOwner, "app", false},
+		{AppIdentityLabel, "", false},
+		{AppIdentityAnnotation, "bad key", false},
+		{"pod", "", false},
+	} {
+		_, err := NewAppIdentifier(c.strategy, c.key)
+		assert.Equal(t, c.valid, err == nil, "%s %s", c.strategy, c.key)
+	}
+}
+
+func TestAppIdentifier(t *testing.T) {
+	labeled := testPod("default", "api-v2-5d8f9c7b6d-x7k2p", KindReplicaSet, "api-v2-5d8f9c7b6d",
+		map[string]string{"app.kubernetes.io/name": "shop"})
+	annotated := testPod("default", "worker-0", KindStatefulSet, "worker", nil)
+	annotated.Annotations = map[string]string{"example.com/app": "shop", "example.com/bad": "not a name"}
+	bare := testPod("default", "backup-27183910-bcdfg", "", "", nil)
+	owned := func(app string) server.AppName { return server.AppName{Name: app, Namespace: "default"} }
+
+	for _, c := range []struct {
+		strategy, key string
+		pod           *corev1.Pod
+		expected      server.AppName
+		ok            bool
+	}{
+		{AppIdentityOwner, "", &labeled, owned("api-v2-5d8f9c7b6d"), true},
+		{AppIdentityOwner, "", &bare, server.AppName{}, false},
+		{AppIdentityLabel, "app.kubernetes.io/name", &labeled, owned("shop"), true},
+		{AppIdentityLabel, "app.kubernetes.io/name", &annotated, owned("worker"), true},
+		{AppIdentityLabel, "app.kubernetes.io/name", &bare, server.AppName{}, false},
+		{AppIdentityAnnotation, "example.com/app", &annotated, owned("shop"), true},
+		{AppIdentityAnnotation, "example.com/bad", &annotated, owned("worker"), true},
+		{AppIdentityNamePrefix, "", &labeled, owned("api-v2-5d8f9c7b6d"), true},
+		{AppIdentityNamePrefix, "", &bare, owned("backup"), true},
+	} {
+		identifier, err := NewAppIdentifier(c.strategy, c.key)
+		assert.NoError(t, err)
+		appName, ok := identifier.Identify(c.pod)
+		assert.Equal(t, c.ok, ok, "%s %s", c.strategy, c.pod.Name)
+		assert.Equal(t, c.expected, appName, "%s %s", c.strategy, c.pod.Name)
+	}
+}
+
+func TestNamePrefix(t *testing.T) {
+	for podName, expected := range map[string]string{
+		"web-0":                      "web",
+		"api-v2-5d8f9c7b6d-x7k2p":    "api-v2",
+		"backup-27183910-bcdfg":      "backup",
+		"kube-apiserver-master":      "kube-apiserver-master",
+		"nginx":                      "nginx",
+		"12345":                      "12345",
+		"redis-sentinel-bcdfg-hjklm": "redis-sentinel",
+	} {
+		assert.Equal(t, expected, namePrefix(podName), podName)
+	}
+}
+
+func TestServerImpl_ScrapePodMetrics_Identity(t *testing.T) {
+	label := map[string]string{"app.kubernetes.io/name": "shop"}
+	apiServer, _ := newPodsApiServer([]corev1.Pod{
+		testPod("default", "frontend-abc-1", KindReplicaSet, "frontend-abc", label),
+		testPod("default", "backend-def-1", KindReplicaSet, "backend-def", label),
+		testPod("default", "cache-0", KindStatefulSet, "cache", nil),
+		testPod("default", "debug-x7k2p", "", "", nil),
+	})
+	defer apiServer.Close()
+
+	for _, c := range []struct {
+		strategy, key string
+		expected      []string
+	}{
+		{AppIdentityOwner, "", []string{"frontend-abc", "backend-def", "cache"}},
+		{AppIdentityLabel, "app.kubernetes.io/name", []string{"shop", "shop", "cache"}},
+		{AppIdentityNamePrefix, "", []string{"frontend-abc", "backend-def", "cache", "debug"}},
+	} {
+		identifier, err := NewAppIdentifier(c.strategy, c.key)
+		assert.NoError(t, err)
+		s := &serverImpl{logger: log.New(os.Stdout, "", 0), apiServerUrl: apiServer.URL, appIdentifier: identifier}
+		result, err := s.scrapePodMetrics(context.Background())
+		assert.NoError(t, err)
+		apps := make([]string, 0)
+		for _, m := range result {
+			apps = append(apps, m.Name)
+		}
+		assert.ElementsMatch(t, c.expected, apps, c.strategy)
+	}
+}
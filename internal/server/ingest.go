@@ -0,0 +1,460 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 推送监控数据的API
+const (
+	IngestPath      = "/ingest"              // 请求体为JSON格式的server.AppPodMetrics数组
+	RemoteWritePath = "/ingest/remote-write" // Prometheus remote write
+)
+
+// remote write中的CPU与内存使用量指标。两个指标除__name__外标签相同、时间戳相同的样本组成一条监控数据
+const (
+	RemoteWriteCpuMetric = "workload_classifier_app_cpu_usage_cores"
+	RemoteWriteMemMetric = "workload_classifier_app_memory_usage_bytes"
+
+	RemoteWriteAppLabel       = "app"
+	RemoteWriteNamespaceLabel = "namespace"
+	RemoteWriteClusterLabel   = "cluster" // 可选，为空表示服务器所在的集群
+)
+
+const (
+	DefaultIngestRateLimit = 1000  // 每个来源每秒可以推送的样本数
+	DefaultIngestBurst     = 10000 // 每个来源可以突发推送的样本数，也是单次推送的样本数上限
+)
+
+const (
+	maxIngestBodySize         = 16 << 20
+	maxRemoteWriteDecodedSize = 4 * maxIngestBodySize
+	maxIngestClockSkew        = 5 * time.Minute // 样本的时间戳最多比服务器的当前时间晚这么久
+	maxIngestErrors           = 10              // 响应中最多列出的不合法样本数
+	remoteWritePairTimeout    = 5 * time.Minute // 只收到CPU或内存样本时，等待另一个样本的时间
+	maxRemoteWritePending     = 100000          // 等待配对的样本数上限，超过时丢弃新的未配对样本
+	ingestLimiterIdle         = 10 * time.Minute
+	ingestLimiterCleanup      = 1000 // 来源数量超过此值时清理长时间没有推送的来源
+)
+
+// ingested_samples_total的format与result标签
+const (
+	ingestFormatJson        = "json"
+	ingestFormatRemoteWrite = "remote-write"
+
+	ingestResultInvalid     = "invalid"
+	ingestResultRateLimited = "rate_limited"
+	ingestResultDropped     = "dropped" // 等待配对的样本过多
+)
+
+var ingestNameRegexp = regexp.MustCompile(fmt.Sprintf("^(?:%s)$", namePattern))
+
+// 按来源限制推送的样本数。来源为认证的用户，未启用认证时为客户端地址
+type ingestLimiter struct {
+	limit rate.Limit
+	burst int
+
+	lock     sync.Mutex
+	limiters map[string]*sourceLimiter
+}
+
+type sourceLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// limit为0时不限制速率，但单次推送的样本数仍不能超过burst
+func newIngestLimiter(limit float64, burst int) *ingestLimiter {
+	if burst <= 0 {
+		burst = DefaultIngestBurst
+	}
+	l := &ingestLimiter{limit: rate.Limit(limit), burst: burst, limiters: make(map[string]*sourceLimiter)}
+	if limit == 0 {
+		l.limit = rate.Inf
+	}
+	return l
+}
+
+// 返回需要等待的时间，为0时允许推送。n超过burst时返回false
+func (l *ingestLimiter) reserve(source string, n int, now time.Time) (time.Duration, bool) {
+	if n > l.burst {
+		return 0, false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.limiters) >= ingestLimiterCleanup {
+		for key, s := range l.limiters {
+			if now.Sub(s.lastSeen) > ingestLimiterIdle {
+				delete(l.limiters, key)
+			}
+		}
+	}
+	s, ok := l.limiters[source]
+	if !ok {
+		s = &sourceLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[source] = s
+	}
+	s.lastSeen = now
+	reservation := s.limiter.ReserveN(now, n)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay, true
+	}
+	return 0, true
+}
+
+// 认证的用户名，未启用认证时为客户端地址
+func ingestSource(request *http.Request) string {
+	if c := callerFromContext(request.Context()); c != nil {
+		return "user:" + c.name
+	}
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// 检查推送的监控数据。时间戳不能早于数据保留时间，也不能晚于当前时间太多
+func (s *serverImpl) validateIngestSample(m *server.AppPodMetrics, now time.Time) error {
+	if !ingestNameRegexp.MatchString(m.Name) || !ingestNameRegexp.MatchString(m.Namespace) {
+		return fmt.Errorf("应用名称%q或名称空间%q不合法", m.Name, m.Namespace)
+	}
+	if err := validateClusterName(m.Cluster); err != nil {
+		return err
+	}
+	for _, value := range []float32{m.Cpu, m.Mem} {
+		if value < 0 || math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+			return fmt.Errorf("CPU或内存使用量不合法：%f，%f", m.Cpu, m.Mem)
+		}
+	}
+	timestamp := time.Unix(int64(m.Timestamp), 0)
+	if m.Timestamp > math.MaxInt64 || timestamp.After(now.Add(maxIngestClockSkew)) {
+		return fmt.Errorf("时间戳%d晚于服务器当前时间", m.Timestamp)
+	}
+	if s.config.MetricDuration > 0 && timestamp.Before(now.Add(-s.config.MetricDuration)) {
+		return fmt.Errorf("时间戳%d早于数据保留时间", m.Timestamp)
+	}
+	return nil
+}
+
+// 将不合法样本的错误组成响应，最多列出maxIngestErrors个
+func ingestErrorMessage(errs []string, total int) string {
+	message := fmt.Sprintf("%d个样本中有%d个不合法，没有保存任何数据", total, len(errs))
+	if len(errs) > maxIngestErrors {
+		errs = append(errs[:maxIngestErrors], "……")
+	}
+	return message + "\n" + strings.Join(errs, "\n")
+}
+
+// 按来源限制推送的样本数，超过限制时写入响应并返回false
+func (s *serverImpl) allowIngest(writer http.ResponseWriter, request *http.Request, format string, n int) bool {
+	delay, ok := s.ingestLimiter.reserve(ingestSource(request), n, time.Now())
+	if !ok {
+		s.metrics.ingestedSamples.WithLabelValues(format, ingestResultInvalid).Add(float64(n))
+		http.Error(writer, fmt.Sprintf("单次推送了%d个样本，超过上限%d", n, s.ingestLimiter.burst),
+			http.StatusRequestEntityTooLarge)
+		return false
+	} else if delay > 0 {
+		s.metrics.ingestedSamples.WithLabelValues(format, ingestResultRateLimited).Add(float64(n))
+		writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		http.Error(writer, "推送的样本数超过速率限制", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// 读取请求体，只接受POST
+func readIngestBody(writer http.ResponseWriter, request *http.Request) ([]byte, bool) {
+	if request.Method != http.MethodPost {
+		writer.Header().Set("Allow", "POST")
+		http.Error(writer, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, maxIngestBodySize))
+	if err != nil {
+		http.Error(writer, errors.Wrap(err, "读取请求出错").Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// 与获取的监控数据一样通过SaveAllAppPodMetrics保存
+func (s *serverImpl) saveIngestedMetrics(request *http.Request, metrics []*server.AppPodMetrics) error {
+	err := s.dao.WithContext(request.Context()).SaveAllAppPodMetrics(metrics)
+	if err == nil {
+		s.metrics.samplesStored.Add(float64(len(metrics)))
+	}
+	return err
+}
+
+// 接收JSON格式的监控数据，有不合法的样本时整批拒绝
+func (s *serverImpl) handleIngest(writer http.ResponseWriter, request *http.Request) {
+	body, ok := readIngestBody(writer, request)
+	if !ok {
+		return
+	}
+	metrics := make([]*server.AppPodMetrics, 0)
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		http.Error(writer, errors.Wrap(err, "解析请求出错").Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	errs := make([]string, 0)
+	for i, m := range metrics {
+		if m == nil {
+			errs = append(errs, fmt.Sprintf("第%d个样本为空", i+1))
+		} else if err := s.validateIngestSample(m, now); err != nil {
+			errs = append(errs, fmt.Sprintf("第%d个样本：%v", i+1, err))
+		}
+	}
+	if len(errs) > 0 {
+		s.metrics.ingestedSamples.WithLabelValues(ingestFormatJson, ingestResultInvalid).Add(float64(len(metrics)))
+		http.Error(writer, ingestErrorMessage(errs, len(metrics)), http.StatusBadRequest)
+		return
+	}
+	if !s.allowIngest(writer, request, ingestFormatJson, len(metrics)) {
+		return
+	}
+	if len(metrics) > 0 {
+		err := s.saveIngestedMetrics(request, metrics)
+		s.metrics.ingestedSamples.WithLabelValues(ingestFormatJson, resultLabel(err)).Add(float64(len(metrics)))
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	marshal, _ := json.Marshal(&ingestResult{Accepted: len(metrics)})
+	writer.Header().Set("Content-Type", "application/json")
+	_, _ = writer.Write(marshal)
+}
+
+type ingestResult struct {
+	Accepted int `json:"accepted"` // 保存的监控数据条数
+}
+
+// 只收到CPU或内存样本的监控数据
+type remoteWriteHalf struct {
+	metrics  server.AppPodMetrics
+	hasCpu   bool
+	hasMem   bool
+	received time.Time
+}
+
+func (h *remoteWriteHalf) merge(other *remoteWriteHalf) {
+	if other.hasCpu {
+		h.metrics.Cpu, h.hasCpu = other.metrics.Cpu, true
+	}
+	if other.hasMem {
+		h.metrics.Mem, h.hasMem = other.metrics.Mem, true
+	}
+}
+
+// 将CPU与内存样本配对。同一序列的两个指标可能在不同的请求中发送，未配对的样本等待remoteWritePairTimeout，
+// 最多等待maxRemoteWritePending个。等待中的样本只保存在本实例中，另一个样本发送到其他实例时无法配对
+type remoteWritePairer struct {
+	lock    sync.Mutex
+	pending map[string]*remoteWriteHalf
+}
+
+func newRemoteWritePairer() *remoteWritePairer {
+	return &remoteWritePairer{pending: make(map[string]*remoteWriteHalf)}
+}
+
+// 将halves与等待中的样本配对，并保存配对完成的监控数据。保存时不持有锁，其他请求可以同时配对；
+// 保存失败时放回用于配对的等待中的样本，请求重试时可以再次配对。返回保存的监控数据条数与因等待的样本过多而丢弃的样本数
+func (p *remoteWritePairer) pairAndSave(halves map[string]*remoteWriteHalf, now time.Time,
+	save func(metrics []*server.AppPodMetrics) error) (int, int, error) {
+	complete, consumed, dropped := p.pair(halves, now)
+	if len(complete) == 0 {
+		return 0, dropped, nil
+	}
+	if err := save(complete); err != nil {
+		p.restore(consumed)
+		return 0, dropped, err
+	}
+	return len(complete), dropped, nil
+}
+
+// 返回配对完成的监控数据、配对时从等待中取出的样本与丢弃的样本数
+func (p *remoteWritePairer) pair(halves map[string]*remoteWriteHalf, now time.Time) ([]*server.AppPodMetrics,
+	map[string]*remoteWriteHalf, int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for key, half := range p.pending {
+		if now.Sub(half.received) > remoteWritePairTimeout {
+			delete(p.pending, key)
+		}
+	}
+	complete := make([]*server.AppPodMetrics, 0)
+	consumed := make(map[string]*remoteWriteHalf)
+	dropped := 0
+	for key, half := range halves {
+		merged := *half
+		merged.received = now
+		old, ok := p.pending[key]
+		if ok {
+			merged = *old
+			merged.merge(half)
+		}
+		if merged.hasCpu && merged.hasMem {
+			m := merged.metrics
+			complete = append(complete, &m)
+			if ok {
+				consumed[key] = old
+				delete(p.pending, key)
+			}
+		} else if ok || len(p.pending) < maxRemoteWritePending {
+			p.pending[key] = &merged
+		} else {
+			dropped++
+		}
+	}
+	return complete, consumed, dropped
+}
+
+// 放回consumed中的样本，期间又收到的同一序列的样本优先
+func (p *remoteWritePairer) restore(consumed map[string]*remoteWriteHalf) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for key, half := range consumed {
+		if _, ok := p.pending[key]; !ok {
+			p.pending[key] = half
+		}
+	}
+}
+
+// 除__name__外的标签，按名称排序后组成序列的键
+func remoteWriteSeriesKey(labels []*prompbLabel) string {
+	pairs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if label.Name != "__name__" {
+			pairs = append(pairs, strconv.Quote(label.Name)+"="+strconv.Quote(label.Value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// 接收Prometheus remote write推送的监控数据，只处理RemoteWriteCpuMetric与RemoteWriteMemMetric，其他指标被忽略。
+// 不合法的样本逐个丢弃并记录日志，其余样本照常保存。Prometheus不会重试4xx的请求，整批拒绝会丢失合法的样本
+func (s *serverImpl) handleRemoteWrite(writer http.ResponseWriter, request *http.Request) {
+	body, ok := readIngestBody(writer, request)
+	if !ok {
+		return
+	}
+	// 请求体为snappy块格式，先检查解压后的长度，避免分配过大的内存
+	decodedLen, err := snappy.DecodedLen(body)
+	if err == nil && decodedLen > maxRemoteWriteDecodedSize {
+		err = fmt.Errorf("解压后的长度%d超过上限%d", decodedLen, maxRemoteWriteDecodedSize)
+	}
+	var decoded []byte
+	if err == nil {
+		decoded, err = snappy.Decode(nil, body)
+	}
+	if err != nil {
+		http.Error(writer, errors.Wrap(err, "解压请求出错").Error(), http.StatusBadRequest)
+		return
+	}
+	writeRequest := &prompbWriteRequest{}
+	if err := proto.Unmarshal(decoded, writeRequest); err != nil {
+		http.Error(writer, errors.Wrap(err, "解析请求出错").Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	halves := make(map[string]*remoteWriteHalf)
+	errs := make([]string, 0)
+	numSamples := 0 // 合法的样本数
+	for _, series := range writeRequest.Timeseries {
+		labels := make(map[string]string, len(series.Labels))
+		for _, label := range series.Labels {
+			labels[label.Name] = label.Value
+		}
+		isCpu := labels["__name__"] == RemoteWriteCpuMetric
+		if !isCpu && labels["__name__"] != RemoteWriteMemMetric {
+			continue
+		}
+		appName := server.AppName{
+			Name:      labels[RemoteWriteAppLabel],
+			Namespace: labels[RemoteWriteNamespaceLabel],
+			Cluster:   labels[RemoteWriteClusterLabel],
+		}
+		seriesKey := remoteWriteSeriesKey(series.Labels)
+		for _, sample := range series.Samples {
+			// 序列消失时Prometheus发送NaN作为结束标记
+			if math.IsNaN(sample.Value) {
+				continue
+			}
+			half := &remoteWriteHalf{metrics: server.AppPodMetrics{AppName: appName}, hasCpu: isCpu, hasMem: !isCpu}
+			if sample.Timestamp >= 0 {
+				half.metrics.Timestamp = uint64(sample.Timestamp / 1000)
+			}
+			if isCpu {
+				half.metrics.Cpu = float32(sample.Value)
+			} else {
+				half.metrics.Mem = float32(sample.Value)
+			}
+			if sample.Timestamp < 0 || sample.Value > math.MaxFloat32 {
+				errs = append(errs, fmt.Sprintf("序列{%s}的样本：时间戳%d或值%f不合法", seriesKey, sample.Timestamp, sample.Value))
+				continue
+			}
+			if err := s.validateIngestSample(&half.metrics, now); err != nil {
+				errs = append(errs, fmt.Sprintf("序列{%s}的样本：%v", seriesKey, err))
+				continue
+			}
+			numSamples++
+			key := seriesKey + "@" + strconv.FormatInt(sample.Timestamp, 10)
+			if old, ok := halves[key]; ok {
+				old.merge(half)
+			} else {
+				halves[key] = half
+			}
+		}
+	}
+	if numInvalid := len(errs); numInvalid > 0 {
+		s.metrics.ingestedSamples.WithLabelValues(ingestFormatRemoteWrite, ingestResultInvalid).Add(float64(numInvalid))
+		if numInvalid > maxIngestErrors {
+			errs = append(errs[:maxIngestErrors], "……")
+		}
+		s.logger.Printf("%s推送的remote write请求中有%d个不合法的样本，已丢弃：\n%s\n", ingestSource(request),
+			numInvalid, strings.Join(errs, "\n"))
+	}
+	if !s.allowIngest(writer, request, ingestFormatRemoteWrite, numSamples) {
+		return
+	}
+
+	_, dropped, err := s.remoteWritePairer.pairAndSave(halves, now, func(metrics []*server.AppPodMetrics) error {
+		return s.saveIngestedMetrics(request, metrics)
+	})
+	if dropped > 0 {
+		s.logger.Printf("等待配对的remote write样本超过%d个，丢弃了%s推送的%d个样本\n", maxRemoteWritePending,
+			ingestSource(request), dropped)
+		s.metrics.ingestedSamples.WithLabelValues(ingestFormatRemoteWrite, ingestResultDropped).Add(float64(dropped))
+	}
+	s.metrics.ingestedSamples.WithLabelValues(ingestFormatRemoteWrite, resultLabel(err)).Add(float64(numSamples - dropped))
+	if err != nil {
+		// 返回5xx时Prometheus会重试
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
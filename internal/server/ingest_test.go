@@ -0,0 +1,276 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newIngestTestServer(config *ServerConfig) (*serverImpl, http.Handler) {
+	config.MetricDuration = 24 * time.Hour
+	s := &serverImpl{
+		config:            config,
+		dao:               NewMemoryDao(),
+		logger:            log.New(os.Stdout, "", 0),
+		metrics:           newServerMetrics(),
+		ingestLimiter:     newIngestLimiter(config.IngestRateLimit, config.IngestBurst),
+		remoteWritePairer: newRemoteWritePairer(),
+	}
+	return s, s.buildServer().Handler
+}
+
+func postIngest(handler http.Handler, path string, body []byte) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	return recorder
+}
+
+func queryIngestedMetrics(t *testing.T, s *serverImpl) []*server.AppPodMetrics {
+	metrics, _, err := s.dao.QueryAppPodMetricsPage(0, 100)
+	assert.NoError(t, err)
+	return metrics
+}
+
+func TestServerImpl_HandleIngest(t *testing.T) {
+	s, handler := newIngestTestServer(&ServerConfig{IngestBurst: 3})
+	now := uint64(time.Now().Unix())
+	app := server.AppName{Name: "vm-batch", Namespace: "legacy"}
+	east := server.AppName{Name: "vm-batch", Namespace: "legacy", Cluster: "east"}
+	body, _ := json.Marshal([]*server.AppPodMetrics{
+		{AppName: app, Timestamp: now - 60, Cpu: 1.5, Mem: 1024},
+		{AppName: east, Timestamp: now, Cpu: 0.5, Mem: 2048},
+	})
+	recorder := postIngest(handler, IngestPath, body)
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.JSONEq(t, `{"accepted":2}`, recorder.Body.String())
+	assert.ElementsMatch(t, []*server.AppPodMetrics{
+		{AppName: app, Timestamp: now - 60, Cpu: 1.5, Mem: 1024},
+		{AppName: east, Timestamp: now, Cpu: 0.5, Mem: 2048},
+	}, queryIngestedMetrics(t, s))
+	assert.Equal(t, float64(2), testutil.ToFloat64(s.metrics.ingestedSamples.WithLabelValues(ingestFormatJson, "success")))
+
+	// 有不合法的样本时整批拒绝
+	for _, metrics := range [][]*server.AppPodMetrics{
+		{{AppName: server.AppName{Name: "new", Namespace: "legacy"}, Timestamp: now}, {AppName: app, Timestamp: now + 3600}},
+		{{AppName: app, Timestamp: now - 2*24*3600}},
+		{{AppName: app, Timestamp: now, Cpu: -1}},
+		{{AppName: server.AppName{Name: "bad name", Namespace: "legacy"}, Timestamp: now}},
+		{{AppName: server.AppName{Name: "app", Namespace: "legacy", Cluster: "bad name"}, Timestamp: now}},
+		{nil},
+	} {
+		body, _ := json.Marshal(metrics)
+		recorder := postIngest(handler, IngestPath, body)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, string(body))
+	}
+	assert.Equal(t, http.StatusBadRequest, postIngest(handler, IngestPath, []byte("{")).Code)
+	assert.Equal(t, 2, len(queryIngestedMetrics(t, s)))
+
+	// 单次推送超过上限
+	body, _ = json.Marshal([]*server.AppPodMetrics{
+		{AppName: app, Timestamp: now - 3}, {AppName: app, Timestamp: now - 2}, {AppName: app, Timestamp: now - 1}, {AppName: app, Timestamp: now},
+	})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, postIngest(handler, IngestPath, body).Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, IngestPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestServerImpl_HandleIngest_RateLimit(t *testing.T) {
+	s, handler := newIngestTestServer(&ServerConfig{IngestRateLimit: 0.01, IngestBurst: 2})
+	now := uint64(time.Now().Unix())
+	body, _ := json.Marshal([]*server.AppPodMetrics{
+		{AppName: server.AppName{Name: "app", Namespace: "test"}, Timestamp: now},
+		{AppName: server.AppName{Name: "app", Namespace: "test"}, Timestamp: now - 1},
+	})
+	assert.Equal(t, http.StatusOK, postIngest(handler, IngestPath, body).Code)
+	recorder := postIngest(handler, IngestPath, body)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.NotEmpty(t, recorder.Header().Get("Retry-After"))
+	assert.Equal(t, float64(2), testutil.ToFloat64(s.metrics.ingestedSamples.WithLabelValues(ingestFormatJson, ingestResultRateLimited)))
+
+	// 重新构建HTTP服务器不会重置限流状态
+	assert.Equal(t, http.StatusTooManyRequests, postIngest(s.buildServer().Handler, IngestPath, body).Code)
+
+	// 其他来源不受影响
+	request := httptest.NewRequest(http.MethodPost, IngestPath, bytes.NewReader(body))
+	request.RemoteAddr = "192.0.2.2:1234"
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+// 构造remote write请求体
+func remoteWriteBody(t *testing.T, series ...*prompbTimeSeries) []byte {
+	marshal, err := proto.Marshal(&prompbWriteRequest{Timeseries: series})
+	assert.NoError(t, err)
+	return snappy.Encode(nil, marshal)
+}
+
+func remoteWriteSeries(metric, app string, timestamp time.Time, value float64) *prompbTimeSeries {
+	return &prompbTimeSeries{
+		Labels: []*prompbLabel{
+			{Name: "__name__", Value: metric},
+			{Name: RemoteWriteAppLabel, Value: app},
+			{Name: RemoteWriteNamespaceLabel, Value: "test"},
+			{Name: RemoteWriteClusterLabel, Value: "east"},
+		},
+		Samples: []*prompbSample{{Value: value, Timestamp: timestamp.UnixNano() / int64(time.Millisecond)}},
+	}
+}
+
+func TestServerImpl_HandleRemoteWrite(t *testing.T) {
+	s, handler := newIngestTestServer(&ServerConfig{})
+	now := time.Now().Truncate(time.Second)
+	app := server.AppName{Name: "web", Namespace: "test", Cluster: "east"}
+
+	// 同一请求中的CPU与内存样本直接配对，其他指标与结束标记被忽略
+	body := remoteWriteBody(t,
+		remoteWriteSeries(RemoteWriteCpuMetric, "web", now.Add(-time.Minute), 0.25),
+		remoteWriteSeries(RemoteWriteMemMetric, "web", now.Add(-time.Minute), 4096),
+		remoteWriteSeries("up", "web", now, 1),
+		remoteWriteSeries(RemoteWriteMemMetric, "web", now.Add(-2*time.Minute), math.Float64frombits(0x7ff0000000000002)),
+	)
+	recorder := postIngest(handler, RemoteWritePath, body)
+	assert.Equal(t, http.StatusNoContent, recorder.Code, recorder.Body.String())
+	assert.Equal(t, []*server.AppPodMetrics{
+		{AppName: app, Timestamp: uint64(now.Add(-time.Minute).Unix()), Cpu: 0.25, Mem: 4096},
+	}, queryIngestedMetrics(t, s))
+
+	// 在不同请求中到达的样本等待配对
+	recorder = postIngest(handler, RemoteWritePath, remoteWriteBody(t, remoteWriteSeries(RemoteWriteCpuMetric, "web", now, 0.5)))
+	assert.Equal(t, http.StatusNoContent, recorder.Code, recorder.Body.String())
+	assert.Equal(t, 1, len(queryIngestedMetrics(t, s)))
+	recorder = postIngest(handler, RemoteWritePath, remoteWriteBody(t, remoteWriteSeries(RemoteWriteMemMetric, "web", now, 8192)))
+	assert.Equal(t, http.StatusNoContent, recorder.Code, recorder.Body.String())
+	assert.Contains(t, queryIngestedMetrics(t, s), &server.AppPodMetrics{AppName: app, Timestamp: uint64(now.Unix()), Cpu: 0.5, Mem: 8192})
+	assert.Equal(t, float64(4), testutil.ToFloat64(s.metrics.ingestedSamples.WithLabelValues(ingestFormatRemoteWrite, "success")))
+
+	// 无法解析的请求
+	for _, body := range [][]byte{
+		[]byte("not snappy"),
+		snappy.Encode(nil, []byte{0xff}),
+		// 声明的解压后长度超过上限
+		{0x80, 0x80, 0x80, 0x80, 0x04},
+	} {
+		assert.Equal(t, http.StatusBadRequest, postIngest(handler, RemoteWritePath, body).Code)
+	}
+
+	// 不合法的样本逐个丢弃，同一请求中的其他样本照常保存
+	recorder = postIngest(handler, RemoteWritePath, remoteWriteBody(t,
+		remoteWriteSeries(RemoteWriteCpuMetric, "web", now.Add(time.Hour), 1),
+		remoteWriteSeries(RemoteWriteCpuMetric, "", now, 1),
+		remoteWriteSeries(RemoteWriteMemMetric, "web", now, -1),
+		remoteWriteSeries(RemoteWriteCpuMetric, "web", now.Add(-2*time.Minute), 1),
+		remoteWriteSeries(RemoteWriteMemMetric, "web", now.Add(-2*time.Minute), 1024),
+	))
+	assert.Equal(t, http.StatusNoContent, recorder.Code, recorder.Body.String())
+	assert.Contains(t, queryIngestedMetrics(t, s), &server.AppPodMetrics{AppName: app, Timestamp: uint64(now.Add(-2 * time.Minute).Unix()), Cpu: 1, Mem: 1024})
+	assert.Equal(t, float64(3), testutil.ToFloat64(s.metrics.ingestedSamples.WithLabelValues(ingestFormatRemoteWrite, ingestResultInvalid)))
+	assert.Equal(t, float64(6), testutil.ToFloat64(s.metrics.ingestedSamples.WithLabelValues(ingestFormatRemoteWrite, "success")))
+	assert.Equal(t, 0, len(s.remoteWritePairer.pending))
+}
+
+func TestRemoteWritePairer(t *testing.T) {
+	p := newRemoteWritePairer()
+	now := time.Now()
+	cpu := func() map[string]*remoteWriteHalf {
+		return map[string]*remoteWriteHalf{"a@1": {metrics: server.AppPodMetrics{Cpu: 1}, hasCpu: true}}
+	}
+	mem := map[string]*remoteWriteHalf{"a@1": {metrics: server.AppPodMetrics{Mem: 2}, hasMem: true}}
+	save := func(metrics []*server.AppPodMetrics) error {
+		return nil
+	}
+	n, _, err := p.pairAndSave(cpu(), now, save)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// 保存失败时保留等待中的样本
+	_, _, err = p.pairAndSave(mem, now, func(metrics []*server.AppPodMetrics) error {
+		return assert.AnError
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, len(p.pending))
+	var saved []*server.AppPodMetrics
+	n, _, err = p.pairAndSave(mem, now, func(metrics []*server.AppPodMetrics) error {
+		saved = metrics
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []*server.AppPodMetrics{{Cpu: 1, Mem: 2}}, saved)
+	assert.Equal(t, 0, len(p.pending))
+
+	// 超时未配对的样本被丢弃
+	_, _, _ = p.pairAndSave(cpu(), now, save)
+	_, _, _ = p.pairAndSave(nil, now.Add(remoteWritePairTimeout+time.Second), save)
+	assert.Equal(t, 0, len(p.pending))
+}
+
+func TestRemoteWritePairer_SaveWithoutLock(t *testing.T) {
+	p := newRemoteWritePairer()
+	now := time.Now()
+	_, _, _ = p.pairAndSave(map[string]*remoteWriteHalf{"a@1": {hasCpu: true}}, now, nil)
+
+	// 保存期间其他请求可以配对
+	saving := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, _, err := p.pairAndSave(map[string]*remoteWriteHalf{"a@1": {hasMem: true}}, now,
+			func(metrics []*server.AppPodMetrics) error {
+				close(saving)
+				<-release
+				return nil
+			})
+		done <- err
+	}()
+	<-saving
+	n, _, err := p.pairAndSave(map[string]*remoteWriteHalf{"b@1": {hasCpu: true, hasMem: true}}, now,
+		func(metrics []*server.AppPodMetrics) error {
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 0, len(p.pending))
+}
+
+func TestRemoteWritePairer_MaxPending(t *testing.T) {
+	p := newRemoteWritePairer()
+	now := time.Now()
+	for i := 0; i < maxRemoteWritePending; i++ {
+		p.pending[strconv.Itoa(i)] = &remoteWriteHalf{hasCpu: true, received: now}
+	}
+	save := func(metrics []*server.AppPodMetrics) error {
+		return nil
+	}
+
+	// 超过上限时丢弃新的未配对样本
+	n, dropped, err := p.pairAndSave(map[string]*remoteWriteHalf{"new@1": {hasCpu: true}}, now, save)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 1, dropped)
+	_, ok := p.pending["new@1"]
+	assert.False(t, ok)
+
+	// 已在等待的样本仍可以配对
+	n, dropped, err = p.pairAndSave(map[string]*remoteWriteHalf{"0": {hasMem: true}}, now, save)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, maxRemoteWritePending-1, len(p.pending))
+}
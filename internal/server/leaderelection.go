@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const KubeApiServerProxyUrl = "http://localhost:8001"
+
+const (
+	DefaultLeaderElectionNamespace = "workload-classifier"
+	DefaultLeaderElectionName      = "workload-classifier"
+	DefaultLeaseDuration           = 15 * time.Second
+	DefaultRenewDeadline           = 10 * time.Second
+	DefaultRetryPeriod             = 2 * time.Second
+)
+
+type leaderElection struct {
+	lock          resourcelock.Interface
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+}
+
+// 优先使用集群内的配置，失败时使用kubectl proxy访问api server
+func newKubernetesClientSet() (kubernetes.Interface, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		restConfig = &rest.Config{Host: KubeApiServerProxyUrl}
+	}
+	clientSet, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "创建Kubernetes客户端出错")
+	}
+	return clientSet, nil
+}
+
+// 使用Kubernetes的Lease作为锁
+func newLeaseLock(config *ServerConfig) (resourcelock.Interface, error) {
+	clientSet, err := newKubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+
+	return &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      config.LeaderElectionName,
+			Namespace: config.LeaderElectionNamespace,
+		},
+		Client: clientSet.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: config.LeaderElectionIdentity,
+		},
+	}, nil
+}
+
+func defaultLeaderElectionIdentity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s_%d", hostname, os.Getpid())
+}
+
+func (s *serverImpl) IsLeader() bool {
+	return atomic.LoadInt32(&s.leader) == 1
+}
+
+func (s *serverImpl) setLeader(leader bool) {
+	if leader {
+		atomic.StoreInt32(&s.leader, 1)
+	} else {
+		atomic.StoreInt32(&s.leader, 0)
+	}
+}
+
+// 仅在成为leader时执行run，run返回后才会释放锁，保证同一时间只有一个实例在执行run。失去leader身份后将会重新参与选举，
+// 直到ctx结束。没有配置选举时，直接执行run。
+// 传给run的第一个context在ctx结束或失去leader身份时结束，第二个context在abortCtx结束或失去leader身份时结束。
+// 因此只有正常退出时正在进行的工作才能在宽限期内完成，失去leader身份时立即中止，避免与新的leader同时写入
+func (s *serverImpl) runWithLeaderElection(ctx, abortCtx context.Context, run func(ctx, abortCtx context.Context)) {
+	if s.election == nil {
+		s.setLeader(true)
+		run(ctx, abortCtx)
+		s.setLeader(false)
+		return
+	}
+
+	for ctx.Err() == nil {
+		s.runElection(ctx, abortCtx, run)
+	}
+}
+
+func (s *serverImpl) runElection(ctx, abortCtx context.Context, run func(ctx, abortCtx context.Context)) {
+	// 选举使用单独的context，使得退出时能够在run结束后才释放锁
+	electionCtx, cancelElection := context.WithCancel(context.Background())
+	defer cancelElection()
+
+	lock := sync.Mutex{}
+	leading := false
+	shuttingDown := false
+	drained := make(chan struct{})
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            s.election.lock,
+		LeaseDuration:   s.election.leaseDuration,
+		RenewDeadline:   s.election.renewDeadline,
+		RetryPeriod:     s.election.retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            s.election.lock.Describe(),
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				defer close(drained)
+				lock.Lock()
+				if shuttingDown {
+					lock.Unlock()
+					return
+				}
+				leading = true
+				lock.Unlock()
+
+				s.logger.Printf("%s成为leader，启动监控数据获取与再聚类线程\n", s.election.lock.Identity())
+				runCtx, cancel := context.WithCancel(leaderCtx)
+				go func() {
+					select {
+					case <-ctx.Done():
+					case <-runCtx.Done():
+					}
+					cancel()
+				}()
+				// leaderCtx在失去leader身份时结束，此时不等待宽限期
+				runAbortCtx, abort := context.WithCancel(leaderCtx)
+				go func() {
+					select {
+					case <-abortCtx.Done():
+					case <-runAbortCtx.Done():
+					}
+					abort()
+				}()
+				s.setLeader(true)
+				run(runCtx, runAbortCtx)
+				s.setLeader(false)
+				cancel()
+				abort()
+				s.logger.Println("监控数据获取与再聚类线程已结束，释放leader身份")
+				cancelElection()
+			},
+			OnStoppedLeading: func() {
+				s.logger.Printf("%s不再参与本轮选举\n", s.election.lock.Identity())
+			},
+			OnNewLeader: func(identity string) {
+				s.logger.Printf("当前leader为%s\n", identity)
+			},
+		},
+	})
+	if err != nil {
+		panic(errors.Wrap(err, "创建选举器出错"))
+	}
+
+	go func() {
+		select {
+		case <-electionCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+		lock.Lock()
+		shuttingDown = true
+		isLeading := leading
+		lock.Unlock()
+		// 正在作为leader运行时，由OnStartedLeading在run结束后结束选举
+		if !isLeading {
+			cancelElection()
+		}
+	}()
+
+	elector.Run(electionCtx)
+
+	// 选举只会在electionCtx结束时放弃获取锁，因此electionCtx未结束时说明曾经获取过锁，而后失去了leader身份。
+	// 此时需要等待run结束，才能重新参与选举
+	if electionCtx.Err() == nil {
+		<-drained
+	}
+}
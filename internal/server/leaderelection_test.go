@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 多个memoryLock共享的锁记录，模拟api server中的Lease
+type memoryLockStore struct {
+	lock    sync.Mutex
+	record  *resourcelock.LeaderElectionRecord
+	version int
+}
+
+type memoryLock struct {
+	store    *memoryLockStore
+	identity string
+}
+
+var _ resourcelock.Interface = &memoryLock{}
+
+func (m *memoryLock) Get(_ context.Context) (*resourcelock.LeaderElectionRecord, []byte, error) {
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+	if m.store.record == nil {
+		return nil, nil, apierrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, "test")
+	}
+	record := *m.store.record
+	raw, _ := json.Marshal(struct {
+		Record  resourcelock.LeaderElectionRecord
+		Version int
+	}{record, m.store.version})
+	return &record, raw, nil
+}
+
+func (m *memoryLock) Create(_ context.Context, ler resourcelock.LeaderElectionRecord) error {
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+	if m.store.record != nil {
+		return apierrors.NewAlreadyExists(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, "test")
+	}
+	m.store.record = &ler
+	m.store.version++
+	return nil
+}
+
+func (m *memoryLock) Update(_ context.Context, ler resourcelock.LeaderElectionRecord) error {
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+	m.store.record = &ler
+	m.store.version++
+	return nil
+}
+
+func (m *memoryLock) RecordEvent(string) {
+}
+
+func (m *memoryLock) Identity() string {
+	return m.identity
+}
+
+func (m *memoryLock) Describe() string {
+	return "memory/test"
+}
+
+func newElectionTestServer(store *memoryLockStore, identity string) *serverImpl {
+	return &serverImpl{
+		config: &ServerConfig{},
+		logger: log.New(os.Stdout, identity+": ", log.LstdFlags),
+		election: &leaderElection{
+			lock:          &memoryLock{store: store, identity: identity},
+			leaseDuration: time.Second,
+			renewDeadline: 500 * time.Millisecond,
+			retryPeriod:   100 * time.Millisecond,
+		},
+	}
+}
+
+func TestRunWithLeaderElection(t *testing.T) {
+	store := &memoryLockStore{}
+	running := int32(0)
+	maxRunning := int32(0)
+	started := make(chan string, 10)
+	run := func(identity string) func(ctx, abortCtx context.Context) {
+		return func(ctx, abortCtx context.Context) {
+			n := atomic.AddInt32(&running, 1)
+			if n > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, n)
+			}
+			started <- identity
+			<-ctx.Done()
+			// 模拟清理工作耗时，交接时另一个实例不应该在此期间开始运行
+			time.Sleep(200 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}
+	}
+
+	s1 := newElectionTestServer(store, "s1")
+	s2 := newElectionTestServer(store, "s2")
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	done1 := make(chan struct{})
+	done2 := make(chan struct{})
+	go func() {
+		s1.runWithLeaderElection(ctx1, context.Background(), run("s1"))
+		close(done1)
+	}()
+	go func() {
+		s2.runWithLeaderElection(ctx2, context.Background(), run("s2"))
+		close(done2)
+	}()
+
+	var leader string
+	select {
+	case leader = <-started:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "没有实例成为leader")
+	}
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&running))
+	if leader == "s1" {
+		assert.True(t, s1.IsLeader())
+		assert.False(t, s2.IsLeader())
+	} else {
+		assert.True(t, s2.IsLeader())
+		assert.False(t, s1.IsLeader())
+	}
+
+	// 关闭leader，另一个实例接管
+	followerDone := done2
+	leaderDone := done1
+	cancelLeader, cancelFollower := cancel1, cancel2
+	if leader == "s2" {
+		followerDone, leaderDone = done1, done2
+		cancelLeader, cancelFollower = cancel2, cancel1
+	}
+	cancelLeader()
+	select {
+	case <-leaderDone:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "leader没有退出")
+	}
+
+	select {
+	case next := <-started:
+		assert.NotEqual(t, leader, next)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "leader退出后没有实例接管")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
+
+	cancelFollower()
+	select {
+	case <-followerDone:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "实例没有退出")
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&running))
+	// 正常退出时会释放锁
+	assert.Equal(t, "", store.record.HolderIdentity)
+}
+
+func TestRunWithLeaderElection_NoElection(t *testing.T) {
+	s := &serverImpl{}
+	ctx, cancel := context.WithCancel(context.Background())
+	leading := make(chan bool)
+	go s.runWithLeaderElection(ctx, context.Background(), func(ctx, abortCtx context.Context) {
+		leading <- s.IsLeader()
+		<-ctx.Done()
+	})
+	assert.True(t, <-leading)
+	cancel()
+}
+
+// 事务开始后阻塞直到ctx结束，用于模拟耗时的再聚类事务
+type blockingTransactionDao struct {
+	Dao
+	ctx     context.Context
+	entered chan struct{}
+}
+
+func (d *blockingTransactionDao) WithContext(ctx context.Context) Dao {
+	return &blockingTransactionDao{Dao: d.Dao.WithContext(ctx), ctx: ctx, entered: d.entered}
+}
+
+func (d *blockingTransactionDao) Transaction(fc func(tx Dao) error) error {
+	close(d.entered)
+	<-d.ctx.Done()
+	return d.ctx.Err()
+}
+
+func TestRunWithLeaderElection_LeaseLost(t *testing.T) {
+	store := &memoryLockStore{}
+	memoryDao := NewMemoryDao()
+	saveTestPatternCenters(t, memoryDao)
+	yesterday := uint64(time.Now().Unix())/core.DayLength - 1
+	for i := 0; i < 4; i++ {
+		appName := server.AppName{Name: fmt.Sprintf("app-%d", i), Namespace: "test"}
+		assert.NoError(t, memoryDao.SaveAllAppPodMetrics(workloadPatternMetrics(appName, i%2, yesterday, 1, core.NumSections, 2)))
+	}
+
+	s := newElectionTestServer(store, "s1")
+	s.config = &ServerConfig{
+		MetricDuration:    7 * 24 * time.Hour,
+		ReClusterInterval: time.Hour,
+		NumClass:          2,
+		NumRound:          DefaultNumRound,
+	}
+	s.schedule, s.scheduleSpec, s.location, _ = buildReClusterSchedule(s.config)
+	s.metrics = newServerMetrics()
+	s.executeReCluster = make(chan struct{})
+	entered := make(chan struct{})
+	s.dao = &blockingTransactionDao{Dao: memoryDao, ctx: context.Background(), entered: entered}
+
+	// 正常退出的宽限期不会结束，只有失去leader身份才能中止再聚类
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reClusterDone := make(chan struct{})
+	go s.runWithLeaderElection(ctx, context.Background(), func(ctx, abortCtx context.Context) {
+		s.reClusterer(ctx, abortCtx)
+		select {
+		case <-reClusterDone:
+		default:
+			close(reClusterDone)
+		}
+	})
+
+	assert.Eventually(t, func() bool {
+		return s.ReCluster() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "再聚类没有开始保存结果")
+	}
+
+	// 其他副本抢占了锁，本实例续约失败后失去leader身份
+	store.lock.Lock()
+	now := metav1.Now()
+	store.record = &resourcelock.LeaderElectionRecord{
+		HolderIdentity:       "s2",
+		LeaseDurationSeconds: 60,
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+	store.version++
+	store.lock.Unlock()
+
+	select {
+	case <-reClusterDone:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "失去leader身份后再聚类没有中止")
+	}
+	assert.False(t, s.IsLeader())
+	classes, err := memoryDao.QueryClassMemberCount()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(classes))
+}
@@ -0,0 +1,872 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errMemoryDaoClosed = fmt.Errorf("内存存储已关闭")
+
+type memoryPodMetrics struct {
+	seq       uint // 写入顺序，相当于数据库中的自增ID
+	appId     uint
+	timestamp uint64
+	cpu       float32
+	mem       float32
+}
+
+type podMetricsKey struct {
+	appId     uint
+	timestamp uint64
+}
+
+type memoryClassMetrics struct {
+	data      []*core.SectionData
+	updatedAt time.Time
+}
+
+// 内存存储的全部数据
+type memoryData struct {
+	nextAppId    uint
+	appIds       map[server.AppName]uint
+	appNames     map[uint]server.AppName
+	nextSeq      uint
+	podMetrics   map[podMetricsKey]*memoryPodMetrics
+	podOrder     []*memoryPodMetrics // 按seq递增排列
+	rollups      map[rollupKey]*sectionRollup
+	classMetrics map[uint]*memoryClassMetrics
+	appClasses   map[uint]*server.AppClass // 键为AppID
+	appPins      map[uint]*server.AppPin   // 键为AppID
+	pinAudits    []*server.AppPinAudit     // 按写入顺序排列
+	classHistory []*server.AppClassHistory // 按写入顺序排列
+	nextVersion  uint64                    // 下一条分类变化的ResourceVersion，相当于数据库中的自增ID
+	events       []*memoryEvent            // 按ResourceVersion递增排列
+}
+
+type memoryEvent struct {
+	event     *server.ClassificationEvent
+	createdAt time.Time
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		nextAppId:    1,
+		appIds:       make(map[server.AppName]uint),
+		appNames:     make(map[uint]server.AppName),
+		nextSeq:      1,
+		podMetrics:   make(map[podMetricsKey]*memoryPodMetrics),
+		podOrder:     make([]*memoryPodMetrics, 0),
+		rollups:      make(map[rollupKey]*sectionRollup),
+		classMetrics: make(map[uint]*memoryClassMetrics),
+		appClasses:   make(map[uint]*server.AppClass),
+		appPins:      make(map[uint]*server.AppPin),
+		pinAudits:    make([]*server.AppPinAudit, 0),
+		classHistory: make([]*server.AppClassHistory, 0),
+		nextVersion:  1,
+		events:       make([]*memoryEvent, 0),
+	}
+}
+
+// 深拷贝，用于事务
+func (m *memoryData) clone() *memoryData {
+	c := newMemoryData()
+	c.nextAppId = m.nextAppId
+	c.nextSeq = m.nextSeq
+	for appName, id := range m.appIds {
+		c.appIds[appName] = id
+		c.appNames[id] = appName
+	}
+	c.podOrder = make([]*memoryPodMetrics, len(m.podOrder))
+	for i, record := range m.podOrder {
+		copied := *record
+		c.podOrder[i] = &copied
+		c.podMetrics[podMetricsKey{appId: copied.appId, timestamp: copied.timestamp}] = &copied
+	}
+	for key, r := range m.rollups {
+		c.rollups[key] = r.clone()
+	}
+	for classId, metrics := range m.classMetrics {
+		c.classMetrics[classId] = &memoryClassMetrics{data: copySectionData(metrics.data), updatedAt: metrics.updatedAt}
+	}
+	for appId, class := range m.appClasses {
+		c.appClasses[appId] = copyAppClass(class)
+	}
+	for appId, pin := range m.appPins {
+		c.appPins[appId] = copyAppPin(pin)
+	}
+	// 审计记录与分类历史只会追加，不会修改
+	c.pinAudits = append(c.pinAudits, m.pinAudits...)
+	c.classHistory = append(c.classHistory, m.classHistory...)
+	c.nextVersion = m.nextVersion
+	c.events = append(c.events, m.events...)
+	return c
+}
+
+// 追加一条分类变化记录，event中的对象会被复制
+func (m *memoryData) recordEvent(event *server.ClassificationEvent) {
+	copied := copyClassificationEvent(event)
+	copied.ResourceVersion = m.nextVersion
+	m.nextVersion++
+	m.events = append(m.events, &memoryEvent{event: copied, createdAt: time.Now()})
+}
+
+func (m *memoryData) appId(appName *server.AppName, createIfNil bool) (uint, error) {
+	if id, ok := m.appIds[*appName]; ok {
+		return id, nil
+	}
+	if !createIfNil {
+		return 0, server.ErrAppNotFound
+	}
+	id := m.nextAppId
+	m.nextAppId++
+	m.appIds[*appName] = id
+	m.appNames[id] = *appName
+	return id, nil
+}
+
+func copySectionData(data []*core.SectionData) []*core.SectionData {
+	result := make([]*core.SectionData, len(data))
+	for i, datum := range data {
+		if datum != nil {
+			copied := *datum
+			result[i] = &copied
+		}
+	}
+	return result
+}
+
+func copyAppClass(class *server.AppClass) *server.AppClass {
+	copied := *class
+	if class.Confidence != nil {
+		confidence := *class.Confidence
+		copied.Confidence = &confidence
+	}
+	return &copied
+}
+
+func copyClassificationEvent(event *server.ClassificationEvent) *server.ClassificationEvent {
+	copied := *event
+	if event.ClassMetrics != nil {
+		copied.ClassMetrics = &server.ClassMetrics{
+			ClassId: event.ClassMetrics.ClassId,
+			Data:    copySectionData(event.ClassMetrics.Data),
+		}
+	}
+	if event.AppAssignment != nil {
+		assignment := *event.AppAssignment
+		if assignment.Confidence != nil {
+			confidence := *assignment.Confidence
+			assignment.Confidence = &confidence
+		}
+		copied.AppAssignment = &assignment
+	}
+	if event.AppPin != nil {
+		copied.AppPin = copyAppPin(event.AppPin)
+	}
+	return &copied
+}
+
+func copyAppPin(pin *server.AppPin) *server.AppPin {
+	copied := *pin
+	if pin.Profile != nil {
+		copied.Profile = copySectionData(pin.Profile)
+	}
+	return &copied
+}
+
+// 所有memoryDao共享的存储
+type memoryStore struct {
+	lock   sync.RWMutex
+	data   *memoryData
+	closed bool
+}
+
+// 将所有数据保存在内存中的Dao，语义与daoImpl相同，用于测试与演示，进程退出后数据丢失。
+// 事务在数据副本上执行，提交时替换原数据。事务执行期间会阻塞其他的读写操作，因此事务中只能使用tx进行操作
+type memoryDao struct {
+	store  *memoryStore
+	tx     *memoryData // 事务中的数据副本，不在事务中时为nil
+	ctx    context.Context
+	logger *log.Logger
+}
+
+var _ Dao = &memoryDao{}
+
+func NewMemoryDao() Dao {
+	return &memoryDao{
+		store:  &memoryStore{data: newMemoryData()},
+		ctx:    context.Background(),
+		logger: log.New(os.Stdout, "MemoryDao: ", log.LstdFlags|log.Lshortfile|log.Lmsgprefix),
+	}
+}
+
+func (d *memoryDao) check() error {
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+	if d.tx == nil && d.store.closed {
+		return errMemoryDaoClosed
+	}
+	return nil
+}
+
+func (d *memoryDao) read(fc func(data *memoryData) error) error {
+	if d.tx != nil {
+		if err := d.check(); err != nil {
+			return err
+		}
+		return fc(d.tx)
+	}
+	d.store.lock.RLock()
+	defer d.store.lock.RUnlock()
+	if err := d.check(); err != nil {
+		return err
+	}
+	return fc(d.store.data)
+}
+
+// fc需要在修改数据前完成所有检查，保证出错时数据不被修改
+func (d *memoryDao) write(fc func(data *memoryData) error) error {
+	if d.tx != nil {
+		if err := d.check(); err != nil {
+			return err
+		}
+		return fc(d.tx)
+	}
+	d.store.lock.Lock()
+	defer d.store.lock.Unlock()
+	if err := d.check(); err != nil {
+		return err
+	}
+	return fc(d.store.data)
+}
+
+func (d *memoryDao) SaveClassMetrics(c *server.ClassMetrics) error {
+	if c.ClassId == 0 {
+		return fmt.Errorf("ClassId不能为0")
+	}
+	d.logger.Printf("正在插入ClassID为%d的ClassMetrics", c.ClassId)
+
+	return d.write(func(data *memoryData) error {
+		existing, ok := data.classMetrics[c.ClassId]
+		if !ok {
+			existing = &memoryClassMetrics{data: make([]*core.SectionData, 0, len(c.Data))}
+			data.classMetrics[c.ClassId] = existing
+		}
+		// 与数据库相同，按SectionNum覆盖已有的数据
+		for i, datum := range c.Data {
+			copied := *datum
+			if i < len(existing.data) {
+				existing.data[i] = &copied
+			} else {
+				existing.data = append(existing.data, &copied)
+			}
+		}
+		existing.updatedAt = time.Now()
+		data.recordEvent(&server.ClassificationEvent{
+			Type:         server.EventTypeModified,
+			Kind:         server.EventKindClassMetrics,
+			ClassMetrics: &server.ClassMetrics{ClassId: c.ClassId, Data: existing.data},
+		})
+		return nil
+	})
+}
+
+func (d *memoryDao) SaveAppClass(a *server.AppClass) error {
+	return d.write(func(data *memoryData) error {
+		appId, _ := data.appId(&a.AppName, true)
+		data.appClasses[appId] = copyAppClass(a)
+		data.recordEvent(&server.ClassificationEvent{
+			Type:          server.EventTypeModified,
+			Kind:          server.EventKindAppAssignment,
+			AppAssignment: server.NewAppAssignment(a),
+		})
+		return nil
+	})
+}
+
+func (d *memoryDao) RemoveAppClass(appName *server.AppName) error {
+	return d.write(func(data *memoryData) error {
+		appId, err := data.appId(appName, false)
+		if err != nil {
+			return nil
+		}
+		if _, ok := data.appClasses[appId]; !ok {
+			return nil
+		}
+		delete(data.appClasses, appId)
+		data.recordEvent(&server.ClassificationEvent{
+			Type:          server.EventTypeDeleted,
+			Kind:          server.EventKindAppAssignment,
+			AppAssignment: &server.AppAssignment{AppName: *appName},
+		})
+		return nil
+	})
+}
+
+func (d *memoryDao) SaveAllAppPodMetrics(arr []*server.AppPodMetrics) error {
+	if len(arr) == 0 {
+		return nil
+	}
+
+	return d.write(func(data *memoryData) error {
+		// 同一批数据中应用与时间戳相同的数据只保留最后一条
+		index := make(map[podMetricsKey]int, len(arr))
+		records := make([]*AppPodMetricsDO, 0, len(arr))
+		for _, metrics := range arr {
+			appId, _ := data.appId(&metrics.AppName, true)
+			do := &AppPodMetricsDO{
+				AppId:     appId,
+				Timestamp: metrics.Timestamp,
+				Cpu:       metrics.Cpu,
+				Mem:       metrics.Mem,
+			}
+			key := podMetricsKey{appId: appId, timestamp: metrics.Timestamp}
+			if i, ok := index[key]; ok {
+				records[i] = do
+			} else {
+				index[key] = len(records)
+				records = append(records, do)
+			}
+		}
+
+		for _, do := range records {
+			key := podMetricsKey{appId: do.AppId, timestamp: do.Timestamp}
+			if record, ok := data.podMetrics[key]; ok {
+				record.cpu = do.Cpu
+				record.mem = do.Mem
+				continue
+			}
+			record := &memoryPodMetrics{
+				seq:       data.nextSeq,
+				appId:     do.AppId,
+				timestamp: do.Timestamp,
+				cpu:       do.Cpu,
+				mem:       do.Mem,
+			}
+			data.nextSeq++
+			data.podMetrics[key] = record
+			data.podOrder = append(data.podOrder, record)
+		}
+
+		changed, stale := rollupAppPodMetrics(data.rollups, records)
+		if len(stale) > 0 {
+			rebuildSectionRollups(data.rollups, changed, stale, data.sectionRecords(stale))
+		}
+		return nil
+	})
+}
+
+// 返回stale中各Section的全部原始数据
+func (data *memoryData) sectionRecords(stale map[rollupKey]struct{}) []*AppPodMetricsDO {
+	result := make([]*AppPodMetricsDO, 0)
+	for key := range stale {
+		start, end := key.timeRange()
+		for timestamp := start; timestamp < end; timestamp++ {
+			if record, ok := data.podMetrics[podMetricsKey{appId: key.appId, timestamp: timestamp}]; ok {
+				result = append(result, &AppPodMetricsDO{
+					AppId:     record.appId,
+					Timestamp: record.timestamp,
+					Cpu:       record.cpu,
+					Mem:       record.mem,
+				})
+			}
+		}
+	}
+	return result
+}
+
+func (d *memoryDao) RemoveAppPodMetricsBefore(timestamp uint64) error {
+	return d.write(func(data *memoryData) error {
+		kept := make([]*memoryPodMetrics, 0, len(data.podOrder))
+		for _, record := range data.podOrder {
+			if record.timestamp < timestamp {
+				delete(data.podMetrics, podMetricsKey{appId: record.appId, timestamp: record.timestamp})
+			} else {
+				kept = append(kept, record)
+			}
+		}
+		data.podOrder = kept
+
+		for key := range data.rollups {
+			if key.day < timestamp/core.DayLength {
+				delete(data.rollups, key)
+			}
+		}
+		return nil
+	})
+}
+
+func (d *memoryDao) BackfillSectionRollups() (int, error) {
+	count := 0
+	err := d.write(func(data *memoryData) error {
+		hasRollup := make(map[uint]struct{})
+		for key := range data.rollups {
+			hasRollup[key.appId] = struct{}{}
+		}
+		missing := make(map[uint][]*AppPodMetricsDO)
+		for _, record := range data.podOrder {
+			if _, ok := hasRollup[record.appId]; ok {
+				continue
+			}
+			missing[record.appId] = append(missing[record.appId], &AppPodMetricsDO{
+				AppId:     record.appId,
+				Timestamp: record.timestamp,
+				Cpu:       record.cpu,
+				Mem:       record.mem,
+			})
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		d.logger.Printf("正在为%d个应用回填汇总数据\n", len(missing))
+		for _, records := range missing {
+			rollupAppPodMetrics(data.rollups, records)
+		}
+		count = len(missing)
+		return nil
+	})
+	return count, err
+}
+
+func (d *memoryDao) RemoveAllClassMetrics() error {
+	return d.write(func(data *memoryData) error {
+		classIds := make([]uint, 0, len(data.classMetrics))
+		for classId := range data.classMetrics {
+			classIds = append(classIds, classId)
+		}
+		sort.Slice(classIds, func(i, j int) bool {
+			return classIds[i] < classIds[j]
+		})
+		for _, classId := range classIds {
+			data.recordEvent(&server.ClassificationEvent{
+				Type:         server.EventTypeDeleted,
+				Kind:         server.EventKindClassMetrics,
+				ClassMetrics: &server.ClassMetrics{ClassId: classId},
+			})
+		}
+		data.classMetrics = make(map[uint]*memoryClassMetrics)
+		return nil
+	})
+}
+
+func (d *memoryDao) QueryClassMetricsByClassId(classId uint) (*server.ClassMetrics, error) {
+	var result *server.ClassMetrics
+	err := d.read(func(data *memoryData) error {
+		metrics, ok := data.classMetrics[classId]
+		if !ok {
+			return server.ErrClassNotFound
+		}
+		if len(metrics.data) != core.NumSections {
+			return fmt.Errorf("ClassID为%d的数据不是%d个", classId, core.NumSections)
+		}
+		result = &server.ClassMetrics{
+			ClassId: classId,
+			Data:    copySectionData(metrics.data),
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (d *memoryDao) QueryAllClassMetrics() ([]*server.ClassMetrics, error) {
+	var result []*server.ClassMetrics
+	err := d.read(func(data *memoryData) error {
+		result = make([]*server.ClassMetrics, 0, len(data.classMetrics))
+		for classId, metrics := range data.classMetrics {
+			c := &server.ClassMetrics{
+				ClassId: classId,
+				Data:    make([]*core.SectionData, core.NumSections),
+			}
+			copy(c.Data, copySectionData(metrics.data))
+			result = append(result, c)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (d *memoryDao) QueryAppClassByApp(appName *server.AppName) (*server.AppClass, error) {
+	var result *server.AppClass
+	err := d.read(func(data *memoryData) error {
+		appId, err := data.appId(appName, false)
+		if err != nil {
+			return err
+		}
+		class, ok := data.appClasses[appId]
+		if !ok {
+			return server.ErrAppNotClassified
+		}
+		result = copyAppClass(class)
+		return nil
+	})
+	return result, err
+}
+
+func (d *memoryDao) QueryClassMemberCount() (map[uint]int, error) {
+	var result map[uint]int
+	err := d.read(func(data *memoryData) error {
+		result = make(map[uint]int)
+		for _, class := range data.appClasses {
+			result[class.ClassId]++
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (d *memoryDao) QueryLastClassifyTime() (time.Time, error) {
+	var result time.Time
+	err := d.read(func(data *memoryData) error {
+		for _, metrics := range data.classMetrics {
+			if metrics.updatedAt.After(result) {
+				result = metrics.updatedAt
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (d *memoryDao) QueryAppPodMetricsPage(cursor uint, limit int) ([]*server.AppPodMetrics, uint, error) {
+	var result []*server.AppPodMetrics
+	next := cursor
+	err := d.read(func(data *memoryData) error {
+		start := sort.Search(len(data.podOrder), func(i int) bool {
+			return data.podOrder[i].seq > cursor
+		})
+		end := start + limit
+		if end > len(data.podOrder) {
+			end = len(data.podOrder)
+		}
+		result = make([]*server.AppPodMetrics, 0, end-start)
+		for _, record := range data.podOrder[start:end] {
+			result = append(result, &server.AppPodMetrics{
+				AppName:   data.appNames[record.appId],
+				Timestamp: record.timestamp,
+				Cpu:       record.cpu,
+				Mem:       record.mem,
+			})
+			next = record.seq
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, cursor, err
+	}
+	return result, next, nil
+}
+
+func (d *memoryDao) ForEachAppClass(fc func(class *server.AppClass) error) error {
+	var classes []*server.AppClass
+	err := d.read(func(data *memoryData) error {
+		classes = make([]*server.AppClass, 0, len(data.appClasses))
+		for _, class := range data.appClasses {
+			classes = append(classes, copyAppClass(class))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, class := range classes {
+		if err := fc(class); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *memoryDao) ForEachAppSectionRollup(fromDay uint64, fc func(appName server.AppName, sections []*sectionRollup) error) error {
+	sections := make(map[uint][]*sectionRollup)
+	appNames := make(map[uint]server.AppName)
+	// 在锁外调用fc，避免fc中访问Dao时死锁
+	err := d.read(func(data *memoryData) error {
+		for key, r := range data.rollups {
+			if key.day < fromDay {
+				continue
+			}
+			arr, ok := sections[key.appId]
+			if !ok {
+				arr = make([]*sectionRollup, core.NumSections)
+				sections[key.appId] = arr
+				appNames[key.appId] = data.appNames[key.appId]
+			}
+			if arr[key.sectionNum] == nil {
+				arr[key.sectionNum] = r.clone()
+			} else {
+				arr[key.sectionNum].merge(r)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	appIds := make([]uint, 0, len(sections))
+	for appId := range sections {
+		appIds = append(appIds, appId)
+	}
+	sort.Slice(appIds, func(i, j int) bool {
+		return appIds[i] < appIds[j]
+	})
+	for _, appId := range appIds {
+		if err := fc(appNames[appId], sections[appId]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *memoryDao) QueryAppSectionRollup(appName *server.AppName, fromDay uint64) ([]*sectionRollup, error) {
+	sections := make([]*sectionRollup, core.NumSections)
+	err := d.read(func(data *memoryData) error {
+		appId, err := data.appId(appName, false)
+		if err != nil {
+			return err
+		}
+		for key, r := range data.rollups {
+			if key.appId != appId || key.day < fromDay {
+				continue
+			}
+			if sections[key.sectionNum] == nil {
+				sections[key.sectionNum] = r.clone()
+			} else {
+				sections[key.sectionNum].merge(r)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+func (d *memoryDao) SaveAppPin(pin *server.AppPin) error {
+	return d.write(func(data *memoryData) error {
+		appId, _ := data.appId(&pin.AppName, true)
+		copied := copyAppPin(pin)
+		if copied.CreatedAt.IsZero() {
+			copied.CreatedAt = time.Now()
+		}
+		data.appPins[appId] = copied
+		data.recordEvent(&server.ClassificationEvent{
+			Type:   server.EventTypeModified,
+			Kind:   server.EventKindAppPin,
+			AppPin: copied,
+		})
+		return nil
+	})
+}
+
+func (d *memoryDao) RemoveAppPin(appName *server.AppName) error {
+	return d.write(func(data *memoryData) error {
+		appId, err := data.appId(appName, false)
+		if err != nil {
+			return server.ErrAppNotPinned
+		}
+		if _, ok := data.appPins[appId]; !ok {
+			return server.ErrAppNotPinned
+		}
+		delete(data.appPins, appId)
+		data.recordEvent(&server.ClassificationEvent{
+			Type:   server.EventTypeDeleted,
+			Kind:   server.EventKindAppPin,
+			AppPin: &server.AppPin{AppName: *appName},
+		})
+		return nil
+	})
+}
+
+func (d *memoryDao) SaveAppPinAudit(audit *server.AppPinAudit) error {
+	return d.write(func(data *memoryData) error {
+		copied := *audit
+		if copied.Time.IsZero() {
+			copied.Time = time.Now()
+		}
+		data.pinAudits = append(data.pinAudits, &copied)
+		return nil
+	})
+}
+
+func (d *memoryDao) QueryAppPin(appName *server.AppName) (*server.AppPin, error) {
+	var result *server.AppPin
+	err := d.read(func(data *memoryData) error {
+		appId, err := data.appId(appName, false)
+		if err != nil {
+			return server.ErrAppNotPinned
+		}
+		pin, ok := data.appPins[appId]
+		if !ok {
+			return server.ErrAppNotPinned
+		}
+		result = copyAppPin(pin)
+		return nil
+	})
+	return result, err
+}
+
+func (d *memoryDao) QueryAllAppPins() ([]*server.AppPin, error) {
+	var result []*server.AppPin
+	err := d.read(func(data *memoryData) error {
+		result = make([]*server.AppPin, 0, len(data.appPins))
+		for _, pin := range data.appPins {
+			result = append(result, copyAppPin(pin))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortAppPins(result)
+	return result, nil
+}
+
+func (d *memoryDao) QueryAppPinAudits(appName *server.AppName) ([]*server.AppPinAudit, error) {
+	var result []*server.AppPinAudit
+	err := d.read(func(data *memoryData) error {
+		result = make([]*server.AppPinAudit, 0)
+		for _, audit := range data.pinAudits {
+			if audit.AppName == *appName {
+				copied := *audit
+				result = append(result, &copied)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (d *memoryDao) SaveAppClassHistory(history []*server.AppClassHistory) error {
+	return d.write(func(data *memoryData) error {
+		for _, h := range history {
+			copied := *h
+			if copied.Confidence != nil {
+				confidence := *copied.Confidence
+				copied.Confidence = &confidence
+			}
+			data.classHistory = append(data.classHistory, &copied)
+		}
+		return nil
+	})
+}
+
+func (d *memoryDao) QueryAppClassHistory(appName *server.AppName) ([]*server.AppClassHistory, error) {
+	var result []*server.AppClassHistory
+	err := d.read(func(data *memoryData) error {
+		result = make([]*server.AppClassHistory, 0)
+		for _, h := range data.classHistory {
+			if h.AppName == *appName {
+				copied := *h
+				result = append(result, &copied)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (d *memoryDao) QueryClassificationEvents(afterVersion uint64, limit int) ([]*server.ClassificationEvent, error) {
+	var result []*server.ClassificationEvent
+	err := d.read(func(data *memoryData) error {
+		start := sort.Search(len(data.events), func(i int) bool {
+			return data.events[i].event.ResourceVersion > afterVersion
+		})
+		result = make([]*server.ClassificationEvent, 0)
+		for i := start; i < len(data.events) && len(result) < limit; i++ {
+			result = append(result, copyClassificationEvent(data.events[i].event))
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (d *memoryDao) QueryClassificationVersions() (uint64, uint64, error) {
+	var oldest, latest uint64
+	err := d.read(func(data *memoryData) error {
+		if len(data.events) > 0 {
+			oldest = data.events[0].event.ResourceVersion
+			latest = data.events[len(data.events)-1].event.ResourceVersion
+		}
+		return nil
+	})
+	return oldest, latest, err
+}
+
+func (d *memoryDao) RemoveClassificationEventsBefore(before time.Time) error {
+	return d.write(func(data *memoryData) error {
+		kept := make([]*memoryEvent, 0, len(data.events))
+		for i, event := range data.events {
+			if i == len(data.events)-1 || !event.createdAt.Before(before) {
+				kept = append(kept, event)
+			}
+		}
+		data.events = kept
+		return nil
+	})
+}
+
+func (d *memoryDao) WithContext(ctx context.Context) Dao {
+	c := *d
+	c.ctx = ctx
+	return &c
+}
+
+func (d *memoryDao) Transaction(fc func(tx Dao) error) error {
+	if d.tx != nil {
+		// 嵌套事务，相当于保存点
+		if err := d.check(); err != nil {
+			return err
+		}
+		clone := d.tx.clone()
+		tx := *d
+		tx.tx = clone
+		if err := fc(&tx); err != nil {
+			return err
+		}
+		*d.tx = *clone
+		return nil
+	}
+
+	d.store.lock.Lock()
+	defer d.store.lock.Unlock()
+	if err := d.check(); err != nil {
+		return err
+	}
+	tx := *d
+	tx.tx = d.store.data.clone()
+	if err := fc(&tx); err != nil {
+		return err
+	}
+	// 与数据库相同，ctx在提交前结束时回滚
+	if err := d.ctx.Err(); err != nil {
+		return errors.Wrap(err, "提交事务出错")
+	}
+	d.store.data = tx.tx
+	return nil
+}
+
+func (d *memoryDao) Ping() error {
+	return d.read(func(data *memoryData) error {
+		return nil
+	})
+}
+
+func (d *memoryDao) Close() error {
+	d.store.lock.Lock()
+	defer d.store.lock.Unlock()
+	d.store.closed = true
+	return nil
+}
@@ -0,0 +1,497 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Dao的各个实现需要满足的共同语义。daoImpl与memoryDao均使用此函数测试，保证两者行为一致
+func testDaoContract(t *testing.T, dao Dao) {
+	t.Run("AppPodMetrics", func(t *testing.T) {
+		appName := server.AppName{Name: "contract-metrics", Namespace: "contract"}
+		base := uint64(300 * core.DayLength)
+		assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+			{AppName: appName, Timestamp: base, Cpu: 1, Mem: 1},
+			{AppName: appName, Timestamp: base, Cpu: 2, Mem: 2},
+			{AppName: appName, Timestamp: base + core.DayLength, Cpu: 3, Mem: 3},
+		}))
+		// 已存在的数据被更新
+		assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+			{AppName: appName, Timestamp: base + core.DayLength, Cpu: 4, Mem: 4},
+		}))
+
+		found := make([]*server.AppPodMetrics, 0)
+		it := NewAppPodMetricsIterator(dao, 1)
+		for metrics, err := it.Next(); err == nil; metrics, err = it.Next() {
+			if metrics.AppName == appName {
+				found = append(found, metrics)
+			}
+		}
+		if assert.Equal(t, 2, len(found)) {
+			assert.Equal(t, &server.AppPodMetrics{AppName: appName, Timestamp: base, Cpu: 2, Mem: 2}, found[0])
+			assert.Equal(t, &server.AppPodMetrics{AppName: appName, Timestamp: base + core.DayLength, Cpu: 4, Mem: 4}, found[1])
+		}
+
+		// 汇总数据不会重复汇总已有时间戳的数据，更新的数据替换汇总中原来的数据
+		var sections []*sectionRollup
+		assert.NoError(t, dao.ForEachAppSectionRollup(base/core.DayLength, func(name server.AppName, s []*sectionRollup) error {
+			if name == appName {
+				sections = s
+			}
+			return nil
+		}))
+		if assert.Equal(t, core.NumSections, len(sections)) {
+			assert.Equal(t, uint64(2), sections[0].count)
+			assert.Equal(t, float64(6), sections[0].cpuSum)
+			assert.Equal(t, float64(4), sections[0].cpuMax)
+			assert.Nil(t, sections[1])
+		}
+
+		single, err := dao.QueryAppSectionRollup(&appName, base/core.DayLength)
+		assert.NoError(t, err)
+		assert.Equal(t, sections, single)
+		single, err = dao.QueryAppSectionRollup(&appName, base/core.DayLength+2)
+		assert.NoError(t, err)
+		assert.Equal(t, make([]*sectionRollup, core.NumSections), single)
+		_, err = dao.QueryAppSectionRollup(&server.AppName{Name: "contract-none", Namespace: "contract"}, 0)
+		assert.Equal(t, server.ErrAppNotFound, err)
+
+		_, err = dao.BackfillSectionRollups()
+		assert.NoError(t, err)
+
+		// 删除过期数据
+		assert.NoError(t, dao.RemoveAppPodMetricsBefore(base+core.DayLength))
+		found = found[:0]
+		it = NewAppPodMetricsIterator(dao, 10)
+		for metrics, err := it.Next(); err == nil; metrics, err = it.Next() {
+			if metrics.AppName == appName {
+				found = append(found, metrics)
+			}
+		}
+		assert.Equal(t, 1, len(found))
+		sections = nil
+		assert.NoError(t, dao.ForEachAppSectionRollup(0, func(name server.AppName, s []*sectionRollup) error {
+			if name == appName {
+				sections = s
+			}
+			return nil
+		}))
+		if assert.NotNil(t, sections) {
+			assert.Equal(t, uint64(1), sections[0].count)
+		}
+
+		assert.NoError(t, dao.SaveAllAppPodMetrics(nil))
+	})
+
+	t.Run("SectionRollupLateMetrics", func(t *testing.T) {
+		appName := server.AppName{Name: "contract-late", Namespace: "contract"}
+		base := uint64(310 * core.DayLength)
+		assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+			{AppName: appName, Timestamp: base + 600, Cpu: 1, Mem: 1},
+		}))
+		// 迟到的数据与已有数据一起重新汇总
+		assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+			{AppName: appName, Timestamp: base + 60, Cpu: 3, Mem: 3},
+			{AppName: appName, Timestamp: base + 660, Cpu: 2, Mem: 2},
+		}))
+		sections, err := dao.QueryAppSectionRollup(&appName, base/core.DayLength)
+		assert.NoError(t, err)
+		if assert.NotNil(t, sections[0]) {
+			assert.Equal(t, uint64(3), sections[0].count)
+			assert.Equal(t, float64(6), sections[0].cpuSum)
+			assert.Equal(t, float64(3), sections[0].cpuMax)
+			assert.Equal(t, base+660, sections[0].lastTimestamp)
+		}
+
+		// 再次写入相同的数据不改变汇总
+		assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+			{AppName: appName, Timestamp: base + 60, Cpu: 3, Mem: 3},
+		}))
+		sections, err = dao.QueryAppSectionRollup(&appName, base/core.DayLength)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(3), sections[0].count)
+		assert.Equal(t, float64(6), sections[0].cpuSum)
+	})
+
+	t.Run("AppClass", func(t *testing.T) {
+		appName := server.AppName{Name: "contract-class", Namespace: "contract"}
+		_, err := dao.QueryAppClassByApp(&appName)
+		assert.Equal(t, server.ErrAppNotFound, err)
+
+		assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{{AppName: appName, Timestamp: 1}}))
+		_, err = dao.QueryAppClassByApp(&appName)
+		assert.Equal(t, server.ErrAppNotClassified, err)
+
+		assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1000, CpuMax: 1, MemMax: 1}))
+		assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1001, CpuMax: 2, MemMax: 2}))
+		class, err := dao.QueryAppClassByApp(&appName)
+		assert.NoError(t, err)
+		assert.Equal(t, &server.AppClass{AppName: appName, ClassId: 1001, CpuMax: 2, MemMax: 2}, class)
+
+		// 距离与置信度
+		confidence := 0.75
+		assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1001, CpuMax: 2, MemMax: 2,
+			Distance: 0.5, RunnerUpClassId: 1000, RunnerUpDistance: 2, Confidence: &confidence, Provisional: true}))
+		class, err = dao.QueryAppClassByApp(&appName)
+		assert.NoError(t, err)
+		assert.Equal(t, 0.5, class.Distance)
+		assert.Equal(t, uint(1000), class.RunnerUpClassId)
+		assert.Equal(t, float64(2), class.RunnerUpDistance)
+		assert.True(t, class.Provisional)
+		if assert.NotNil(t, class.Confidence) {
+			assert.Equal(t, confidence, *class.Confidence)
+		}
+		found := false
+		assert.NoError(t, dao.ForEachAppClass(func(c *server.AppClass) error {
+			if c.AppName == appName {
+				found = true
+				assert.Equal(t, class, c)
+			}
+			return nil
+		}))
+		assert.True(t, found)
+
+		count, err := dao.QueryClassMemberCount()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count[1000])
+		assert.Equal(t, 1, count[1001])
+
+		// 删除后可以重新分类
+		assert.NoError(t, dao.RemoveAppClass(&appName))
+		_, err = dao.QueryAppClassByApp(&appName)
+		assert.Equal(t, server.ErrAppNotClassified, err)
+		assert.NoError(t, dao.RemoveAppClass(&appName))
+		assert.NoError(t, dao.RemoveAppClass(&server.AppName{Name: "contract-unknown", Namespace: "contract"}))
+		assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1000, CpuMax: 1, MemMax: 1}))
+		_, err = dao.QueryAppClassByApp(&appName)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ClassMetrics", func(t *testing.T) {
+		assert.Error(t, dao.SaveClassMetrics(&server.ClassMetrics{ClassId: 0}))
+		assert.NoError(t, dao.RemoveAllClassMetrics())
+		last, err := dao.QueryLastClassifyTime()
+		assert.NoError(t, err)
+		assert.True(t, last.IsZero())
+
+		metrics := &server.ClassMetrics{ClassId: 1, Data: make([]*core.SectionData, core.NumSections)}
+		for i := range metrics.Data {
+			metrics.Data[i] = &core.SectionData{CpuAvg: float32(i), MemMax: float32(i)}
+		}
+		assert.NoError(t, dao.SaveClassMetrics(metrics))
+		assert.NoError(t, dao.SaveClassMetrics(&server.ClassMetrics{ClassId: 2, Data: metrics.Data[:1]}))
+
+		queried, err := dao.QueryClassMetricsByClassId(1)
+		assert.NoError(t, err)
+		assert.Equal(t, metrics, queried)
+		// 数据不完整的类别
+		_, err = dao.QueryClassMetricsByClassId(2)
+		assert.Error(t, err)
+		_, err = dao.QueryClassMetricsByClassId(3)
+		assert.Equal(t, server.ErrClassNotFound, err)
+
+		all, err := dao.QueryAllClassMetrics()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(all))
+		last, err = dao.QueryLastClassifyTime()
+		assert.NoError(t, err)
+		assert.False(t, last.IsZero())
+
+		assert.NoError(t, dao.RemoveAllClassMetrics())
+		all, err = dao.QueryAllClassMetrics()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(all))
+	})
+
+	t.Run("AppPin", func(t *testing.T) {
+		appName := server.AppName{Name: "contract-pin", Namespace: "contract"}
+		other := server.AppName{Name: "contract-pin", Namespace: "contract-a"}
+		_, err := dao.QueryAppPin(&appName)
+		assert.Equal(t, server.ErrAppNotPinned, err)
+		assert.Equal(t, server.ErrAppNotPinned, dao.RemoveAppPin(&appName))
+
+		createdAt := time.Unix(1600000000, 0)
+		assert.NoError(t, dao.SaveAppPin(&server.AppPin{AppName: appName, ClassId: 2, Reason: "批处理", CreatedBy: "admin", CreatedAt: createdAt}))
+		profile := make([]*core.SectionData, core.NumSections)
+		for i := range profile {
+			profile[i] = &core.SectionData{CpuMax: float32(i), MemMax: 1}
+		}
+		pin := &server.AppPin{AppName: appName, Profile: profile, Reason: "自定义", CreatedBy: "ops", CreatedAt: createdAt}
+		assert.NoError(t, dao.SaveAppPin(pin))
+		assert.NoError(t, dao.SaveAppPin(&server.AppPin{AppName: other, ClassId: 1, Reason: "其他", CreatedBy: "admin", CreatedAt: createdAt}))
+
+		queried, err := dao.QueryAppPin(&appName)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), queried.ClassId, "再次固定应覆盖原有的固定分类")
+		assert.Equal(t, profile, queried.Profile)
+		assert.Equal(t, "自定义", queried.Reason)
+		assert.Equal(t, "ops", queried.CreatedBy)
+		assert.True(t, createdAt.Equal(queried.CreatedAt))
+
+		all, err := dao.QueryAllAppPins()
+		assert.NoError(t, err)
+		names := make([]server.AppName, 0)
+		for _, p := range all {
+			if p.Name == "contract-pin" {
+				names = append(names, p.AppName)
+			}
+		}
+		assert.Equal(t, []server.AppName{appName, other}, names, "应按名称空间排序")
+
+		// 重新聚类不影响固定分类
+		assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 3, CpuMax: 1, MemMax: 1}))
+		_, err = dao.QueryAppPin(&appName)
+		assert.NoError(t, err)
+
+		assert.NoError(t, dao.RemoveAppPin(&appName))
+		_, err = dao.QueryAppPin(&appName)
+		assert.Equal(t, server.ErrAppNotPinned, err)
+		assert.Equal(t, server.ErrAppNotPinned, dao.RemoveAppPin(&appName))
+		assert.NoError(t, dao.RemoveAppPin(&other))
+
+		assert.NoError(t, dao.SaveAppPinAudit(&server.AppPinAudit{AppName: appName, Action: server.PinActionPin,
+			ClassId: 2, Reason: "批处理", Actor: "admin", Time: createdAt}))
+		assert.NoError(t, dao.SaveAppPinAudit(&server.AppPinAudit{AppName: appName, Action: server.PinActionUnpin,
+			Reason: "恢复", Actor: "admin", Time: createdAt.Add(time.Hour)}))
+		audits, err := dao.QueryAppPinAudits(&appName)
+		assert.NoError(t, err)
+		if assert.Equal(t, 2, len(audits)) {
+			assert.Equal(t, server.PinActionPin, audits[0].Action)
+			assert.Equal(t, uint(2), audits[0].ClassId)
+			assert.Equal(t, server.PinActionUnpin, audits[1].Action)
+			assert.Equal(t, "恢复", audits[1].Reason)
+			assert.True(t, createdAt.Add(time.Hour).Equal(audits[1].Time))
+		}
+		audits, err = dao.QueryAppPinAudits(&server.AppName{Name: "contract-none", Namespace: "contract"})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(audits))
+	})
+
+	t.Run("AppClassHistory", func(t *testing.T) {
+		appName := server.AppName{Name: "contract-history", Namespace: "contract"}
+		history, err := dao.QueryAppClassHistory(&appName)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(history))
+		assert.NoError(t, dao.SaveAppClassHistory(nil))
+
+		at := time.Unix(1600000000, 0)
+		confidence := 0.5
+		assert.NoError(t, dao.SaveAppClassHistory([]*server.AppClassHistory{
+			{AppName: appName, ClassId: 1, Time: at},
+			{AppName: server.AppName{Name: "contract-history", Namespace: "contract-a"}, ClassId: 3, Time: at},
+		}))
+		assert.NoError(t, dao.SaveAppClassHistory([]*server.AppClassHistory{
+			{AppName: appName, ClassId: 2, PreviousClassId: 1, Distance: 0.2, Confidence: &confidence, Provisional: true, Time: at.Add(time.Hour)},
+		}))
+
+		history, err = dao.QueryAppClassHistory(&appName)
+		assert.NoError(t, err)
+		if assert.Equal(t, 2, len(history)) {
+			assert.Equal(t, uint(1), history[0].ClassId)
+			assert.Equal(t, uint(0), history[0].PreviousClassId)
+			assert.Nil(t, history[0].Confidence)
+			assert.Equal(t, appName, history[1].AppName)
+			assert.Equal(t, uint(2), history[1].ClassId)
+			assert.Equal(t, uint(1), history[1].PreviousClassId)
+			assert.Equal(t, 0.2, history[1].Distance)
+			assert.True(t, history[1].Provisional)
+			assert.True(t, at.Add(time.Hour).Equal(history[1].Time))
+			if assert.NotNil(t, history[1].Confidence) {
+				assert.Equal(t, confidence, *history[1].Confidence)
+			}
+		}
+	})
+
+	t.Run("Cluster", func(t *testing.T) {
+		// 不同集群中的同名应用是不同的应用
+		local := server.AppName{Name: "contract-cluster", Namespace: "contract"}
+		remote := server.AppName{Name: "contract-cluster", Namespace: "contract", Cluster: "contract-remote"}
+		base := uint64(300 * core.DayLength)
+		assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+			{AppName: local, Timestamp: base, Cpu: 1, Mem: 1},
+			{AppName: remote, Timestamp: base, Cpu: 2, Mem: 2},
+		}))
+		found := make(map[server.AppName]float32)
+		it := NewAppPodMetricsIterator(dao, 1)
+		for metrics, err := it.Next(); err == nil; metrics, err = it.Next() {
+			if metrics.Name == local.Name {
+				found[metrics.AppName] = metrics.Cpu
+			}
+		}
+		assert.Equal(t, map[server.AppName]float32{local: 1, remote: 2}, found)
+
+		assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: remote, ClassId: 2}))
+		_, err := dao.QueryAppClassByApp(&local)
+		assert.Equal(t, server.ErrAppNotClassified, err)
+		class, err := dao.QueryAppClassByApp(&remote)
+		if assert.NoError(t, err) {
+			assert.Equal(t, remote, class.AppName)
+		}
+
+		at := time.Unix(1600000000, 0)
+		assert.NoError(t, dao.SaveAppClassHistory([]*server.AppClassHistory{{AppName: remote, ClassId: 2, Time: at}}))
+		assert.NoError(t, dao.SaveAppPinAudit(&server.AppPinAudit{AppName: remote, Action: server.PinActionUnpin, Time: at}))
+		for _, appName := range []server.AppName{local, remote} {
+			history, err := dao.QueryAppClassHistory(&appName)
+			assert.NoError(t, err)
+			audits, err := dao.QueryAppPinAudits(&appName)
+			assert.NoError(t, err)
+			if appName == remote && assert.Equal(t, 1, len(history)) && assert.Equal(t, 1, len(audits)) {
+				assert.Equal(t, remote, history[0].AppName)
+				assert.Equal(t, remote, audits[0].AppName)
+			} else if appName == local {
+				assert.Equal(t, 0, len(history))
+				assert.Equal(t, 0, len(audits))
+			}
+		}
+		assert.NoError(t, dao.RemoveAppClass(&remote))
+	})
+
+	t.Run("ClassificationEvents", func(t *testing.T) {
+		_, before, err := dao.QueryClassificationVersions()
+		assert.NoError(t, err)
+
+		appName := server.AppName{Name: "contract-events", Namespace: "contract"}
+		metrics := &server.ClassMetrics{ClassId: 9, Data: make([]*core.SectionData, core.NumSections)}
+		for i := range metrics.Data {
+			metrics.Data[i] = &core.SectionData{CpuAvg: float32(i)}
+		}
+		confidence := 0.5
+		assert.NoError(t, dao.SaveClassMetrics(metrics))
+		assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 9, CpuMax: 2, Confidence: &confidence}))
+		// 回滚的事务不留下记录
+		assert.Error(t, dao.Transaction(func(tx Dao) error {
+			if err := tx.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 10}); err != nil {
+				return err
+			}
+			return fmt.Errorf("回滚")
+		}))
+
+		events, err := dao.QueryClassificationEvents(before, 10)
+		assert.NoError(t, err)
+		if assert.Equal(t, 2, len(events)) {
+			assert.Equal(t, server.EventTypeModified, events[0].Type)
+			assert.Equal(t, server.EventKindClassMetrics, events[0].Kind)
+			assert.Equal(t, metrics, events[0].ClassMetrics)
+			assert.True(t, events[0].ResourceVersion > before)
+			assert.Equal(t, server.EventKindAppAssignment, events[1].Kind)
+			assert.Equal(t, appName, events[1].AppAssignment.AppName)
+			assert.Equal(t, uint(9), events[1].AppAssignment.ClassId)
+			assert.Equal(t, float32(2), events[1].AppAssignment.CpuMax)
+			assert.Equal(t, &confidence, events[1].AppAssignment.Confidence)
+			assert.True(t, events[1].ResourceVersion > events[0].ResourceVersion)
+		}
+		limited, err := dao.QueryClassificationEvents(before, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(limited))
+
+		assert.NoError(t, dao.RemoveAllClassMetrics())
+		assert.NoError(t, dao.RemoveAppClass(&appName))
+		events, err = dao.QueryClassificationEvents(before, 10)
+		assert.NoError(t, err)
+		if assert.Equal(t, 4, len(events)) {
+			assert.Equal(t, server.EventTypeDeleted, events[2].Type)
+			assert.Equal(t, uint(9), events[2].ClassMetrics.ClassId)
+			assert.Equal(t, server.EventTypeDeleted, events[3].Type)
+			assert.Equal(t, server.EventKindAppAssignment, events[3].Kind)
+			assert.Equal(t, appName, events[3].AppAssignment.AppName)
+		}
+		_, latest, err := dao.QueryClassificationVersions()
+		assert.NoError(t, err)
+		assert.Equal(t, events[len(events)-1].ResourceVersion, latest)
+
+		// 清理时保留最新的一条
+		assert.NoError(t, dao.RemoveClassificationEventsBefore(time.Now().Add(time.Hour)))
+		oldest, remained, err := dao.QueryClassificationVersions()
+		assert.NoError(t, err)
+		assert.Equal(t, latest, oldest)
+		assert.Equal(t, latest, remained)
+		events, err = dao.QueryClassificationEvents(before, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(events))
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		appName := server.AppName{Name: "contract-tx", Namespace: "contract"}
+		err := dao.Transaction(func(tx Dao) error {
+			if err := tx.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1}); err != nil {
+				return err
+			}
+			return fmt.Errorf("回滚")
+		})
+		assert.Error(t, err)
+		_, err = dao.QueryAppClassByApp(&appName)
+		assert.Equal(t, server.ErrAppNotFound, err)
+
+		err = dao.Transaction(func(tx Dao) error {
+			return tx.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1})
+		})
+		assert.NoError(t, err)
+		class, err := dao.QueryAppClassByApp(&appName)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), class.ClassId)
+
+		// ctx结束后操作失败
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Error(t, dao.WithContext(ctx).SaveAppClass(&server.AppClass{AppName: appName, ClassId: 2}))
+		class, _ = dao.QueryAppClassByApp(&appName)
+		assert.Equal(t, uint(1), class.ClassId)
+	})
+
+	assert.NoError(t, dao.Ping())
+}
+
+func TestMemoryDao_Contract(t *testing.T) {
+	testDaoContract(t, NewMemoryDao())
+}
+
+func TestMemoryDao_Transaction(t *testing.T) {
+	dao := NewMemoryDao()
+	appName := server.AppName{Name: "tx", Namespace: "test"}
+
+	// 嵌套事务失败只回滚内层
+	err := dao.Transaction(func(tx Dao) error {
+		if err := tx.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1}); err != nil {
+			return err
+		}
+		_ = tx.Transaction(func(inner Dao) error {
+			_ = inner.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 2})
+			return fmt.Errorf("回滚")
+		})
+		return nil
+	})
+	assert.NoError(t, err)
+	class, err := dao.QueryAppClassByApp(&appName)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), class.ClassId)
+
+	// 修改返回的数据不影响存储的数据
+	class.ClassId = 100
+	class, _ = dao.QueryAppClassByApp(&appName)
+	assert.Equal(t, uint(1), class.ClassId)
+
+	// ctx在提交前结束时回滚
+	ctx, cancel := context.WithCancel(context.Background())
+	err = dao.WithContext(ctx).Transaction(func(tx Dao) error {
+		err := tx.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 3})
+		cancel()
+		return err
+	})
+	assert.Error(t, err)
+	class, _ = dao.QueryAppClassByApp(&appName)
+	assert.Equal(t, uint(1), class.ClassId)
+}
+
+func TestMemoryDao_Close(t *testing.T) {
+	dao := NewMemoryDao()
+	assert.NoError(t, dao.Ping())
+	assert.NoError(t, dao.Close())
+	assert.Error(t, dao.Ping())
+	assert.Error(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{{AppName: server.AppName{Name: "a"}}}))
+}
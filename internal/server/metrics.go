@@ -0,0 +1,469 @@
+package server
+
+import (
+	"context"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const MetricsNamespace = "workload_classifier"
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// 收集类别数据时查询数据库的超时时间
+const classificationCollectTimeout = 5 * time.Second
+
+// 服务器导出到Prometheus的各项指标
+type serverMetrics struct {
+	registry *prometheus.Registry
+
+	scrapeDuration      prometheus.Histogram
+	scrapeTotal         *prometheus.CounterVec
+	samplesStored       prometheus.Counter
+	appsTracked         prometheus.Gauge
+	dbDuration          *prometheus.HistogramVec
+	reClusterDuration   prometheus.Histogram
+	reClusterTotal      *prometheus.CounterVec
+	reClusterInertia    prometheus.Gauge
+	reClusterDistance   prometheus.Gauge
+	reClusterIneligible *prometheus.GaugeVec
+	webhookTotal        *prometheus.CounterVec
+	apiRequests         *prometheus.CounterVec
+	apiDuration         *prometheus.HistogramVec
+	ingestedSamples     *prometheus.CounterVec
+}
+
+func newServerMetrics() *serverMetrics {
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+		scrapeDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "scrape_duration_seconds",
+			Help:      "从api server获取一次监控数据所用的时间",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		scrapeTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "scrapes_total",
+			Help:      "获取并保存监控数据的次数，按结果区分",
+		}, []string{"result"}),
+		samplesStored: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "samples_stored_total",
+			Help:      "保存到数据库的监控数据条数",
+		}),
+		appsTracked: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "apps_tracked",
+			Help:      "最近一次获取到监控数据的应用数量",
+		}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "db_operation_duration_seconds",
+			Help:      "数据库操作所用的时间，按操作与结果区分",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+		}, []string{"operation", "result"}),
+		reClusterDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "recluster_duration_seconds",
+			Help:      "一次再聚类所用的时间",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		}),
+		reClusterTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "reclusters_total",
+			Help:      "再聚类的次数，按结果区分",
+		}, []string{"result"}),
+		reClusterInertia: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "recluster_inertia",
+			Help:      "最近一次再聚类中，参与计算类别中心的各应用到所属类别中心距离的平方和",
+		}),
+		reClusterDistance: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "recluster_mean_distance",
+			Help:      "最近一次再聚类中，参与计算类别中心的各应用到所属类别中心的平均距离",
+		}),
+		reClusterIneligible: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "recluster_ineligible_apps",
+			Help:      "最近一次再聚类中，数据不足而没有参与计算类别中心的应用数量，按原因区分",
+		}, []string{"reason"}),
+		webhookTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "class_change_notifications_total",
+			Help:      "发送分类变化通知的次数，按重试后的最终结果区分",
+		}, []string{"result"}),
+		apiRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "api_requests_total",
+			Help:      "API请求数，按路由与状态码区分",
+		}, []string{"route", "code"}),
+		apiDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "api_request_duration_seconds",
+			Help:      "API请求处理时间，按路由区分",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route"}),
+		ingestedSamples: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "ingested_samples_total",
+			Help:      "推送的样本数，按格式与结果区分。remote write中CPU与内存各为一个样本",
+		}, []string{"format", "result"}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.scrapeDuration,
+		m.scrapeTotal,
+		m.samplesStored,
+		m.appsTracked,
+		m.dbDuration,
+		m.reClusterDuration,
+		m.reClusterTotal,
+		m.reClusterInertia,
+		m.reClusterDistance,
+		m.reClusterIneligible,
+		m.webhookTotal,
+		m.apiRequests,
+		m.apiDuration,
+		m.ingestedSamples,
+	)
+	return m
+}
+
+func (m *serverMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return resultFailure
+	}
+	return resultSuccess
+}
+
+// 记录一次获取并保存监控数据的结果
+func (m *serverMetrics) observeScrape(podMetrics []*server.AppPodMetrics, err error) {
+	m.scrapeTotal.WithLabelValues(resultLabel(err)).Inc()
+	if err != nil {
+		return
+	}
+	m.samplesStored.Add(float64(len(podMetrics)))
+	apps := make(map[server.AppName]struct{})
+	for _, metrics := range podMetrics {
+		apps[metrics.AppName] = struct{}{}
+	}
+	m.appsTracked.Set(float64(len(apps)))
+}
+
+func (m *serverMetrics) observeReCluster(start time.Time, result *reClusterResult, err error) {
+	m.reClusterDuration.Observe(time.Since(start).Seconds())
+	m.reClusterTotal.WithLabelValues(resultLabel(err)).Inc()
+	if err != nil {
+		return
+	}
+	m.reClusterInertia.Set(result.inertia)
+	if result.numClustered > 0 {
+		m.reClusterDistance.Set(result.totalDistance / float64(result.numClustered))
+	}
+	for _, reason := range ineligibleReasons {
+		m.reClusterIneligible.WithLabelValues(reason).Set(float64(result.ineligible[reason]))
+	}
+}
+
+// 数据不足的所有原因，用于在没有对应应用时将指标置0
+var ineligibleReasons = []string{server.IneligibleObservedDays, server.IneligibleSectionCoverage, server.IneligibleSampleCount}
+
+// 记录状态码的ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// 流式响应需要刷新缓冲
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// 记录handler的请求数与处理时间。route为路由模板，避免将应用名称作为标签值
+func (m *serverMetrics) instrumentHandler(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		handler(recorder, request)
+		m.apiDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		m.apiRequests.WithLabelValues(route, strconv.Itoa(recorder.status)).Inc()
+	}
+}
+
+// 记录gRPC请求的请求数与处理时间，路由为完整的方法名，状态码为gRPC状态码的名称
+func (m *serverMetrics) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.apiDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	m.apiRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	return resp, err
+}
+
+func (m *serverMetrics) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, stream)
+	m.apiDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	m.apiRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	return err
+}
+
+// 在收集指标时从数据库读取当前生效的分类情况，使得所有副本都能导出，而不仅仅是执行再聚类的leader
+type classificationCollector struct {
+	dao            Dao
+	logger         *log.Logger
+	classMembers   *prometheus.Desc
+	classification *prometheus.Desc
+}
+
+var _ prometheus.Collector = &classificationCollector{}
+
+func newClassificationCollector(dao Dao, logger *log.Logger) *classificationCollector {
+	return &classificationCollector{
+		dao:    dao,
+		logger: logger,
+		classMembers: prometheus.NewDesc(prometheus.BuildFQName(MetricsNamespace, "", "class_members"),
+			"各类别包含的应用数量", []string{"class"}, nil),
+		classification: prometheus.NewDesc(prometheus.BuildFQName(MetricsNamespace, "", "classification_age_seconds"),
+			"当前生效的分类结果距今的时间", nil, nil),
+	}
+}
+
+func (c *classificationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.classMembers
+	ch <- c.classification
+}
+
+func (c *classificationCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), classificationCollectTimeout)
+	defer cancel()
+	dao := c.dao.WithContext(ctx)
+
+	members, err := dao.QueryClassMemberCount()
+	if err != nil {
+		c.logger.Printf("查询各类别应用数量出错：%v\n", err)
+	} else {
+		for classId, count := range members {
+			ch <- prometheus.MustNewConstMetric(c.classMembers, prometheus.GaugeValue, float64(count),
+				strconv.FormatUint(uint64(classId), 10))
+		}
+	}
+
+	last, err := dao.QueryLastClassifyTime()
+	if err != nil {
+		c.logger.Printf("查询分类结果时间出错：%v\n", err)
+	} else if !last.IsZero() {
+		ch <- prometheus.MustNewConstMetric(c.classification, prometheus.GaugeValue, time.Since(last).Seconds())
+	}
+}
+
+// 记录各个数据库操作所用时间的Dao
+type metricsDao struct {
+	dao     Dao
+	metrics *serverMetrics
+}
+
+var _ Dao = &metricsDao{}
+
+func newMetricsDao(dao Dao, metrics *serverMetrics) Dao {
+	return &metricsDao{
+		dao:     dao,
+		metrics: metrics,
+	}
+}
+
+func (m *metricsDao) observe(operation string, start time.Time, err error) {
+	m.metrics.dbDuration.WithLabelValues(operation, resultLabel(err)).Observe(time.Since(start).Seconds())
+}
+
+func (m *metricsDao) WithContext(ctx context.Context) Dao {
+	return newMetricsDao(m.dao.WithContext(ctx), m.metrics)
+}
+
+func (m *metricsDao) Transaction(fc func(tx Dao) error) (err error) {
+	defer func(start time.Time) { m.observe("Transaction", start, err) }(time.Now())
+	return m.dao.Transaction(func(tx Dao) error {
+		return fc(newMetricsDao(tx, m.metrics))
+	})
+}
+
+func (m *metricsDao) Ping() (err error) {
+	defer func(start time.Time) { m.observe("Ping", start, err) }(time.Now())
+	return m.dao.Ping()
+}
+
+func (m *metricsDao) Close() error {
+	return m.dao.Close()
+}
+
+func (m *metricsDao) SaveClassMetrics(c *server.ClassMetrics) (err error) {
+	defer func(start time.Time) { m.observe("SaveClassMetrics", start, err) }(time.Now())
+	return m.dao.SaveClassMetrics(c)
+}
+
+func (m *metricsDao) SaveAppClass(a *server.AppClass) (err error) {
+	defer func(start time.Time) { m.observe("SaveAppClass", start, err) }(time.Now())
+	return m.dao.SaveAppClass(a)
+}
+
+func (m *metricsDao) RemoveAppClass(appName *server.AppName) (err error) {
+	defer func(start time.Time) { m.observe("RemoveAppClass", start, err) }(time.Now())
+	return m.dao.RemoveAppClass(appName)
+}
+
+func (m *metricsDao) SaveAllAppPodMetrics(arr []*server.AppPodMetrics) (err error) {
+	defer func(start time.Time) { m.observe("SaveAllAppPodMetrics", start, err) }(time.Now())
+	return m.dao.SaveAllAppPodMetrics(arr)
+}
+
+func (m *metricsDao) RemoveAppPodMetricsBefore(timestamp uint64) (err error) {
+	defer func(start time.Time) { m.observe("RemoveAppPodMetricsBefore", start, err) }(time.Now())
+	return m.dao.RemoveAppPodMetricsBefore(timestamp)
+}
+
+func (m *metricsDao) BackfillSectionRollups() (_ int, err error) {
+	defer func(start time.Time) { m.observe("BackfillSectionRollups", start, err) }(time.Now())
+	return m.dao.BackfillSectionRollups()
+}
+
+func (m *metricsDao) ForEachAppSectionRollup(fromDay uint64, fc func(appName server.AppName, sections []*sectionRollup) error) (err error) {
+	defer func(start time.Time) { m.observe("ForEachAppSectionRollup", start, err) }(time.Now())
+	return m.dao.ForEachAppSectionRollup(fromDay, fc)
+}
+
+func (m *metricsDao) QueryAppSectionRollup(appName *server.AppName, fromDay uint64) (_ []*sectionRollup, err error) {
+	defer func(start time.Time) { m.observe("QueryAppSectionRollup", start, err) }(time.Now())
+	return m.dao.QueryAppSectionRollup(appName, fromDay)
+}
+
+func (m *metricsDao) SaveAppPin(pin *server.AppPin) (err error) {
+	defer func(start time.Time) { m.observe("SaveAppPin", start, err) }(time.Now())
+	return m.dao.SaveAppPin(pin)
+}
+
+func (m *metricsDao) RemoveAppPin(appName *server.AppName) (err error) {
+	defer func(start time.Time) { m.observe("RemoveAppPin", start, err) }(time.Now())
+	return m.dao.RemoveAppPin(appName)
+}
+
+func (m *metricsDao) SaveAppPinAudit(audit *server.AppPinAudit) (err error) {
+	defer func(start time.Time) { m.observe("SaveAppPinAudit", start, err) }(time.Now())
+	return m.dao.SaveAppPinAudit(audit)
+}
+
+func (m *metricsDao) QueryAppPin(appName *server.AppName) (_ *server.AppPin, err error) {
+	defer func(start time.Time) { m.observe("QueryAppPin", start, err) }(time.Now())
+	return m.dao.QueryAppPin(appName)
+}
+
+func (m *metricsDao) QueryAllAppPins() (_ []*server.AppPin, err error) {
+	defer func(start time.Time) { m.observe("QueryAllAppPins", start, err) }(time.Now())
+	return m.dao.QueryAllAppPins()
+}
+
+func (m *metricsDao) QueryAppPinAudits(appName *server.AppName) (_ []*server.AppPinAudit, err error) {
+	defer func(start time.Time) { m.observe("QueryAppPinAudits", start, err) }(time.Now())
+	return m.dao.QueryAppPinAudits(appName)
+}
+
+func (m *metricsDao) SaveAppClassHistory(history []*server.AppClassHistory) (err error) {
+	defer func(start time.Time) { m.observe("SaveAppClassHistory", start, err) }(time.Now())
+	return m.dao.SaveAppClassHistory(history)
+}
+
+func (m *metricsDao) QueryAppClassHistory(appName *server.AppName) (_ []*server.AppClassHistory, err error) {
+	defer func(start time.Time) { m.observe("QueryAppClassHistory", start, err) }(time.Now())
+	return m.dao.QueryAppClassHistory(appName)
+}
+
+func (m *metricsDao) RemoveClassificationEventsBefore(before time.Time) (err error) {
+	defer func(start time.Time) { m.observe("RemoveClassificationEventsBefore", start, err) }(time.Now())
+	return m.dao.RemoveClassificationEventsBefore(before)
+}
+
+func (m *metricsDao) QueryClassificationEvents(afterVersion uint64, limit int) (_ []*server.ClassificationEvent, err error) {
+	defer func(start time.Time) { m.observe("QueryClassificationEvents", start, err) }(time.Now())
+	return m.dao.QueryClassificationEvents(afterVersion, limit)
+}
+
+func (m *metricsDao) QueryClassificationVersions() (_ uint64, _ uint64, err error) {
+	defer func(start time.Time) { m.observe("QueryClassificationVersions", start, err) }(time.Now())
+	return m.dao.QueryClassificationVersions()
+}
+
+func (m *metricsDao) QueryAppPodMetricsPage(cursor uint, limit int) (_ []*server.AppPodMetrics, _ uint, err error) {
+	defer func(start time.Time) { m.observe("QueryAppPodMetricsPage", start, err) }(time.Now())
+	return m.dao.QueryAppPodMetricsPage(cursor, limit)
+}
+
+func (m *metricsDao) ForEachAppClass(fc func(class *server.AppClass) error) (err error) {
+	defer func(start time.Time) { m.observe("ForEachAppClass", start, err) }(time.Now())
+	return m.dao.ForEachAppClass(fc)
+}
+
+func (m *metricsDao) RemoveAllClassMetrics() (err error) {
+	defer func(start time.Time) { m.observe("RemoveAllClassMetrics", start, err) }(time.Now())
+	return m.dao.RemoveAllClassMetrics()
+}
+
+func (m *metricsDao) QueryClassMetricsByClassId(classId uint) (_ *server.ClassMetrics, err error) {
+	defer func(start time.Time) {
+		if err == server.ErrClassNotFound {
+			m.observe("QueryClassMetricsByClassId", start, nil)
+		} else {
+			m.observe("QueryClassMetricsByClassId", start, err)
+		}
+	}(time.Now())
+	return m.dao.QueryClassMetricsByClassId(classId)
+}
+
+func (m *metricsDao) QueryAllClassMetrics() (_ []*server.ClassMetrics, err error) {
+	defer func(start time.Time) { m.observe("QueryAllClassMetrics", start, err) }(time.Now())
+	return m.dao.QueryAllClassMetrics()
+}
+
+func (m *metricsDao) QueryAppClassByApp(appName *server.AppName) (_ *server.AppClass, err error) {
+	defer func(start time.Time) {
+		// 应用不存在或未分类属于正常的查询结果
+		if err == server.ErrAppNotFound || err == server.ErrAppNotClassified {
+			m.observe("QueryAppClassByApp", start, nil)
+		} else {
+			m.observe("QueryAppClassByApp", start, err)
+		}
+	}(time.Now())
+	return m.dao.QueryAppClassByApp(appName)
+}
+
+func (m *metricsDao) QueryClassMemberCount() (_ map[uint]int, err error) {
+	defer func(start time.Time) { m.observe("QueryClassMemberCount", start, err) }(time.Now())
+	return m.dao.QueryClassMemberCount()
+}
+
+func (m *metricsDao) QueryLastClassifyTime() (_ time.Time, err error) {
+	defer func(start time.Time) { m.observe("QueryLastClassifyTime", start, err) }(time.Now())
+	return m.dao.QueryLastClassifyTime()
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMetricsDao(t *testing.T) {
+	metrics := newServerMetrics()
+	fake := newFakeDao()
+	dao := newMetricsDao(fake, metrics)
+
+	err := dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{{}})
+	assert.NoError(t, err)
+	err = dao.WithContext(context.Background()).Transaction(func(tx Dao) error {
+		return tx.SaveAppClass(&server.AppClass{ClassId: 1})
+	})
+	assert.NoError(t, err)
+
+	// 写入失败时记录为failure
+	fake.beforeWrite = func(_ context.Context) error {
+		return fmt.Errorf("test")
+	}
+	err = dao.SaveAllAppPodMetrics(nil)
+	assert.Error(t, err)
+
+	count := func(operation, result string) uint64 {
+		m := &dto.Metric{}
+		_ = metrics.dbDuration.WithLabelValues(operation, result).(prometheus.Metric).Write(m)
+		return m.GetHistogram().GetSampleCount()
+	}
+	assert.Equal(t, uint64(1), count("SaveAllAppPodMetrics", resultSuccess))
+	assert.Equal(t, uint64(1), count("SaveAllAppPodMetrics", resultFailure))
+	// 事务中的操作同样会被记录
+	assert.Equal(t, uint64(1), count("Transaction", resultSuccess))
+	assert.Equal(t, uint64(1), count("SaveAppClass", resultSuccess))
+}
+
+func TestServerMetrics_ObserveScrape(t *testing.T) {
+	metrics := newServerMetrics()
+	metrics.observeScrape([]*server.AppPodMetrics{
+		{AppName: server.AppName{Name: "a", Namespace: "test"}},
+		{AppName: server.AppName{Name: "a", Namespace: "test"}},
+		{AppName: server.AppName{Name: "b", Namespace: "test"}},
+	}, nil)
+	metrics.observeScrape(nil, fmt.Errorf("test"))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.scrapeTotal.WithLabelValues(resultSuccess)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.scrapeTotal.WithLabelValues(resultFailure)))
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.samplesStored))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.appsTracked))
+}
+
+func TestServerMetrics_Handler(t *testing.T) {
+	metrics := newServerMetrics()
+	dao := newFakeDao()
+	metrics.registry.MustRegister(newClassificationCollector(dao, log.New(os.Stdout, "", 0)))
+	_ = dao.SaveClassMetrics(&server.ClassMetrics{ClassId: 1})
+	_ = dao.SaveAppClass(&server.AppClass{AppName: server.AppName{Name: "a", Namespace: "test"}, ClassId: 1})
+	_ = dao.SaveAppClass(&server.AppClass{AppName: server.AppName{Name: "b", Namespace: "test"}, ClassId: 1})
+	_ = dao.SaveAppClass(&server.AppClass{AppName: server.AppName{Name: "c", Namespace: "test"}, ClassId: 2})
+
+	handler := metrics.instrumentHandler("/test/{name}", func(writer http.ResponseWriter, request *http.Request) {
+		http.NotFound(writer, request)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/a", nil))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.apiRequests.WithLabelValues("/test/{name}", "404")))
+
+	recorder := httptest.NewRecorder()
+	metrics.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	body, _ := ioutil.ReadAll(recorder.Body)
+	text := string(body)
+	assert.True(t, strings.Contains(text, `workload_classifier_class_members{class="1"} 2`))
+	assert.True(t, strings.Contains(text, `workload_classifier_class_members{class="2"} 1`))
+	assert.True(t, strings.Contains(text, "workload_classifier_classification_age_seconds"))
+	assert.True(t, strings.Contains(text, `workload_classifier_api_requests_total{code="404",route="/test/{name}"} 1`))
+}
+
+func TestEuclideanDistance(t *testing.T) {
+	assert.Equal(t, float64(5), euclideanDistance([]float32{0, 0}, []float32{3, 4}))
+	assert.Equal(t, float64(0), euclideanDistance([]float32{1, 2}, []float32{1, 2}))
+	assert.False(t, math.IsNaN(euclideanDistance(nil, nil)))
+}
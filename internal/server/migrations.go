@@ -0,0 +1,578 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log"
+	"os"
+	"time"
+)
+
+// 数据库结构迁移。每个迁移都有递增的版本号，已执行的迁移记录在schema_migrations表中。
+// 已发布的迁移不能再修改，表结构的变更需要追加新的迁移。迁移中使用的表结构是当时的快照，
+// 不能直接引用会随版本变化的DO类型。
+type migration struct {
+	version uint
+	name    string
+	up      func(tx *gorm.DB) error
+	down    func(tx *gorm.DB) error
+}
+
+// 已执行的迁移记录
+type SchemaMigrationDO struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (SchemaMigrationDO) TableName() string {
+	return "schema_migrations"
+}
+
+// 迁移的执行状态
+type MigrationStatus struct {
+	Version   uint
+	Name      string
+	AppliedAt *time.Time // 为nil表示尚未执行
+	Unknown   bool       // 数据库中记录了本程序不认识的迁移，说明数据库由更新的版本创建
+}
+
+// 多个实例同时启动时，使用此锁保证只有一个实例执行迁移
+const (
+	migrationLockName    = "workload_classifier_schema_migration"
+	migrationLockTimeout = 60
+)
+
+var migrations = []*migration{
+	{
+		version: 1,
+		name:    "创建应用、监控数据、分类与类别数据表",
+		up: func(tx *gorm.DB) error {
+			// 旧版本使用AutoMigrate创建了相同的表，此处AutoMigrate可以直接接管这些表
+			return tx.Migrator().AutoMigrate(&v1AppDo{}, &v1AppPodMetricsDO{}, &v1AppClassDO{}, &v1ClassSectionMetricsDO{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v1AppDo{}, &v1AppPodMetricsDO{}, &v1AppClassDO{}, &v1ClassSectionMetricsDO{})
+		},
+	},
+	{
+		version: 2,
+		name:    "创建Section汇总数据表",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(&v2AppSectionRollupDO{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v2AppSectionRollupDO{})
+		},
+	},
+	{
+		version: 3,
+		name:    "应用分类增加距离与置信度",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(&v3AppClassDO{})
+		},
+		down: func(tx *gorm.DB) error {
+			for _, column := range []string{"Distance", "RunnerUpClassId", "RunnerUpDistance", "Confidence"} {
+				if err := tx.Migrator().DropColumn(&v3AppClassDO{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version: 4,
+		name:    "应用分类增加暂定标记",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(&v4AppClassDO{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&v4AppClassDO{}, "Provisional")
+		},
+	},
+	{
+		version: 5,
+		name:    "创建固定分类与审计表",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(&v5AppPinDO{}, &v5AppPinAuditDO{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v5AppPinDO{}, &v5AppPinAuditDO{})
+		},
+	},
+	{
+		version: 6,
+		name:    "创建应用分类历史表",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(&v6AppClassHistoryDO{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v6AppClassHistoryDO{})
+		},
+	},
+	{
+		version: 7,
+		name:    "创建分类变化记录表",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(&v7ClassificationEventDO{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v7ClassificationEventDO{})
+		},
+	},
+	{
+		version: 8,
+		name:    "应用、固定分类审计与分类历史增加集群",
+		up: func(tx *gorm.DB) error {
+			// 已有的记录属于服务器所在的集群，集群为空。索引需要包含新的列，因此删除后重建
+			for _, table := range []struct {
+				model interface{}
+				index string
+			}{{&v8AppDo{}, "app"}, {&v8AppPinAuditDO{}, "pin_audit_app"}, {&v8AppClassHistoryDO{}, "class_history_app"}} {
+				if err := tx.Migrator().AddColumn(table.model, "Cluster"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropIndex(table.model, table.index); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(table.model, table.index); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		down: func(tx *gorm.DB) error {
+			// 不同集群中有同名应用时，无法重建不含集群的唯一索引，需要先删除其他集群的应用
+			for _, table := range []struct {
+				model, previous interface{}
+				index           string
+			}{{&v8AppDo{}, &v1AppDo{}, "app"}, {&v8AppPinAuditDO{}, &v5AppPinAuditDO{}, "pin_audit_app"},
+				{&v8AppClassHistoryDO{}, &v6AppClassHistoryDO{}, "class_history_app"}} {
+				if err := tx.Migrator().DropIndex(table.model, table.index); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(table.model, "Cluster"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(table.previous, table.index); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// 本程序支持的最新数据库结构版本
+func latestSchemaVersion() uint {
+	return migrations[len(migrations)-1].version
+}
+
+// 数据库结构迁移工具
+type SchemaMigrator struct {
+	db     *gorm.DB
+	logger *log.Logger
+}
+
+func NewSchemaMigrator(host string) (*SchemaMigrator, error) {
+	db, err := openDatabase(host)
+	if err != nil {
+		return nil, err
+	}
+	return newSchemaMigrator(db), nil
+}
+
+func newSchemaMigrator(db *gorm.DB) *SchemaMigrator {
+	return &SchemaMigrator{
+		db:     db,
+		logger: log.New(os.Stdout, "Migrator: ", log.LstdFlags|log.Lshortfile|log.Lmsgprefix),
+	}
+}
+
+func (m *SchemaMigrator) Close() error {
+	s, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+	return s.Close()
+}
+
+// 数据库当前的结构版本，为已执行的迁移中最大的版本号。没有执行过迁移时为0
+func (m *SchemaMigrator) CurrentVersion() (uint, error) {
+	if !m.db.Migrator().HasTable(&SchemaMigrationDO{}) {
+		return 0, nil
+	}
+	var version uint
+	err := m.db.Model(&SchemaMigrationDO{}).Select("COALESCE(MAX(version), 0)").Row().Scan(&version)
+	if err != nil {
+		return 0, errors.Wrap(err, "查询数据库结构版本出错")
+	}
+	return version, nil
+}
+
+// 返回所有迁移的执行状态，按版本号递增排列
+func (m *SchemaMigrator) Status() ([]*MigrationStatus, error) {
+	applied := make([]*SchemaMigrationDO, 0)
+	if m.db.Migrator().HasTable(&SchemaMigrationDO{}) {
+		if err := m.db.Order("version").Find(&applied).Error; err != nil {
+			return nil, errors.Wrap(err, "查询迁移记录出错")
+		}
+	}
+	appliedMap := make(map[uint]*SchemaMigrationDO, len(applied))
+	for _, do := range applied {
+		appliedMap[do.Version] = do
+	}
+
+	result := make([]*MigrationStatus, 0, len(migrations))
+	for _, mg := range migrations {
+		status := &MigrationStatus{Version: mg.version, Name: mg.name}
+		if do, ok := appliedMap[mg.version]; ok {
+			appliedAt := do.AppliedAt
+			status.AppliedAt = &appliedAt
+			delete(appliedMap, mg.version)
+		}
+		result = append(result, status)
+	}
+	for _, do := range applied {
+		if _, ok := appliedMap[do.Version]; ok {
+			appliedAt := do.AppliedAt
+			result = append(result, &MigrationStatus{Version: do.Version, Name: do.Name, AppliedAt: &appliedAt, Unknown: true})
+		}
+	}
+	return result, nil
+}
+
+// 升级到本程序支持的最新版本。数据库结构比本程序支持的更新时返回错误
+func (m *SchemaMigrator) Up() error {
+	return m.MigrateTo(latestSchemaVersion())
+}
+
+// 升级或回滚到指定的版本，版本为0表示回滚所有迁移
+func (m *SchemaMigrator) MigrateTo(target uint) error {
+	if target > latestSchemaVersion() {
+		return fmt.Errorf("目标版本%d不存在，本程序支持的最新版本为%d", target, latestSchemaVersion())
+	}
+
+	return m.withLock(func() error {
+		if err := m.db.Migrator().AutoMigrate(&SchemaMigrationDO{}); err != nil {
+			return errors.Wrap(err, "创建迁移记录表出错")
+		}
+		current, err := m.CurrentVersion()
+		if err != nil {
+			return err
+		}
+		if err = checkSchemaVersion(current); err != nil {
+			return err
+		}
+
+		if current == target {
+			return nil
+		}
+		if current < target {
+			for _, mg := range migrations {
+				if mg.version <= current || mg.version > target {
+					continue
+				}
+				if err := m.apply(mg, true); err != nil {
+					return err
+				}
+			}
+		} else {
+			for i := len(migrations) - 1; i >= 0; i-- {
+				mg := migrations[i]
+				if mg.version > current || mg.version <= target {
+					continue
+				}
+				if err := m.apply(mg, false); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// 执行一个迁移并记录。注意MySQL的DDL语句会隐式提交事务，迁移中途失败时可能需要手动处理
+func (m *SchemaMigrator) apply(mg *migration, up bool) error {
+	if up {
+		m.logger.Printf("正在执行迁移%d：%s", mg.version, mg.name)
+	} else {
+		m.logger.Printf("正在回滚迁移%d：%s", mg.version, mg.name)
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if up {
+			if err := mg.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigrationDO{Version: mg.version, Name: mg.name, AppliedAt: time.Now()}).Error
+		}
+		if err := mg.down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigrationDO{}, mg.version).Error
+	})
+	if err != nil {
+		if up {
+			return errors.Wrap(err, fmt.Sprintf("执行迁移%d出错", mg.version))
+		}
+		return errors.Wrap(err, fmt.Sprintf("回滚迁移%d出错", mg.version))
+	}
+	return nil
+}
+
+// 使用MySQL的命名锁，避免多个实例同时执行迁移
+func (m *SchemaMigrator) withLock(fc func() error) error {
+	s, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := s.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "获取数据库连接出错")
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var locked int
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockTimeout).Scan(&locked)
+	if err != nil {
+		return errors.Wrap(err, "获取迁移锁出错")
+	}
+	if locked != 1 {
+		return fmt.Errorf("等待%d秒后仍未获得迁移锁，可能有其他实例正在执行迁移", migrationLockTimeout)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName)
+	}()
+
+	return fc()
+}
+
+func checkSchemaVersion(current uint) error {
+	if current > latestSchemaVersion() {
+		return fmt.Errorf("数据库结构版本为%d，比本程序支持的最新版本%d更新，请使用更新版本的程序", current, latestSchemaVersion())
+	}
+	return nil
+}
+
+// 以下为各个迁移使用的表结构快照
+
+type v1AppDo struct {
+	gorm.Model
+	Name      string `gorm:"uniqueIndex:app;type:VARCHAR(256)"`
+	Namespace string `gorm:"uniqueIndex:app;type:VARCHAR(256)"`
+}
+
+func (v1AppDo) TableName() string {
+	return "app_dos"
+}
+
+type v1AppPodMetricsDO struct {
+	gorm.Model
+	AppId     uint   `gorm:"uniqueIndex:unique_record"`
+	Timestamp uint64 `gorm:"uniqueIndex:unique_record"`
+	Cpu       float32
+	Mem       float32
+}
+
+func (v1AppPodMetricsDO) TableName() string {
+	return "app_pod_metrics_dos"
+}
+
+type v1AppClassDO struct {
+	gorm.Model
+	AppId   uint `gorm:"uniqueIndex"`
+	ClassId uint
+	CpuMax  float32
+	MemMax  float32
+}
+
+func (v1AppClassDO) TableName() string {
+	return "app_class_dos"
+}
+
+type v1ClassSectionMetricsDO struct {
+	ID         uint `gorm:"primarykey"`
+	SectionNum uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	CpuAvg     float32
+	CpuMax     float32
+	CpuMin     float32
+	CpuP50     float32
+	CpuP90     float32
+	CpuP99     float32
+	MemAvg     float32
+	MemMax     float32
+	MemMin     float32
+	MemP50     float32
+	MemP90     float32
+	MemP99     float32
+}
+
+func (v1ClassSectionMetricsDO) TableName() string {
+	return "class_section_metrics_dos"
+}
+
+type v2AppSectionRollupDO struct {
+	AppId         uint   `gorm:"primaryKey;autoIncrement:false"`
+	Day           uint64 `gorm:"primaryKey;autoIncrement:false"`
+	SectionNum    uint   `gorm:"primaryKey;autoIncrement:false"`
+	Count         uint64
+	CpuSum        float64
+	CpuMin        float64
+	CpuMax        float64
+	CpuSketch     []byte
+	MemSum        float64
+	MemMin        float64
+	MemMax        float64
+	MemSketch     []byte
+	LastTimestamp uint64
+	UpdatedAt     time.Time
+}
+
+func (v2AppSectionRollupDO) TableName() string {
+	return "app_section_rollup_dos"
+}
+
+type v3AppClassDO struct {
+	gorm.Model
+	AppId            uint `gorm:"uniqueIndex"`
+	ClassId          uint
+	CpuMax           float32
+	MemMax           float32
+	Distance         float64
+	RunnerUpClassId  uint
+	RunnerUpDistance float64
+	Confidence       *float64
+}
+
+func (v3AppClassDO) TableName() string {
+	return "app_class_dos"
+}
+
+type v4AppClassDO struct {
+	gorm.Model
+	AppId            uint `gorm:"uniqueIndex"`
+	ClassId          uint
+	CpuMax           float32
+	MemMax           float32
+	Distance         float64
+	RunnerUpClassId  uint
+	RunnerUpDistance float64
+	Confidence       *float64
+	Provisional      bool
+}
+
+func (v4AppClassDO) TableName() string {
+	return "app_class_dos"
+}
+
+type v5AppPinDO struct {
+	ID        uint `gorm:"primarykey"`
+	AppId     uint `gorm:"uniqueIndex"`
+	ClassId   uint
+	Profile   []byte
+	Reason    string `gorm:"type:VARCHAR(1024)"`
+	CreatedBy string `gorm:"type:VARCHAR(256)"`
+	CreatedAt time.Time
+}
+
+func (v5AppPinDO) TableName() string {
+	return "app_pin_dos"
+}
+
+type v5AppPinAuditDO struct {
+	ID        uint   `gorm:"primarykey"`
+	Name      string `gorm:"index:pin_audit_app;type:VARCHAR(256)"`
+	Namespace string `gorm:"index:pin_audit_app;type:VARCHAR(256)"`
+	Action    string `gorm:"type:VARCHAR(16)"`
+	ClassId   uint
+	Custom    bool
+	Reason    string `gorm:"type:VARCHAR(1024)"`
+	Actor     string `gorm:"type:VARCHAR(256)"`
+	CreatedAt time.Time
+}
+
+func (v5AppPinAuditDO) TableName() string {
+	return "app_pin_audit_dos"
+}
+
+type v6AppClassHistoryDO struct {
+	ID              uint   `gorm:"primarykey"`
+	Name            string `gorm:"index:class_history_app;type:VARCHAR(256)"`
+	Namespace       string `gorm:"index:class_history_app;type:VARCHAR(256)"`
+	ClassId         uint
+	PreviousClassId uint
+	Distance        float64
+	Confidence      *float64
+	Provisional     bool
+	CreatedAt       time.Time
+}
+
+func (v6AppClassHistoryDO) TableName() string {
+	return "app_class_history_dos"
+}
+
+type v7ClassificationEventDO struct {
+	ID        uint64 `gorm:"primarykey"`
+	Type      string `gorm:"type:VARCHAR(16)"`
+	Kind      string `gorm:"type:VARCHAR(16)"`
+	Object    []byte
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (v7ClassificationEventDO) TableName() string {
+	return "classification_event_dos"
+}
+
+type v8AppDo struct {
+	gorm.Model
+	Name      string `gorm:"uniqueIndex:app;type:VARCHAR(256)"`
+	Namespace string `gorm:"uniqueIndex:app;type:VARCHAR(256)"`
+	Cluster   string `gorm:"uniqueIndex:app;type:VARCHAR(64);not null;default:''"`
+}
+
+func (v8AppDo) TableName() string {
+	return "app_dos"
+}
+
+type v8AppPinAuditDO struct {
+	ID        uint   `gorm:"primarykey"`
+	Name      string `gorm:"index:pin_audit_app;type:VARCHAR(256)"`
+	Namespace string `gorm:"index:pin_audit_app;type:VARCHAR(256)"`
+	Cluster   string `gorm:"index:pin_audit_app;type:VARCHAR(64);not null;default:''"`
+	Action    string `gorm:"type:VARCHAR(16)"`
+	ClassId   uint
+	Custom    bool
+	Reason    string `gorm:"type:VARCHAR(1024)"`
+	Actor     string `gorm:"type:VARCHAR(256)"`
+	CreatedAt time.Time
+}
+
+func (v8AppPinAuditDO) TableName() string {
+	return "app_pin_audit_dos"
+}
+
+type v8AppClassHistoryDO struct {
+	ID              uint   `gorm:"primarykey"`
+	Name            string `gorm:"index:class_history_app;type:VARCHAR(256)"`
+	Namespace       string `gorm:"index:class_history_app;type:VARCHAR(256)"`
+	Cluster         string `gorm:"index:class_history_app;type:VARCHAR(64);not null;default:''"`
+	ClassId         uint
+	PreviousClassId uint
+	Distance        float64
+	Confidence      *float64
+	Provisional     bool
+	CreatedAt       time.Time
+}
+
+func (v8AppClassHistoryDO) TableName() string {
+	return "app_class_history_dos"
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMigrations_Order(t *testing.T) {
+	for i, mg := range migrations {
+		assert.Equal(t, uint(i+1), mg.version, "迁移版本号应从1开始连续递增")
+		assert.NotEmpty(t, mg.name)
+		assert.NotNil(t, mg.up)
+		assert.NotNil(t, mg.down)
+	}
+	assert.Equal(t, uint(len(migrations)), latestSchemaVersion())
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	assert.NoError(t, checkSchemaVersion(0))
+	assert.NoError(t, checkSchemaVersion(latestSchemaVersion()))
+	assert.Error(t, checkSchemaVersion(latestSchemaVersion()+1))
+}
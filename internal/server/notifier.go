@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultWebhookRetries = 3
+
+	webhookTimeout   = 10 * time.Second
+	webhookBackoff   = time.Second // 第一次重试前的等待时间，之后每次加倍
+	webhookQueueSize = 16          // 等待发送的通知的最大数量
+)
+
+// 再聚类后将分类发生变化的应用POST到配置的URL
+type webhookNotifier struct {
+	url     string
+	retries uint
+	backoff time.Duration
+	client  *http.Client
+	logger  *log.Logger
+	metrics *serverMetrics
+	queue   chan *server.ClassChangeNotification
+}
+
+func newWebhookNotifier(config *ServerConfig, logger *log.Logger, metrics *serverMetrics) *webhookNotifier {
+	return &webhookNotifier{
+		url:     config.ClassChangeWebhook,
+		retries: config.ClassChangeWebhookRetries,
+		backoff: webhookBackoff,
+		client:  &http.Client{Timeout: webhookTimeout},
+		logger:  logger,
+		metrics: metrics,
+		queue:   make(chan *server.ClassChangeNotification, webhookQueueSize),
+	}
+}
+
+// 将通知放入队列后立即返回，由run发送，使再聚类不必等待webhook。队列已满时丢弃通知
+func (n *webhookNotifier) enqueue(notification *server.ClassChangeNotification) {
+	select {
+	case n.queue <- notification:
+	default:
+		n.metrics.webhookTotal.WithLabelValues(resultFailure).Inc()
+		n.logger.Printf("分类变化通知队列已满，丢弃%d个应用的分类变化通知\n", len(notification.Changes))
+	}
+}
+
+// 依次发送队列中的通知，直到ctx结束。ctx结束时停止正在进行的重试，并丢弃队列中尚未发送的通知
+func (n *webhookNotifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			n.discardQueued()
+			return
+		case notification := <-n.queue:
+			n.notify(ctx, notification)
+		}
+	}
+}
+
+func (n *webhookNotifier) discardQueued() {
+	for {
+		select {
+		case notification := <-n.queue:
+			n.metrics.webhookTotal.WithLabelValues(resultFailure).Inc()
+			n.logger.Printf("通知线程退出，丢弃%d个应用的分类变化通知\n", len(notification.Changes))
+		default:
+			return
+		}
+	}
+}
+
+// 发送通知，失败时按指数退避重试，最多重试n.retries次。通知失败不影响再聚类，只记录日志
+func (n *webhookNotifier) notify(ctx context.Context, notification *server.ClassChangeNotification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		n.logger.Printf("序列化分类变化通知出错：%v\n", err)
+		return
+	}
+
+	backoff := n.backoff
+	for attempt := uint(0); ; attempt++ {
+		err = n.post(ctx, body)
+		if err == nil {
+			n.metrics.webhookTotal.WithLabelValues(resultSuccess).Inc()
+			n.logger.Printf("已通知%d个应用的分类变化\n", len(notification.Changes))
+			return
+		}
+		if attempt >= n.retries || !isRetryable(err) {
+			break
+		}
+		n.logger.Printf("发送分类变化通知失败，%s后第%d次重试：%v\n", backoff, attempt+1, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+		case <-timer.C:
+			backoff *= 2
+			continue
+		}
+		break
+	}
+	n.metrics.webhookTotal.WithLabelValues(resultFailure).Inc()
+	n.logger.Printf("发送分类变化通知失败，放弃发送：%v\n", err)
+}
+
+// webhook返回的错误状态码。4xx中除429外的状态码表示请求本身有问题，重试没有意义
+type webhookStatusError int
+
+func (e webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook返回状态码%d", int(e))
+}
+
+func isRetryable(err error) bool {
+	if code, ok := err.(webhookStatusError); ok {
+		return code >= 500 || code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+func (n *webhookNotifier) post(ctx context.Context, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "创建请求出错")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := n.client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "请求webhook出错")
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return webhookStatusError(response.StatusCode)
+	}
+	return nil
+}
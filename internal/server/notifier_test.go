@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var attempts int32
+	status := int32(http.StatusOK)
+	webhook := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+		writer.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer webhook.Close()
+
+	metrics := newServerMetrics()
+	notifier := newWebhookNotifier(&ServerConfig{ClassChangeWebhook: webhook.URL, ClassChangeWebhookRetries: 2},
+		log.New(os.Stdout, "", 0), metrics)
+	notifier.backoff = time.Millisecond
+	notification := &server.ClassChangeNotification{Changes: []*server.AppClassHistory{{ClassId: 2, PreviousClassId: 1}}}
+
+	notifier.notify(context.Background(), notification)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.webhookTotal.WithLabelValues(resultSuccess)))
+
+	// 服务端错误重试至多2次
+	atomic.StoreInt32(&attempts, 0)
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+	notifier.notify(context.Background(), notification)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.webhookTotal.WithLabelValues(resultFailure)))
+
+	// 请求本身有问题时不重试
+	atomic.StoreInt32(&attempts, 0)
+	atomic.StoreInt32(&status, http.StatusBadRequest)
+	notifier.notify(context.Background(), notification)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// ctx结束后不再重试
+	atomic.StoreInt32(&attempts, 0)
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	notifier.backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	notifier.notify(ctx, notification)
+	assert.True(t, time.Since(start) < time.Minute)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// 队列已满时丢弃新的通知，通知线程退出时丢弃尚未发送的通知
+	failures := testutil.ToFloat64(metrics.webhookTotal.WithLabelValues(resultFailure))
+	for i := 0; i <= webhookQueueSize; i++ {
+		notifier.enqueue(notification)
+	}
+	assert.Equal(t, failures+1, testutil.ToFloat64(metrics.webhookTotal.WithLabelValues(resultFailure)))
+	cancelled, cancelRun := context.WithCancel(context.Background())
+	cancelRun()
+	notifier.run(cancelled)
+	assert.Equal(t, 0, len(notifier.queue))
+}
@@ -0,0 +1,856 @@
+package server
+
+import (
+	"net/http"
+)
+
+// HTTP API的OpenAPI文档，修改buildServer或pkg/client时需要同步修改，由openapi_test.go检查二者是否与文档一致。
+// 错误响应为http.Error写入的纯文本
+const openapiDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "workload-classifier",
+    "description": "应用负载分类服务的HTTP API。启用认证后，除存活检查、就绪检查、监控指标与本文档外的API都需要bearer token",
+    "version": "1"
+  },
+  "security": [{"bearerAuth": []}],
+  "paths": {
+    "/namespaces/{namespace}/appcharacteristics/{name}": {
+      "get": {
+        "summary": "查询应用的运行特征",
+        "operationId": "queryAppCharacteristics",
+        "parameters": [
+          {"$ref": "#/components/parameters/namespace"},
+          {"$ref": "#/components/parameters/name"},
+          {"$ref": "#/components/parameters/cluster"}
+        ],
+        "responses": {
+          "200": {
+            "description": "应用各Section的运行特征与分类",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AppCharacteristics"}}}
+          },
+          "400": {"$ref": "#/components/responses/AppNotClassified"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/namespaces/{namespace}/appprofile/{name}": {
+      "get": {
+        "summary": "查询应用自身的运行画像",
+        "operationId": "queryAppProfile",
+        "parameters": [
+          {"$ref": "#/components/parameters/namespace"},
+          {"$ref": "#/components/parameters/name"},
+          {"$ref": "#/components/parameters/cluster"}
+        ],
+        "responses": {
+          "200": {
+            "description": "由保留时间内的监控数据计算的画像",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AppProfile"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/namespaces/{namespace}/appclasshistory/{name}": {
+      "get": {
+        "summary": "查询应用分类的变化历史",
+        "operationId": "queryAppClassHistory",
+        "parameters": [
+          {"$ref": "#/components/parameters/namespace"},
+          {"$ref": "#/components/parameters/name"},
+          {"$ref": "#/components/parameters/cluster"}
+        ],
+        "responses": {
+          "200": {
+            "description": "按时间先后排序的分类变化",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/AppClassHistory"}}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/recluster": {
+      "get": {
+        "summary": "立即执行一次再聚类",
+        "description": "需要admin权限。再聚类在后台执行，请求立即返回。只有leader接受请求",
+        "operationId": "reCluster",
+        "responses": {
+          "200": {"$ref": "#/components/responses/OK"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "409": {
+            "description": "正在再聚类",
+            "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+          },
+          "503": {
+            "description": "本实例不是leader，可以重试",
+            "headers": {"Retry-After": {"schema": {"type": "integer", "minimum": 1}}},
+            "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+          }
+        }
+      }
+    },
+    "/recluster/schedule": {
+      "get": {
+        "summary": "查询再聚类的调度计划",
+        "operationId": "queryReClusterSchedule",
+        "responses": {
+          "200": {
+            "description": "调度计划与下一次执行的时间",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReClusterSchedule"}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/recluster/summary": {
+      "get": {
+        "summary": "查询本实例最近一次再聚类的概况",
+        "operationId": "queryReClusterSummary",
+        "responses": {
+          "200": {
+            "description": "再聚类的概况",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReClusterSummary"}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/recluster/dryrun": {
+      "post": {
+        "summary": "试运行再聚类",
+        "description": "需要admin权限。不修改数据库，客户端断开后中止",
+        "operationId": "reClusterDryRun",
+        "requestBody": {
+          "required": false,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReClusterParams"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "提议的类别中心与分类变化",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReClusterDryRun"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/classification": {
+      "get": {
+        "summary": "查询全部类别中心与应用分类的快照",
+        "operationId": "queryClassificationSnapshot",
+        "parameters": [{"$ref": "#/components/parameters/clusterFilter"}],
+        "responses": {
+          "200": {
+            "description": "快照及对应的resourceVersion",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ClassificationSnapshot"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/classification/watch": {
+      "get": {
+        "summary": "持续获取分类的变化",
+        "description": "以分块传输的方式推送事件，每行一个ClassificationEvent",
+        "operationId": "watchClassification",
+        "parameters": [
+          {
+            "name": "resourceVersion",
+            "in": "query",
+            "description": "从此版本之后开始推送，不指定则只推送之后的变化",
+            "schema": {"type": "integer", "format": "int64", "minimum": 0}
+          },
+          {
+            "name": "timeoutSeconds",
+            "in": "query",
+            "description": "连接的持续时间，默认为30分钟，最长为1小时",
+            "schema": {"type": "integer", "minimum": 1}
+          },
+          {"$ref": "#/components/parameters/clusterFilter"}
+        ],
+        "responses": {
+          "200": {
+            "description": "换行分隔的事件流",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ClassificationEvent"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "410": {
+            "description": "resourceVersion之后的部分变化已被清理，需要重新获取快照",
+            "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+          },
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/admin/state": {
+      "get": {
+        "summary": "导出状态",
+        "description": "需要admin权限",
+        "operationId": "exportState",
+        "parameters": [
+          {
+            "name": "metrics",
+            "in": "query",
+            "description": "是否包含原始监控数据",
+            "schema": {"type": "boolean", "default": false}
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "tar.gz格式的状态归档",
+            "content": {"application/gzip": {"schema": {"type": "string", "format": "binary"}}}
+          },
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      },
+      "post": {
+        "summary": "导入状态",
+        "description": "需要admin权限，覆盖现有的类别中心与应用分类",
+        "operationId": "importState",
+        "requestBody": {
+          "required": true,
+          "content": {"application/gzip": {"schema": {"type": "string", "format": "binary"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "导入的内容",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StateManifest"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"}
+        }
+      }
+    },
+    "/admin/pins": {
+      "get": {
+        "summary": "列出所有固定的应用分类",
+        "description": "需要admin权限",
+        "operationId": "listAppPins",
+        "parameters": [{"$ref": "#/components/parameters/clusterFilter"}],
+        "responses": {
+          "200": {
+            "description": "所有固定的应用分类",
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/AppPin"}}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/admin/pins/{namespace}/{name}": {
+      "parameters": [
+        {"$ref": "#/components/parameters/namespace"},
+        {"$ref": "#/components/parameters/name"},
+        {"$ref": "#/components/parameters/cluster"}
+      ],
+      "get": {
+        "summary": "查询应用的固定分类与审计记录",
+        "description": "需要admin权限",
+        "operationId": "queryAppPin",
+        "responses": {
+          "200": {
+            "description": "固定分类与审计记录",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AppPinDetail"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      },
+      "put": {
+        "summary": "固定应用的分类",
+        "description": "需要admin权限",
+        "operationId": "pinApp",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AppPinRequest"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "保存的固定分类",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AppPin"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      },
+      "delete": {
+        "summary": "取消固定应用的分类",
+        "description": "需要admin权限",
+        "operationId": "unpinApp",
+        "parameters": [
+          {"name": "reason", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "by", "in": "query", "description": "操作人，启用认证时被忽略", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/OK"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/ingest": {
+      "post": {
+        "summary": "推送JSON格式的监控数据",
+        "description": "需要ingest或admin权限。与获取的监控数据一样保存，有不合法的样本时整批拒绝。按来源（认证的用户或客户端地址）限制样本数",
+        "operationId": "ingestMetrics",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/AppPodMetrics"}}}}
+        },
+        "responses": {
+          "200": {
+            "description": "保存的样本数",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IngestResult"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "413": {"$ref": "#/components/responses/PayloadTooLarge"},
+          "429": {"$ref": "#/components/responses/TooManyRequests"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/ingest/remote-write": {
+      "post": {
+        "summary": "接收Prometheus remote write推送的监控数据",
+        "description": "需要ingest或admin权限。只处理workload_classifier_app_cpu_usage_cores与workload_classifier_app_memory_usage_bytes指标，按app、namespace与可选的cluster标签确定应用，除__name__外标签与时间戳相同的两个样本组成一条监控数据",
+        "operationId": "ingestRemoteWrite",
+        "requestBody": {
+          "required": true,
+          "content": {"application/x-protobuf": {"schema": {"type": "string", "format": "binary", "description": "snappy压缩的prometheus.WriteRequest"}}}
+        },
+        "responses": {
+          "204": {"description": "成功"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "403": {"$ref": "#/components/responses/Forbidden"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "413": {"$ref": "#/components/responses/PayloadTooLarge"},
+          "429": {"$ref": "#/components/responses/TooManyRequests"},
+          "500": {"$ref": "#/components/responses/InternalError"}
+        }
+      }
+    },
+    "/livez": {
+      "get": {
+        "summary": "存活检查",
+        "operationId": "live",
+        "security": [],
+        "responses": {"200": {"$ref": "#/components/responses/OK"}}
+      }
+    },
+    "/healthz": {
+      "get": {
+        "summary": "旧版本的存活检查",
+        "operationId": "health",
+        "deprecated": true,
+        "security": [],
+        "responses": {"200": {"$ref": "#/components/responses/OK"}}
+      }
+    },
+    "/readyz": {
+      "get": {
+        "summary": "就绪检查",
+        "operationId": "ready",
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "所有检查通过",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReadinessReport"}}}
+          },
+          "503": {
+            "description": "存在未通过的检查",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ReadinessReport"}}}
+          }
+        }
+      }
+    },
+    "/metrics": {
+      "get": {
+        "summary": "Prometheus格式的监控指标",
+        "operationId": "metrics",
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "监控指标",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "本文档",
+        "operationId": "openapi",
+        "security": [],
+        "responses": {
+          "200": {
+            "description": "OpenAPI文档",
+            "content": {"application/json": {"schema": {"type": "object"}}}
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": {
+        "type": "http",
+        "scheme": "bearer",
+        "description": "静态token或Kubernetes ServiceAccount token，只在启用认证时需要"
+      }
+    },
+    "parameters": {
+      "namespace": {
+        "name": "namespace",
+        "in": "path",
+        "required": true,
+        "schema": {"$ref": "#/components/schemas/Name"}
+      },
+      "name": {
+        "name": "name",
+        "in": "path",
+        "required": true,
+        "schema": {"$ref": "#/components/schemas/Name"}
+      },
+      "cluster": {
+        "name": "cluster",
+        "in": "query",
+        "description": "应用所在的集群，不指定则为服务器所在的集群",
+        "schema": {"$ref": "#/components/schemas/Cluster"}
+      },
+      "clusterFilter": {
+        "name": "cluster",
+        "in": "query",
+        "description": "只包含此集群的应用，不指定则包含所有集群。类别中心为各集群共用",
+        "schema": {"$ref": "#/components/schemas/Cluster"}
+      }
+    },
+    "responses": {
+      "OK": {
+        "description": "成功",
+        "content": {"text/plain": {"schema": {"type": "string", "enum": ["OK"]}}}
+      },
+      "BadRequest": {
+        "description": "请求的参数错误",
+        "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      },
+      "AppNotClassified": {
+        "description": "应用尚未分类，或集群参数错误",
+        "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      },
+      "Unauthorized": {
+        "description": "启用了认证，但请求没有token或token无效",
+        "headers": {"WWW-Authenticate": {"schema": {"type": "string"}}},
+        "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      },
+      "Forbidden": {
+        "description": "没有所需的权限",
+        "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      },
+      "NotFound": {
+        "description": "对象不存在",
+        "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      },
+      "MethodNotAllowed": {
+        "description": "不支持的请求方法",
+        "headers": {"Allow": {"schema": {"type": "string"}}},
+        "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      },
+      "PayloadTooLarge": {
+        "description": "单次推送的样本数超过上限",
+        "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      },
+      "TooManyRequests": {
+        "description": "推送的样本数超过速率限制",
+        "headers": {"Retry-After": {"schema": {"type": "integer", "minimum": 1}}},
+        "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      },
+      "InternalError": {
+        "description": "服务器内部错误",
+        "content": {"text/plain": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      }
+    },
+    "schemas": {
+      "Error": {
+        "type": "string",
+        "description": "错误信息"
+      },
+      "Name": {
+        "type": "string",
+        "pattern": "^(?:[\\d\\w][\\d\\w-.]{0,251}[\\d\\w]|[\\d\\w])$"
+      },
+      "Cluster": {
+        "type": "string",
+        "maxLength": 63,
+        "pattern": "^(?:[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?)?$"
+      },
+      "SectionData": {
+        "type": "object",
+        "description": "一个Section内的CPU与内存统计值",
+        "required": ["cpuAvg", "cpuMax", "cpuMin", "cpuP50", "cpuP90", "cpuP99", "memAvg", "memMax", "memMin", "memP50", "memP90", "memP99"],
+        "properties": {
+          "cpuAvg": {"type": "number", "format": "float"},
+          "cpuMax": {"type": "number", "format": "float"},
+          "cpuMin": {"type": "number", "format": "float"},
+          "cpuP50": {"type": "number", "format": "float"},
+          "cpuP90": {"type": "number", "format": "float"},
+          "cpuP99": {"type": "number", "format": "float"},
+          "memAvg": {"type": "number", "format": "float"},
+          "memMax": {"type": "number", "format": "float"},
+          "memMin": {"type": "number", "format": "float"},
+          "memP50": {"type": "number", "format": "float"},
+          "memP90": {"type": "number", "format": "float"},
+          "memP99": {"type": "number", "format": "float"}
+        },
+        "additionalProperties": false
+      },
+      "AppCharacteristics": {
+        "type": "object",
+        "required": ["Name", "Namespace", "sectionData", "classId", "distance"],
+        "properties": {
+          "Name": {"type": "string"},
+          "Namespace": {"type": "string"},
+          "Cluster": {"type": "string", "description": "应用所在的集群，为空表示服务器所在的集群"},
+          "sectionData": {"type": "array", "items": {"$ref": "#/components/schemas/SectionData"}},
+          "classId": {"type": "integer", "minimum": 0},
+          "distance": {"type": "number"},
+          "runnerUpClassId": {"type": "integer", "minimum": 0},
+          "confidence": {"type": "number", "minimum": 0, "maximum": 1, "description": "不存在表示分类结果没有置信度数据"},
+          "provisional": {"type": "boolean", "description": "应用数据不足，分类是暂定的"},
+          "pinned": {"type": "boolean", "description": "分类由运维人员手动固定"},
+          "pinStale": {"type": "boolean", "description": "应用固定的类别已不存在，固定分类没有生效，结果为应用当前的分类"}
+        },
+        "additionalProperties": false
+      },
+      "AppProfile": {
+        "type": "object",
+        "required": ["Name", "Namespace", "sectionData", "sampleCount", "coverage", "classified"],
+        "properties": {
+          "Name": {"type": "string"},
+          "Namespace": {"type": "string"},
+          "Cluster": {"type": "string", "description": "应用所在的集群，为空表示服务器所在的集群"},
+          "sectionData": {
+            "type": "array",
+            "description": "没有数据的Section为null",
+            "items": {"allOf": [{"$ref": "#/components/schemas/SectionData"}], "nullable": true}
+          },
+          "sampleCount": {"type": "array", "items": {"type": "integer", "minimum": 0}},
+          "coverage": {"type": "array", "items": {"type": "number", "minimum": 0, "maximum": 1}},
+          "classified": {"type": "boolean"},
+          "classId": {"type": "integer", "minimum": 0},
+          "distance": {"type": "number"}
+        },
+        "additionalProperties": false
+      },
+      "AppClassHistory": {
+        "type": "object",
+        "required": ["Name", "Namespace", "classId", "previousClassId", "distance", "time"],
+        "properties": {
+          "Name": {"type": "string"},
+          "Namespace": {"type": "string"},
+          "Cluster": {"type": "string", "description": "应用所在的集群，为空表示服务器所在的集群"},
+          "classId": {"type": "integer", "minimum": 0},
+          "previousClassId": {"type": "integer", "minimum": 0, "description": "首次分类时为0"},
+          "distance": {"type": "number"},
+          "confidence": {"type": "number", "minimum": 0, "maximum": 1},
+          "provisional": {"type": "boolean"},
+          "time": {"type": "string", "format": "date-time"}
+        },
+        "additionalProperties": false
+      },
+      "ClassMetrics": {
+        "type": "object",
+        "description": "标准化后的类别中心",
+        "required": ["classId", "data"],
+        "properties": {
+          "classId": {"type": "integer", "minimum": 0},
+          "data": {
+            "type": "array",
+            "nullable": true,
+            "description": "各Section的数据。watch的删除事件中为null",
+            "items": {"$ref": "#/components/schemas/SectionData"}
+          }
+        },
+        "additionalProperties": false
+      },
+      "ReClusterSchedule": {
+        "type": "object",
+        "required": ["schedule", "timeZone", "next"],
+        "properties": {
+          "schedule": {"type": "string", "description": "cron表达式或者@every形式的固定间隔"},
+          "timeZone": {"type": "string"},
+          "next": {"type": "string", "format": "date-time", "description": "尚未计算出时为零值"}
+        },
+        "additionalProperties": false
+      },
+      "ReClusterSummary": {
+        "type": "object",
+        "required": ["startedAt", "finishedAt", "numApps", "numClustered", "ineligible", "inertia", "meanDistance", "numChanged"],
+        "properties": {
+          "startedAt": {"type": "string", "format": "date-time"},
+          "finishedAt": {"type": "string", "format": "date-time"},
+          "numApps": {"type": "integer", "minimum": 0},
+          "numClustered": {"type": "integer", "minimum": 0},
+          "ineligible": {
+            "type": "object",
+            "nullable": true,
+            "description": "按原因统计的不参与计算类别中心的应用数量",
+            "additionalProperties": {"type": "integer", "minimum": 0}
+          },
+          "inertia": {"type": "number"},
+          "meanDistance": {"type": "number"},
+          "numChanged": {"type": "integer", "minimum": 0}
+        },
+        "additionalProperties": false
+      },
+      "ReClusterParams": {
+        "type": "object",
+        "description": "为0时使用服务器的配置",
+        "properties": {
+          "numClass": {"type": "integer", "minimum": 0},
+          "numRound": {"type": "integer", "minimum": 0}
+        },
+        "additionalProperties": false
+      },
+      "CenterShift": {
+        "type": "object",
+        "required": ["classId", "distance", "numApps"],
+        "properties": {
+          "classId": {"type": "integer", "minimum": 0},
+          "matchedClassId": {"type": "integer", "minimum": 0, "description": "不存在表示新增的类别"},
+          "distance": {"type": "number"},
+          "numApps": {"type": "integer", "minimum": 0}
+        },
+        "additionalProperties": false
+      },
+      "AppClassChange": {
+        "type": "object",
+        "required": ["Name", "Namespace", "currentClassId", "proposedClassId"],
+        "properties": {
+          "Name": {"type": "string"},
+          "Namespace": {"type": "string"},
+          "Cluster": {"type": "string", "description": "应用所在的集群，为空表示服务器所在的集群"},
+          "currentClassId": {"type": "integer", "minimum": 0},
+          "proposedClassId": {"type": "integer", "minimum": 0},
+          "matchedClassId": {"type": "integer", "minimum": 0},
+          "provisional": {"type": "boolean"},
+          "pinned": {"type": "boolean"}
+        },
+        "additionalProperties": false
+      },
+      "ReClusterDryRun": {
+        "type": "object",
+        "required": ["params", "summary", "centers", "centerShifts", "removedClasses", "changes", "numUnchanged", "numNewApps"],
+        "properties": {
+          "params": {"$ref": "#/components/schemas/ReClusterParams"},
+          "summary": {"$ref": "#/components/schemas/ReClusterSummary"},
+          "centers": {"type": "array", "items": {"$ref": "#/components/schemas/ClassMetrics"}},
+          "centerShifts": {"type": "array", "items": {"$ref": "#/components/schemas/CenterShift"}},
+          "removedClasses": {"type": "array", "items": {"type": "integer", "minimum": 0}},
+          "changes": {"type": "array", "items": {"$ref": "#/components/schemas/AppClassChange"}},
+          "numUnchanged": {"type": "integer", "minimum": 0},
+          "numNewApps": {"type": "integer", "minimum": 0}
+        },
+        "additionalProperties": false
+      },
+      "AppAssignment": {
+        "type": "object",
+        "required": ["Name", "Namespace", "classId", "cpuMax", "memMax", "distance"],
+        "properties": {
+          "Name": {"type": "string"},
+          "Namespace": {"type": "string"},
+          "Cluster": {"type": "string", "description": "应用所在的集群，为空表示服务器所在的集群"},
+          "classId": {"type": "integer", "minimum": 0},
+          "cpuMax": {"type": "number", "format": "float"},
+          "memMax": {"type": "number", "format": "float"},
+          "distance": {"type": "number"},
+          "runnerUpClassId": {"type": "integer", "minimum": 0},
+          "confidence": {"type": "number", "minimum": 0, "maximum": 1},
+          "provisional": {"type": "boolean"}
+        },
+        "additionalProperties": false
+      },
+      "ClassificationSnapshot": {
+        "type": "object",
+        "required": ["resourceVersion", "centers", "apps", "pins"],
+        "properties": {
+          "resourceVersion": {"type": "integer", "format": "int64", "minimum": 0},
+          "centers": {"type": "array", "nullable": true, "items": {"$ref": "#/components/schemas/ClassMetrics"}},
+          "apps": {"type": "array", "items": {"$ref": "#/components/schemas/AppAssignment"}},
+          "pins": {"type": "array", "items": {"$ref": "#/components/schemas/AppPin"}}
+        },
+        "additionalProperties": false
+      },
+      "ClassificationEvent": {
+        "type": "object",
+        "required": ["type", "resourceVersion"],
+        "properties": {
+          "type": {"type": "string", "enum": ["MODIFIED", "DELETED", "BOOKMARK", "ERROR"]},
+          "kind": {"type": "string", "enum": ["ClassMetrics", "AppAssignment", "AppPin"]},
+          "resourceVersion": {"type": "integer", "format": "int64", "minimum": 0},
+          "classMetrics": {"$ref": "#/components/schemas/ClassMetrics"},
+          "appAssignment": {"$ref": "#/components/schemas/AppAssignment"},
+          "appPin": {"$ref": "#/components/schemas/AppPin"},
+          "code": {"type": "integer", "description": "type为ERROR时的HTTP状态码"},
+          "message": {"type": "string"}
+        },
+        "additionalProperties": false
+      },
+      "AppPin": {
+        "type": "object",
+        "required": ["Name", "Namespace", "reason", "createdBy", "createdAt"],
+        "properties": {
+          "Name": {"type": "string"},
+          "Namespace": {"type": "string"},
+          "Cluster": {"type": "string", "description": "应用所在的集群，为空表示服务器所在的集群"},
+          "classId": {"type": "integer", "minimum": 0, "description": "固定到的类别，与profile二选一"},
+          "profile": {"type": "array", "items": {"$ref": "#/components/schemas/SectionData"}},
+          "reason": {"type": "string"},
+          "createdBy": {"type": "string"},
+          "createdAt": {"type": "string", "format": "date-time"}
+        },
+        "additionalProperties": false
+      },
+      "AppPinAudit": {
+        "type": "object",
+        "required": ["Name", "Namespace", "action", "reason", "actor", "time"],
+        "properties": {
+          "Name": {"type": "string"},
+          "Namespace": {"type": "string"},
+          "Cluster": {"type": "string", "description": "应用所在的集群，为空表示服务器所在的集群"},
+          "action": {"type": "string", "enum": ["pin", "unpin"]},
+          "classId": {"type": "integer", "minimum": 0},
+          "custom": {"type": "boolean"},
+          "reason": {"type": "string"},
+          "actor": {"type": "string"},
+          "time": {"type": "string", "format": "date-time"}
+        },
+        "additionalProperties": false
+      },
+      "AppPinRequest": {
+        "type": "object",
+        "required": ["reason"],
+        "properties": {
+          "classId": {"type": "integer", "minimum": 0, "description": "固定到的类别，与profile二选一"},
+          "profile": {"type": "array", "description": "自定义的运行特征，需要包含所有Section", "items": {"$ref": "#/components/schemas/SectionData"}},
+          "reason": {"type": "string"},
+          "by": {"type": "string", "description": "操作人，启用认证时被忽略"}
+        },
+        "additionalProperties": false
+      },
+      "AppPinDetail": {
+        "type": "object",
+        "required": ["pin", "audits"],
+        "properties": {
+          "pin": {"allOf": [{"$ref": "#/components/schemas/AppPin"}], "nullable": true, "description": "没有被固定时为null"},
+          "audits": {"type": "array", "items": {"$ref": "#/components/schemas/AppPinAudit"}}
+        },
+        "additionalProperties": false
+      },
+      "StateManifest": {
+        "type": "object",
+        "required": ["formatVersion", "createdAt", "classIds", "numAppClasses", "numPins", "includeMetrics", "numMetrics"],
+        "properties": {
+          "formatVersion": {"type": "integer"},
+          "createdAt": {"type": "string", "format": "date-time"},
+          "classIds": {"type": "array", "items": {"type": "integer", "minimum": 0}},
+          "numAppClasses": {"type": "integer", "minimum": 0},
+          "numPins": {"type": "integer", "minimum": 0},
+          "includeMetrics": {"type": "boolean"},
+          "numMetrics": {"type": "integer", "minimum": 0}
+        },
+        "additionalProperties": false
+      },
+      "AppPodMetrics": {
+        "type": "object",
+        "required": ["Name", "Namespace", "Timestamp", "Cpu", "Mem"],
+        "properties": {
+          "Name": {"$ref": "#/components/schemas/Name"},
+          "Namespace": {"$ref": "#/components/schemas/Name"},
+          "Cluster": {"allOf": [{"$ref": "#/components/schemas/Cluster"}], "description": "应用所在的集群，为空表示服务器所在的集群"},
+          "Timestamp": {"type": "integer", "format": "int64", "minimum": 0, "description": "Unix时间戳，单位为秒。不能早于数据保留时间，也不能比服务器的当前时间晚5分钟以上"},
+          "Cpu": {"type": "number", "format": "float", "minimum": 0, "description": "CPU使用量，单位为核"},
+          "Mem": {"type": "number", "format": "float", "minimum": 0, "description": "内存使用量，单位为字节"}
+        },
+        "additionalProperties": false
+      },
+      "IngestResult": {
+        "type": "object",
+        "required": ["accepted"],
+        "properties": {
+          "accepted": {"type": "integer", "minimum": 0, "description": "保存的监控数据条数"}
+        },
+        "additionalProperties": false
+      },
+      "ReadinessReport": {
+        "type": "object",
+        "required": ["status", "checks"],
+        "properties": {
+          "status": {"type": "string", "enum": ["ok", "fail"]},
+          "checks": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["name", "status"],
+              "properties": {
+                "name": {"type": "string"},
+                "status": {"type": "string", "enum": ["ok", "fail"]},
+                "message": {"type": "string"}
+              },
+              "additionalProperties": false
+            }
+          }
+        },
+        "additionalProperties": false
+      }
+    }
+  }
+}
+`
+
+func handleOpenAPI(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	_, _ = writer.Write([]byte(openapiDocument))
+}
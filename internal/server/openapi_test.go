@@ -0,0 +1,523 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/client"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 文档中的一个操作
+type openapiOperation struct {
+	method  string
+	path    string
+	pattern *regexp.Regexp
+	spec    map[string]interface{}
+}
+
+// 按OpenAPI文档检查请求与响应，只支持文档中用到的关键字
+type openapiContract struct {
+	doc        map[string]interface{}
+	operations []*openapiOperation
+
+	lock   sync.Mutex
+	called map[*openapiOperation]bool
+}
+
+var openapiPathParam = regexp.MustCompile("{[^/]+}")
+
+func loadOpenapiContract(t *testing.T) *openapiContract {
+	c := &openapiContract{called: make(map[*openapiOperation]bool)}
+	decoder := json.NewDecoder(strings.NewReader(openapiDocument))
+	decoder.UseNumber()
+	if !assert.NoError(t, decoder.Decode(&c.doc), "文档不是合法的JSON") {
+		assert.FailNow(t, "无法解析文档")
+	}
+
+	paths := c.doc["paths"].(map[string]interface{})
+	for path, item := range paths {
+		pattern := regexp.MustCompile("^" + openapiPathParam.ReplaceAllString(path, "[^/]+") + "$")
+		for method, spec := range item.(map[string]interface{}) {
+			if method == "parameters" {
+				continue
+			}
+			c.operations = append(c.operations, &openapiOperation{
+				method:  strings.ToUpper(method),
+				path:    path,
+				pattern: pattern,
+				spec:    spec.(map[string]interface{}),
+			})
+		}
+	}
+	sort.Slice(c.operations, func(i, j int) bool {
+		return c.operations[i].path+c.operations[i].method < c.operations[j].path+c.operations[j].method
+	})
+	return c
+}
+
+// 跟随$ref，返回实际的对象
+func (c *openapiContract) resolve(node interface{}) map[string]interface{} {
+	object, _ := node.(map[string]interface{})
+	for object != nil {
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object
+		}
+		var current interface{} = c.doc
+		for _, segment := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			m, _ := current.(map[string]interface{})
+			current = m[segment]
+		}
+		if current == nil {
+			panic(fmt.Sprintf("无法解析%s", ref))
+		}
+		object = current.(map[string]interface{})
+	}
+	return object
+}
+
+// 检查所有的$ref都能解析
+func (c *openapiContract) checkRefs(t *testing.T, node interface{}) {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := node["$ref"]; ok {
+			assert.NotPanics(t, func() { c.resolve(node) }, "%v", ref)
+		}
+		for _, v := range node {
+			c.checkRefs(t, v)
+		}
+	case []interface{}:
+		for _, v := range node {
+			c.checkRefs(t, v)
+		}
+	}
+}
+
+func (c *openapiContract) find(method, path string) *openapiOperation {
+	for _, op := range c.operations {
+		if op.method == method && op.pattern.MatchString(path) {
+			return op
+		}
+	}
+	return nil
+}
+
+// 检查value是否符合schema，at为value在响应中的位置
+func (c *openapiContract) validate(schema interface{}, value interface{}, at string) error {
+	s := c.resolve(schema)
+	if value == nil {
+		if s["nullable"] == true {
+			return nil
+		}
+		return fmt.Errorf("%s不能为null", at)
+	}
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if err := c.validate(sub, value, at); err != nil {
+				return err
+			}
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || fmt.Sprint(e) == fmt.Sprint(value)
+		}
+		if !found {
+			return fmt.Errorf("%s的值%v不在%v中", at, value, enum)
+		}
+	}
+
+	switch s["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s应为对象", at)
+		}
+		properties, _ := s["properties"].(map[string]interface{})
+		required, _ := s["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				return fmt.Errorf("%s缺少字段%s", at, name)
+			}
+		}
+		for name, v := range object {
+			if property, ok := properties[name]; ok {
+				if err := c.validate(property, v, at+"."+name); err != nil {
+					return err
+				}
+				continue
+			}
+			switch additional := s["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fmt.Errorf("%s有文档中没有的字段%s", at, name)
+				}
+			case map[string]interface{}:
+				if err := c.validate(additional, v, at+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s应为数组", at)
+		}
+		for i, item := range array {
+			if err := c.validate(s["items"], item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s应为字符串", at)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s应为布尔值", at)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s应为数字", at)
+		}
+		if s["type"] == "integer" && strings.ContainsAny(number.String(), ".eE") {
+			return fmt.Errorf("%s应为整数，现在为%s", at, number)
+		}
+		f, _ := number.Float64()
+		if minimum, ok := s["minimum"].(json.Number); ok {
+			if m, _ := minimum.Float64(); f < m {
+				return fmt.Errorf("%s的值%s小于%s", at, number, minimum)
+			}
+		}
+		if maximum, ok := s["maximum"].(json.Number); ok {
+			if m, _ := maximum.Float64(); f > m {
+				return fmt.Errorf("%s的值%s大于%s", at, number, maximum)
+			}
+		}
+	}
+	return nil
+}
+
+// 按content中mediaType的schema检查body。JSON的body可以是多个值，如watch的事件流
+func (c *openapiContract) validateContent(content interface{}, mediaType string, body []byte) error {
+	media, ok := c.resolve(content)[mediaType]
+	if !ok {
+		return fmt.Errorf("文档中没有%s格式的内容", mediaType)
+	}
+	schema := c.resolve(media)["schema"]
+	switch mediaType {
+	case "application/json":
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		for i := 0; ; i++ {
+			var value interface{}
+			err := decoder.Decode(&value)
+			if err == io.EOF && i > 0 {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("不是合法的JSON：%v", err)
+			}
+			if err := c.validate(schema, value, "$"); err != nil {
+				return err
+			}
+		}
+	case "text/plain":
+		return c.validate(schema, strings.TrimSpace(string(body)), "$")
+	default:
+		return nil
+	}
+}
+
+func (c *openapiContract) checkRequest(op *openapiOperation, request *http.Request) error {
+	requestBody, ok := op.spec["requestBody"]
+	if !ok {
+		return nil
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if len(body) == 0 {
+		if c.resolve(requestBody)["required"] == true {
+			return fmt.Errorf("缺少请求体")
+		}
+		return nil
+	}
+	return c.validateContent(c.resolve(requestBody)["content"], mediaType, body)
+}
+
+func (c *openapiContract) checkResponse(op *openapiOperation, status int, header http.Header, body []byte) error {
+	response, ok := c.resolve(op.spec["responses"])[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("文档中没有状态码%d", status)
+	}
+	content, ok := c.resolve(response)["content"]
+	if !ok {
+		return nil
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		// 与net/http相同，根据内容判断
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return c.validateContent(content, mediaType, body)
+}
+
+// 记录发送的响应，同时转发给原来的writer
+type contractResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *contractResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *contractResponseWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *contractResponseWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// 检查经过handler的所有请求与响应都符合文档
+func (c *openapiContract) middleware(t *testing.T, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		op := c.find(request.Method, request.URL.Path)
+		if op == nil {
+			t.Errorf("文档中没有%s %s", request.Method, request.URL.Path)
+			handler.ServeHTTP(writer, request)
+			return
+		}
+		c.lock.Lock()
+		c.called[op] = true
+		c.lock.Unlock()
+		requestErr := c.checkRequest(op, request)
+
+		recorder := &contractResponseWriter{ResponseWriter: writer, status: http.StatusOK}
+		handler.ServeHTTP(recorder, request)
+		// 不符合文档的请求应被拒绝
+		if requestErr != nil && recorder.status != http.StatusBadRequest {
+			t.Errorf("%s %s的请求不符合文档，但状态码为%d：%v", request.Method, request.URL.Path, recorder.status, requestErr)
+		}
+		// 客户端断开时流式响应可能没有内容
+		if recorder.body.Len() == 0 && request.Context().Err() != nil {
+			return
+		}
+		if err := c.checkResponse(op, recorder.status, writer.Header(), recorder.body.Bytes()); err != nil {
+			t.Errorf("%s %s的响应不符合文档：%v", request.Method, request.URL.Path, err)
+		}
+	})
+}
+
+func TestOpenapiDocument(t *testing.T) {
+	c := loadOpenapiContract(t)
+	c.checkRefs(t, c.doc)
+
+	operationIds := make(map[string]bool)
+	for _, op := range c.operations {
+		id, _ := op.spec["operationId"].(string)
+		assert.NotEmpty(t, id, "%s %s没有operationId", op.method, op.path)
+		assert.False(t, operationIds[id], "operationId %s重复", id)
+		operationIds[id] = true
+	}
+
+	// 类型的所有JSON字段都在文档中
+	types := map[string]interface{}{
+		"SectionData":            &core.SectionData{},
+		"AppCharacteristics":     &server.AppCharacteristics{},
+		"AppProfile":             &server.AppProfile{},
+		"AppClassHistory":        &server.AppClassHistory{},
+		"ClassMetrics":           &server.ClassMetrics{},
+		"ReClusterSchedule":      &server.ReClusterSchedule{},
+		"ReClusterSummary":       &server.ReClusterSummary{},
+		"ReClusterParams":        &server.ReClusterParams{NumClass: 1, NumRound: 1},
+		"CenterShift":            &server.CenterShift{MatchedClassId: 1},
+		"AppClassChange":         &server.AppClassChange{MatchedClassId: 1, Provisional: true, Pinned: true},
+		"ReClusterDryRun":        &server.ReClusterDryRun{Summary: &server.ReClusterSummary{}},
+		"AppAssignment":          &server.AppAssignment{RunnerUpClassId: 1, Provisional: true},
+		"ClassificationSnapshot": &server.ClassificationSnapshot{},
+		"AppPin":                 &server.AppPin{ClassId: 1, Profile: []*core.SectionData{}},
+		"AppPinAudit":            &server.AppPinAudit{Action: server.PinActionPin, ClassId: 1, Custom: true},
+		"AppPinRequest":          &appPinRequest{ClassId: 1, Profile: []*core.SectionData{}},
+		"AppPinDetail":           &appPinDetail{},
+		"StateManifest":          &StateManifest{},
+		"ReadinessReport":        &readinessReport{Status: readinessStatusOk, Checks: []*readinessCheck{{Status: readinessStatusOk, Message: "OK"}}},
+	}
+	schemas := c.doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for name, value := range types {
+		marshal, err := json.Marshal(value)
+		assert.NoError(t, err)
+		decoder := json.NewDecoder(bytes.NewReader(marshal))
+		decoder.UseNumber()
+		var decoded map[string]interface{}
+		assert.NoError(t, decoder.Decode(&decoded))
+		properties := c.resolve(schemas[name])["properties"].(map[string]interface{})
+		for field := range decoded {
+			_, ok := properties[field]
+			assert.True(t, ok, "%s的字段%s不在文档中", name, field)
+		}
+	}
+}
+
+func TestOpenapiContract(t *testing.T) {
+	dao := NewMemoryDao()
+	config := &ServerConfig{
+		MetricDuration:    7 * 24 * time.Hour,
+		ScrapeInterval:    time.Minute,
+		NumClass:          2,
+		NumRound:          DefaultNumRound,
+		MinObservedDays:   1,
+		ReClusterSchedule: "@daily",
+		TimeZone:          "UTC",
+	}
+	schedule, spec, location, err := buildReClusterSchedule(config)
+	assert.NoError(t, err)
+	s := &serverImpl{
+		config:            config,
+		dao:               dao,
+		logger:            log.New(os.Stdout, "", 0),
+		metrics:           newServerMetrics(),
+		executeReCluster:  make(chan struct{}),
+		schedule:          schedule,
+		scheduleSpec:      spec,
+		location:          location,
+		ingestLimiter:     newIngestLimiter(config.IngestRateLimit, config.IngestBurst),
+		remoteWritePairer: newRemoteWritePairer(),
+	}
+	saveTestPatternCenters(t, dao)
+	yesterday := uint64(time.Now().Unix())/core.DayLength - 1
+	for i := 0; i < 6; i++ {
+		appName := server.AppName{Name: fmt.Sprintf("app-%d", i), Namespace: "test"}
+		assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(appName, i%2, yesterday, 1, core.NumSections, 2)))
+	}
+	assert.NoError(t, s.reCluster(context.Background()))
+	app := server.AppName{Name: "app-0", Namespace: "test"}
+	unknown := server.AppName{Name: "unknown", Namespace: "test"}
+	// 有数据但尚未分类
+	unclassified := server.AppName{Name: "new", Namespace: "test"}
+	assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{{AppName: unclassified, Timestamp: uint64(time.Now().Unix())}}))
+
+	c := loadOpenapiContract(t)
+	apiServer := httptest.NewServer(c.middleware(t, s.buildServer().Handler))
+	defer apiServer.Close()
+
+	// pkg/client发出的请求与收到的响应都符合文档
+	api, err := client.NewApiClientWithConfig(&client.ClientConfig{BaseUrl: apiServer.URL})
+	assert.NoError(t, err)
+	characteristics, err := api.QueryAppCharacteristics(app)
+	assert.NoError(t, err)
+	assert.Equal(t, app, characteristics.AppName)
+	_, err = api.QueryAppCharacteristics(unknown)
+	assert.Error(t, err)
+	_, err = api.QueryAppCharacteristics(unclassified)
+	assert.Error(t, err)
+	_, err = api.QueryAppProfile(app)
+	assert.NoError(t, err)
+	_, err = api.QueryAppProfile(unknown)
+	assert.Equal(t, server.ErrAppNotFound, err)
+	history, err := api.QueryAppClassHistory(app)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(history))
+	_, err = api.QueryAppClassHistory(unknown)
+	assert.Equal(t, server.ErrAppNotFound, err)
+	api.ReCluster()
+	_, err = api.QueryReClusterSchedule()
+	assert.NoError(t, err)
+	_, err = api.QueryReClusterSummary()
+	assert.NoError(t, err)
+	_, err = api.ReClusterDryRun(&server.ReClusterParams{NumClass: 3})
+	assert.NoError(t, err)
+	_, err = api.QueryClassificationSnapshot()
+	assert.NoError(t, err)
+
+	watcher, err := client.NewClassificationWatcherWithConfig(&client.ClientConfig{BaseUrl: apiServer.URL})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, watcher.HasSynced, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	// 其他API
+	request := func(method, path, contentType string, body []byte, expected int) []byte {
+		r, err := http.NewRequest(method, apiServer.URL+path, bytes.NewReader(body))
+		assert.NoError(t, err)
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		response, err := http.DefaultClient.Do(r)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		defer func() {
+			_ = response.Body.Close()
+		}()
+		content, err := ioutil.ReadAll(response.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, response.StatusCode, "%s %s：%s", method, path, string(content))
+		return content
+	}
+	request(http.MethodGet, "/classification/watch?resourceVersion=0&timeoutSeconds=1", "", nil, http.StatusOK)
+	request(http.MethodGet, "/classification/watch?resourceVersion=latest", "", nil, http.StatusBadRequest)
+	request(http.MethodPost, "/recluster/dryrun", "application/json", []byte("{"), http.StatusBadRequest)
+	request(http.MethodPut, "/admin/pins/test/app-0", "application/json", []byte(`{"classId":2,"reason":"测试"}`), http.StatusOK)
+	request(http.MethodPut, "/admin/pins/test/app-1", "application/json", []byte(`{"classId":2}`), http.StatusBadRequest)
+	request(http.MethodGet, "/admin/pins", "", nil, http.StatusOK)
+	request(http.MethodGet, "/admin/pins/test/app-0", "", nil, http.StatusOK)
+	request(http.MethodGet, "/admin/pins/test/app-1", "", nil, http.StatusNotFound)
+	request(http.MethodDelete, "/admin/pins/test/app-0?reason="+url.QueryEscape("测试"), "", nil, http.StatusOK)
+	request(http.MethodDelete, "/admin/pins/test/app-0?reason="+url.QueryEscape("测试"), "", nil, http.StatusNotFound)
+	state := request(http.MethodGet, "/admin/state", "", nil, http.StatusOK)
+	request(http.MethodPost, "/admin/state", "application/gzip", state, http.StatusOK)
+	ingest := fmt.Sprintf(`[{"Name":"vm","Namespace":"test","Cluster":"east","Timestamp":%d,"Cpu":1.5,"Mem":1024}]`, time.Now().Unix())
+	request(http.MethodPost, "/ingest", "application/json", []byte(ingest), http.StatusOK)
+	request(http.MethodPost, "/ingest", "application/json", []byte(`[{"Name":"vm","Namespace":"test","Timestamp":0}]`), http.StatusBadRequest)
+	remoteWrite := remoteWriteBody(t,
+		remoteWriteSeries(RemoteWriteCpuMetric, "web", time.Now(), 1),
+		remoteWriteSeries(RemoteWriteMemMetric, "web", time.Now(), 1024),
+	)
+	request(http.MethodPost, "/ingest/remote-write", "application/x-protobuf", remoteWrite, http.StatusNoContent)
+	request(http.MethodGet, "/livez", "", nil, http.StatusOK)
+	request(http.MethodGet, "/healthz", "", nil, http.StatusOK)
+	request(http.MethodGet, "/readyz", "", nil, http.StatusOK)
+	request(http.MethodGet, "/metrics", "", nil, http.StatusOK)
+	assert.JSONEq(t, openapiDocument, string(request(http.MethodGet, "/openapi.json", "", nil, http.StatusOK)))
+
+	// 文档中的每个操作都存在
+	for _, op := range c.operations {
+		assert.True(t, c.called[op], "没有测试%s %s", op.method, op.path)
+	}
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// 固定分类的请求体
+type appPinRequest struct {
+	ClassId uint                `json:"classId,omitempty"`
+	Profile []*core.SectionData `json:"profile,omitempty"`
+	Reason  string              `json:"reason"`
+	By      string              `json:"by"` // 操作人，为空则使用请求的来源地址
+}
+
+// 单个应用的固定分类与审计记录
+type appPinDetail struct {
+	Pin    *server.AppPin        `json:"pin"` // 没有被固定时为null
+	Audits []*server.AppPinAudit `json:"audits"`
+}
+
+// 检查固定分类是否合法。固定到类别时类别必须存在，且应用需要已经被分类，以便按应用的CpuMax与MemMax换算类别中心；
+// 自定义运行特征需要包含所有Section的数据
+func (s *serverImpl) validateAppPin(pin *server.AppPin) error {
+	if err := checkAppPinFields(pin); err != nil {
+		return err
+	}
+	if pin.ClassId != 0 {
+		if _, err := s.dao.QueryClassMetricsByClassId(pin.ClassId); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("ClassID为%d的类别不存在", pin.ClassId))
+		}
+		if _, err := s.dao.QueryAppClassByApp(&pin.AppName); err != nil {
+			return errors.Wrap(err, "应用尚未被分类，只能固定为自定义运行特征")
+		}
+	}
+	return nil
+}
+
+// 检查固定分类本身的字段，不检查类别是否存在
+func checkAppPinFields(pin *server.AppPin) error {
+	if pin.Reason == "" {
+		return fmt.Errorf("必须说明固定分类的原因")
+	}
+	if (pin.ClassId == 0) == (pin.Profile == nil) {
+		return fmt.Errorf("classId与profile必须且只能指定一个")
+	}
+	if pin.ClassId != 0 {
+		return nil
+	}
+	if len(pin.Profile) != core.NumSections {
+		return fmt.Errorf("自定义运行特征应有%d个Section，实际为%d个", core.NumSections, len(pin.Profile))
+	}
+	for i, datum := range pin.Profile {
+		if datum == nil {
+			return fmt.Errorf("自定义运行特征缺少第%d个Section的数据", i)
+		}
+		for _, v := range []float32{datum.CpuAvg, datum.CpuMax, datum.CpuMin, datum.CpuP50, datum.CpuP90, datum.CpuP99,
+			datum.MemAvg, datum.MemMax, datum.MemMin, datum.MemP50, datum.MemP90, datum.MemP99} {
+			if v < 0 {
+				return fmt.Errorf("自定义运行特征第%d个Section的数据不能为负数", i)
+			}
+		}
+	}
+	return nil
+}
+
+// 在同一个事务中保存固定分类与审计记录
+func (s *serverImpl) pinApp(pin *server.AppPin) error {
+	s.logger.Printf("%s将名称空间%s，名称为%s的应用固定到类别%d，自定义运行特征：%t，原因：%s\n",
+		pin.CreatedBy, pin.Namespace, pin.Name, pin.ClassId, pin.Profile != nil, pin.Reason)
+	return s.dao.Transaction(func(tx Dao) error {
+		if err := tx.SaveAppPin(pin); err != nil {
+			return err
+		}
+		return tx.SaveAppPinAudit(&server.AppPinAudit{
+			AppName: pin.AppName,
+			Action:  server.PinActionPin,
+			ClassId: pin.ClassId,
+			Custom:  pin.Profile != nil,
+			Reason:  pin.Reason,
+			Actor:   pin.CreatedBy,
+			Time:    pin.CreatedAt,
+		})
+	})
+}
+
+// 在同一个事务中取消固定并保存审计记录。应用没有被固定时返回ErrAppNotPinned
+func (s *serverImpl) unpinApp(appName server.AppName, reason, actor string) error {
+	s.logger.Printf("%s取消了名称空间%s，名称为%s的应用的固定分类，原因：%s\n", actor, appName.Namespace, appName.Name, reason)
+	return s.dao.Transaction(func(tx Dao) error {
+		if err := tx.RemoveAppPin(&appName); err != nil {
+			return err
+		}
+		return tx.SaveAppPinAudit(&server.AppPinAudit{
+			AppName: appName,
+			Action:  server.PinActionUnpin,
+			Reason:  reason,
+			Actor:   actor,
+			Time:    time.Now(),
+		})
+	})
+}
+
+// 固定分类的应用的运行特征。固定到类别时按应用的CpuMax与MemMax换算类别中心，因此应用需要已经被分类。
+// 导入的状态归档不包含固定的类别时，固定分类失效，按应用当前的分类返回，并设置PinStale
+func (s *serverImpl) pinnedCharacteristics(appName server.AppName, pin *server.AppPin) (*server.AppCharacteristics, error) {
+	confidence := float64(1)
+	result := &server.AppCharacteristics{
+		AppName:    appName,
+		ClassId:    pin.ClassId,
+		Confidence: &confidence,
+		Pinned:     true,
+	}
+	if pin.Profile != nil {
+		result.SectionData = pin.Profile
+		return result, nil
+	}
+
+	appClass, err := s.dao.QueryAppClassByApp(&appName)
+	if err == server.ErrAppNotFound || err == server.ErrAppNotClassified {
+		return nil, err
+	} else if err != nil {
+		s.logger.Printf("查询AppClass失败，原因为：%v\n", err)
+		return nil, err
+	}
+	metric, err := s.dao.QueryClassMetricsByClassId(pin.ClassId)
+	if err == server.ErrClassNotFound {
+		s.logger.Printf("名称空间%s，名称为%s的应用固定的类别%d已不存在，按当前分类返回\n", appName.Namespace, appName.Name, pin.ClassId)
+		characteristics, err := s.classifiedCharacteristics(appName, appClass)
+		if err != nil {
+			return nil, err
+		}
+		characteristics.PinStale = true
+		return characteristics, nil
+	} else if err != nil {
+		s.logger.Printf("查询固定的类别时出错，ClassID为%d，错误为：%v", pin.ClassId, err)
+		return nil, err
+	}
+	result.SectionData = server.ScaleClassMetrics(metric, appClass.CpuMax, appClass.MemMax)
+	return result, nil
+}
+
+func (s *serverImpl) handlePinList(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writer.Header().Set("Allow", "GET")
+		http.Error(writer, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	cluster, err := clusterFromRequest(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pins, err := s.dao.QueryAllAppPins()
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filtered := make([]*server.AppPin, 0, len(pins))
+	for _, pin := range pins {
+		if appInCluster(pin.AppName, cluster) {
+			filtered = append(filtered, pin)
+		}
+	}
+	marshal, err := json.Marshal(filtered)
+	if err != nil {
+		http.Error(writer, errors.Wrap(err, "序列化问题").Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	_, _ = writer.Write(marshal)
+}
+
+var pinPathPattern = regexp.MustCompile(fmt.Sprintf("^/admin/pins/(%s)/(%s)$", namePattern, namePattern))
+
+// GET查询应用的固定分类与审计记录，PUT固定分类，DELETE取消固定，原因与操作人通过reason与by参数指定
+func (s *serverImpl) handlePin(writer http.ResponseWriter, request *http.Request) {
+	subMatch := pinPathPattern.FindStringSubmatch(request.URL.Path)
+	if subMatch == nil {
+		http.NotFound(writer, request)
+		return
+	}
+	appName, err := appNameFromRequest(request, subMatch[1], subMatch[2])
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		pin, err := s.dao.QueryAppPin(&appName)
+		if err != nil && err != server.ErrAppNotPinned {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		audits, err := s.dao.QueryAppPinAudits(&appName)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if pin == nil && len(audits) == 0 {
+			http.Error(writer, server.ErrAppNotPinned.Error(), http.StatusNotFound)
+			return
+		}
+		marshal, err := json.Marshal(&appPinDetail{Pin: pin, Audits: audits})
+		if err != nil {
+			http.Error(writer, errors.Wrap(err, "序列化问题").Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(marshal)
+	case http.MethodPut:
+		body := &appPinRequest{}
+		if err := json.NewDecoder(request.Body).Decode(body); err != nil {
+			http.Error(writer, errors.Wrap(err, "解析请求出错").Error(), http.StatusBadRequest)
+			return
+		}
+		pin := &server.AppPin{
+			AppName:   appName,
+			ClassId:   body.ClassId,
+			Profile:   body.Profile,
+			Reason:    body.Reason,
+			CreatedBy: requestActor(body.By, request),
+			CreatedAt: time.Now(),
+		}
+		if err := s.validateAppPin(pin); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.pinApp(pin); err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		marshal, err := json.Marshal(pin)
+		if err != nil {
+			http.Error(writer, errors.Wrap(err, "序列化问题").Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(marshal)
+	case http.MethodDelete:
+		query := request.URL.Query()
+		reason := query.Get("reason")
+		if reason == "" {
+			http.Error(writer, "必须说明取消固定的原因", http.StatusBadRequest)
+			return
+		}
+		err := s.unpinApp(appName, reason, requestActor(query.Get("by"), request))
+		if err == server.ErrAppNotPinned {
+			http.Error(writer, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = writer.Write([]byte("OK"))
+	default:
+		writer.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(writer, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 操作人。启用认证时为认证的用户名，否则为by，未指定时使用请求的来源地址
+func requestActor(by string, request *http.Request) string {
+	if c := callerFromContext(request.Context()); c != nil {
+		return c.name
+	}
+	if by != "" {
+		return by
+	}
+	return request.RemoteAddr
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestServerImpl_HandlePin(t *testing.T) {
+	dao := NewMemoryDao()
+	for classId := uint(1); classId <= 2; classId++ {
+		metrics := &server.ClassMetrics{ClassId: classId, Data: make([]*core.SectionData, core.NumSections)}
+		for i := range metrics.Data {
+			metrics.Data[i] = &core.SectionData{CpuAvg: float32(classId), MemAvg: float32(classId)}
+		}
+		assert.NoError(t, dao.SaveClassMetrics(metrics))
+	}
+	appName := server.AppName{Name: "batch", Namespace: "test"}
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1, CpuMax: 2, MemMax: 4}))
+
+	s := &serverImpl{
+		config:  &ServerConfig{NumClass: 2},
+		dao:     dao,
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+	handler := s.buildServer().Handler
+	do := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		var reader *bytes.Reader
+		if body != nil {
+			marshal, _ := json.Marshal(body)
+			reader = bytes.NewReader(marshal)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, url, reader))
+		return recorder
+	}
+
+	// 不合法的固定分类
+	profile := make([]*core.SectionData, core.NumSections)
+	for i := range profile {
+		profile[i] = &core.SectionData{CpuMax: 10, MemMax: 20}
+	}
+	for _, body := range []*appPinRequest{
+		{ClassId: 2},
+		{Reason: "未指定类别"},
+		{ClassId: 2, Profile: profile, Reason: "同时指定"},
+		{ClassId: 9, Reason: "类别不存在"},
+		{Profile: profile[:1], Reason: "Section数量不足"},
+	} {
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/admin/pins/test/batch", body).Code, body.Reason)
+	}
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/pins/test/batch", nil).Code)
+	// 尚未分类的应用只能固定为自定义运行特征
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/admin/pins/test/new", &appPinRequest{ClassId: 2, Reason: "未分类"}).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/admin/pins/test/new", &appPinRequest{Profile: profile, Reason: "未分类"}).Code)
+	characteristics, err := s.QueryAppCharacteristics(server.AppName{Name: "new", Namespace: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, profile, characteristics.SectionData)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/admin/pins/test/new?reason=恢复", nil).Code)
+
+	// 固定到类别，按应用的CpuMax与MemMax换算
+	recorder := do(http.MethodPut, "/admin/pins/test/batch", &appPinRequest{ClassId: 2, Reason: "夜间批处理", By: "admin"})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	characteristics, err = s.QueryAppCharacteristics(appName)
+	assert.NoError(t, err)
+	assert.True(t, characteristics.Pinned)
+	assert.False(t, characteristics.PinStale)
+	assert.Equal(t, uint(2), characteristics.ClassId)
+	assert.Equal(t, float32(4), characteristics.SectionData[0].CpuAvg)
+	assert.Equal(t, float32(8), characteristics.SectionData[0].MemAvg)
+
+	// 重新聚类后固定分类仍然有效
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1, CpuMax: 2, MemMax: 4}))
+	characteristics, err = s.QueryAppCharacteristics(appName)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), characteristics.ClassId)
+
+	// 导入的状态归档不包含固定的类别时，按当前分类返回并标记固定分类失效
+	removed := NewMemoryDao()
+	pin, err := dao.QueryAppPin(&appName)
+	assert.NoError(t, err)
+	assert.NoError(t, removed.SaveAppPin(pin))
+	metrics, _ := dao.QueryClassMetricsByClassId(1)
+	assert.NoError(t, removed.SaveClassMetrics(metrics))
+	assert.NoError(t, removed.SaveAppClass(&server.AppClass{AppName: appName, ClassId: 1, CpuMax: 2, MemMax: 4}))
+	characteristics, err = (&serverImpl{dao: removed, logger: s.logger}).QueryAppCharacteristics(appName)
+	assert.NoError(t, err)
+	assert.False(t, characteristics.Pinned)
+	assert.True(t, characteristics.PinStale)
+	assert.Equal(t, uint(1), characteristics.ClassId)
+
+	// 应用分类被删除后不再返回500
+	assert.NoError(t, removed.RemoveAppClass(&appName))
+	_, err = (&serverImpl{dao: removed, logger: s.logger}).QueryAppCharacteristics(appName)
+	assert.Equal(t, server.ErrAppNotClassified, err)
+
+	// 固定到自定义运行特征
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/admin/pins/test/batch", &appPinRequest{Profile: profile, Reason: "手工画像"}).Code)
+	characteristics, err = s.QueryAppCharacteristics(appName)
+	assert.NoError(t, err)
+	assert.True(t, characteristics.Pinned)
+	assert.Equal(t, uint(0), characteristics.ClassId)
+	assert.Equal(t, profile, characteristics.SectionData)
+
+	recorder = do(http.MethodGet, "/admin/pins", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	pins := make([]*server.AppPin, 0)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &pins))
+	if assert.Equal(t, 1, len(pins)) {
+		assert.Equal(t, appName, pins[0].AppName)
+		assert.Equal(t, "手工画像", pins[0].Reason)
+	}
+
+	// 取消固定
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodDelete, "/admin/pins/test/batch", nil).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/admin/pins/test/batch?reason=恢复&by=ops", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/admin/pins/test/batch?reason=恢复", nil).Code)
+	characteristics, err = s.QueryAppCharacteristics(appName)
+	assert.NoError(t, err)
+	assert.False(t, characteristics.Pinned)
+	assert.Equal(t, uint(1), characteristics.ClassId)
+
+	// 审计记录
+	recorder = do(http.MethodGet, "/admin/pins/test/batch", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	detail := &appPinDetail{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), detail))
+	assert.Nil(t, detail.Pin)
+	if assert.Equal(t, 3, len(detail.Audits)) {
+		assert.Equal(t, server.PinActionPin, detail.Audits[0].Action)
+		assert.Equal(t, "admin", detail.Audits[0].Actor)
+		assert.Equal(t, uint(2), detail.Audits[0].ClassId)
+		assert.True(t, detail.Audits[1].Custom)
+		assert.Equal(t, server.PinActionUnpin, detail.Audits[2].Action)
+		assert.Equal(t, "ops", detail.Audits[2].Actor)
+		assert.Equal(t, "恢复", detail.Audits[2].Reason)
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPost, "/admin/pins/test/batch", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/pins/test", nil).Code)
+}
@@ -1,82 +1,174 @@
 package server
 
 import (
+	"bufio"
 	"context"
 	"encoding/csv"
 	"fmt"
 	"github.com/packagewjx/workload-classifier/internal/classify"
-	"github.com/packagewjx/workload-classifier/internal/datasource"
 	"github.com/packagewjx/workload-classifier/internal/preprocess"
 	"github.com/packagewjx/workload-classifier/internal/utils"
 	"github.com/packagewjx/workload-classifier/pkg/core"
 	"github.com/packagewjx/workload-classifier/pkg/server"
 	"github.com/pkg/errors"
 	"io"
+	"math"
 	"os"
 	"reflect"
+	"sort"
 	"time"
 )
 
-func (s *serverImpl) reClusterer(ctx context.Context) {
+// 再聚类的goroutine主函数。ctx结束后不再开始新的再聚类，但会完成正在进行的再聚类，除非abortCtx也已结束，此时事务将被回滚
+func (s *serverImpl) reClusterer(ctx, abortCtx context.Context) {
 	s.logger.Println("再聚类线程启动")
 
-	if s.config.InitialCenterCsvFile != "" {
-		s.logger.Println("正在读取中心数据")
-		f, err := os.Open(s.config.InitialCenterCsvFile)
-		if err != nil {
-			panic(fmt.Sprintf("打开文件%s失败", s.config.InitialCenterCsvFile))
+	for {
+		next := s.schedule.Next(time.Now())
+		s.setNextReClusterTime(next)
+		s.logger.Printf("聚类将于%s执行\n", next.In(s.location).Format("2006-01-02T15:04:05-0700"))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.logger.Println("再聚类线程退出")
+			return
+		case <-timer.C:
+			s.runReCluster(ctx, abortCtx)
+		case <-s.executeReCluster:
+			timer.Stop()
+			s.runReCluster(ctx, abortCtx)
 		}
-		center, err := readInitialCenter(f)
-		if err != nil {
-			panic(fmt.Sprintf("读取文件%s失败", s.config.InitialCenterCsvFile))
+	}
+
+}
+
+func (s *serverImpl) runReCluster(stopCtx, ctx context.Context) {
+	if stopCtx.Err() != nil {
+		// 已经要求退出，不再开始新的再聚类
+		return
+	}
+	// 标签、负载种类与注解只能在获取监控数据时检查，重启或切换leader后需要等待第一次获取完成，避免为应被排除的应用分类
+	select {
+	case <-s.scrapeFilter.excludedSynced():
+	default:
+		s.logger.Println("等待第一次获取监控数据完成后再聚类")
+		select {
+		case <-s.scrapeFilter.excludedSynced():
+		case <-stopCtx.Done():
+			return
 		}
+	}
+	err := s.reCluster(ctx)
+	if err != nil && ctx.Err() != nil {
+		s.logger.Printf("再聚类被中止：%v\n", err)
+	} else if err != nil {
+		panic(errors.Wrap(err, "再聚类出错"))
+	}
+}
+
+func (s *serverImpl) setNextReClusterTime(next time.Time) {
+	s.nextReClusterLock.Lock()
+	defer s.nextReClusterLock.Unlock()
+	s.nextReCluster = next
+}
 
-		s.logger.Printf("正在删除数据库的中心数据\n")
-		err = s.dao.RemoveAllClassMetrics()
+func (s *serverImpl) nextReClusterTime() time.Time {
+	s.nextReClusterLock.RLock()
+	defer s.nextReClusterLock.RUnlock()
+	return s.nextReCluster
+}
+
+// 读取初始中心文件，并替换数据库中的类别数据。启用leader选举时，每个新leader都会执行一次，
+// 因此数据库中已有类别数据（例如再聚类的结果）时不覆盖，避免切换leader时丢失再聚类的结果。
+// 每个进程只会在第一次成为leader时执行一次
+func (s *serverImpl) loadInitialCenter() {
+	if s.config.InitialCenterCsvFile == "" {
+		return
+	}
+	if s.config.LeaderElect {
+		existing, err := s.dao.QueryAllClassMetrics()
 		if err != nil {
-			panic(fmt.Sprintf("删除数据库聚类数据失败：%v", err))
+			panic(fmt.Sprintf("查询数据库的类别数据失败：%v", err))
+		}
+		if len(existing) > 0 {
+			s.logger.Printf("已启用leader选举，数据库中已有%d个类别的数据，不读取初始中心文件\n", len(existing))
+			return
 		}
+	}
 
-		s.logger.Println("正在更新数据库的中心数据")
+	s.logger.Println("正在读取中心数据")
+	center, err := readInitialCenterFile(s.config.InitialCenterCsvFile)
+	if err != nil {
+		panic(fmt.Sprintf("读取文件%s失败：%v", s.config.InitialCenterCsvFile, err))
+	}
+
+	s.logger.Println("正在替换数据库的中心数据")
+	err = s.dao.Transaction(func(tx Dao) error {
+		if err := tx.RemoveAllClassMetrics(); err != nil {
+			return errors.Wrap(err, "删除数据库聚类数据失败")
+		}
 		for _, center := range center {
-			err := s.dao.SaveClassMetrics(center)
-			if err != nil {
-				panic(fmt.Sprintf("写入类数据失败，类数据为%v，错误为：%v", center, err))
+			if err := tx.SaveClassMetrics(center); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("写入类数据失败，类数据为%v", center))
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		panic(err.Error())
 	}
+}
 
-	// 将next设置为下一天的启动时间
-	now := time.Now()
-	now.Add(24 * time.Hour)
-	now.Date()
-	next := time.Date(now.Year(), now.Month(), now.Day()+1, int(s.config.ReClusterTime.Hours()), 0, 0, 0, now.Location())
-	waitTime := next.Sub(now)
+// 为升级前已有的监控数据生成汇总数据。每个进程只会在第一次成为leader时执行一次
+func (s *serverImpl) backfillSectionRollups() {
+	n, err := s.dao.BackfillSectionRollups()
+	if err != nil {
+		s.logger.Printf("回填汇总数据出错，已处理%d个应用：%v\n", n, err)
+	} else if n > 0 {
+		s.logger.Printf("已为%d个应用回填汇总数据\n", n)
+	}
+}
 
-	for {
-		next = time.Now().Add(waitTime)
-		s.logger.Printf("聚类将于%s执行\n", next.Format("2006-01-02T15:04:05-0700"))
-		select {
-		case <-ctx.Done():
-			s.logger.Println("再聚类线程退出")
-			return
-		case <-time.After(waitTime):
-			waitTime = time.Hour * 24
-			err := s.reCluster()
-			if err != nil {
-				panic(errors.Wrap(err, "再聚类出错"))
-			}
-		case <-s.executeReCluster:
-			err := s.reCluster()
-			if err != nil {
-				panic(errors.Wrap(err, "再聚类出错"))
-			}
+// 读取初始中心文件。文件可以是CSV格式的原始中心数据，读取后进行预处理；也可以是state export导出的归档，
+// 归档中的中心已经过预处理，直接使用
+func readInitialCenterFile(path string) ([]*server.ClassMetrics, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "打开文件出错")
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	reader := bufio.NewReader(f)
+	// gzip文件的前两个字节
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return readStateArchiveCenters(reader)
+	}
+	return readInitialCenter(reader)
+}
+
+func readInitialCenter(csvInput io.Reader) ([]*server.ClassMetrics, error) {
+	result, err := readCenterCsv(csvInput)
+	if err != nil {
+		return nil, err
+	}
+
+	preprocessor := preprocess.Default()
+	for _, c := range result {
+		// 对array的数据进行预处理
+		temp := &core.ContainerWorkloadData{
+			ContainerId: "",
+			Data:        c.Data,
 		}
+		preprocessor.Preprocess(temp)
 	}
 
+	return result, nil
 }
 
-func readInitialCenter(csvInput io.Reader) ([]*server.ClassMetrics, error) {
+// 读取中心数据CSV文件，每行为一个类别，第i行的ClassID为i+1。不进行预处理
+func readCenterCsv(csvInput io.Reader) ([]*server.ClassMetrics, error) {
 	result := make([]*server.ClassMetrics, 0)
 	records, err := csv.NewReader(csvInput).ReadAll()
 	if err != nil {
@@ -85,52 +177,150 @@ func readInitialCenter(csvInput io.Reader) ([]*server.ClassMetrics, error) {
 		return nil, fmt.Errorf("没有读取到任何数据")
 	}
 
-	preprocessor := preprocess.Default()
 	for i, record := range records {
 		if len(record) != core.NumSections*core.NumSectionFields {
 			return nil, fmt.Errorf("第%d行数据有问题", i)
 		}
 
-		c := &server.ClassMetrics{
-			ClassId: uint(i + 1),
-			Data:    nil,
-		}
-
 		array, err := utils.RecordsToSectionArray(record)
 		if err != nil {
 			return nil, errors.Wrap(err, fmt.Sprintf("第%d行数据有问题", i))
 		}
-		// 对array的数据进行预处理
-		temp := &core.ContainerWorkloadData{
-			ContainerId: "",
-			Data:        array,
-		}
-		preprocessor.Preprocess(temp)
-
-		c.Data = array
 
-		result = append(result, c)
+		result = append(result, &server.ClassMetrics{
+			ClassId: uint(i + 1),
+			Data:    array,
+		})
 	}
 
 	return result, nil
 }
 
-func (s *serverImpl) reCluster() error {
+// 再聚类的结果
+type reClusterResult struct {
+	centers    []*server.ClassMetrics
+	appClasses []*server.AppClass
+	// 参与计算类别中心的各应用到所属类别中心的距离平方和与距离和，用于衡量聚类质量
+	inertia       float64
+	totalDistance float64
+	numClustered  int            // 参与计算类别中心的应用数量
+	ineligible    map[string]int // 按原因统计的数据不足的应用数量
+	// 保存时与原有分类比较得到的变化，包括首次分类的应用
+	history []*server.AppClassHistory
+}
+
+func (s *serverImpl) reCluster(ctx context.Context) (err error) {
 	s.logger.Println("再聚类开始")
+	var result *reClusterResult
+	start := time.Now()
+	defer func() { s.metrics.observeReCluster(start, result, err) }()
+
+	result, err = s.computeReCluster(ctx, s.config.NumClass, s.config.NumRound)
+	if err != nil {
+		return err
+	}
+
+	err = s.saveReClusterResult(ctx, result)
+	if err != nil {
+		return err
+	}
+
+	summary := result.summary(start, time.Now())
+	s.setReClusterSummary(summary)
+	s.logger.Printf("再聚类结束，共%d个应用，%d个参与计算类别中心，%d个应用分类发生变化，数据不足的应用数量为%v\n",
+		summary.NumApps, summary.NumClustered, summary.NumChanged, summary.Ineligible)
+
+	if s.notifier != nil && summary.NumChanged > 0 {
+		s.notifier.enqueue(&server.ClassChangeNotification{
+			ReClusterTime: start,
+			Changes:       result.changes(),
+		})
+	}
+	return nil
+}
+
+// 分类发生变化的应用，不包括首次分类的应用
+func (r *reClusterResult) changes() []*server.AppClassHistory {
+	result := make([]*server.AppClassHistory, 0)
+	for _, h := range r.history {
+		if h.PreviousClassId != 0 {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func (r *reClusterResult) summary(startedAt, finishedAt time.Time) *server.ReClusterSummary {
+	summary := &server.ReClusterSummary{
+		StartedAt:    startedAt,
+		FinishedAt:   finishedAt,
+		NumApps:      len(r.appClasses),
+		NumClustered: r.numClustered,
+		Ineligible:   make(map[string]int, len(r.ineligible)),
+		Inertia:      r.inertia,
+	}
+	for reason, count := range r.ineligible {
+		summary.Ineligible[reason] = count
+	}
+	if r.numClustered > 0 {
+		summary.MeanDistance = r.totalDistance / float64(r.numClustered)
+	}
+	summary.NumChanged = len(r.changes())
+	return summary
+}
+
+func (s *serverImpl) setReClusterSummary(summary *server.ReClusterSummary) {
+	s.summaryLock.Lock()
+	defer s.summaryLock.Unlock()
+	s.reClusterSummary = summary
+}
+
+func (s *serverImpl) lastReClusterSummary() *server.ReClusterSummary {
+	s.summaryLock.RLock()
+	defer s.summaryLock.RUnlock()
+	return s.reClusterSummary
+}
+
+// 读取监控数据并以numClass个类别、numRound轮迭代执行聚类，不修改数据库。数据不足的应用不参与计算类别中心，聚类后暂时分到最近的类别
+func (s *serverImpl) computeReCluster(ctx context.Context, numClass, numRound uint) (*reClusterResult, error) {
+	dao := s.dao.WithContext(ctx)
 
 	type dataFeature struct {
 		cpuMax float32
 		memMax float32
 	}
 
-	// 获取并转换数据
-	s.logger.Println("正在获取所有应用监控数据")
-	dataSource := NewDatabaseDatasource(s.dao.DB())
-	rawData, err := datasource.NewDataSourceRawDataReader(dataSource).Read()
+	// 读取保留时间内各应用的汇总数据，数据足够的应用排在前面
+	s.logger.Println("正在获取所有应用监控数据的汇总")
+	eligibleData := make([]*core.ContainerWorkloadData, 0)
+	ineligibleData := make([]*core.ContainerWorkloadData, 0)
+	ineligible := make(map[string]int)
+	excluded := 0
+	err := dao.ForEachAppSectionRollup(s.metricsFromDay(), func(appName server.AppName, sections []*sectionRollup) error {
+		if s.scrapeFilter.appExcluded(appName) {
+			excluded++
+			return ctx.Err()
+		}
+		datum := rollupsToWorkloadData(appName, sections)
+		if reason := checkEligibility(sections, s.config); reason != "" {
+			ineligible[reason]++
+			ineligibleData = append(ineligibleData, datum)
+		} else {
+			eligibleData = append(eligibleData, datum)
+		}
+		return ctx.Err()
+	})
 	if err != nil {
-		return errors.Wrap(err, "读取数据库监控出错")
+		return nil, errors.Wrap(err, "读取数据库监控汇总出错")
+	}
+	if excluded > 0 {
+		s.logger.Printf("%d个应用被过滤规则排除，不参与再聚类\n", excluded)
+	}
+	numClustered := len(eligibleData)
+	workloadData := append(eligibleData, ineligibleData...)
+	if len(ineligibleData) > 0 {
+		s.logger.Printf("%d个应用数据不足，不参与计算类别中心：%v\n", len(ineligibleData), ineligible)
 	}
-	workloadData := datasource.ConvertAllRawData(rawData)
 
 	// 由于预处理后真实数据将会丢失，此处保留数据特征
 	features := make([]dataFeature, len(workloadData))
@@ -153,19 +343,21 @@ func (s *serverImpl) reCluster() error {
 	for _, datum := range workloadData {
 		preprocessor.Preprocess(datum)
 	}
-	dataArray := utils.ContainerWorkloadToFloatArray(workloadData)
+	allData := utils.ContainerWorkloadToFloatArray(workloadData)
+	dataArray := make([][]float32, numClustered, len(allData))
+	copy(dataArray, allData[:numClustered])
 
 	// 获取算法实现
 	alg := classify.GetAlgorithm(classify.KMeans)
-	ctx := &classify.KMeansContext{
-		Round: int(s.config.NumRound),
+	kMeansCtx := &classify.KMeansContext{
+		Round: int(numRound),
 	}
 
 	// 获取类别中心，并加入到dataArray中作为数据的一部分，避免中心变化太大
 	s.logger.Println("正在获取聚类中心数据，并加入到数据集中")
-	classMetrics, err := s.dao.QueryAllClassMetrics()
+	classMetrics, err := dao.QueryAllClassMetrics()
 	if err != nil {
-		return errors.Wrap(err, "查询类别中心时出错")
+		return nil, errors.Wrap(err, "查询类别中心时出错")
 	}
 	for _, metric := range classMetrics {
 		dataArray = append(dataArray, utils.SectionDataToFloatArray(metric.Data))
@@ -173,35 +365,200 @@ func (s *serverImpl) reCluster() error {
 
 	// 聚类执行
 	s.logger.Println("开始执行聚类")
-	centers, class := alg.Run(dataArray, int(s.config.NumClass), ctx)
+	centers, class := alg.Run(dataArray, int(numClass), kMeansCtx)
 	s.logger.Println("聚类执行完成")
 
-	s.logger.Println("正在保存中心数据")
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "聚类完成后发现再聚类已被中止")
+	}
+
+	result := &reClusterResult{
+		centers:      make([]*server.ClassMetrics, len(centers)),
+		appClasses:   make([]*server.AppClass, len(workloadData)),
+		numClustered: numClustered,
+		ineligible:   ineligible,
+	}
 	for i, center := range centers {
-		c := floatArrayToClassMetrics(i+1, center)
-		err := s.dao.SaveClassMetrics(c)
-		if err != nil {
-			return errors.Wrap(err, "保存ClassMetrics时出现错误")
+		result.centers[i] = floatArrayToClassMetrics(i+1, center)
+	}
+	// 类别数量不变时，按中心距离将新的类别对应到原有类别并沿用其ClassID，避免同一类负载的ClassID在每次再聚类后随意变化
+	classIds := make([]uint, len(centers))
+	for i := range classIds {
+		classIds[i] = uint(i + 1)
+	}
+	if len(classMetrics) == len(centers) {
+		matched, _ := matchCenters(result.centers, classMetrics)
+		for i, center := range result.centers {
+			classIds[i] = matched[center.ClassId]
+			center.ClassId = classIds[i]
 		}
+		sort.Slice(result.centers, func(i, j int) bool { return result.centers[i].ClassId < result.centers[j].ClassId })
 	}
-
-	s.logger.Println("保存新的应用与类别绑定关系")
 	for i := 0; i < len(workloadData); i++ {
-		a := &server.AppClass{
-			AppName: server.AppNameFromContainerId(workloadData[i].ContainerId),
-			ClassId: uint(class[i]),
-			CpuMax:  features[i].cpuMax,
-			MemMax:  features[i].memMax,
+		provisional := i >= numClustered
+		var assigned int
+		if provisional {
+			assigned = nearestCenter(allData[i], centers)
+		} else {
+			assigned = class[i]
+		}
+		distance := euclideanDistance(allData[i], centers[assigned])
+		if !provisional {
+			result.inertia += distance * distance
+			result.totalDistance += distance
+		}
+
+		// 除所属类别外最近的类别
+		runnerUp := -1
+		runnerUpDistance := float64(0)
+		for j, center := range centers {
+			if j == assigned {
+				continue
+			}
+			if d := euclideanDistance(allData[i], center); runnerUp == -1 || d < runnerUpDistance {
+				runnerUp = j
+				runnerUpDistance = d
+			}
+		}
+		// 暂定分类的数据大部分是插值得到的，距离没有参考价值
+		confidence := float64(0)
+		if !provisional {
+			confidence = classifyConfidence(distance, runnerUpDistance, runnerUp != -1)
+		}
+
+		runnerUpClassId := uint(0)
+		if runnerUp != -1 {
+			runnerUpClassId = classIds[runnerUp]
 		}
-		err := s.dao.SaveAppClass(a)
+
+		result.appClasses[i] = &server.AppClass{
+			AppName:          server.AppNameFromContainerId(workloadData[i].ContainerId),
+			ClassId:          classIds[assigned],
+			CpuMax:           features[i].cpuMax,
+			MemMax:           features[i].memMax,
+			Distance:         distance,
+			RunnerUpClassId:  runnerUpClassId,
+			RunnerUpDistance: runnerUpDistance,
+			Confidence:       &confidence,
+			Provisional:      provisional,
+		}
+	}
+
+	return result, nil
+}
+
+// 返回距离data最近的中心的下标
+func nearestCenter(data []float32, centers [][]float32) int {
+	nearest := 0
+	nearestDistance := math.Inf(1)
+	for i, center := range centers {
+		if d := euclideanDistance(data, center); d < nearestDistance {
+			nearest = i
+			nearestDistance = d
+		}
+	}
+	return nearest
+}
+
+// 在同一个事务中保存再聚类的结果，并追加分类发生变化的应用的历史，写入result.history。ctx结束时事务将被回滚
+func (s *serverImpl) saveReClusterResult(ctx context.Context, result *reClusterResult) error {
+	return s.dao.WithContext(ctx).Transaction(func(tx Dao) error {
+		previous := make(map[server.AppName]uint)
+		err := tx.ForEachAppClass(func(class *server.AppClass) error {
+			previous[class.AppName] = class.ClassId
+			return nil
+		})
 		if err != nil {
-			return errors.Wrap(err, fmt.Sprintf("保存名称空间%s，名称为%s的应用的ClassID出现问题",
-				a.AppName.Namespace, a.AppName.Name))
+			return errors.Wrap(err, "查询原有的应用分类时出现错误")
+		}
+		// 被过滤规则排除的应用不再提供分类
+		for appName := range previous {
+			if !s.scrapeFilter.appExcluded(appName) {
+				continue
+			}
+			appName := appName
+			if err := tx.RemoveAppClass(&appName); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("删除名称空间%s，名称为%s的应用的分类出现问题", appName.Namespace, appName.Name))
+			}
 		}
+		now := time.Now()
+		result.history = make([]*server.AppClassHistory, 0)
+		for _, a := range result.appClasses {
+			if previous[a.AppName] == a.ClassId {
+				continue
+			}
+			result.history = append(result.history, &server.AppClassHistory{
+				AppName:         a.AppName,
+				ClassId:         a.ClassId,
+				PreviousClassId: previous[a.AppName],
+				Distance:        a.Distance,
+				Confidence:      a.Confidence,
+				Provisional:     a.Provisional,
+				Time:            now,
+			})
+		}
+
+		s.logger.Println("正在保存中心数据")
+		for _, c := range result.centers {
+			err := tx.SaveClassMetrics(c)
+			if err != nil {
+				return errors.Wrap(err, "保存ClassMetrics时出现错误")
+			}
+		}
+
+		s.logger.Println("保存新的应用与类别绑定关系")
+		for _, a := range result.appClasses {
+			err := tx.SaveAppClass(a)
+			if err != nil {
+				return errors.Wrap(err, fmt.Sprintf("保存名称空间%s，名称为%s的应用的ClassID出现问题",
+					a.AppName.Namespace, a.AppName.Name))
+			}
+		}
+
+		s.logger.Printf("保存%d条应用分类历史\n", len(result.history))
+		if err := tx.SaveAppClassHistory(result.history); err != nil {
+			return errors.Wrap(err, "保存应用分类历史时出现错误")
+		}
+		return nil
+	})
+}
+
+// 根据与所属类别及第二近类别的距离计算分类置信度。位于所属类别中心时为1，与两个类别距离相同时为0。
+// 只有一个类别时置信度为1
+func classifyConfidence(distance, runnerUpDistance float64, hasRunnerUp bool) float64 {
+	if !hasRunnerUp {
+		return 1
+	}
+	if runnerUpDistance <= 0 {
+		return 0
 	}
+	return math.Max(0, math.Min(1, 1-distance/runnerUpDistance))
+}
 
-	s.logger.Println("再聚类结束")
-	return nil
+// 保留时间内的监控数据所在的第一天
+func (s *serverImpl) metricsFromDay() uint64 {
+	return uint64(time.Now().Add(-s.config.MetricDuration).Unix()) / core.DayLength
+}
+
+// 将应用各Section的汇总数据转换为聚类使用的数据，没有数据的Section的值为NaN，由预处理填充
+func rollupsToWorkloadData(appName server.AppName, sections []*sectionRollup) *core.ContainerWorkloadData {
+	datum := &core.ContainerWorkloadData{
+		ContainerId: appName.ContainerId(),
+		Data:        make([]*core.SectionData, core.NumSections),
+	}
+	for i, section := range sections {
+		datum.Data[i] = section.sectionData()
+	}
+	return datum
+}
+
+func euclideanDistance(a, b []float32) float64 {
+	sum := float64(0)
+	for i := range a {
+		d := float64(a[i] - b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
 }
 
 func floatArrayToClassMetrics(id int, data []float32) *server.ClassMetrics {
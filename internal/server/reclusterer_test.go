@@ -1,22 +1,40 @@
 package server
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"github.com/packagewjx/workload-classifier/internal/alitrace"
 	"github.com/packagewjx/workload-classifier/internal/preprocess"
+	"github.com/packagewjx/workload-classifier/internal/utils"
 	"github.com/packagewjx/workload-classifier/pkg/core"
 	"github.com/packagewjx/workload-classifier/pkg/server"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"log"
 	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
+// 测试数据文件较大，没有放在仓库中，不存在时跳过测试
+func requireTestFile(t *testing.T, path string) {
+	if _, err := os.Stat(path); err != nil {
+		t.Skipf("缺少测试数据文件%s，跳过测试", path)
+	}
+}
+
 func TestReadInitialCenters(t *testing.T) {
+	requireTestFile(t, "../../test/csv/centers.csv")
 	f, _ := os.Open("../../test/csv/centers.csv")
 	centers, err := readInitialCenter(f)
 	assert.NoError(t, err)
@@ -65,12 +83,10 @@ func TestFloatArrayToClassMetrics(t *testing.T) {
 }
 
 func TestReCluster(t *testing.T) {
+	requireTestFile(t, "../../test/csv/centers.csv")
+	requireTestFile(t, "../../test/csv/30containers.csv")
 	// 准备测试数据
-	dao, err := NewDao(testHost)
-	if !assert.NoError(t, err) {
-		assert.FailNow(t, "DAO创建失败")
-		return
-	}
+	dao := NewMemoryDao()
 	s := &serverImpl{
 		config: &ServerConfig{
 			MetricDuration:       0,
@@ -81,15 +97,11 @@ func TestReCluster(t *testing.T) {
 			NumRound:             DefaultNumRound,
 			InitialCenterCsvFile: "",
 		},
-		dao:    dao,
-		logger: log.New(os.Stdout, "TestServer", log.LstdFlags),
+		dao:     dao,
+		logger:  log.New(os.Stdout, "TestServer", log.LstdFlags),
+		metrics: newServerMetrics(),
 	}
 
-	// 删除无关数据
-	dao.DB().Delete(&ClassSectionMetricsDO{}, "1 = 1")
-	dao.DB().Delete(&AppClassDO{}, "1 = 1")
-	dao.DB().Delete(&AppPodMetricsDO{}, "1 = 1")
-
 	// 导入类别数据
 	centerFin, _ := os.Open("../../test/csv/centers.csv")
 	center, err := readInitialCenter(centerFin)
@@ -133,7 +145,7 @@ func TestReCluster(t *testing.T) {
 	podMetrics = nil
 
 	// 测试开始
-	err = s.reCluster()
+	err = s.reCluster(context.Background())
 	assert.NoError(t, err)
 
 	// 检验聚类结果
@@ -182,3 +194,445 @@ func TestReCluster(t *testing.T) {
 	}
 
 }
+
+func TestClassifyConfidence(t *testing.T) {
+	assert.Equal(t, float64(1), classifyConfidence(0, 1, true))
+	assert.Equal(t, float64(0), classifyConfidence(1, 1, true))
+	assert.Equal(t, 0.5, classifyConfidence(1, 2, true))
+	// 所属类别比第二近的类别更远
+	assert.Equal(t, float64(0), classifyConfidence(2, 1, true))
+	assert.Equal(t, float64(0), classifyConfidence(0, 0, true))
+	assert.Equal(t, float64(1), classifyConfidence(1, 0, false))
+}
+
+// 生成两种负载模式的应用：pattern为0的应用上午负载高，为1的应用下午负载高
+func workloadPatternMetrics(appName server.AppName, pattern int, fromDay uint64, days uint64, numSections int, perSection int) []*server.AppPodMetrics {
+	result := make([]*server.AppPodMetrics, 0)
+	for day := fromDay; day < fromDay+days; day++ {
+		for i := 0; i < numSections; i++ {
+			value := float32(0.1)
+			if (i < core.NumSections/2) == (pattern == 0) {
+				value = 1
+			}
+			for j := 0; j < perSection; j++ {
+				result = append(result, &server.AppPodMetrics{
+					AppName:   appName,
+					Timestamp: day*core.DayLength + uint64(i*core.SectionLength+j*60),
+					Cpu:       value + rand.Float32()*0.05,
+					Mem:       value + rand.Float32()*0.05,
+				})
+			}
+		}
+	}
+	return result
+}
+
+func TestReCluster_Eligibility(t *testing.T) {
+	dao := NewMemoryDao()
+	s := &serverImpl{
+		config: &ServerConfig{
+			MetricDuration:     7 * 24 * time.Hour,
+			NumClass:           2,
+			NumRound:           DefaultNumRound,
+			MinObservedDays:    2,
+			MinSectionCoverage: 0.5,
+			MinSampleCount:     200,
+		},
+		dao:     dao,
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+	_, err := s.QueryReClusterSummary()
+	assert.Equal(t, server.ErrReClusterNotRun, err)
+
+	saveTestPatternCenters(t, dao)
+	yesterday := uint64(time.Now().Unix())/core.DayLength - 1
+	for i := 0; i < 6; i++ {
+		appName := server.AppName{Name: fmt.Sprintf("app-%d", i), Namespace: "test"}
+		assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(appName, i%2, yesterday-1, 2, core.NumSections, 2)))
+	}
+	// 只有一天的数据
+	short := server.AppName{Name: "short", Namespace: "test"}
+	assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(short, 0, yesterday, 1, 1, 5)))
+	// 只有少数Section有数据
+	partial := server.AppName{Name: "partial", Namespace: "test"}
+	assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(partial, 0, yesterday-1, 2, 10, 15)))
+	// 样本数量不足
+	sparse := server.AppName{Name: "sparse", Namespace: "test"}
+	assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(sparse, 1, yesterday-1, 2, core.NumSections/2, 1)))
+
+	assert.NoError(t, s.reCluster(context.Background()))
+
+	for i := 0; i < 6; i++ {
+		class, err := dao.QueryAppClassByApp(&server.AppName{Name: fmt.Sprintf("app-%d", i), Namespace: "test"})
+		assert.NoError(t, err)
+		assert.False(t, class.Provisional)
+		if assert.NotNil(t, class.Confidence) {
+			assert.True(t, *class.Confidence > 0)
+		}
+	}
+	// 相同模式的应用分到相同的类别
+	a, _ := dao.QueryAppClassByApp(&server.AppName{Name: "app-0", Namespace: "test"})
+	b, _ := dao.QueryAppClassByApp(&server.AppName{Name: "app-1", Namespace: "test"})
+	c, _ := dao.QueryAppClassByApp(&server.AppName{Name: "app-2", Namespace: "test"})
+	assert.Equal(t, a.ClassId, c.ClassId)
+	assert.NotEqual(t, a.ClassId, b.ClassId)
+
+	for _, appName := range []server.AppName{short, partial, sparse} {
+		class, err := dao.QueryAppClassByApp(&appName)
+		if assert.NoError(t, err) {
+			assert.True(t, class.Provisional, "%s应为暂定分类", appName.Name)
+			assert.Equal(t, float64(0), *class.Confidence)
+			assert.NotZero(t, class.ClassId)
+		}
+	}
+
+	summary, err := s.QueryReClusterSummary()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 9, summary.NumApps)
+		assert.Equal(t, 6, summary.NumClustered)
+		assert.Equal(t, map[string]int{
+			server.IneligibleObservedDays:    1,
+			server.IneligibleSectionCoverage: 1,
+			server.IneligibleSampleCount:     1,
+		}, summary.Ineligible)
+		assert.False(t, summary.FinishedAt.Before(summary.StartedAt))
+	}
+
+	handler := s.buildServer().Handler
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/recluster/summary", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, recorder.Body.String(), `workload_classifier_recluster_ineligible_apps{reason="sampleCount"} 1`)
+}
+
+// 保存两个类别中心，类别1为前半天负载高，类别2为后半天负载高，与workloadPatternMetrics的两种模式对应
+func saveTestPatternCenters(t *testing.T, dao Dao) {
+	for classId := uint(1); classId <= 2; classId++ {
+		center := &server.ClassMetrics{ClassId: classId, Data: make([]*core.SectionData, core.NumSections)}
+		for i := range center.Data {
+			value := float32(0.1)
+			if (i < core.NumSections/2) == (classId == 1) {
+				value = 1
+			}
+			center.Data[i] = &core.SectionData{CpuAvg: value, CpuMax: value, CpuMin: value, CpuP50: value, CpuP90: value, CpuP99: value,
+				MemAvg: value, MemMax: value, MemMin: value, MemP50: value, MemP90: value, MemP99: value}
+		}
+		assert.NoError(t, dao.SaveClassMetrics(center))
+	}
+}
+
+func TestReCluster_History(t *testing.T) {
+	received := make(chan *server.ClassChangeNotification, 1)
+	var attempts int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		notification := &server.ClassChangeNotification{}
+		assert.NoError(t, json.NewDecoder(request.Body).Decode(notification))
+		received <- notification
+	}))
+	defer webhook.Close()
+
+	dao := NewMemoryDao()
+	config := &ServerConfig{
+		MetricDuration:            7 * 24 * time.Hour,
+		NumClass:                  2,
+		NumRound:                  DefaultNumRound,
+		ClassChangeWebhook:        webhook.URL,
+		ClassChangeWebhookRetries: 1,
+	}
+	logger := log.New(os.Stdout, "", 0)
+	metrics := newServerMetrics()
+	s := &serverImpl{
+		config:   config,
+		dao:      dao,
+		logger:   logger,
+		metrics:  metrics,
+		notifier: newWebhookNotifier(config, logger, metrics),
+	}
+	s.notifier.backoff = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.notifier.run(ctx)
+	saveTestPatternCenters(t, dao)
+	yesterday := uint64(time.Now().Unix())/core.DayLength - 1
+	for i := 0; i < 6; i++ {
+		appName := server.AppName{Name: fmt.Sprintf("app-%d", i), Namespace: "test"}
+		assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(appName, i%2, yesterday, 1, core.NumSections, 2)))
+	}
+
+	// 首次分类只记录历史，不发送通知。模式0前半天负载高，应沿用类别1的ClassID
+	assert.NoError(t, s.reCluster(context.Background()))
+	app0 := server.AppName{Name: "app-0", Namespace: "test"}
+	app1 := server.AppName{Name: "app-1", Namespace: "test"}
+	history, err := s.QueryAppClassHistory(app0)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(history)) {
+		assert.Equal(t, uint(1), history[0].ClassId)
+		assert.Equal(t, uint(0), history[0].PreviousClassId)
+	}
+	assert.Equal(t, 0, s.lastReClusterSummary().NumChanged)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&attempts))
+
+	// 类别数量不变时ClassID保持稳定，再次聚类没有变化
+	assert.NoError(t, s.reCluster(context.Background()))
+	history, _ = s.QueryAppClassHistory(app0)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&attempts))
+
+	// 分类发生变化时记录历史并通知，第一次通知失败后重试
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: app1, ClassId: 1}))
+	assert.NoError(t, s.reCluster(context.Background()))
+	assert.Equal(t, 1, s.lastReClusterSummary().NumChanged)
+	history, _ = s.QueryAppClassHistory(app1)
+	if assert.Equal(t, 2, len(history)) {
+		assert.Equal(t, uint(2), history[1].ClassId)
+		assert.Equal(t, uint(1), history[1].PreviousClassId)
+	}
+	select {
+	case notification := <-received:
+		if assert.Equal(t, 1, len(notification.Changes)) {
+			assert.Equal(t, app1, notification.Changes[0].AppName)
+			assert.Equal(t, uint(1), notification.Changes[0].PreviousClassId)
+		}
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "没有收到分类变化通知")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	handler := s.buildServer().Handler
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/namespaces/test/appclasshistory/app-1", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	history = make([]*server.AppClassHistory, 0)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &history))
+	assert.Equal(t, 2, len(history))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/namespaces/test/appclasshistory/none", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestReCluster_WebhookOutage(t *testing.T) {
+	var attempts int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer webhook.Close()
+
+	dao := NewMemoryDao()
+	config := &ServerConfig{
+		MetricDuration:            7 * 24 * time.Hour,
+		ReClusterInterval:         time.Hour,
+		NumClass:                  2,
+		NumRound:                  DefaultNumRound,
+		ClassChangeWebhook:        webhook.URL,
+		ClassChangeWebhookRetries: 100,
+	}
+	logger := log.New(os.Stdout, "", 0)
+	metrics := newServerMetrics()
+	s := &serverImpl{
+		config:           config,
+		dao:              dao,
+		logger:           logger,
+		metrics:          metrics,
+		notifier:         newWebhookNotifier(config, logger, metrics),
+		executeReCluster: make(chan struct{}),
+	}
+	s.notifier.backoff = time.Hour
+	s.schedule, s.scheduleSpec, s.location, _ = buildReClusterSchedule(config)
+	s.setLeader(true)
+	saveTestPatternCenters(t, dao)
+	yesterday := uint64(time.Now().Unix())/core.DayLength - 1
+	for i := 0; i < 4; i++ {
+		appName := server.AppName{Name: fmt.Sprintf("app-%d", i), Namespace: "test"}
+		assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(appName, i%2, yesterday, 1, core.NumSections, 2)))
+	}
+	assert.NoError(t, s.reCluster(context.Background()))
+	app1 := server.AppName{Name: "app-1", Namespace: "test"}
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: app1, ClassId: 1}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notifierDone := make(chan struct{})
+	go func() {
+		s.notifier.run(ctx)
+		close(notifierDone)
+	}()
+	go s.reClusterer(ctx, ctx)
+
+	// 分类发生变化，通知失败后等待重试
+	assert.Eventually(t, func() bool {
+		return s.ReCluster() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&attempts) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 重试期间仍然可以再聚类
+	assert.Eventually(t, func() bool {
+		return s.ReCluster() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 通知线程结束时停止重试
+	cancel()
+	select {
+	case <-notifierDone:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "通知线程没有退出")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestReCluster_Filter(t *testing.T) {
+	dao := NewMemoryDao()
+	filter, err := newScrapeFilter(&ScrapeFilter{ExcludeNamespaces: []string{"kube-system"}})
+	assert.NoError(t, err)
+	s := &serverImpl{
+		config: &ServerConfig{
+			MetricDuration: 7 * 24 * time.Hour,
+			NumClass:       2,
+			NumRound:       DefaultNumRound,
+		},
+		dao:          dao,
+		logger:       log.New(os.Stdout, "", 0),
+		metrics:      newServerMetrics(),
+		scrapeFilter: filter,
+	}
+	saveTestPatternCenters(t, dao)
+	yesterday := uint64(time.Now().Unix())/core.DayLength - 1
+	apps := []server.AppName{
+		{Name: "app-0", Namespace: "test"},
+		{Name: "app-1", Namespace: "test"},
+		{Name: "opt-out", Namespace: "test"},
+		{Name: "kube-proxy", Namespace: "kube-system"},
+	}
+	for i, appName := range apps {
+		assert.NoError(t, dao.SaveAllAppPodMetrics(workloadPatternMetrics(appName, i%2, yesterday, 1, core.NumSections, 2)))
+	}
+	// 在设置排除规则之前已经有分类
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: apps[2], ClassId: 1}))
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: apps[3], ClassId: 2}))
+	// 获取监控数据之前不再聚类，避免为带有排除注解的负载分类
+	s.executeReCluster = make(chan struct{})
+	s.config.ReClusterInterval = time.Hour
+	s.schedule, s.scheduleSpec, s.location, _ = buildReClusterSchedule(s.config)
+	s.setLeader(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.reClusterer(ctx, ctx)
+	assert.Eventually(t, func() bool {
+		return s.ReCluster() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, server.ErrReClusterBusy, s.ReCluster())
+	assert.Nil(t, s.lastReClusterSummary())
+	_, err = dao.QueryAppClassByApp(&apps[2])
+	assert.NoError(t, err)
+
+	// 模拟获取监控数据时opt-out的负载带有排除注解，获取完成后开始再聚类
+	filter.setExcluded(map[server.AppName]bool{apps[2]: true}, nil)
+	assert.Eventually(t, func() bool {
+		return s.lastReClusterSummary() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, appName := range apps[:2] {
+		_, err := dao.QueryAppClassByApp(&appName)
+		assert.NoError(t, err)
+	}
+	for _, appName := range apps[2:] {
+		_, err := dao.QueryAppClassByApp(&appName)
+		assert.Equal(t, server.ErrAppNotClassified, err, appName.Name)
+	}
+	assert.Equal(t, 2, s.lastReClusterSummary().NumApps)
+}
+
+func TestServerImpl_ReCluster(t *testing.T) {
+	s := &serverImpl{
+		config:           &ServerConfig{},
+		dao:              NewMemoryDao(),
+		logger:           log.New(os.Stdout, "", 0),
+		metrics:          newServerMetrics(),
+		executeReCluster: make(chan struct{}),
+	}
+	handler := s.buildServer().Handler
+	request := func() int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/recluster", nil))
+		return recorder.Code
+	}
+
+	// 非leader不接受再聚类请求
+	assert.Equal(t, server.ErrNotLeader, s.ReCluster())
+	assert.Equal(t, http.StatusServiceUnavailable, request())
+
+	// 再聚类线程未在等待，即正在再聚类
+	s.setLeader(true)
+	assert.Equal(t, server.ErrReClusterBusy, s.ReCluster())
+	assert.Equal(t, http.StatusConflict, request())
+
+	received := make(chan struct{})
+	go func() {
+		<-s.executeReCluster
+		close(received)
+	}()
+	assert.Eventually(t, func() bool {
+		return request() == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	<-received
+}
+
+func TestServerImpl_LoadInitialCenter(t *testing.T) {
+	source := NewMemoryDao()
+	saveTestPatternCenters(t, source)
+	patternCenters, err := source.QueryAllClassMetrics()
+	assert.NoError(t, err)
+	centerFile, err := ioutil.TempFile("", "centers")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.Remove(centerFile.Name())
+	}()
+	writer := csv.NewWriter(centerFile)
+	for _, center := range patternCenters {
+		assert.NoError(t, writer.Write(formatFloats(utils.SectionDataToFloatArray(center.Data))))
+	}
+	writer.Flush()
+	assert.NoError(t, centerFile.Close())
+
+	dao := NewMemoryDao()
+	s := &serverImpl{
+		config: &ServerConfig{InitialCenterCsvFile: centerFile.Name()},
+		dao:    dao,
+		logger: log.New(os.Stdout, "", 0),
+	}
+	s.loadInitialCenter()
+	centers, err := dao.QueryAllClassMetrics()
+	assert.NoError(t, err)
+	assert.Equal(t, len(patternCenters), len(centers))
+
+	// 未启用leader选举时替换已有的类别数据
+	assert.NoError(t, dao.RemoveAllClassMetrics())
+	assert.NoError(t, dao.SaveClassMetrics(&server.ClassMetrics{ClassId: 7, Data: centers[0].Data}))
+	s.loadInitialCenter()
+	centers, err = dao.QueryAllClassMetrics()
+	assert.NoError(t, err)
+	assert.Equal(t, len(patternCenters), len(centers))
+	for _, center := range centers {
+		assert.NotEqual(t, uint(7), center.ClassId)
+	}
+
+	// 启用leader选举时，已有类别数据（例如再聚类的结果）时不覆盖
+	s.config.LeaderElect = true
+	assert.NoError(t, dao.RemoveAllClassMetrics())
+	assert.NoError(t, dao.SaveClassMetrics(&server.ClassMetrics{ClassId: 7, Data: centers[0].Data}))
+	s.loadInitialCenter()
+	centers, err = dao.QueryAllClassMetrics()
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(centers)) {
+		assert.Equal(t, uint(7), centers[0].ClassId)
+	}
+}
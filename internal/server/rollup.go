@@ -0,0 +1,225 @@
+package server
+
+import (
+	"github.com/packagewjx/workload-classifier/internal/sketch"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/pkg/errors"
+	"math"
+	"sort"
+)
+
+// 一个Section内监控数据的汇总。多个汇总可以合并，合并的结果与直接汇总所有数据相同
+type sectionRollup struct {
+	count         uint64
+	cpuSum        float64
+	cpuMin        float64
+	cpuMax        float64
+	cpu           *sketch.Sketch
+	memSum        float64
+	memMin        float64
+	memMax        float64
+	mem           *sketch.Sketch
+	lastTimestamp uint64
+	days          uint64 // 合并的汇总所覆盖的天数，每天的汇总为1。不保存到数据库
+}
+
+// 汇总数据的主键
+type rollupKey struct {
+	appId      uint
+	day        uint64
+	sectionNum uint
+}
+
+func rollupKeyOf(appId uint, timestamp uint64) rollupKey {
+	return rollupKey{
+		appId:      appId,
+		day:        timestamp / core.DayLength,
+		sectionNum: uint(timestamp % core.DayLength / core.SectionLength),
+	}
+}
+
+func newSectionRollup() *sectionRollup {
+	return &sectionRollup{
+		cpuMin: math.Inf(1),
+		cpuMax: math.Inf(-1),
+		cpu:    sketch.New(),
+		memMin: math.Inf(1),
+		memMax: math.Inf(-1),
+		mem:    sketch.New(),
+	}
+}
+
+func (r *sectionRollup) add(timestamp uint64, cpu, mem float32) {
+	if r.days == 0 {
+		r.days = 1
+	}
+	r.count++
+	r.cpuSum += float64(cpu)
+	r.cpuMin = math.Min(r.cpuMin, float64(cpu))
+	r.cpuMax = math.Max(r.cpuMax, float64(cpu))
+	r.cpu.Add(float64(cpu))
+	r.memSum += float64(mem)
+	r.memMin = math.Min(r.memMin, float64(mem))
+	r.memMax = math.Max(r.memMax, float64(mem))
+	r.mem.Add(float64(mem))
+	if timestamp > r.lastTimestamp {
+		r.lastTimestamp = timestamp
+	}
+}
+
+func (r *sectionRollup) merge(other *sectionRollup) {
+	r.count += other.count
+	r.cpuSum += other.cpuSum
+	r.cpuMin = math.Min(r.cpuMin, other.cpuMin)
+	r.cpuMax = math.Max(r.cpuMax, other.cpuMax)
+	r.cpu.Merge(other.cpu)
+	r.memSum += other.memSum
+	r.memMin = math.Min(r.memMin, other.memMin)
+	r.memMax = math.Max(r.memMax, other.memMax)
+	r.mem.Merge(other.mem)
+	if other.lastTimestamp > r.lastTimestamp {
+		r.lastTimestamp = other.lastTimestamp
+	}
+	r.days += other.days
+}
+
+func (r *sectionRollup) clone() *sectionRollup {
+	c := newSectionRollup()
+	c.merge(r)
+	return c
+}
+
+// 转换为与datasource.ConvertRawData相同含义的SectionData，没有数据时各项为NaN
+func (r *sectionRollup) sectionData() *core.SectionData {
+	if r == nil || r.count == 0 {
+		nan := float32(math.NaN())
+		return &core.SectionData{
+			CpuAvg: nan, CpuMax: nan, CpuMin: nan, CpuP50: nan, CpuP90: nan, CpuP99: nan,
+			MemAvg: nan, MemMax: nan, MemMin: nan, MemP50: nan, MemP90: nan, MemP99: nan,
+		}
+	}
+
+	// 草图的分位数是近似值，限制在真实的最值范围内
+	quantile := func(s *sketch.Sketch, min, max, q float64) float32 {
+		return float32(math.Max(min, math.Min(max, s.Quantile(q))))
+	}
+	return &core.SectionData{
+		CpuAvg: float32(r.cpuSum / float64(r.count)),
+		CpuMax: float32(r.cpuMax),
+		CpuMin: float32(r.cpuMin),
+		CpuP50: quantile(r.cpu, r.cpuMin, r.cpuMax, 0.5),
+		CpuP90: quantile(r.cpu, r.cpuMin, r.cpuMax, 0.9),
+		CpuP99: quantile(r.cpu, r.cpuMin, r.cpuMax, 0.99),
+		MemAvg: float32(r.memSum / float64(r.count)),
+		MemMax: float32(r.memMax),
+		MemMin: float32(r.memMin),
+		MemP50: quantile(r.mem, r.memMin, r.memMax, 0.5),
+		MemP90: quantile(r.mem, r.memMin, r.memMax, 0.9),
+		MemP99: quantile(r.mem, r.memMin, r.memMax, 0.99),
+	}
+}
+
+func rollupFromDO(do *AppSectionRollupDO) (*sectionRollup, error) {
+	r := &sectionRollup{
+		count:         do.Count,
+		cpuSum:        do.CpuSum,
+		cpuMin:        do.CpuMin,
+		cpuMax:        do.CpuMax,
+		cpu:           sketch.New(),
+		memSum:        do.MemSum,
+		memMin:        do.MemMin,
+		memMax:        do.MemMax,
+		mem:           sketch.New(),
+		lastTimestamp: do.LastTimestamp,
+		days:          1,
+	}
+	if err := r.cpu.UnmarshalBinary(do.CpuSketch); err != nil {
+		return nil, errors.Wrap(err, "解析CPU分位数草图出错")
+	}
+	if err := r.mem.UnmarshalBinary(do.MemSketch); err != nil {
+		return nil, errors.Wrap(err, "解析内存分位数草图出错")
+	}
+	return r, nil
+}
+
+func (r *sectionRollup) toDO(key rollupKey) (*AppSectionRollupDO, error) {
+	cpuSketch, err := r.cpu.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	memSketch, err := r.mem.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &AppSectionRollupDO{
+		AppId:         key.appId,
+		Day:           key.day,
+		SectionNum:    key.sectionNum,
+		Count:         r.count,
+		CpuSum:        r.cpuSum,
+		CpuMin:        r.cpuMin,
+		CpuMax:        r.cpuMax,
+		CpuSketch:     cpuSketch,
+		MemSum:        r.memSum,
+		MemMin:        r.memMin,
+		MemMax:        r.memMax,
+		MemSketch:     memSketch,
+		LastTimestamp: r.lastTimestamp,
+	}, nil
+}
+
+// Section中监控数据的时间戳范围[start, end)
+func (key rollupKey) timeRange() (uint64, uint64) {
+	start := key.day*core.DayLength + uint64(key.sectionNum)*core.SectionLength
+	return start, start + core.SectionLength
+}
+
+// 将监控数据合并到existing中，existing中不存在的汇总将被创建。返回有变化的汇总，以及无法直接合并的汇总的键。
+// 只有时间戳都晚于汇总中最新时间戳的数据才能直接累加；迟到或乱序的数据，以及更新已有数据的upsert可能与已汇总的数据重复，
+// 这些汇总保持不变，调用者需要在保存原始数据后通过rebuildSectionRollups按Section内的全部原始数据重新汇总
+func rollupAppPodMetrics(existing map[rollupKey]*sectionRollup, records []*AppPodMetricsDO) (map[rollupKey]*sectionRollup, map[rollupKey]struct{}) {
+	sorted := make([]*AppPodMetricsDO, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+
+	stale := make(map[rollupKey]struct{})
+	for _, record := range sorted {
+		key := rollupKeyOf(record.AppId, record.Timestamp)
+		if r, ok := existing[key]; ok && record.Timestamp <= r.lastTimestamp {
+			stale[key] = struct{}{}
+		}
+	}
+
+	changed := make(map[rollupKey]*sectionRollup)
+	for _, record := range sorted {
+		key := rollupKeyOf(record.AppId, record.Timestamp)
+		if _, ok := stale[key]; ok {
+			continue
+		}
+		r, ok := existing[key]
+		if !ok {
+			r = newSectionRollup()
+			existing[key] = r
+		}
+		r.add(record.Timestamp, record.Cpu, record.Mem)
+		changed[key] = r
+	}
+	return changed, stale
+}
+
+// 由stale中各Section的全部原始数据raw重新生成汇总，替换existing中的汇总并加入changed。raw中其他Section的数据被忽略
+func rebuildSectionRollups(existing, changed map[rollupKey]*sectionRollup, stale map[rollupKey]struct{}, raw []*AppPodMetricsDO) {
+	records := make([]*AppPodMetricsDO, 0, len(raw))
+	for _, record := range raw {
+		if _, ok := stale[rollupKeyOf(record.AppId, record.Timestamp)]; ok {
+			records = append(records, record)
+		}
+	}
+	rebuilt, _ := rollupAppPodMetrics(make(map[rollupKey]*sectionRollup), records)
+	for key, r := range rebuilt {
+		existing[key] = r
+		changed[key] = r
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"github.com/packagewjx/workload-classifier/internal/datasource"
+	"github.com/packagewjx/workload-classifier/internal/sketch"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRollupAppPodMetrics(t *testing.T) {
+	const appId = 1
+	const day = 3
+	records := make([]*AppPodMetricsDO, 0)
+	raw := &core.ContainerRawData{Data: make([]*core.RawSectionData, core.NumSections)}
+	for i := range raw.Data {
+		raw.Data[i] = &core.RawSectionData{}
+	}
+	// 两个Section，每个Section每分钟一条数据
+	for section := 0; section < 2; section++ {
+		for minute := 0; minute < core.SectionLength/60; minute++ {
+			cpu := rand.Float32() * 4
+			mem := rand.Float32() * 1024
+			records = append(records, &AppPodMetricsDO{
+				AppId:     appId,
+				Timestamp: uint64(day*core.DayLength + section*core.SectionLength + minute*60),
+				Cpu:       cpu,
+				Mem:       mem,
+			})
+			rawSection := raw.Data[section]
+			rawSection.Cpu = append(rawSection.Cpu, cpu)
+			rawSection.CpuSum += cpu
+			rawSection.Mem = append(rawSection.Mem, mem)
+			rawSection.MemSum += mem
+		}
+	}
+	// 乱序写入，分两批汇总
+	rand.Shuffle(len(records), func(i, j int) {
+		records[i], records[j] = records[j], records[i]
+	})
+
+	existing := make(map[rollupKey]*sectionRollup)
+	changed, stale := rollupAppPodMetrics(existing, records)
+	assert.Equal(t, 2, len(changed))
+	assert.Empty(t, stale)
+	first := rollupKey{appId: appId, day: day, sectionNum: 0}
+	assert.Equal(t, uint64(core.SectionLength/60), existing[first].count)
+	assert.Equal(t, uint64(day*core.DayLength+core.SectionLength-60), existing[first].lastTimestamp)
+
+	// 不晚于已汇总的最新时间戳的数据不直接累加，对应的Section需要重新汇总
+	changed, stale = rollupAppPodMetrics(existing, records[:10])
+	assert.Equal(t, 0, len(changed))
+	assert.NotEmpty(t, stale)
+	assert.Equal(t, uint64(core.SectionLength/60), existing[first].count)
+	rebuildSectionRollups(existing, changed, stale, records)
+	assert.Equal(t, len(stale), len(changed))
+	assert.Equal(t, uint64(core.SectionLength/60), existing[first].count)
+
+	expected := datasource.ConvertRawData(raw)
+	for section := 0; section < 2; section++ {
+		actual := existing[rollupKey{appId: appId, day: day, sectionNum: uint(section)}].sectionData()
+		e := expected.Data[section]
+		assert.InDelta(t, e.CpuAvg, actual.CpuAvg, 1e-4)
+		assert.Equal(t, e.CpuMax, actual.CpuMax)
+		assert.Equal(t, e.CpuMin, actual.CpuMin)
+		assert.InDelta(t, e.CpuP50, actual.CpuP50, float64(e.CpuP50)*sketch.RelativeAccuracy*2)
+		assert.InDelta(t, e.CpuP90, actual.CpuP90, float64(e.CpuP90)*sketch.RelativeAccuracy*2)
+		assert.InDelta(t, e.MemAvg, actual.MemAvg, 1e-1)
+		assert.Equal(t, e.MemMax, actual.MemMax)
+		assert.InDelta(t, e.MemP99, actual.MemP99, float64(e.MemP99)*sketch.RelativeAccuracy*2)
+	}
+
+	// 没有数据的Section
+	var empty *sectionRollup
+	assert.True(t, math.IsNaN(float64(empty.sectionData().CpuAvg)))
+}
+
+func TestSectionRollup_Merge(t *testing.T) {
+	all := newSectionRollup()
+	days := []*sectionRollup{newSectionRollup(), newSectionRollup()}
+	for i := 0; i < 100; i++ {
+		cpu := rand.Float32()
+		mem := rand.Float32()
+		all.add(uint64(i), cpu, mem)
+		days[i%2].add(uint64(i), cpu, mem)
+	}
+	merged := newSectionRollup()
+	merged.merge(days[0])
+	merged.merge(days[1])
+
+	assert.Equal(t, all.count, merged.count)
+	assert.Equal(t, all.cpuMax, merged.cpuMax)
+	assert.Equal(t, all.memMin, merged.memMin)
+	assert.Equal(t, all.lastTimestamp, merged.lastTimestamp)
+	assert.Equal(t, uint64(1), all.days)
+	assert.Equal(t, uint64(2), merged.days)
+	assert.InDelta(t, all.cpuSum, merged.cpuSum, 1e-9)
+	assert.Equal(t, all.sectionData().CpuP90, merged.sectionData().CpuP90)
+}
+
+func TestSectionRollup_DO(t *testing.T) {
+	r := newSectionRollup()
+	for i := 0; i < 10; i++ {
+		r.add(uint64(i), float32(i), float32(i*2))
+	}
+	key := rollupKey{appId: 1, day: 2, sectionNum: 3}
+	do, err := r.toDO(key)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), do.AppId)
+	assert.Equal(t, uint64(2), do.Day)
+	assert.Equal(t, uint(3), do.SectionNum)
+
+	decoded, err := rollupFromDO(do)
+	assert.NoError(t, err)
+	assert.Equal(t, r, decoded)
+
+	do.CpuSketch = []byte{1}
+	_, err = rollupFromDO(do)
+	assert.Error(t, err)
+}
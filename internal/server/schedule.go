@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"math/rand"
+	"time"
+)
+
+const minReClusterInterval = time.Minute
+
+// 带随机抖动的调度计划，在原计划的时间上增加[0, jitter)的随机延迟，避免多个实例同时执行
+type jitterSchedule struct {
+	schedule cron.Schedule
+	jitter   time.Duration
+}
+
+func (j *jitterSchedule) Next(t time.Time) time.Time {
+	next := j.schedule.Next(t)
+	if j.jitter > 0 && !next.IsZero() {
+		next = next.Add(time.Duration(rand.Int63n(int64(j.jitter))))
+	}
+	return next
+}
+
+// 根据配置生成再聚类的调度计划，同时返回计划的描述以及所使用的时区。
+// 优先级为：ReClusterSchedule（cron表达式） > ReClusterInterval（固定间隔） > ReClusterTime（每天定时）
+func buildReClusterSchedule(config *ServerConfig) (cron.Schedule, string, *time.Location, error) {
+	location := time.Local
+	if config.TimeZone != "" {
+		loc, err := time.LoadLocation(config.TimeZone)
+		if err != nil {
+			return nil, "", nil, errors.Wrap(err, fmt.Sprintf("无法识别时区%s", config.TimeZone))
+		}
+		location = loc
+	}
+
+	var schedule cron.Schedule
+	var spec string
+	if config.ReClusterSchedule != "" {
+		if config.ReClusterInterval != 0 {
+			return nil, "", nil, fmt.Errorf("ReClusterSchedule与ReClusterInterval不能同时设置")
+		}
+		spec = config.ReClusterSchedule
+	} else if config.ReClusterInterval != 0 {
+		if config.ReClusterInterval < minReClusterInterval {
+			return nil, "", nil, fmt.Errorf("再聚类间隔不能短于%s，现在为%s", minReClusterInterval, config.ReClusterInterval)
+		}
+		spec = fmt.Sprintf("@every %s", config.ReClusterInterval)
+	} else {
+		// 兼容原有的每天定时配置，精确到分钟
+		t := config.ReClusterTime % (24 * time.Hour)
+		spec = fmt.Sprintf("%d %d * * *", int(t.Minutes())%60, int(t.Hours()))
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, "", nil, errors.Wrap(err, fmt.Sprintf("解析再聚类调度计划%s出错", spec))
+	}
+	if specSchedule, ok := schedule.(*cron.SpecSchedule); ok {
+		// 表达式中使用CRON_TZ指定了时区时，以表达式为准
+		if specSchedule.Location == time.Local {
+			specSchedule.Location = location
+		} else {
+			location = specSchedule.Location
+		}
+	}
+
+	if config.ReClusterJitter < 0 {
+		return nil, "", nil, fmt.Errorf("ReClusterJitter不能为负数，现在为%s", config.ReClusterJitter)
+	} else if config.ReClusterJitter > 0 {
+		schedule = &jitterSchedule{
+			schedule: schedule,
+			jitter:   config.ReClusterJitter,
+		}
+		spec = fmt.Sprintf("%s (jitter %s)", spec, config.ReClusterJitter)
+	}
+
+	return schedule, spec, location, nil
+}
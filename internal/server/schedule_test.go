@@ -0,0 +1,102 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBuildReClusterSchedule(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "加载时区失败")
+	}
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, shanghai)
+
+	// 兼容原有的每天定时配置，且不再忽略分钟
+	schedule, spec, loc, err := buildReClusterSchedule(&ServerConfig{
+		ReClusterTime: time.Hour + 30*time.Minute,
+		TimeZone:      "Asia/Shanghai",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "30 1 * * *", spec)
+	assert.Equal(t, shanghai.String(), loc.String())
+	assert.True(t, time.Date(2020, 10, 2, 1, 30, 0, 0, shanghai).Equal(schedule.Next(now)))
+
+	// cron表达式
+	schedule, _, _, err = buildReClusterSchedule(&ServerConfig{
+		ReClusterSchedule: "15 */6 * * *",
+		TimeZone:          "Asia/Shanghai",
+	})
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2020, 10, 1, 12, 15, 0, 0, shanghai).Equal(schedule.Next(now)))
+
+	// 表达式中的时区优先
+	schedule, _, loc, err = buildReClusterSchedule(&ServerConfig{
+		ReClusterSchedule: "CRON_TZ=UTC 0 0 * * *",
+		TimeZone:          "Asia/Shanghai",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "UTC", loc.String())
+	assert.True(t, time.Date(2020, 10, 2, 0, 0, 0, 0, time.UTC).Equal(schedule.Next(now)))
+
+	// 固定间隔
+	schedule, spec, _, err = buildReClusterSchedule(&ServerConfig{
+		ReClusterInterval: 6 * time.Hour,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "@every 6h0m0s", spec)
+	assert.True(t, now.Add(6*time.Hour).Equal(schedule.Next(now)))
+
+	// 随机延迟
+	schedule, _, _, err = buildReClusterSchedule(&ServerConfig{
+		ReClusterInterval: 6 * time.Hour,
+		ReClusterJitter:   10 * time.Minute,
+	})
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		next := schedule.Next(now)
+		assert.False(t, next.Before(now.Add(6*time.Hour)))
+		assert.True(t, next.Before(now.Add(6*time.Hour+10*time.Minute)))
+	}
+
+	/*
+		错误的配置
+	*/
+	_, _, _, err = buildReClusterSchedule(&ServerConfig{ReClusterSchedule: "invalid"})
+	assert.Error(t, err)
+	_, _, _, err = buildReClusterSchedule(&ServerConfig{ReClusterSchedule: "@daily", ReClusterInterval: time.Hour})
+	assert.Error(t, err)
+	_, _, _, err = buildReClusterSchedule(&ServerConfig{ReClusterInterval: time.Second})
+	assert.Error(t, err)
+	_, _, _, err = buildReClusterSchedule(&ServerConfig{ReClusterJitter: -time.Second})
+	assert.Error(t, err)
+	_, _, _, err = buildReClusterSchedule(&ServerConfig{TimeZone: "Nowhere/Invalid"})
+	assert.Error(t, err)
+}
+
+func TestServerImpl_QueryReClusterSchedule(t *testing.T) {
+	config := &ServerConfig{
+		ReClusterSchedule: "@daily",
+		TimeZone:          "UTC",
+	}
+	schedule, spec, loc, _ := buildReClusterSchedule(config)
+	s := &serverImpl{
+		config:       config,
+		schedule:     schedule,
+		scheduleSpec: spec,
+		location:     loc,
+	}
+
+	result, err := s.QueryReClusterSchedule()
+	assert.NoError(t, err)
+	assert.Equal(t, "@daily", result.Schedule)
+	assert.Equal(t, "UTC", result.TimeZone)
+	assert.True(t, result.Next.IsZero())
+
+	next := schedule.Next(time.Now())
+	s.setNextReClusterTime(next)
+	result, err = s.QueryReClusterSchedule()
+	assert.NoError(t, err)
+	assert.True(t, next.Equal(result.Next))
+}
@@ -3,28 +3,45 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/packagewjx/workload-classifier/pkg/server"
 	"github.com/pkg/errors"
 	"io/ioutil"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	metrics "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	"net/http"
 	"time"
 )
 
-// 用于从metrics server获取数据并保存到数据库的goroutine主函数
-func (s *serverImpl) scrapper(ctx context.Context) {
+// 用于从metrics server获取数据并保存到数据库的goroutine主函数。ctx结束后不再获取新的数据，但会保存完正在处理的一批数据，
+// 除非abortCtx也已结束
+func (s *serverImpl) scrapper(ctx, abortCtx context.Context) {
 	s.logger.Println("监控数据获取线程启动")
-	tickCh := time.Tick(s.config.ScrapeInterval)
+	s.scrapeStatus.start(time.Now())
+	defer s.scrapeStatus.stop()
+	ticker := time.NewTicker(s.config.ScrapeInterval)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-tickCh:
-			podMetrics, err := s.scrapePodMetrics()
-			if err != nil {
-				panic(err)
+		case <-ticker.C:
+			if ctx.Err() != nil {
+				// 已经要求退出，不再获取新的数据
+				continue
 			}
-			err = s.dao.SaveAllAppPodMetrics(podMetrics)
-			if err != nil {
+			start := time.Now()
+			podMetrics, err := s.scrapePodMetrics(abortCtx)
+			s.metrics.scrapeDuration.Observe(time.Since(start).Seconds())
+			if err == nil {
+				err = s.dao.WithContext(abortCtx).SaveAllAppPodMetrics(podMetrics)
+			}
+			s.metrics.observeScrape(podMetrics, err)
+			if err == nil {
+				s.scrapeStatus.success(time.Now())
+			} else if abortCtx.Err() != nil {
+				s.logger.Printf("监控数据获取被中止：%v\n", err)
+				return
+			} else {
 				panic(err)
 			}
 		case <-ctx.Done():
@@ -34,12 +51,87 @@ func (s *serverImpl) scrapper(ctx context.Context) {
 	}
 }
 
-func (s *serverImpl) scrapePodMetrics() ([]*server.AppPodMetrics, error) {
+const retentionInterval = time.Hour
+
+// 定期删除超出MetricDuration的监控数据的goroutine主函数
+func (s *serverImpl) retainer(ctx, abortCtx context.Context) {
+	s.logger.Println("过期数据清理线程启动")
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if ctx.Err() != nil {
+				continue
+			}
+			before := uint64(time.Now().Add(-s.config.MetricDuration).Unix())
+			s.logger.Printf("正在删除时间戳%d之前的监控数据\n", before)
+			err := s.dao.WithContext(abortCtx).RemoveAppPodMetricsBefore(before)
+			if err != nil {
+				s.logger.Printf("删除过期监控数据出错：%v\n", err)
+			}
+			// 分类变化记录与监控数据保留相同的时间，断开更久的watch客户端需要重新获取快照
+			err = s.dao.WithContext(abortCtx).RemoveClassificationEventsBefore(time.Now().Add(-s.config.MetricDuration))
+			if err != nil {
+				s.logger.Printf("删除过期分类变化记录出错：%v\n", err)
+			}
+		case <-ctx.Done():
+			s.logger.Println("过期数据清理线程结束")
+			return
+		}
+	}
+}
+
+// 获取所有集群的监控数据。某个集群获取失败时记录日志并跳过，全部失败时返回错误
+func (s *serverImpl) scrapePodMetrics(ctx context.Context) ([]*server.AppPodMetrics, error) {
+	result := make([]*server.AppPodMetrics, 0)
+	included := make(map[server.AppName]bool)
+	excluded := make(map[server.AppName]bool)
+	failed := make(map[string]bool)
+	var lastErr error
+	succeeded := 0
+	sources := s.clusterSources()
+	for _, source := range sources {
+		metrics, err := s.scrapeCluster(ctx, source, included, excluded)
+		if err != nil {
+			if len(sources) > 1 {
+				s.logger.Printf("获取集群%q的监控数据出错：%v\n", source.name, err)
+			}
+			failed[source.name] = true
+			lastErr = err
+			continue
+		}
+		succeeded++
+		result = append(result, metrics...)
+	}
+	if succeeded == 0 {
+		return nil, lastErr
+	}
+
+	// 只有部分Pod被排除的应用仍然保留
+	for appName := range included {
+		delete(excluded, appName)
+	}
+	s.scrapeFilter.setExcluded(excluded, failed)
+	if len(excluded) > 0 {
+		s.logger.Printf("按过滤规则排除了%d个应用\n", len(excluded))
+	}
+	return result, nil
+}
+
+// 获取一个集群的监控数据，并将包含与排除的应用记录到included与excluded中
+func (s *serverImpl) scrapeCluster(ctx context.Context, source *clusterSource,
+	included, excluded map[server.AppName]bool) ([]*server.AppPodMetrics, error) {
 	listFunc := func(url string, dest interface{}) error {
-		response, err := http.Get(url)
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		response, err := source.client.Do(request)
 		if err != nil {
 			return err
 		}
+		defer response.Body.Close()
 		body, err := ioutil.ReadAll(response.Body)
 		if err != nil {
 			return err
@@ -56,32 +148,43 @@ func (s *serverImpl) scrapePodMetrics() ([]*server.AppPodMetrics, error) {
 
 	s.logger.Println("正在从api server获取PodList")
 	podList := &corev1.PodList{}
-	err := listFunc(PodListUrl, podList)
+	err := listFunc(source.url+PodListPath, podList)
 	if err != nil {
 		return nil, errors.Wrap(err, "请求PodList出错")
 	}
 	s.logger.Printf("获取了%d条PodList数据\n", len(podList.Items))
 
+	excludedWorkloads := s.listExcludedWorkloads(ctx, source)
+	identifier := s.appIdentifier
+	if identifier == nil {
+		identifier = ownerIdentifier{}
+	}
 	podAppNameMap := make(map[string]server.AppName)
-	for _, item := range podList.Items {
-		for _, reference := range item.OwnerReferences {
-			switch reference.Kind {
-			case KindDaemonSet, KindStatefulSet, KindReplicaSet:
-				podAppNameMap[keyFunc(item.Name, item.Namespace)] = server.AppName{
-					Name:      reference.Name,
-					Namespace: item.Namespace,
-				}
-			default:
-				continue
-			}
-			break
+	for i := range podList.Items {
+		item := &podList.Items[i]
+		appName, ok := identifier.Identify(item)
+		if !ok {
+			// 按所选的策略无法确定应用的Pod，如owner策略下直接部署的Pod，不保存其监控数据
+			continue
+		}
+		appName.Cluster = source.name
+		ownerKind := ""
+		workloadExcluded := false
+		if owner := workloadOwner(item); owner != nil {
+			ownerKind = owner.Kind
+			workloadExcluded = excludedWorkloads[workloadKey(owner.Kind, item.Namespace, owner.Name)]
+		}
+		if s.scrapeFilter.ownerKindIncluded(ownerKind) && s.scrapeFilter.podIncluded(item) && !workloadExcluded {
+			podAppNameMap[keyFunc(item.Name, item.Namespace)] = appName
+			included[appName] = true
+		} else {
+			excluded[appName] = true
 		}
-		// 这里可能遍历item没有结果，因为有部分是直接部署的Pod，暂时不处理
 	}
 
 	s.logger.Println("正在从metrics server获取PodMetricsList")
 	podMetricsList := &metrics.PodMetricsList{}
-	err = listFunc(PodMetricsListUrl, podMetricsList)
+	err = listFunc(source.url+PodMetricsListPath, podMetricsList)
 	if err != nil {
 		return nil, errors.Wrap(err, "请求PodMetricsList出错")
 	}
@@ -97,7 +200,7 @@ func (s *serverImpl) scrapePodMetrics() ([]*server.AppPodMetrics, error) {
 		}
 		appName, ok := podAppNameMap[keyFunc(podMetrics.Name, podMetrics.Namespace)]
 		if !ok {
-			// 没有appName代表是直接部署的Pod，不会保存其监控数据
+			// 没有appName代表Pod不属于任何应用或被排除，不会保存其监控数据
 			continue
 		}
 
@@ -112,3 +215,49 @@ func (s *serverImpl) scrapePodMetrics() ([]*server.AppPodMetrics, error) {
 
 	return result, nil
 }
+
+func workloadKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// 只获取负载的元数据
+const partialObjectMetadataListAccept = "application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1"
+
+// 返回带有ExcludeAnnotation注解的负载，键由workloadKey生成。Deployment的注解会被复制到其ReplicaSet上。
+// 获取失败时只记录日志，本次不按负载的注解排除
+func (s *serverImpl) listExcludedWorkloads(ctx context.Context, source *clusterSource) map[string]bool {
+	result := make(map[string]bool)
+	for _, kind := range s.scrapeFilter.workloadKinds() {
+		list := &metav1.PartialObjectMetadataList{}
+		err := func() error {
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, source.url+workloadListPaths[kind], nil)
+			if err != nil {
+				return err
+			}
+			request.Header.Set("Accept", partialObjectMetadataListAccept)
+			response, err := source.client.Do(request)
+			if err != nil {
+				return err
+			}
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+			if err != nil {
+				return err
+			}
+			if response.StatusCode != http.StatusOK {
+				return fmt.Errorf("状态码为%d，响应为%s", response.StatusCode, string(body))
+			}
+			return json.Unmarshal(body, list)
+		}()
+		if err != nil {
+			s.logger.Printf("获取集群%q的%s列表出错，本次不按其注解排除应用：%v\n", source.name, kind, err)
+			continue
+		}
+		for _, item := range list.Items {
+			if hasExcludeAnnotation(item.Annotations) {
+				result[workloadKey(kind, item.Namespace, item.Name)] = true
+			}
+		}
+	}
+	return result
+}
@@ -1,12 +1,32 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"github.com/packagewjx/workload-classifier/pkg/server"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metrics "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestScrape(t *testing.T) {
+	// 需要通过kubectl proxy访问集群
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(KubeApiServerProxyUrl, "http://"), time.Second)
+	if err != nil {
+		t.Skipf("无法连接%s，跳过测试：%v", KubeApiServerProxyUrl, err)
+	}
+	_ = conn.Close()
+
 	server, err := NewServer(&ServerConfig{
 		MetricDuration:       7 * 24 * time.Hour,
 		Port:                 2000,
@@ -15,14 +35,14 @@ func TestScrape(t *testing.T) {
 		NumClass:             30,
 		NumRound:             20,
 		InitialCenterCsvFile: "",
-		MysqlHost:            "127.0.0.1:3306",
+		Storage:              StorageMemory,
 	})
 	if !assert.NoError(t, err) {
 		assert.FailNow(t, "创建服务器失败")
 	}
 	impl := server.(*serverImpl)
 
-	metrics, err := impl.scrapePodMetrics()
+	metrics, err := impl.scrapePodMetrics(context.Background())
 	assert.NoError(t, err)
 
 	for _, metric := range metrics {
@@ -32,3 +52,82 @@ func TestScrape(t *testing.T) {
 		assert.NotEqual(t, "", metric.Namespace)
 	}
 }
+
+// 模拟api server，返回pods及其监控数据，每个Pod使用0.1核CPU。返回的mux可以增加其他路径
+func newPodsApiServer(pods []corev1.Pod) (*httptest.Server, *http.ServeMux) {
+	podMetrics := &metrics.PodMetricsList{}
+	for _, p := range pods {
+		podMetrics.Items = append(podMetrics.Items, metrics.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Namespace: p.Namespace, Name: p.Name},
+			Timestamp:  metav1.Now(),
+			Containers: []metrics.ContainerMetrics{{Usage: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("64Mi"),
+			}}},
+		})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(PodListPath, func(writer http.ResponseWriter, request *http.Request) {
+		_ = json.NewEncoder(writer).Encode(&corev1.PodList{Items: pods})
+	})
+	mux.HandleFunc(PodMetricsListPath, func(writer http.ResponseWriter, request *http.Request) {
+		_ = json.NewEncoder(writer).Encode(podMetrics)
+	})
+	return httptest.NewServer(mux), mux
+}
+
+func testPod(namespace, name, ownerKind, ownerName string, labels map[string]string) corev1.Pod {
+	p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+	if ownerKind != "" {
+		p.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: ownerName}}
+	}
+	return p
+}
+
+func TestServerImpl_ScrapePodMetrics_Filter(t *testing.T) {
+	pods := []corev1.Pod{
+		testPod("default", "web-1", KindReplicaSet, "web-abc", map[string]string{"tier": "web"}),
+		testPod("default", "batch-1", KindReplicaSet, "batch-abc", map[string]string{"tier": "batch"}),
+		testPod("default", "opt-1", KindReplicaSet, "opt-abc", nil),
+		testPod("default", "db-0", KindStatefulSet, "db", nil),
+		testPod("kube-system", "proxy-1", KindDaemonSet, "kube-proxy", nil),
+		testPod("default", "bare", "", "", nil),
+	}
+	apiServer, mux := newPodsApiServer(pods)
+	defer apiServer.Close()
+	accepts := make([]string, 0)
+	mux.HandleFunc(ReplicaSetListPath, func(writer http.ResponseWriter, request *http.Request) {
+		accepts = append(accepts, request.Header.Get("Accept"))
+		_ = json.NewEncoder(writer).Encode(&metav1.PartialObjectMetadataList{Items: []metav1.PartialObjectMetadata{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-abc"}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "opt-abc", Annotations: map[string]string{ExcludeAnnotation: "true"}}},
+		}})
+	})
+	// 获取负载列表失败时不影响监控数据的获取
+	mux.HandleFunc(StatefulSetListPath, func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "forbidden", http.StatusForbidden)
+	})
+
+	filter, err := newScrapeFilter(&ScrapeFilter{
+		ExcludeNamespaces: []string{"kube-*"},
+		LabelSelector:     "tier!=batch",
+		OwnerKinds:        []string{KindReplicaSet, KindStatefulSet},
+	})
+	assert.NoError(t, err)
+	s := &serverImpl{logger: log.New(os.Stdout, "", 0), apiServerUrl: apiServer.URL, scrapeFilter: filter}
+
+	result, err := s.scrapePodMetrics(context.Background())
+	assert.NoError(t, err)
+	apps := make([]string, 0)
+	for _, m := range result {
+		apps = append(apps, m.Name)
+		assert.InDelta(t, 0.1, m.Cpu, 1e-6)
+	}
+	assert.ElementsMatch(t, []string{"web-abc", "db"}, apps)
+	assert.Equal(t, []string{partialObjectMetadataListAccept}, accepts, "只获取需要的负载种类的元数据")
+	for _, name := range []string{"batch-abc", "opt-abc"} {
+		assert.True(t, filter.appExcluded(server.AppName{Name: name, Namespace: "default"}), name)
+	}
+	assert.True(t, filter.appExcluded(server.AppName{Name: "kube-proxy", Namespace: "kube-system"}))
+	assert.False(t, filter.appExcluded(server.AppName{Name: "web-abc", Namespace: "default"}))
+}
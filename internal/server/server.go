@@ -6,17 +6,23 @@ import (
 	"fmt"
 	"github.com/packagewjx/workload-classifier/pkg/server"
 	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"google.golang.org/grpc"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"regexp"
+	"sync"
 	"syscall"
 	"time"
 )
 
-const PodMetricsListUrl = "http://localhost:8001/apis/metrics.k8s.io/v1beta1/pods"
-const PodListUrl = "http://localhost:8001/api/v1/pods"
+const PodMetricsListPath = "/apis/metrics.k8s.io/v1beta1/pods"
+const PodListPath = "/api/v1/pods"
 
 const (
 	KindReplicaSet  = "ReplicaSet"
@@ -26,24 +32,79 @@ const (
 
 const (
 	DefaultPort           = 2000
+	DefaultGrpcPort       = 2001
 	DefaultScrapeInterval = time.Minute
 	DefaultMetricDuration = 7 * 24 * time.Hour
 	DefaultReClusterTime  = 1 * time.Hour
 	DefaultNumRound       = 30
 	DefaultNumClass       = 20
+
+	DefaultShutdownGracePeriod = 30 * time.Second
 )
 
 const minDuration = 24 * time.Hour
 
+// 数据存储方式
+const (
+	StorageMysql  = "mysql"
+	StorageMemory = "memory" // 数据保存在内存中，进程退出后丢失，仅用于测试与演示
+
+	DefaultStorage = StorageMysql
+)
+
 type ServerConfig struct {
 	MetricDuration       time.Duration // 给每个应用保留的数据的时间长度
 	Port                 uint16        // 本服务器监听端口
+	GrpcPort             uint16        // gRPC API监听端口，为0则不提供gRPC API
 	ScrapeInterval       time.Duration // 从metrics server获取数据的周期。至少为15s。
-	ReClusterTime        time.Duration // 再聚类的时间
+	ReClusterTime        time.Duration // 每天再聚类的时间。仅在ReClusterSchedule与ReClusterInterval均未设置时使用
+	ReClusterSchedule    string        // 再聚类的cron表达式，如"30 1 * * *"，也支持"@daily"等描述符
+	ReClusterInterval    time.Duration // 再聚类的固定间隔，不能与ReClusterSchedule同时设置
+	ReClusterJitter      time.Duration // 每次再聚类时间上增加的随机延迟的上限
+	TimeZone             string        // 计算再聚类时间所使用的时区，如"Asia/Shanghai"。为空则使用本地时区
 	NumClass             uint          // 类别数量
 	NumRound             uint          // 聚类迭代轮次
-	InitialCenterCsvFile string        // 初始各类中心的数据文件。若不是空，则会清空数据库的数据并读取。若为空，则使用数据库数据，此时如果数据库没有类别数据，则会产生错误。
+	InitialCenterCsvFile string        // 初始各类中心的数据文件。若不是空，则读取此文件并替换数据库的类别数据；启用leader选举且数据库已有类别数据时不读取。若都没有则会产生错误。
+	Storage              string        // 数据存储方式，为StorageMysql或StorageMemory，为空则使用DefaultStorage
 	MysqlHost            string
+	ShutdownGracePeriod  time.Duration // 退出时等待正在进行的数据获取与再聚类完成的最长时间，超过后将中止这些操作
+
+	ScrapeFilter   ScrapeFilter // 被排除的应用不获取监控数据，也不参与再聚类
+	AppIdentity    string       // 确定Pod所属应用的策略，为AppIdentityOwner等。为空则使用DefaultAppIdentity
+	AppIdentityKey string       // AppIdentityLabel与AppIdentityAnnotation策略所用的标签或注解的键
+
+	// 除服务器所在的集群外，还从kubeconfig中的这些context对应的集群获取监控数据，各集群的应用共用类别中心
+	ClusterName  string   // 服务器所在集群的名称，为空则查询该集群的应用时不需要指定集群
+	Kubeconfig   string   // 为空则使用KUBECONFIG环境变量或~/.kube/config
+	KubeContexts []string // 每项为"context"或"集群名称=context"，前者集群名称与context相同
+
+	// 应用参与计算类别中心的数据要求，不满足的应用只暂定分类。为零值时不检查
+	MinObservedDays    uint    // 至少有数据的天数
+	MinSectionCoverage float64 // 有数据的Section占一天中所有Section的最小比例，范围为0到1
+	MinSampleCount     uint64  // 保留时间内的最少样本数量
+
+	ClassChangeWebhook        string // 再聚类后有应用分类发生变化时，将变化POST到此URL。为空则不通知
+	ClassChangeWebhookRetries uint   // 通知失败后的最大重试次数
+
+	LeaderElect             bool   // 是否启用leader选举。启用后可以运行多个副本，所有副本均提供查询API，只有leader获取监控数据与再聚类
+	LeaderElectionNamespace string // leader选举所用Lease所在的名称空间
+	LeaderElectionName      string // leader选举所用Lease的名称
+	LeaderElectionIdentity  string // 本实例参与选举的标识，为空则使用环境变量POD_NAME或主机名
+
+	TLSCertFile string // HTTP与gRPC API使用的证书，与TLSKeyFile同时设置时启用TLS。文件被修改后自动重新加载
+	TLSKeyFile  string
+
+	// 以下任一认证方式启用后，除存活检查、就绪检查与监控指标外的API都需要bearer token
+	TokenAuthFile string   // 静态token文件，每行为"token,用户名,权限"，权限为PermissionRead、PermissionIngest或PermissionAdmin
+	TokenReview   bool     // 通过Kubernetes TokenReview验证token
+	AdminUsers    []string // 通过TokenReview认证、具有admin权限的用户名
+	AdminGroups   []string // 通过TokenReview认证、其成员具有admin权限的组
+	IngestUsers   []string // 通过TokenReview认证、具有ingest权限的用户名
+	IngestGroups  []string // 通过TokenReview认证、其成员具有ingest权限的组
+
+	// 推送监控数据时对每个来源的限制，来源为认证的用户，未启用认证时为客户端地址
+	IngestRateLimit float64 // 每秒可以推送的样本数，为0则不限制速率
+	IngestBurst     int     // 可以突发推送的样本数，也是单次推送的样本数上限。为0则使用DefaultIngestBurst
 }
 
 func (s ServerConfig) String() string {
@@ -60,16 +121,93 @@ func NewServer(config *ServerConfig) (Server, error) {
 		return nil, err
 	}
 
-	dao, err := NewDao(config.MysqlHost)
+	var dao Dao
+	if config.Storage == StorageMemory {
+		dao = NewMemoryDao()
+	} else {
+		var err error
+		dao, err = NewDao(config.MysqlHost)
+		if err != nil {
+			return nil, err
+		}
+	}
+	logger := log.New(os.Stdout, "workload server: ", log.LstdFlags|log.Lshortfile|log.Lmsgprefix)
+	metrics := newServerMetrics()
+	dao = newMetricsDao(dao, metrics)
+	metrics.registry.MustRegister(newClassificationCollector(dao, logger))
+
+	schedule, scheduleSpec, location, err := buildReClusterSchedule(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var election *leaderElection
+	if config.LeaderElect {
+		lock, err := newLeaseLock(config)
+		if err != nil {
+			return nil, err
+		}
+		election = &leaderElection{
+			lock:          lock,
+			leaseDuration: DefaultLeaseDuration,
+			renewDeadline: DefaultRenewDeadline,
+			retryPeriod:   DefaultRetryPeriod,
+		}
+	}
+
+	var notifier *webhookNotifier
+	if config.ClassChangeWebhook != "" {
+		notifier = newWebhookNotifier(config, logger, metrics)
+	}
+
+	var certificates *certificateReloader
+	if config.TLSCertFile != "" {
+		certificates, err = newCertificateReloader(config.TLSCertFile, config.TLSKeyFile, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	auth, err := newAuthenticator(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := newScrapeFilter(&config.ScrapeFilter)
+	if err != nil {
+		return nil, err
+	}
+	identifier, err := NewAppIdentifier(config.AppIdentity, config.AppIdentityKey)
+	if err != nil {
+		return nil, err
+	}
+	contexts, err := parseKubeContexts(config.KubeContexts, config.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	remoteClusters, err := newKubeconfigClusters(config.Kubeconfig, contexts)
 	if err != nil {
 		return nil, err
 	}
 
 	return &serverImpl{
-		config:           config,
-		dao:              dao,
-		logger:           log.New(os.Stdout, "workload server: ", log.LstdFlags|log.Lshortfile|log.Lmsgprefix),
-		executeReCluster: make(chan struct{}),
+		config:            config,
+		dao:               dao,
+		logger:            logger,
+		metrics:           metrics,
+		executeReCluster:  make(chan struct{}),
+		schedule:          schedule,
+		scheduleSpec:      scheduleSpec,
+		location:          location,
+		election:          election,
+		notifier:          notifier,
+		certificates:      certificates,
+		authenticator:     auth,
+		scrapeFilter:      filter,
+		appIdentifier:     identifier,
+		apiServerUrl:      KubeApiServerProxyUrl,
+		clusterName:       config.ClusterName,
+		remoteClusters:    remoteClusters,
+		ingestLimiter:     newIngestLimiter(config.IngestRateLimit, config.IngestBurst),
+		remoteWritePairer: newRemoteWritePairer(),
 	}, nil
 }
 
@@ -77,13 +215,46 @@ type serverImpl struct {
 	config           *ServerConfig
 	dao              Dao
 	logger           *log.Logger
+	metrics          *serverMetrics
 	executeReCluster chan struct{}
+	apiServerUrl     string // 获取PodList与PodMetricsList的api server地址
+	clusterName      string // 服务器所在集群的名称
+	remoteClusters   []*clusterSource
+
+	schedule     cron.Schedule
+	scheduleSpec string
+	location     *time.Location
+
+	nextReClusterLock sync.RWMutex
+	nextReCluster     time.Time
+
+	summaryLock      sync.RWMutex
+	reClusterSummary *server.ReClusterSummary // 本实例最近一次完成的再聚类的概况
+
+	scrapeStatus  scrapeStatus
+	scrapeFilter  *scrapeFilter    // 为nil时不排除任何应用
+	appIdentifier AppIdentifier    // 确定Pod所属的应用，为nil时使用owner策略
+	notifier      *webhookNotifier // 为nil时不发送分类变化通知
+
+	ingestLimiter     *ingestLimiter
+	remoteWritePairer *remoteWritePairer
+
+	certificates  *certificateReloader // 为nil时不使用TLS
+	authenticator authenticator        // 为nil时不进行认证
+
+	election          *leaderElection // 为nil时不进行选举，本实例总是leader
+	leader            int32
+	initialCenterOnce sync.Once
+	backfillOnce      sync.Once
 }
 
 func (config *ServerConfig) Complete() error {
 	if config.Port < 1024 {
 		return fmt.Errorf("端口号应该在1024到65535之间，现在为%d", config.Port)
 	}
+	if config.GrpcPort != 0 && (config.GrpcPort < 1024 || config.GrpcPort == config.Port) {
+		return fmt.Errorf("gRPC端口号应该在1024到65535之间且不同于HTTP端口，现在为%d", config.GrpcPort)
+	}
 
 	if config.MetricDuration < minDuration {
 		return fmt.Errorf("MetricDuration应该至少为%f小时，现在为%f小时", minDuration.Hours(), config.MetricDuration.Hours())
@@ -92,10 +263,63 @@ func (config *ServerConfig) Complete() error {
 	if config.ScrapeInterval < time.Second*15 {
 		return fmt.Errorf("时间不能短于15s，现在是%fs", config.ScrapeInterval.Seconds())
 	}
+	if err := config.ScrapeFilter.validate(); err != nil {
+		return err
+	}
+	if config.AppIdentity == "" {
+		config.AppIdentity = DefaultAppIdentity
+	}
+	if _, err := NewAppIdentifier(config.AppIdentity, config.AppIdentityKey); err != nil {
+		return err
+	}
+	if err := validateClusterName(config.ClusterName); err != nil {
+		return err
+	}
+	if _, err := parseKubeContexts(config.KubeContexts, config.ClusterName); err != nil {
+		return err
+	}
 
 	// 限制重计算时间在24小时内，为一天内的时间
 	config.ReClusterTime %= 24 * time.Hour
 
+	if _, _, _, err := buildReClusterSchedule(config); err != nil {
+		return err
+	}
+
+	if config.ShutdownGracePeriod < 0 {
+		return fmt.Errorf("退出宽限期不能为负数，现在为%s", config.ShutdownGracePeriod)
+	}
+
+	if config.MinSectionCoverage < 0 || config.MinSectionCoverage > 1 {
+		return fmt.Errorf("有数据的Section比例应在0到1之间，现在为%f", config.MinSectionCoverage)
+	}
+	if config.MinObservedDays > uint(config.MetricDuration/(24*time.Hour)) {
+		return fmt.Errorf("要求有数据的天数%d超过了数据保留的天数%d", config.MinObservedDays, uint(config.MetricDuration/(24*time.Hour)))
+	}
+
+	if config.ClassChangeWebhook != "" {
+		webhookUrl, err := url.Parse(config.ClassChangeWebhook)
+		if err != nil || (webhookUrl.Scheme != "http" && webhookUrl.Scheme != "https") || webhookUrl.Host == "" {
+			return fmt.Errorf("分类变化通知的地址%s不是合法的http或https地址", config.ClassChangeWebhook)
+		}
+	}
+
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return fmt.Errorf("TLS证书与私钥文件需要同时设置")
+	}
+	if config.IngestRateLimit < 0 || config.IngestBurst < 0 {
+		return fmt.Errorf("推送的速率限制不能为负数")
+	}
+	if config.IngestBurst == 0 {
+		config.IngestBurst = DefaultIngestBurst
+	}
+	if (len(config.AdminUsers) != 0 || len(config.AdminGroups) != 0) && !config.TokenReview {
+		return fmt.Errorf("admin用户与组只用于TokenReview认证，需要同时启用TokenReview")
+	}
+	if (len(config.IngestUsers) != 0 || len(config.IngestGroups) != 0) && !config.TokenReview {
+		return fmt.Errorf("ingest用户与组只用于TokenReview认证，需要同时启用TokenReview")
+	}
+
 	if config.NumRound == 0 {
 		return fmt.Errorf("聚类轮次不能为0")
 	}
@@ -103,68 +327,196 @@ func (config *ServerConfig) Complete() error {
 		return fmt.Errorf("聚类类别数目不能为0")
 	}
 
-	if config.MysqlHost == "" {
-		config.MysqlHost = fmt.Sprintf("%s:%s",
-			os.Getenv("MYSQL_SERVICE_HOST"), os.Getenv("MYSQL_SERVICE_PORT"))
+	switch config.Storage {
+	case "":
+		config.Storage = DefaultStorage
+	case StorageMysql, StorageMemory:
+	default:
+		return fmt.Errorf("不支持的存储方式%s，应为%s或%s", config.Storage, StorageMysql, StorageMemory)
+	}
+	if config.Storage == StorageMysql && config.MysqlHost == "" {
+		config.MysqlHost = MysqlHostFromEnv()
+	}
+	if config.Storage == StorageMemory && config.LeaderElect {
+		return fmt.Errorf("使用内存存储时各个副本的数据互不相通，不能启用leader选举")
+	}
+
+	if config.LeaderElect {
+		if config.LeaderElectionNamespace == "" {
+			config.LeaderElectionNamespace = DefaultLeaderElectionNamespace
+		}
+		if config.LeaderElectionName == "" {
+			config.LeaderElectionName = DefaultLeaderElectionName
+		}
+		if config.LeaderElectionIdentity == "" {
+			config.LeaderElectionIdentity = defaultLeaderElectionIdentity()
+		}
 	}
 
 	return nil
 }
 
+// 从Kubernetes注入的环境变量MYSQL_SERVICE_HOST与MYSQL_SERVICE_PORT取得Mysql服务器地址
+func MysqlHostFromEnv() string {
+	return fmt.Sprintf("%s:%s", os.Getenv("MYSQL_SERVICE_HOST"), os.Getenv("MYSQL_SERVICE_PORT"))
+}
+
 func (s *serverImpl) Start() error {
-	rootCtx, cancel := context.WithCancel(context.Background())
-	scraperContext, _ := context.WithCancel(rootCtx)
-	reClustererContext, _ := context.WithCancel(rootCtx)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 注册信号接收器
+	termSigChan := make(chan os.Signal, 1)
+	signal.Notify(termSigChan, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(termSigChan)
+	go func() {
+		select {
+		case sig := <-termSigChan:
+			s.logger.Printf("接收到信号%v，服务器开始退出\n", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return s.run(ctx)
+}
 
+// 运行服务器直到ctx结束，然后依次停止接收请求、等待正在进行的数据获取与再聚类完成、关闭数据库。
+// 等待超过ShutdownGracePeriod时，中止尚未完成的数据库操作。
+func (s *serverImpl) run(ctx context.Context) error {
 	s.logger.Printf("服务器启动。配置：%v\n", s.config)
 
-	go s.scrapper(scraperContext)
+	// abortCtx在宽限期结束时取消，用于中止仍在进行的操作。失去leader身份时，正在进行的操作会被立即中止
+	abortCtx, abort := context.WithCancel(context.Background())
+	defer abort()
 
-	go s.reClusterer(reClustererContext)
+	loopCtx, stopLoops := context.WithCancel(ctx)
+	defer stopLoops()
+	loopsDone := make(chan struct{})
+	go func() {
+		defer close(loopsDone)
+		s.runWithLeaderElection(loopCtx, abortCtx, s.runLeaderLoops)
+	}()
 
 	srv := s.buildServer()
-	errCh := make(chan error)
+	errCh := make(chan error, 1)
 	go s.serve(srv, errCh)
 
-	// 注册信号接收器
-	termSigChan := make(chan os.Signal)
-	signal.Notify(termSigChan, syscall.SIGTERM, syscall.SIGINT)
+	var grpcSrv *grpc.Server
+	var stopGrpcWatches context.CancelFunc
+	var grpcErrCh chan error
+	if s.config.GrpcPort != 0 {
+		grpcSrv, stopGrpcWatches = s.buildGrpcServer()
+		defer stopGrpcWatches()
+		grpcErrCh = make(chan error, 1)
+		go s.serveGrpc(grpcSrv, grpcErrCh)
+	}
 
+	var serveErr, grpcServeErr error
 	select {
-	case <-termSigChan:
-		err := srv.Shutdown(rootCtx)
-		if err != nil {
-			return errors.Wrap(err, "关闭HTTP服务器失败")
+	case <-ctx.Done():
+	case serveErr = <-errCh:
+		// HTTP服务器异常退出，同样需要停止其他线程
+		errCh = nil
+	case grpcServeErr = <-grpcErrCh:
+		grpcErrCh = nil
+	}
+
+	graceCtx, cancelGrace := context.WithTimeout(context.Background(), s.config.ShutdownGracePeriod)
+	defer cancelGrace()
+
+	s.logger.Println("正在关闭HTTP服务器")
+	shutdownErr := srv.Shutdown(graceCtx)
+	if shutdownErr != nil {
+		s.logger.Printf("HTTP服务器未能在宽限期内关闭：%v\n", shutdownErr)
+	}
+	if errCh != nil {
+		serveErr = <-errCh
+	}
+
+	if grpcSrv != nil {
+		s.logger.Println("正在关闭gRPC服务器")
+		stopGrpcWatches()
+		s.stopGrpc(graceCtx, grpcSrv)
+		if grpcErrCh != nil {
+			grpcServeErr = <-grpcErrCh
 		}
-		cancel()
 	}
 
-	// 等待HTTP服务器结束
-	err := <-errCh
-	if err != nil {
-		return errors.Wrap(err, "HTTP关闭出现错误")
+	s.logger.Println("正在等待监控数据获取与再聚类线程结束")
+	stopLoops()
+	select {
+	case <-loopsDone:
+	case <-graceCtx.Done():
+		s.logger.Println("等待超过宽限期，中止正在进行的操作")
+		abort()
+		<-loopsDone
 	}
 
+	s.logger.Println("正在关闭数据库连接")
+	if err := s.dao.Close(); err != nil {
+		s.logger.Printf("关闭数据库连接出错：%v\n", err)
+	}
+
+	if serveErr != nil {
+		return errors.Wrap(serveErr, "HTTP服务器出现错误")
+	}
+	if grpcServeErr != nil {
+		return errors.Wrap(grpcServeErr, "gRPC服务器出现错误")
+	}
+	if shutdownErr != nil {
+		return errors.Wrap(shutdownErr, "关闭HTTP服务器失败")
+	}
+	s.logger.Println("服务器已退出")
 	return nil
 }
 
+// 只能由一个实例运行的各个线程，在所有线程退出后返回。ctx结束后各线程不再开始新的工作，abortCtx结束后中止正在进行的工作
+func (s *serverImpl) runLeaderLoops(ctx context.Context, abortCtx context.Context) {
+	s.initialCenterOnce.Do(s.loadInitialCenter)
+	s.backfillOnce.Do(s.backfillSectionRollups)
+
+	loops := []func(ctx, abortCtx context.Context){s.scrapper, s.retainer, s.reClusterer}
+	if s.notifier != nil {
+		// 通知不需要在退出前完成，失去leader身份或开始退出时即停止发送
+		loops = append(loops, func(ctx, _ context.Context) { s.notifier.run(ctx) })
+	}
+	wg := sync.WaitGroup{}
+	for _, loop := range loops {
+		wg.Add(1)
+		go func(loop func(ctx, abortCtx context.Context)) {
+			defer wg.Done()
+			loop(ctx, abortCtx)
+		}(loop)
+	}
+	wg.Wait()
+}
+
+// 名称空间与应用名称的格式
+const namePattern = "(?:[\\d\\w][\\d\\w-.]{0,251}[\\d\\w])|[\\d\\w]"
+
 func (s *serverImpl) buildServer() *http.Server {
 	mux := http.NewServeMux()
-	const NamePattern = "(?:[\\d\\w][\\d\\w-.]{0,251}[\\d\\w])|[\\d\\w]"
-	pattern := regexp.MustCompile(fmt.Sprintf("/namespaces/(%s)/appcharacteristics/(%s)", NamePattern, NamePattern))
+	pattern := regexp.MustCompile(fmt.Sprintf("/namespaces/(%s)/appcharacteristics/(%s)", namePattern, namePattern))
+	profilePattern := regexp.MustCompile(fmt.Sprintf("^/namespaces/(%s)/appprofile/(%s)$", namePattern, namePattern))
+	historyPattern := regexp.MustCompile(fmt.Sprintf("^/namespaces/(%s)/appclasshistory/(%s)$", namePattern, namePattern))
 
-	mux.HandleFunc("/namespaces/", func(writer http.ResponseWriter, request *http.Request) {
+	handle := func(pattern, route string, required permission, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, s.metrics.instrumentHandler(route, s.authorize(required, handler)))
+	}
+
+	characteristicsHandler := s.metrics.instrumentHandler("/namespaces/{namespace}/appcharacteristics/{name}", s.authorize(permissionRead, func(writer http.ResponseWriter, request *http.Request) {
 		if !pattern.MatchString(request.URL.Path) {
 			http.NotFound(writer, request)
 			return
 		}
 		subMatch := pattern.FindStringSubmatch(request.URL.Path)
-		namespace := subMatch[1]
-		name := subMatch[2]
-		characteristics, err := s.QueryAppCharacteristics(server.AppName{
-			Name:      name,
-			Namespace: namespace,
-		})
+		appName, err := appNameFromRequest(request, subMatch[1], subMatch[2])
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		characteristics, err := s.QueryAppCharacteristics(appName)
 		if err == server.ErrAppNotFound {
 			writer.WriteHeader(http.StatusNotFound)
 			_, _ = writer.Write([]byte(err.Error()))
@@ -184,32 +536,232 @@ func (s *serverImpl) buildServer() *http.Server {
 			return
 		}
 
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(marshal)
+	}))
+
+	profileHandler := s.metrics.instrumentHandler("/namespaces/{namespace}/appprofile/{name}", s.authorize(permissionRead, func(writer http.ResponseWriter, request *http.Request) {
+		subMatch := profilePattern.FindStringSubmatch(request.URL.Path)
+		appName, err := appNameFromRequest(request, subMatch[1], subMatch[2])
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		profile, err := s.QueryAppProfile(appName)
+		if err == server.ErrAppNotFound {
+			writer.WriteHeader(http.StatusNotFound)
+			_, _ = writer.Write([]byte(err.Error()))
+			return
+		} else if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		marshal, err := json.Marshal(profile)
+		if err != nil {
+			http.Error(writer, errors.Wrap(err, "序列化问题").Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(marshal)
+	}))
+
+	historyHandler := s.metrics.instrumentHandler("/namespaces/{namespace}/appclasshistory/{name}", s.authorize(permissionRead, func(writer http.ResponseWriter, request *http.Request) {
+		subMatch := historyPattern.FindStringSubmatch(request.URL.Path)
+		appName, err := appNameFromRequest(request, subMatch[1], subMatch[2])
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		history, err := s.QueryAppClassHistory(appName)
+		if err == server.ErrAppNotFound {
+			http.Error(writer, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		marshal, err := json.Marshal(history)
+		if err != nil {
+			http.Error(writer, errors.Wrap(err, "序列化问题").Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
 		_, _ = writer.Write(marshal)
+	}))
+
+	mux.HandleFunc("/namespaces/", func(writer http.ResponseWriter, request *http.Request) {
+		if profilePattern.MatchString(request.URL.Path) {
+			profileHandler(writer, request)
+		} else if historyPattern.MatchString(request.URL.Path) {
+			historyHandler(writer, request)
+		} else {
+			characteristicsHandler(writer, request)
+		}
 	})
 
-	mux.HandleFunc("/recluster", func(writer http.ResponseWriter, request *http.Request) {
-		s.ReCluster()
-		_, _ = writer.Write([]byte("OK"))
+	handle("/recluster", "/recluster", permissionAdmin, func(writer http.ResponseWriter, request *http.Request) {
+		switch err := s.ReCluster(); err {
+		case nil:
+			_, _ = writer.Write([]byte("OK"))
+		case server.ErrNotLeader:
+			// 多个副本共用一个Service时，客户端重试可能到达leader
+			writer.Header().Set("Retry-After", "1")
+			http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+		case server.ErrReClusterBusy:
+			http.Error(writer, err.Error(), http.StatusConflict)
+		default:
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+		}
 	})
 
-	mux.HandleFunc("/healthz", func(writer http.ResponseWriter, request *http.Request) {
+	handle("/recluster/schedule", "/recluster/schedule", permissionRead, func(writer http.ResponseWriter, request *http.Request) {
+		schedule, err := s.QueryReClusterSchedule()
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		marshal, err := json.Marshal(schedule)
+		if err != nil {
+			http.Error(writer, errors.Wrap(err, "序列化问题").Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(marshal)
+	})
+
+	handle("/recluster/summary", "/recluster/summary", permissionRead, func(writer http.ResponseWriter, request *http.Request) {
+		summary, err := s.QueryReClusterSummary()
+		if err == server.ErrReClusterNotRun {
+			http.Error(writer, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		marshal, err := json.Marshal(summary)
+		if err != nil {
+			http.Error(writer, errors.Wrap(err, "序列化问题").Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(marshal)
+	})
+
+	// 试运行再聚类可能耗时较长，客户端断开后中止。由于占用较多资源，需要admin权限
+	handle("/recluster/dryrun", "/recluster/dryrun", permissionAdmin, func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			writer.Header().Set("Allow", "POST")
+			http.Error(writer, "不支持的请求方法", http.StatusMethodNotAllowed)
+			return
+		}
+		params := &server.ReClusterParams{}
+		if err := json.NewDecoder(request.Body).Decode(params); err != nil && err != io.EOF {
+			http.Error(writer, errors.Wrap(err, "解析请求出错").Error(), http.StatusBadRequest)
+			return
+		}
+		dryRun, err := s.dryRunReCluster(request.Context(), params)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		marshal, err := json.Marshal(dryRun)
+		if err != nil {
+			http.Error(writer, errors.Wrap(err, "序列化问题").Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(marshal)
+	})
+
+	// 存活检查只表示进程仍在运行，/healthz为旧版本的存活检查路径
+	live := func(writer http.ResponseWriter, request *http.Request) {
 		_, _ = writer.Write([]byte("OK"))
+	}
+	handle("/livez", "/livez", permissionNone, live)
+	handle("/healthz", "/healthz", permissionNone, live)
+	handle("/readyz", "/readyz", permissionNone, s.handleReadiness)
+
+	handle(IngestPath, IngestPath, permissionIngest, s.handleIngest)
+	handle(RemoteWritePath, RemoteWritePath, permissionIngest, s.handleRemoteWrite)
+
+	handle("/admin/state", "/admin/state", permissionAdmin, s.handleState)
+	handle("/admin/pins", "/admin/pins", permissionAdmin, s.handlePinList)
+	handle("/admin/pins/", "/admin/pins/{namespace}/{name}", permissionAdmin, s.handlePin)
+
+	// watch是长连接，关闭HTTP服务器时需要通知其结束，否则Shutdown会一直等待到宽限期结束
+	watchCtx, stopWatches := context.WithCancel(context.Background())
+	handle("/classification", "/classification", permissionRead, s.handleClassificationSnapshot)
+	handle("/classification/watch", "/classification/watch", permissionRead, func(writer http.ResponseWriter, request *http.Request) {
+		s.handleClassificationWatch(watchCtx, writer, request)
 	})
 
+	// 监控指标不需要认证，以便Prometheus按注解抓取
+	mux.Handle("/metrics", s.metrics.handler())
+	handle("/openapi.json", "/openapi.json", permissionNone, handleOpenAPI)
+
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", s.config.Port),
 		Handler: mux,
 	}
+	if s.certificates != nil {
+		srv.TLSConfig = s.certificates.tlsConfig()
+	}
+	srv.RegisterOnShutdown(stopWatches)
 	return srv
 }
 
 func (s *serverImpl) serve(server *http.Server, errCh chan<- error) {
 	s.logger.Printf("API服务器启动")
 
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		errCh <- err
+	var err error
+	if server.TLSConfig != nil {
+		// 证书由TLSConfig提供
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		err = nil
 	}
 
 	s.logger.Printf("API服务器结束")
-	errCh <- nil
+	errCh <- err
+}
+
+func (s *serverImpl) serveGrpc(srv *grpc.Server, errCh chan<- error) {
+	s.logger.Printf("gRPC服务器启动")
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.config.GrpcPort))
+	if err == nil {
+		err = srv.Serve(listener)
+	}
+
+	s.logger.Printf("gRPC服务器结束")
+	errCh <- err
+}
+
+// 等待正在处理的请求完成，超过宽限期时强制关闭
+func (s *serverImpl) stopGrpc(graceCtx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		srv.GracefulStop()
+	}()
+	select {
+	case <-stopped:
+	case <-graceCtx.Done():
+		s.logger.Println("gRPC服务器未能在宽限期内关闭，强制关闭")
+		srv.Stop()
+		<-stopped
+	}
 }
@@ -15,7 +15,7 @@ func TestNewServer(t *testing.T) {
 		NumClass:             DefaultNumClass,
 		NumRound:             DefaultNumRound,
 		InitialCenterCsvFile: "",
-		MysqlHost:            "127.0.0.1:3306",
+		Storage:              StorageMemory,
 	}
 	_, err := NewServer(&ctx)
 	assert.NoError(t, err)
@@ -30,9 +30,75 @@ func TestNewServer(t *testing.T) {
 	_, err = NewServer(&ctxCopy)
 	assert.Error(t, err)
 
+	// gRPC端口不能与HTTP端口相同
+	ctxCopy = ctx
+	ctxCopy.GrpcPort = 2000
+	_, err = NewServer(&ctxCopy)
+	assert.Error(t, err)
+
+	ctxCopy = ctx
+	ctxCopy.GrpcPort = DefaultGrpcPort
+	_, err = NewServer(&ctxCopy)
+	assert.NoError(t, err)
+
+	// TLS证书与私钥需要同时设置，admin与ingest用户与组只用于TokenReview
+	ctxCopy = ctx
+	ctxCopy.TLSCertFile = "tls.crt"
+	_, err = NewServer(&ctxCopy)
+	assert.Error(t, err)
+
+	ctxCopy = ctx
+	ctxCopy.AdminGroups = []string{"ops"}
+	_, err = NewServer(&ctxCopy)
+	assert.Error(t, err)
+
+	ctxCopy = ctx
+	ctxCopy.IngestUsers = []string{"prometheus"}
+	_, err = NewServer(&ctxCopy)
+	assert.Error(t, err)
+
 	ctxCopy = ctx
 	ctxCopy.ScrapeInterval = 0
 	_, err = NewServer(&ctxCopy)
 	assert.Error(t, err)
 
+	ctxCopy = ctx
+	ctxCopy.Storage = "redis"
+	_, err = NewServer(&ctxCopy)
+	assert.Error(t, err)
+
+	// 内存存储不能与leader选举同时使用
+	ctxCopy = ctx
+	ctxCopy.LeaderElect = true
+	_, err = NewServer(&ctxCopy)
+	assert.Error(t, err)
+
+	// 数据要求
+	ctxCopy = ctx
+	ctxCopy.MinSectionCoverage = 1.5
+	_, err = NewServer(&ctxCopy)
+	assert.Error(t, err)
+
+	ctxCopy = ctx
+	ctxCopy.MinObservedDays = 2
+	_, err = NewServer(&ctxCopy)
+	assert.Error(t, err)
+
+	ctxCopy = ctx
+	ctxCopy.MinObservedDays = DefaultMinObservedDays
+	ctxCopy.MinSectionCoverage = DefaultMinSectionCoverage
+	ctxCopy.MinSampleCount = DefaultMinSampleCount
+	_, err = NewServer(&ctxCopy)
+	assert.NoError(t, err)
+
+	// 分类变化通知地址
+	ctxCopy = ctx
+	ctxCopy.ClassChangeWebhook = "ftp://example.com/hook"
+	_, err = NewServer(&ctxCopy)
+	assert.Error(t, err)
+
+	ctxCopy = ctx
+	ctxCopy.ClassChangeWebhook = "http://example.com/hook"
+	_, err = NewServer(&ctxCopy)
+	assert.NoError(t, err)
 }
@@ -0,0 +1,398 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeDaoState struct {
+	lock         sync.Mutex
+	closed       bool
+	pingErr      error
+	classifiedAt time.Time
+	podMetrics   []*server.AppPodMetrics
+	classMetrics map[uint]*server.ClassMetrics
+	appClasses   map[server.AppName]*server.AppClass
+	history      []*server.AppClassHistory
+}
+
+// 用于测试退出流程的Dao，未实现的方法调用时会panic
+type fakeDao struct {
+	Dao
+	state *fakeDaoState
+	ctx   context.Context
+	// 写入前调用，用于模拟耗时的数据库操作
+	beforeWrite func(ctx context.Context) error
+}
+
+func newFakeDao() *fakeDao {
+	return &fakeDao{
+		state: &fakeDaoState{
+			classMetrics: map[uint]*server.ClassMetrics{},
+			appClasses:   map[server.AppName]*server.AppClass{},
+		},
+		ctx: context.Background(),
+	}
+}
+
+func (f *fakeDao) write(fc func(state *fakeDaoState)) error {
+	if f.beforeWrite != nil {
+		if err := f.beforeWrite(f.ctx); err != nil {
+			return err
+		}
+	}
+	if err := f.ctx.Err(); err != nil {
+		return err
+	}
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	fc(f.state)
+	return nil
+}
+
+func (f *fakeDao) WithContext(ctx context.Context) Dao {
+	c := *f
+	c.ctx = ctx
+	return &c
+}
+
+func (f *fakeDao) Transaction(fc func(tx Dao) error) error {
+	tx := newFakeDao()
+	tx.ctx = f.ctx
+	tx.beforeWrite = f.beforeWrite
+	if err := fc(tx); err != nil {
+		return err
+	}
+	return f.write(func(state *fakeDaoState) {
+		state.podMetrics = append(state.podMetrics, tx.state.podMetrics...)
+		for id, c := range tx.state.classMetrics {
+			state.classMetrics[id] = c
+		}
+		if tx.state.classifiedAt.After(state.classifiedAt) {
+			state.classifiedAt = tx.state.classifiedAt
+		}
+		for name, a := range tx.state.appClasses {
+			state.appClasses[name] = a
+		}
+		state.history = append(state.history, tx.state.history...)
+	})
+}
+
+func (f *fakeDao) Close() error {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	f.state.closed = true
+	return nil
+}
+
+func (f *fakeDao) SaveAllAppPodMetrics(arr []*server.AppPodMetrics) error {
+	return f.write(func(state *fakeDaoState) {
+		state.podMetrics = append(state.podMetrics, arr...)
+	})
+}
+
+func (f *fakeDao) SaveClassMetrics(c *server.ClassMetrics) error {
+	return f.write(func(state *fakeDaoState) {
+		state.classMetrics[c.ClassId] = c
+		state.classifiedAt = time.Now()
+	})
+}
+
+func (f *fakeDao) SaveAppClass(a *server.AppClass) error {
+	return f.write(func(state *fakeDaoState) {
+		state.appClasses[a.AppName] = a
+	})
+}
+
+func (f *fakeDao) RemoveAppClass(appName *server.AppName) error {
+	return f.write(func(state *fakeDaoState) {
+		delete(state.appClasses, *appName)
+	})
+}
+
+func (f *fakeDao) SaveAppClassHistory(history []*server.AppClassHistory) error {
+	return f.write(func(state *fakeDaoState) {
+		state.history = append(state.history, history...)
+	})
+}
+
+func (f *fakeDao) ForEachAppClass(fc func(class *server.AppClass) error) error {
+	f.state.lock.Lock()
+	classes := make([]*server.AppClass, 0, len(f.state.appClasses))
+	for _, a := range f.state.appClasses {
+		classes = append(classes, a)
+	}
+	f.state.lock.Unlock()
+	for _, a := range classes {
+		if err := fc(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeDao) RemoveAppPodMetricsBefore(_ uint64) error {
+	return nil
+}
+
+func (f *fakeDao) BackfillSectionRollups() (int, error) {
+	return 0, nil
+}
+
+func (f *fakeDao) Ping() error {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	return f.state.pingErr
+}
+
+func (f *fakeDao) QueryAllClassMetrics() ([]*server.ClassMetrics, error) {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	if f.state.pingErr != nil {
+		return nil, f.state.pingErr
+	}
+	result := make([]*server.ClassMetrics, 0, len(f.state.classMetrics))
+	for _, c := range f.state.classMetrics {
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func (f *fakeDao) QueryClassMemberCount() (map[uint]int, error) {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	result := make(map[uint]int)
+	for _, a := range f.state.appClasses {
+		result[a.ClassId]++
+	}
+	return result, nil
+}
+
+func (f *fakeDao) QueryLastClassifyTime() (time.Time, error) {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	return f.state.classifiedAt, nil
+}
+
+func (f *fakeDao) snapshot() (closed bool, numPodMetrics, numClassMetrics, numAppClasses int) {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	return f.state.closed, len(f.state.podMetrics), len(f.state.classMetrics), len(f.state.appClasses)
+}
+
+func freePort(t *testing.T) uint16 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		assert.FailNow(t, "获取空闲端口失败")
+	}
+	defer listener.Close()
+	return uint16(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// 返回一个只有一个Pod的api server
+func newFakeApiServer() *httptest.Server {
+	apiServer, _ := newPodsApiServer([]corev1.Pod{testPod("test", "app-1", KindReplicaSet, "app", nil)})
+	return apiServer
+}
+
+func newShutdownTestServer(t *testing.T, dao Dao, apiServerUrl string, gracePeriod time.Duration) *serverImpl {
+	config := &ServerConfig{
+		Port:                freePort(t),
+		ScrapeInterval:      50 * time.Millisecond,
+		MetricDuration:      DefaultMetricDuration,
+		ReClusterInterval:   time.Hour,
+		NumClass:            DefaultNumClass,
+		NumRound:            DefaultNumRound,
+		ShutdownGracePeriod: gracePeriod,
+	}
+	schedule, spec, location, _ := buildReClusterSchedule(config)
+	return &serverImpl{
+		config:           config,
+		dao:              dao,
+		logger:           log.New(os.Stdout, "TestShutdown: ", log.LstdFlags),
+		metrics:          newServerMetrics(),
+		executeReCluster: make(chan struct{}),
+		apiServerUrl:     apiServerUrl,
+		schedule:         schedule,
+		scheduleSpec:     spec,
+		location:         location,
+	}
+}
+
+func startTestServer(t *testing.T, s *serverImpl) (cancel context.CancelFunc, done <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.run(ctx)
+	}()
+
+	// 等待HTTP服务器启动
+	url := fmt.Sprintf("http://127.0.0.1:%d/healthz", s.config.Port)
+	for i := 0; ; i++ {
+		response, err := http.Get(url)
+		if err == nil {
+			_ = response.Body.Close()
+			break
+		}
+		if i > 100 {
+			assert.FailNow(t, "HTTP服务器没有启动")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cancel, errCh
+}
+
+func TestServerImpl_Run_Shutdown(t *testing.T) {
+	dao := newFakeDao()
+	s := newShutdownTestServer(t, dao, "http://127.0.0.1:1", time.Second)
+	s.config.ScrapeInterval = time.Hour
+
+	cancel, done := startTestServer(t, s)
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.FailNow(t, "服务器没有退出")
+	}
+
+	closed, _, _, _ := dao.snapshot()
+	assert.True(t, closed)
+	assert.False(t, s.IsLeader())
+	// 不再接收请求
+	_, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/healthz", s.config.Port))
+	assert.Error(t, err)
+}
+
+func TestServerImpl_Run_DrainScrape(t *testing.T) {
+	apiServer := newFakeApiServer()
+	defer apiServer.Close()
+
+	dao := newFakeDao()
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	once := sync.Once{}
+	dao.beforeWrite = func(ctx context.Context) error {
+		once.Do(func() { close(entered) })
+		<-release
+		return nil
+	}
+	s := newShutdownTestServer(t, dao, apiServer.URL, 5*time.Second)
+
+	cancel, done := startTestServer(t, s)
+	<-entered
+	cancel()
+
+	// 正在保存的数据完成前，服务器不会退出，也不会关闭数据库
+	select {
+	case <-done:
+		assert.FailNow(t, "服务器在数据保存完成前退出")
+	case <-time.After(200 * time.Millisecond):
+	}
+	closed, _, _, _ := dao.snapshot()
+	assert.False(t, closed)
+
+	close(release)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.FailNow(t, "服务器没有退出")
+	}
+	closed, numPodMetrics, _, _ := dao.snapshot()
+	assert.True(t, closed)
+	assert.Equal(t, 1, numPodMetrics)
+}
+
+func TestServerImpl_Run_AbortScrape(t *testing.T) {
+	apiServer := newFakeApiServer()
+	defer apiServer.Close()
+
+	dao := newFakeDao()
+	entered := make(chan struct{})
+	once := sync.Once{}
+	dao.beforeWrite = func(ctx context.Context) error {
+		once.Do(func() { close(entered) })
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	const gracePeriod = 200 * time.Millisecond
+	s := newShutdownTestServer(t, dao, apiServer.URL, gracePeriod)
+
+	cancel, done := startTestServer(t, s)
+	<-entered
+	start := time.Now()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "宽限期结束后服务器没有退出")
+	}
+	assert.True(t, time.Since(start) >= gracePeriod)
+	closed, numPodMetrics, _, _ := dao.snapshot()
+	assert.True(t, closed)
+	assert.Equal(t, 0, numPodMetrics)
+}
+
+func newTestReClusterResult() *reClusterResult {
+	result := &reClusterResult{}
+	for i := 1; i <= 3; i++ {
+		c := &server.ClassMetrics{ClassId: uint(i), Data: make([]*core.SectionData, core.NumSections)}
+		for j := range c.Data {
+			c.Data[j] = &core.SectionData{}
+		}
+		result.centers = append(result.centers, c)
+		result.appClasses = append(result.appClasses, &server.AppClass{
+			AppName: server.AppName{Name: fmt.Sprintf("app-%d", i), Namespace: "test"},
+			ClassId: uint(i),
+		})
+	}
+	return result
+}
+
+func TestServerImpl_SaveReClusterResult(t *testing.T) {
+	dao := newFakeDao()
+	s := newShutdownTestServer(t, dao, "", time.Second)
+
+	err := s.saveReClusterResult(context.Background(), newTestReClusterResult())
+	assert.NoError(t, err)
+	_, _, numClassMetrics, numAppClasses := dao.snapshot()
+	assert.Equal(t, 3, numClassMetrics)
+	assert.Equal(t, 3, numAppClasses)
+}
+
+func TestServerImpl_SaveReClusterResult_Abort(t *testing.T) {
+	dao := newFakeDao()
+	s := newShutdownTestServer(t, dao, "", time.Second)
+
+	// 保存了部分数据后中止，事务应该回滚
+	ctx, cancel := context.WithCancel(context.Background())
+	writes := 0
+	dao.beforeWrite = func(_ context.Context) error {
+		writes++
+		if writes == 4 {
+			cancel()
+		}
+		return nil
+	}
+
+	err := s.saveReClusterResult(ctx, newTestReClusterResult())
+	assert.Error(t, err)
+	_, _, numClassMetrics, numAppClasses := dao.snapshot()
+	assert.Equal(t, 0, numClassMetrics)
+	assert.Equal(t, 0, numAppClasses)
+}
@@ -0,0 +1,601 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/internal/utils"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 状态归档的格式版本。格式变化时递增，导入时拒绝比本程序更新的版本。
+// 版本2在app_classes.csv中增加了距离与置信度，版本3增加了暂定标记，版本4在两个表中增加了集群，版本5增加了固定分类
+const StateFormatVersion = 5
+
+// 归档中的文件
+const (
+	stateManifestFile   = "manifest.json"
+	stateCentersFile    = "centers.csv"     // 与readCenterCsv读取的格式相同，中心已经过预处理
+	stateAppClassesFile = "app_classes.csv" // 列见appClassesHeader，第一行为表头
+	stateAppPinsFile    = "app_pins.json"   // server.AppPin的JSON数组，不包含审计记录
+	stateMetricsFile    = "metrics.csv"     // 列见metricsHeader，第一行为表头
+)
+
+var (
+	appClassesHeader = []string{"namespace", "name", "class_id", "cpu_max", "mem_max",
+		"distance", "runner_up_class_id", "runner_up_distance", "confidence", "provisional", "cluster"}
+	// 旧格式版本的表头，导入时仍然支持，缺少的列为零值
+	appClassesHeaderV3 = appClassesHeader[:10]
+	appClassesHeaderV2 = appClassesHeader[:9]
+	appClassesHeaderV1 = appClassesHeader[:5]
+	metricsHeader      = []string{"namespace", "name", "timestamp", "cpu", "mem", "cluster"}
+	metricsHeaderV3    = metricsHeader[:5]
+)
+
+// 导入时每次写入的监控数据数量
+const stateImportBatchSize = 1000
+
+// 状态归档的描述信息，总是归档中的第一个文件
+type StateManifest struct {
+	FormatVersion  int       `json:"formatVersion"`
+	CreatedAt      time.Time `json:"createdAt"`
+	ClassIds       []uint    `json:"classIds"` // centers.csv中各行对应的ClassID
+	NumAppClasses  int       `json:"numAppClasses"`
+	NumPins        int       `json:"numPins"`
+	IncludeMetrics bool      `json:"includeMetrics"`
+	NumMetrics     int       `json:"numMetrics"`
+}
+
+type StateExportOptions struct {
+	IncludeMetrics bool // 是否导出原始监控数据
+}
+
+// 将类别中心、应用分类、固定分类以及可选的原始监控数据导出为tar.gz归档
+func ExportState(dao Dao, w io.Writer, options *StateExportOptions) (*StateManifest, error) {
+	manifest := &StateManifest{
+		FormatVersion:  StateFormatVersion,
+		CreatedAt:      time.Now(),
+		IncludeMetrics: options.IncludeMetrics,
+	}
+
+	classMetrics, err := dao.QueryAllClassMetrics()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(classMetrics, func(i, j int) bool {
+		return classMetrics[i].ClassId < classMetrics[j].ClassId
+	})
+	centers := &bytes.Buffer{}
+	centersWriter := csv.NewWriter(centers)
+	for _, metrics := range classMetrics {
+		for i, datum := range metrics.Data {
+			if datum == nil {
+				return nil, fmt.Errorf("ClassID为%d的类别缺少第%d个Section的数据", metrics.ClassId, i)
+			}
+		}
+		_ = centersWriter.Write(formatFloats(utils.SectionDataToFloatArray(metrics.Data)))
+		manifest.ClassIds = append(manifest.ClassIds, metrics.ClassId)
+	}
+	centersWriter.Flush()
+
+	appClasses := &bytes.Buffer{}
+	appClassesWriter := csv.NewWriter(appClasses)
+	_ = appClassesWriter.Write(appClassesHeader)
+	err = dao.ForEachAppClass(func(class *server.AppClass) error {
+		manifest.NumAppClasses++
+		return appClassesWriter.Write([]string{
+			class.Namespace,
+			class.Name,
+			strconv.FormatUint(uint64(class.ClassId), 10),
+			formatFloat(class.CpuMax),
+			formatFloat(class.MemMax),
+			formatFloat64(class.Distance),
+			strconv.FormatUint(uint64(class.RunnerUpClassId), 10),
+			formatFloat64(class.RunnerUpDistance),
+			formatOptionalFloat64(class.Confidence),
+			strconv.FormatBool(class.Provisional),
+			class.Cluster,
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "读取应用分类出错")
+	}
+	appClassesWriter.Flush()
+
+	pins, err := dao.QueryAllAppPins()
+	if err != nil {
+		return nil, errors.Wrap(err, "读取固定分类出错")
+	}
+	manifest.NumPins = len(pins)
+	appPins, _ := json.Marshal(pins)
+
+	// 监控数据可能很大，先写入临时文件
+	var metricsFile *os.File
+	var metricsSize int64
+	if options.IncludeMetrics {
+		metricsFile, err = ioutil.TempFile("", "workload-classifier-metrics-*.csv")
+		if err != nil {
+			return nil, errors.Wrap(err, "创建临时文件出错")
+		}
+		defer func() {
+			_ = metricsFile.Close()
+			_ = os.Remove(metricsFile.Name())
+		}()
+
+		metricsWriter := csv.NewWriter(metricsFile)
+		_ = metricsWriter.Write(metricsHeader)
+		iterator := NewAppPodMetricsIterator(dao, onetimeReadSize)
+		for {
+			metrics, err := iterator.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, errors.Wrap(err, "读取监控数据出错")
+			}
+			manifest.NumMetrics++
+			_ = metricsWriter.Write([]string{
+				metrics.Namespace,
+				metrics.Name,
+				strconv.FormatUint(metrics.Timestamp, 10),
+				formatFloat(metrics.Cpu),
+				formatFloat(metrics.Mem),
+				metrics.Cluster,
+			})
+		}
+		metricsWriter.Flush()
+		if err := metricsWriter.Error(); err != nil {
+			return nil, errors.Wrap(err, "写入临时文件出错")
+		}
+		if metricsSize, err = metricsFile.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		if _, err = metricsFile.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	manifestData, _ := json.MarshalIndent(manifest, "", "  ")
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	writeFile := func(name string, size int64, content io.Reader) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    size,
+			ModTime: manifest.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, content)
+		return err
+	}
+	if err := writeFile(stateManifestFile, int64(len(manifestData)), bytes.NewReader(manifestData)); err != nil {
+		return nil, errors.Wrap(err, "写入归档出错")
+	}
+	if err := writeFile(stateCentersFile, int64(centers.Len()), centers); err != nil {
+		return nil, errors.Wrap(err, "写入归档出错")
+	}
+	if err := writeFile(stateAppClassesFile, int64(appClasses.Len()), appClasses); err != nil {
+		return nil, errors.Wrap(err, "写入归档出错")
+	}
+	if err := writeFile(stateAppPinsFile, int64(len(appPins)), bytes.NewReader(appPins)); err != nil {
+		return nil, errors.Wrap(err, "写入归档出错")
+	}
+	if metricsFile != nil {
+		if err := writeFile(stateMetricsFile, metricsSize, metricsFile); err != nil {
+			return nil, errors.Wrap(err, "写入归档出错")
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "写入归档出错")
+	}
+	if err := gw.Close(); err != nil {
+		return nil, errors.Wrap(err, "写入归档出错")
+	}
+	return manifest, nil
+}
+
+type StateImportOptions struct {
+	NumClass uint // 要求归档中类别中心的数量等于此值，为0时不检查
+}
+
+// 从ExportState生成的归档导入状态。类别中心将替换已有的中心，应用分类、固定分类与监控数据将覆盖已有的同名应用的数据。
+// 应用分类与固定分类引用的类别必须在归档的类别中心中。类别中心、应用分类与固定分类在同一个事务中写入，监控数据随后分批写入
+func ImportState(dao Dao, r io.Reader, options *StateImportOptions) (*StateManifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取归档出错")
+	}
+	tr := tar.NewReader(gr)
+	manifest, err := readStateManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	if options.NumClass != 0 && uint(len(manifest.ClassIds)) != options.NumClass {
+		return nil, fmt.Errorf("归档中有%d个类别，与配置的类别数量%d不一致", len(manifest.ClassIds), options.NumClass)
+	}
+
+	var centers []*server.ClassMetrics
+	var appClasses []*server.AppClass
+	var pins []*server.AppPin
+	applied := false
+	// 在读取到监控数据或归档结束时，写入类别中心与应用分类
+	apply := func() error {
+		if applied {
+			return nil
+		}
+		applied = true
+		if centers == nil {
+			return fmt.Errorf("归档中缺少%s", stateCentersFile)
+		}
+		if err := checkStateReferences(centers, appClasses, pins); err != nil {
+			return err
+		}
+		return dao.Transaction(func(tx Dao) error {
+			if err := tx.RemoveAllClassMetrics(); err != nil {
+				return err
+			}
+			for _, center := range centers {
+				if err := tx.SaveClassMetrics(center); err != nil {
+					return err
+				}
+			}
+			for _, class := range appClasses {
+				if err := tx.SaveAppClass(class); err != nil {
+					return err
+				}
+			}
+			for _, pin := range pins {
+				if err := tx.SaveAppPin(pin); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "读取归档出错")
+		}
+
+		switch header.Name {
+		case stateCentersFile:
+			centers, err = readStateCenters(tr, manifest)
+			if err != nil {
+				return nil, err
+			}
+		case stateAppClassesFile:
+			appClasses, err = readAppClassesCsv(tr)
+			if err != nil {
+				return nil, errors.Wrap(err, "读取应用分类出错")
+			}
+		case stateAppPinsFile:
+			pins = make([]*server.AppPin, 0)
+			if err := json.NewDecoder(tr).Decode(&pins); err != nil {
+				return nil, errors.Wrap(err, "读取固定分类出错")
+			}
+			manifest.NumPins = len(pins)
+		case stateMetricsFile:
+			if err := apply(); err != nil {
+				return nil, errors.Wrap(err, "写入类别中心与应用分类出错")
+			}
+			if err := importMetricsCsv(dao, tr); err != nil {
+				return nil, errors.Wrap(err, "导入监控数据出错")
+			}
+		default:
+			// 忽略不认识的文件，便于以后增加内容
+		}
+	}
+
+	if err := apply(); err != nil {
+		return nil, errors.Wrap(err, "写入类别中心与应用分类出错")
+	}
+	return manifest, nil
+}
+
+// 读取归档的第一个文件，即描述信息，并检查格式版本
+func readStateManifest(tr *tar.Reader) (*StateManifest, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, errors.Wrap(err, "读取归档出错")
+	}
+	if header.Name != stateManifestFile {
+		return nil, fmt.Errorf("归档的第一个文件应为%s，实际为%s", stateManifestFile, header.Name)
+	}
+	manifest := &StateManifest{}
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, errors.Wrap(err, "解析归档描述信息出错")
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > StateFormatVersion {
+		return nil, fmt.Errorf("不支持格式版本为%d的归档，本程序支持的最新版本为%d", manifest.FormatVersion, StateFormatVersion)
+	}
+	return manifest, nil
+}
+
+// 读取归档中的类别中心，ClassID为描述信息中的ClassID
+func readStateCenters(r io.Reader, manifest *StateManifest) ([]*server.ClassMetrics, error) {
+	if len(manifest.ClassIds) == 0 {
+		return []*server.ClassMetrics{}, nil
+	}
+	centers, err := readCenterCsv(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取类别中心出错")
+	}
+	if len(manifest.ClassIds) != len(centers) {
+		return nil, fmt.Errorf("类别中心有%d个，与描述信息中的%d个不一致", len(centers), len(manifest.ClassIds))
+	}
+	for i, center := range centers {
+		center.ClassId = manifest.ClassIds[i]
+	}
+	return centers, nil
+}
+
+// 只读取ExportState生成的归档中的类别中心，用作初始中心。归档中的中心已经过预处理，不再预处理
+func readStateArchiveCenters(r io.Reader) ([]*server.ClassMetrics, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取归档出错")
+	}
+	tr := tar.NewReader(gr)
+	manifest, err := readStateManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.ClassIds) == 0 {
+		return nil, fmt.Errorf("归档中没有类别中心")
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("归档中缺少%s", stateCentersFile)
+		} else if err != nil {
+			return nil, errors.Wrap(err, "读取归档出错")
+		}
+		if header.Name == stateCentersFile {
+			return readStateCenters(tr, manifest)
+		}
+	}
+}
+
+// 检查应用分类与固定分类引用的类别都在导入的类别中心中
+func checkStateReferences(centers []*server.ClassMetrics, appClasses []*server.AppClass, pins []*server.AppPin) error {
+	classIds := make(map[uint]bool, len(centers))
+	for _, center := range centers {
+		classIds[center.ClassId] = true
+	}
+	for _, class := range appClasses {
+		if !classIds[class.ClassId] {
+			return fmt.Errorf("名称空间%s，名称为%s的应用的类别%d不在归档的类别中心中", class.Namespace, class.Name, class.ClassId)
+		}
+		if class.RunnerUpClassId != 0 && !classIds[class.RunnerUpClassId] {
+			return fmt.Errorf("名称空间%s，名称为%s的应用的次近类别%d不在归档的类别中心中", class.Namespace, class.Name, class.RunnerUpClassId)
+		}
+	}
+	for _, pin := range pins {
+		if pin == nil {
+			return fmt.Errorf("固定分类不能为空")
+		}
+		if err := checkAppPinFields(pin); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("名称空间%s，名称为%s的应用的固定分类不合法", pin.Namespace, pin.Name))
+		}
+		if pin.ClassId != 0 && !classIds[pin.ClassId] {
+			return fmt.Errorf("名称空间%s，名称为%s的应用固定到的类别%d不在归档的类别中心中", pin.Namespace, pin.Name, pin.ClassId)
+		}
+	}
+	return nil
+}
+
+// GET导出状态，参数metrics=true时包含原始监控数据；POST导入状态，请求体为导出的归档
+func (s *serverImpl) handleState(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		includeMetrics, _ := strconv.ParseBool(request.URL.Query().Get("metrics"))
+		writer.Header().Set("Content-Type", "application/gzip")
+		writer.Header().Set("Content-Disposition",
+			fmt.Sprintf("attachment; filename=\"workload-classifier-state-%s.tar.gz\"", time.Now().Format("20060102150405")))
+		// 归档边生成边发送，出错时只能中断响应
+		_, err := ExportState(s.dao.WithContext(request.Context()), writer, &StateExportOptions{IncludeMetrics: includeMetrics})
+		if err != nil {
+			s.logger.Printf("导出状态出错：%v\n", err)
+			panic(http.ErrAbortHandler)
+		}
+	case http.MethodPost:
+		manifest, err := ImportState(s.dao.WithContext(request.Context()), request.Body, &StateImportOptions{NumClass: s.config.NumClass})
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Printf("已导入%d个类别、%d个应用分类、%d个固定分类与%d条监控数据\n",
+			len(manifest.ClassIds), manifest.NumAppClasses, manifest.NumPins, manifest.NumMetrics)
+		marshal, _ := json.Marshal(manifest)
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(marshal)
+	default:
+		writer.Header().Set("Allow", "GET, POST")
+		http.Error(writer, "只支持GET与POST", http.StatusMethodNotAllowed)
+	}
+}
+
+// 读取应用分类，同时支持旧格式版本的文件
+func readAppClassesCsv(r io.Reader) ([]*server.AppClass, error) {
+	records, err := readCsvWithHeader(r, appClassesHeader, appClassesHeaderV3, appClassesHeaderV2, appClassesHeaderV1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*server.AppClass, 0, len(records))
+	for i, record := range records {
+		classId, err := strconv.ParseUint(record[2], 10, 32)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("第%d行ClassID有误", i+2))
+		}
+		cpuMax, err := strconv.ParseFloat(record[3], 32)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("第%d行CpuMax有误", i+2))
+		}
+		memMax, err := strconv.ParseFloat(record[4], 32)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("第%d行MemMax有误", i+2))
+		}
+		class := &server.AppClass{
+			AppName: server.AppName{Namespace: record[0], Name: record[1]},
+			ClassId: uint(classId),
+			CpuMax:  float32(cpuMax),
+			MemMax:  float32(memMax),
+		}
+		if len(record) >= len(appClassesHeaderV2) {
+			if class.Distance, err = strconv.ParseFloat(record[5], 64); err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("第%d行距离有误", i+2))
+			}
+			runnerUp, err := strconv.ParseUint(record[6], 10, 32)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("第%d行第二近的ClassID有误", i+2))
+			}
+			class.RunnerUpClassId = uint(runnerUp)
+			if class.RunnerUpDistance, err = strconv.ParseFloat(record[7], 64); err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("第%d行第二近的距离有误", i+2))
+			}
+			if record[8] != "" {
+				confidence, err := strconv.ParseFloat(record[8], 64)
+				if err != nil {
+					return nil, errors.Wrap(err, fmt.Sprintf("第%d行置信度有误", i+2))
+				}
+				class.Confidence = &confidence
+			}
+		}
+		if len(record) >= len(appClassesHeaderV3) {
+			if class.Provisional, err = strconv.ParseBool(record[9]); err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("第%d行暂定标记有误", i+2))
+			}
+		}
+		if len(record) >= len(appClassesHeader) {
+			class.Cluster = record[10]
+		}
+		result = append(result, class)
+	}
+	return result, nil
+}
+
+func importMetricsCsv(dao Dao, r io.Reader) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	if !stringsEqual(header, metricsHeader) && !stringsEqual(header, metricsHeaderV3) {
+		return fmt.Errorf("表头应为%v，实际为%v", metricsHeader, header)
+	}
+
+	batch := make([]*server.AppPodMetrics, 0, stateImportBatchSize)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if len(record) != len(header) {
+			return fmt.Errorf("第%d行数据列数有误", line)
+		}
+		timestamp, err := strconv.ParseUint(record[2], 10, 64)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("第%d行时间戳有误", line))
+		}
+		cpu, err := strconv.ParseFloat(record[3], 32)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("第%d行CPU数据有误", line))
+		}
+		mem, err := strconv.ParseFloat(record[4], 32)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("第%d行内存数据有误", line))
+		}
+		appName := server.AppName{Namespace: record[0], Name: record[1]}
+		if len(record) == len(metricsHeader) {
+			appName.Cluster = record[5]
+		}
+		batch = append(batch, &server.AppPodMetrics{
+			AppName:   appName,
+			Timestamp: timestamp,
+			Cpu:       float32(cpu),
+			Mem:       float32(mem),
+		})
+		if len(batch) == stateImportBatchSize {
+			if err := dao.SaveAllAppPodMetrics(batch); err != nil {
+				return err
+			}
+			batch = make([]*server.AppPodMetrics, 0, stateImportBatchSize)
+		}
+	}
+	return dao.SaveAllAppPodMetrics(batch)
+}
+
+// 读取第一行为表头的CSV，返回表头之后的数据。表头可以是headers中的任意一个，所有行的列数与表头相同
+func readCsvWithHeader(r io.Reader, headers ...[]string) ([][]string, error) {
+	reader := csv.NewReader(r)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 {
+		for _, header := range headers {
+			if stringsEqual(records[0], header) {
+				return records[1:], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("表头应为%v", headers[0])
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 使用能够精确还原float32的最短表示
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+func formatFloat64(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// nil格式化为空字符串
+func formatOptionalFloat64(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return formatFloat64(*f)
+}
+
+func formatFloats(arr []float32) []string {
+	result := make([]string, len(arr))
+	for i, f := range arr {
+		result[i] = formatFloat(f)
+	}
+	return result
+}
@@ -0,0 +1,347 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newStateTestDao(t *testing.T) Dao {
+	dao := NewMemoryDao()
+	for classId := uint(1); classId <= 3; classId++ {
+		metrics := &server.ClassMetrics{ClassId: classId, Data: make([]*core.SectionData, core.NumSections)}
+		for i := range metrics.Data {
+			metrics.Data[i] = &core.SectionData{CpuAvg: rand.Float32(), CpuMax: rand.Float32(), MemP99: rand.Float32() / 3}
+		}
+		assert.NoError(t, dao.SaveClassMetrics(metrics))
+	}
+	for i := 0; i < 10; i++ {
+		appName := server.AppName{Name: string(rune('a' + i)), Namespace: "state"}
+		if i%3 == 0 {
+			appName.Cluster = "remote"
+		}
+		class := &server.AppClass{AppName: appName, ClassId: uint(i%3 + 1), CpuMax: rand.Float32(), MemMax: rand.Float32() * 1024}
+		// 一部分应用的分类由旧版本保存，没有置信度
+		if i%2 == 0 {
+			confidence := rand.Float64()
+			class.Distance = rand.Float64()
+			class.RunnerUpClassId = uint((i+1)%3 + 1)
+			class.RunnerUpDistance = class.Distance * 2
+			class.Confidence = &confidence
+			class.Provisional = i%4 == 0
+		}
+		assert.NoError(t, dao.SaveAppClass(class))
+		assert.NoError(t, dao.SaveAllAppPodMetrics([]*server.AppPodMetrics{
+			{AppName: appName, Timestamp: uint64(i), Cpu: rand.Float32(), Mem: rand.Float32()},
+			{AppName: appName, Timestamp: uint64(i + 60), Cpu: rand.Float32(), Mem: rand.Float32()},
+		}))
+	}
+
+	profile := make([]*core.SectionData, core.NumSections)
+	for i := range profile {
+		profile[i] = &core.SectionData{CpuAvg: 0.5, MemAvg: 1024}
+	}
+	createdAt := time.Unix(1601571600, 0).UTC()
+	assert.NoError(t, dao.SaveAppPin(&server.AppPin{AppName: server.AppName{Name: "a", Namespace: "state", Cluster: "remote"},
+		ClassId: 2, Reason: "测试", CreatedBy: "admin", CreatedAt: createdAt}))
+	assert.NoError(t, dao.SaveAppPin(&server.AppPin{AppName: server.AppName{Name: "b", Namespace: "state"},
+		Profile: profile, Reason: "测试", CreatedBy: "admin", CreatedAt: createdAt}))
+	return dao
+}
+
+func collectAppPins(t *testing.T, dao Dao) []*server.AppPin {
+	pins, err := dao.QueryAllAppPins()
+	assert.NoError(t, err)
+	sort.Slice(pins, func(i, j int) bool {
+		return pins[i].Name < pins[j].Name
+	})
+	return pins
+}
+
+func collectAppClasses(t *testing.T, dao Dao) []*server.AppClass {
+	result := make([]*server.AppClass, 0)
+	assert.NoError(t, dao.ForEachAppClass(func(class *server.AppClass) error {
+		result = append(result, class)
+		return nil
+	}))
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func collectAppPodMetrics(t *testing.T, dao Dao) []*server.AppPodMetrics {
+	result := make([]*server.AppPodMetrics, 0)
+	it := NewAppPodMetricsIterator(dao, 0)
+	for metrics, err := it.Next(); err != io.EOF; metrics, err = it.Next() {
+		assert.NoError(t, err)
+		result = append(result, metrics)
+	}
+	return result
+}
+
+// 读取归档中的所有文件
+func readArchive(t *testing.T, data []byte) map[string][]byte {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "归档格式错误")
+	}
+	tr := tar.NewReader(gr)
+	files := make(map[string][]byte)
+	for header, err := tr.Next(); err != io.EOF; header, err = tr.Next() {
+		assert.NoError(t, err)
+		files[header.Name], _ = ioutil.ReadAll(tr)
+	}
+	return files
+}
+
+func writeArchive(files map[string][]byte, order []string) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range order {
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+		_, _ = tw.Write(files[name])
+	}
+	_ = tw.Close()
+	_ = gw.Close()
+	return buf.Bytes()
+}
+
+func TestExportImportState(t *testing.T) {
+	src := newStateTestDao(t)
+	buf := &bytes.Buffer{}
+	manifest, err := ExportState(src, buf, &StateExportOptions{IncludeMetrics: true})
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "导出失败")
+	}
+	assert.Equal(t, []uint{1, 2, 3}, manifest.ClassIds)
+	assert.Equal(t, 10, manifest.NumAppClasses)
+	assert.Equal(t, 2, manifest.NumPins)
+	assert.Equal(t, 20, manifest.NumMetrics)
+
+	// 导入到已有数据的Dao中，类别中心被替换
+	dst := NewMemoryDao()
+	assert.NoError(t, dst.SaveClassMetrics(&server.ClassMetrics{ClassId: 10, Data: []*core.SectionData{{}}}))
+	imported, err := ImportState(dst, bytes.NewReader(buf.Bytes()), &StateImportOptions{NumClass: 3})
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "导入失败")
+	}
+	assert.Equal(t, manifest.ClassIds, imported.ClassIds)
+
+	for _, classId := range manifest.ClassIds {
+		expected, _ := src.QueryClassMetricsByClassId(classId)
+		actual, err := dst.QueryClassMetricsByClassId(classId)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual, "ClassID为%d的类别中心不一致", classId)
+	}
+	all, _ := dst.QueryAllClassMetrics()
+	assert.Equal(t, 3, len(all))
+	assert.Equal(t, collectAppClasses(t, src), collectAppClasses(t, dst))
+	assert.Equal(t, collectAppPodMetrics(t, src), collectAppPodMetrics(t, dst))
+	assert.Equal(t, 2, imported.NumPins)
+	assert.Equal(t, collectAppPins(t, src), collectAppPins(t, dst))
+
+	// 类别数量不一致
+	_, err = ImportState(NewMemoryDao(), bytes.NewReader(buf.Bytes()), &StateImportOptions{NumClass: 20})
+	assert.Error(t, err)
+}
+
+func TestExportState_Centers(t *testing.T) {
+	dao := newStateTestDao(t)
+	buf := &bytes.Buffer{}
+	manifest, err := ExportState(dao, buf, &StateExportOptions{})
+	assert.NoError(t, err)
+	assert.False(t, manifest.IncludeMetrics)
+
+	files := readArchive(t, buf.Bytes())
+	_, ok := files[stateMetricsFile]
+	assert.False(t, ok)
+
+	raw, err := readCenterCsv(bytes.NewReader(files[stateCentersFile]))
+	assert.NoError(t, err)
+	expected, _ := dao.QueryClassMetricsByClassId(2)
+	assert.Equal(t, expected, raw[1])
+
+	// 归档可以直接作为初始中心文件，中心不再预处理
+	f, err := ioutil.TempFile("", "state-*.tar.gz")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+	_, _ = f.Write(buf.Bytes())
+	_ = f.Close()
+	centers, err := readInitialCenterFile(f.Name())
+	assert.NoError(t, err)
+	expectedCenters, _ := dao.QueryAllClassMetrics()
+	sort.Slice(expectedCenters, func(i, j int) bool {
+		return expectedCenters[i].ClassId < expectedCenters[j].ClassId
+	})
+	assert.Equal(t, expectedCenters, centers)
+
+	// 没有类别中心的归档不能作为初始中心文件
+	empty := &bytes.Buffer{}
+	_, err = ExportState(NewMemoryDao(), empty, &StateExportOptions{})
+	assert.NoError(t, err)
+	_, err = readStateArchiveCenters(empty)
+	assert.Error(t, err)
+
+	// 没有导出监控数据时不改变已有的监控数据
+	dst := NewMemoryDao()
+	_ = dst.SaveAllAppPodMetrics([]*server.AppPodMetrics{{AppName: server.AppName{Name: "x"}, Timestamp: 1}})
+	_, err = ImportState(dst, bytes.NewReader(buf.Bytes()), &StateImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(collectAppPodMetrics(t, dst)))
+}
+
+func TestImportState_Invalid(t *testing.T) {
+	buf := &bytes.Buffer{}
+	_, err := ExportState(newStateTestDao(t), buf, &StateExportOptions{})
+	assert.NoError(t, err)
+	files := readArchive(t, buf.Bytes())
+	order := []string{stateManifestFile, stateCentersFile, stateAppClassesFile}
+
+	_, err = ImportState(NewMemoryDao(), bytes.NewReader([]byte("not an archive")), &StateImportOptions{})
+	assert.Error(t, err)
+
+	// 格式版本比本程序更新
+	manifest := &StateManifest{}
+	_ = json.Unmarshal(files[stateManifestFile], manifest)
+	manifest.FormatVersion = StateFormatVersion + 1
+	newer := make(map[string][]byte)
+	for name, content := range files {
+		newer[name] = content
+	}
+	newer[stateManifestFile], _ = json.Marshal(manifest)
+	_, err = ImportState(NewMemoryDao(), bytes.NewReader(writeArchive(newer, order)), &StateImportOptions{})
+	assert.Error(t, err)
+
+	// 描述信息不是第一个文件
+	_, err = ImportState(NewMemoryDao(), bytes.NewReader(writeArchive(files, []string{stateCentersFile, stateManifestFile})), &StateImportOptions{})
+	assert.Error(t, err)
+
+	// 缺少类别中心，且出错时不写入任何数据
+	dst := NewMemoryDao()
+	_, err = ImportState(dst, bytes.NewReader(writeArchive(files, []string{stateManifestFile, stateAppClassesFile})), &StateImportOptions{})
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(collectAppClasses(t, dst)))
+
+	// 应用分类或固定分类引用了归档中没有的类别
+	fewer := make(map[string][]byte)
+	for name, content := range files {
+		fewer[name] = content
+	}
+	_ = json.Unmarshal(files[stateManifestFile], manifest)
+	manifest.ClassIds = manifest.ClassIds[:2]
+	fewer[stateManifestFile], _ = json.Marshal(manifest)
+	fewer[stateCentersFile] = bytes.Join(bytes.SplitAfter(files[stateCentersFile], []byte("\n"))[:2], nil)
+	_, err = ImportState(dst, bytes.NewReader(writeArchive(fewer, order)), &StateImportOptions{})
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(collectAppClasses(t, dst)))
+
+	for _, pins := range []string{
+		`[{"Name":"a","Namespace":"state","classId":4,"reason":"测试"}]`,
+		`[{"Name":"a","Namespace":"state","reason":"测试"}]`,
+		`[null]`,
+		`{`,
+	} {
+		invalid := make(map[string][]byte)
+		for name, content := range files {
+			invalid[name] = content
+		}
+		invalid[stateAppPinsFile] = []byte(pins)
+		_, err = ImportState(dst, bytes.NewReader(writeArchive(invalid, append(order, stateAppPinsFile))), &StateImportOptions{})
+		assert.Error(t, err, pins)
+	}
+	assert.Equal(t, 0, len(collectAppPins(t, dst)))
+}
+
+func TestImportState_OldAppClasses(t *testing.T) {
+	buf := &bytes.Buffer{}
+	_, err := ExportState(newStateTestDao(t), buf, &StateExportOptions{})
+	assert.NoError(t, err)
+	files := readArchive(t, buf.Bytes())
+	files[stateAppClassesFile] = []byte("namespace,name,class_id,cpu_max,mem_max\nold,a,2,0.5,100\n")
+
+	dst := NewMemoryDao()
+	_, err = ImportState(dst, bytes.NewReader(writeArchive(files, []string{stateManifestFile, stateCentersFile, stateAppClassesFile})), &StateImportOptions{})
+	assert.NoError(t, err)
+	class, err := dst.QueryAppClassByApp(&server.AppName{Namespace: "old", Name: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, &server.AppClass{AppName: server.AppName{Namespace: "old", Name: "a"}, ClassId: 2, CpuMax: 0.5, MemMax: 100}, class)
+
+	// 格式版本2
+	files[stateAppClassesFile] = []byte("namespace,name,class_id,cpu_max,mem_max,distance,runner_up_class_id,runner_up_distance,confidence\nold,a,2,0.5,100,0.25,3,1,0.75\n")
+	_, err = ImportState(dst, bytes.NewReader(writeArchive(files, []string{stateManifestFile, stateCentersFile, stateAppClassesFile})), &StateImportOptions{})
+	assert.NoError(t, err)
+	class, err = dst.QueryAppClassByApp(&server.AppName{Namespace: "old", Name: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), class.RunnerUpClassId)
+	if assert.NotNil(t, class.Confidence) {
+		assert.Equal(t, 0.75, *class.Confidence)
+	}
+	assert.False(t, class.Provisional)
+
+	// 格式版本3的监控数据没有集群
+	files[stateMetricsFile] = []byte("namespace,name,timestamp,cpu,mem\nold,a,60,0.5,100\n")
+	_, err = ImportState(dst, bytes.NewReader(writeArchive(files, []string{stateManifestFile, stateCentersFile, stateAppClassesFile, stateMetricsFile})), &StateImportOptions{})
+	assert.NoError(t, err)
+	metrics, err := NewAppPodMetricsIterator(dst, 10).Next()
+	if assert.NoError(t, err) {
+		assert.Equal(t, &server.AppPodMetrics{AppName: server.AppName{Namespace: "old", Name: "a"}, Timestamp: 60, Cpu: 0.5, Mem: 100}, metrics)
+	}
+
+	// 列数与表头不一致
+	files[stateAppClassesFile] = []byte("namespace,name,class_id,cpu_max,mem_max\nold,a,2,0.5,100,1\n")
+	_, err = ImportState(NewMemoryDao(), bytes.NewReader(writeArchive(files, []string{stateManifestFile, stateCentersFile, stateAppClassesFile})), &StateImportOptions{})
+	assert.Error(t, err)
+}
+
+func TestServerImpl_HandleState(t *testing.T) {
+	s := &serverImpl{
+		config:  &ServerConfig{NumClass: 3},
+		dao:     newStateTestDao(t),
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+	handler := s.buildServer().Handler
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/state?metrics=true", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/gzip", recorder.Header().Get("Content-Type"))
+	archive := recorder.Body.Bytes()
+	_, ok := readArchive(t, archive)[stateMetricsFile]
+	assert.True(t, ok)
+
+	s.dao = NewMemoryDao()
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/state", bytes.NewReader(archive)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	manifest := &StateManifest{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), manifest))
+	assert.Equal(t, 20, manifest.NumMetrics)
+	assert.Equal(t, 10, len(collectAppClasses(t, s.dao)))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/state", bytes.NewReader([]byte("bad"))))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/admin/state", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
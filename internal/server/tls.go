@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/tls"
+	"github.com/pkg/errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const fileCheckInterval = 10 * time.Second // 检查证书与token文件是否被修改的间隔
+
+// 判断一组文件在上次检查后是否被修改，最多每interval检查一次。不是并发安全的
+type fileChangeDetector struct {
+	paths     []string
+	interval  time.Duration
+	checkedAt time.Time
+	modTimes  []time.Time
+}
+
+func newFileChangeDetector(paths ...string) *fileChangeDetector {
+	return &fileChangeDetector{
+		paths:    paths,
+		interval: fileCheckInterval,
+		modTimes: make([]time.Time, len(paths)),
+	}
+}
+
+// 第一次调用时总是返回true。文件无法访问时视为没有修改，继续使用已加载的内容
+func (d *fileChangeDetector) changed(now time.Time) bool {
+	if !d.checkedAt.IsZero() && now.Sub(d.checkedAt) < d.interval {
+		return false
+	}
+	first := d.checkedAt.IsZero()
+	d.checkedAt = now
+
+	changed := false
+	for i, path := range d.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(d.modTimes[i]) {
+			d.modTimes[i] = info.ModTime()
+			changed = true
+		}
+	}
+	return first || changed
+}
+
+// 在握手时提供证书，证书或私钥文件被修改后重新加载，使得更新Secret后不需要重启服务器
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+	logger   *log.Logger
+
+	lock     sync.Mutex
+	detector *fileChangeDetector
+	cert     *tls.Certificate
+}
+
+// 立即加载一次证书，失败时返回错误
+func newCertificateReloader(certFile, keyFile string, logger *log.Logger) (*certificateReloader, error) {
+	r := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+		detector: newFileChangeDetector(certFile, keyFile),
+	}
+	if _, err := r.getCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certificateReloader) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.detector.changed(time.Now()) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert == nil {
+			return nil, errors.Wrap(err, "加载TLS证书出错")
+		}
+		// 证书与私钥可能只更新了一个，继续使用旧的证书，下次检查时再尝试
+		r.logger.Printf("重新加载TLS证书出错，继续使用原有证书：%v\n", err)
+		r.detector.modTimes = make([]time.Time, len(r.detector.paths))
+		return r.cert, nil
+	}
+	if r.cert != nil {
+		r.logger.Println("TLS证书已更新")
+	}
+	r.cert = &cert
+	return r.cert, nil
+}
+
+func (r *certificateReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: r.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 生成自签名证书，写入certFile与keyFile，修改时间设为modTime
+func writeTestCertificate(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{commonName},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	assert.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	assert.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	logger := log.New(os.Stdout, "", 0)
+
+	_, err = newCertificateReloader(certFile, keyFile, logger)
+	assert.Error(t, err)
+
+	writeTestCertificate(t, certFile, keyFile, "old.example.com", time.Now().Add(-time.Minute))
+	reloader, err := newCertificateReloader(certFile, keyFile, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+	commonName := func() string {
+		cert, err := reloader.getCertificate(nil)
+		assert.NoError(t, err)
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		assert.NoError(t, err)
+		return parsed.Subject.CommonName
+	}
+	assert.Equal(t, "old.example.com", commonName())
+
+	// 检查间隔内不会重新加载
+	writeTestCertificate(t, certFile, keyFile, "new.example.com", time.Now())
+	assert.Equal(t, "old.example.com", commonName())
+	reloader.detector.interval = 0
+	assert.Equal(t, "new.example.com", commonName())
+
+	// 私钥与证书不匹配时继续使用原有证书
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("broken"), 0600))
+	assert.NoError(t, os.Chtimes(keyFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	assert.Equal(t, "new.example.com", commonName())
+}
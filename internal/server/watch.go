@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	watchPollInterval     = 2 * time.Second  // 查询数据库中新的分类变化的间隔，所有副本都能以此提供watch
+	watchBookmarkInterval = 30 * time.Second // 发送BOOKMARK事件的间隔，同时用于保持连接
+	watchEventBatchSize   = 500
+
+	DefaultWatchTimeout = 30 * time.Minute // watch连接的默认持续时间，结束后客户端从最新的ResourceVersion重新连接
+	maxWatchTimeout     = time.Hour
+)
+
+func (s *serverImpl) QueryClassificationSnapshot() (*server.ClassificationSnapshot, error) {
+	snapshot := &server.ClassificationSnapshot{}
+	// 在同一个事务中读取，保证数据与ResourceVersion一致
+	err := s.dao.Transaction(func(tx Dao) error {
+		_, latest, err := tx.QueryClassificationVersions()
+		if err != nil {
+			return err
+		}
+		snapshot.ResourceVersion = latest
+
+		snapshot.Centers, err = tx.QueryAllClassMetrics()
+		if err != nil {
+			return err
+		}
+		// QueryAllAppPins已按应用排序
+		snapshot.Pins, err = tx.QueryAllAppPins()
+		if err != nil {
+			return err
+		}
+		snapshot.Apps = make([]*server.AppAssignment, 0)
+		return tx.ForEachAppClass(func(class *server.AppClass) error {
+			snapshot.Apps = append(snapshot.Apps, server.NewAppAssignment(class))
+			return nil
+		})
+	})
+	if err != nil {
+		s.logger.Printf("查询分类快照出错：%v\n", err)
+		return nil, err
+	}
+
+	sort.Slice(snapshot.Centers, func(i, j int) bool {
+		return snapshot.Centers[i].ClassId < snapshot.Centers[j].ClassId
+	})
+	sort.Slice(snapshot.Apps, func(i, j int) bool {
+		return snapshot.Apps[i].AppName.Less(snapshot.Apps[j].AppName)
+	})
+	return snapshot, nil
+}
+
+// 指定cluster参数时只返回该集群的应用分类与固定分类
+func (s *serverImpl) handleClassificationSnapshot(writer http.ResponseWriter, request *http.Request) {
+	cluster, err := clusterFromRequest(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	snapshot, err := s.QueryClassificationSnapshot()
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filterSnapshotByCluster(snapshot, cluster)
+
+	marshal, err := json.Marshal(snapshot)
+	if err != nil {
+		http.Error(writer, errors.Wrap(err, "序列化问题").Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	_, _ = writer.Write(marshal)
+}
+
+// 以分块传输的方式持续推送resourceVersion之后的分类变化，每行一个JSON格式的server.ClassificationEvent。
+// 不指定resourceVersion时只推送之后的变化，指定cluster时只推送该集群的应用分类与固定分类变化。stop结束时关闭连接
+func (s *serverImpl) handleClassificationWatch(stop context.Context, writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	cluster, err := clusterFromRequest(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	timeout := DefaultWatchTimeout
+	if value := query.Get("timeoutSeconds"); value != "" {
+		seconds, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || seconds == 0 {
+			http.Error(writer, fmt.Sprintf("timeoutSeconds格式错误：%s", value), http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+		if timeout > maxWatchTimeout {
+			timeout = maxWatchTimeout
+		}
+	}
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+
+	var version *uint64
+	if value := query.Get("resourceVersion"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("resourceVersion格式错误：%s", value), http.StatusBadRequest)
+			return
+		}
+		version = &parsed
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), timeout)
+	defer cancel()
+	dao := s.dao.WithContext(ctx)
+
+	start, err := watchStartVersion(dao, version)
+	if err == server.ErrResourceVersionExpired {
+		http.Error(writer, err.Error(), http.StatusGone)
+		return
+	} else if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	encoder := json.NewEncoder(writer)
+
+	// 记录已发送的最新版本，出错时告知客户端
+	sent := start
+	sendFailed := false
+	send := func(event *server.ClassificationEvent) error {
+		if !eventInCluster(event, cluster) {
+			return nil
+		}
+		if err := encoder.Encode(event); err != nil {
+			sendFailed = true
+			return err
+		}
+		sent = event.ResourceVersion
+		return nil
+	}
+	err = streamClassificationEvents(ctx, stop, dao, start, send, flusher.Flush)
+	if err != nil && !sendFailed && ctx.Err() == nil {
+		_ = encoder.Encode(&server.ClassificationEvent{
+			Type:            server.EventTypeError,
+			ResourceVersion: sent,
+			Code:            http.StatusInternalServerError,
+			Message:         err.Error(),
+		})
+	}
+}
+
+// 返回watch开始的ResourceVersion。version为nil时从最新的版本开始，version之后的变化已被清理时返回server.ErrResourceVersionExpired
+func watchStartVersion(dao Dao, version *uint64) (uint64, error) {
+	oldest, latest, err := dao.QueryClassificationVersions()
+	if err != nil {
+		return 0, err
+	}
+	if version == nil {
+		return latest, nil
+	}
+	// version之后的第一条记录已被清理
+	if oldest > *version+1 {
+		return 0, server.ErrResourceVersionExpired
+	}
+	return *version, nil
+}
+
+// 依次发送version之后的分类变化，没有变化时定期发送BOOKMARK，直到ctx或stop结束时返回nil。
+// 查询或发送出错时返回该错误。flush在每批事件发送后调用
+func streamClassificationEvents(ctx, stop context.Context, dao Dao, version uint64,
+	send func(event *server.ClassificationEvent) error, flush func()) error {
+	poll := time.NewTicker(watchPollInterval)
+	defer poll.Stop()
+	bookmark := time.NewTicker(watchBookmarkInterval)
+	defer bookmark.Stop()
+	for {
+		// 一次发送所有积压的变化
+		for {
+			events, err := dao.QueryClassificationEvents(version, watchEventBatchSize)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			for _, event := range events {
+				if err := send(event); err != nil {
+					return err
+				}
+				version = event.ResourceVersion
+			}
+			flush()
+			if len(events) < watchEventBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-poll.C:
+		case <-bookmark.C:
+			err := send(&server.ClassificationEvent{Type: server.EventTypeBookmark, ResourceVersion: version})
+			if err != nil {
+				return err
+			}
+			flush()
+		case <-ctx.Done():
+			return nil
+		case <-stop.Done():
+			return nil
+		}
+	}
+}
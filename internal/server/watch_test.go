@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// 在后台读取watch事件，连接结束时关闭返回的channel
+func readWatchEvents(body io.Reader) <-chan *server.ClassificationEvent {
+	events := make(chan *server.ClassificationEvent, 100)
+	go func() {
+		defer close(events)
+		decoder := json.NewDecoder(body)
+		for {
+			event := &server.ClassificationEvent{}
+			if err := decoder.Decode(event); err != nil {
+				return
+			}
+			events <- event
+		}
+	}()
+	return events
+}
+
+func nextWatchEvent(t *testing.T, events <-chan *server.ClassificationEvent) *server.ClassificationEvent {
+	select {
+	case event, ok := <-events:
+		if !ok {
+			assert.FailNow(t, "watch连接已结束")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "等待watch事件超时")
+	}
+	return nil
+}
+
+func TestServerImpl_ClassificationWatch(t *testing.T) {
+	dao := NewMemoryDao()
+	s := &serverImpl{
+		config:  &ServerConfig{},
+		dao:     dao,
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+	saveTestPatternCenters(t, dao)
+	app1 := server.AppName{Name: "app-1", Namespace: "test"}
+	app2 := server.AppName{Name: "app-2", Namespace: "test"}
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: app2, ClassId: 2, CpuMax: 4, MemMax: 8}))
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: app1, ClassId: 1, CpuMax: 2, MemMax: 2}))
+
+	srv := s.buildServer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	baseUrl := "http://" + listener.Addr().String()
+
+	response, err := http.Get(baseUrl + "/classification")
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "请求快照出错")
+	}
+	snapshot := &server.ClassificationSnapshot{}
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(snapshot))
+	_ = response.Body.Close()
+	assert.Equal(t, uint64(4), snapshot.ResourceVersion)
+	if assert.Equal(t, 2, len(snapshot.Centers)) {
+		assert.Equal(t, uint(1), snapshot.Centers[0].ClassId)
+	}
+	if assert.Equal(t, 2, len(snapshot.Apps)) {
+		assert.Equal(t, app1, snapshot.Apps[0].AppName)
+		assert.Equal(t, float32(4), snapshot.Apps[1].CpuMax)
+	}
+
+	// 从快照的版本开始，推送之后的变化
+	response, err = http.Get(fmt.Sprintf("%s/classification/watch?resourceVersion=%d", baseUrl, snapshot.ResourceVersion))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	events := readWatchEvents(response.Body)
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: app1, ClassId: 2, CpuMax: 2, MemMax: 2}))
+	event := nextWatchEvent(t, events)
+	assert.Equal(t, server.EventTypeModified, event.Type)
+	assert.Equal(t, uint64(5), event.ResourceVersion)
+	assert.Equal(t, app1, event.AppAssignment.AppName)
+	assert.Equal(t, uint(2), event.AppAssignment.ClassId)
+	_ = response.Body.Close()
+
+	// 从头开始时立即推送已有的变化
+	response, err = http.Get(baseUrl + "/classification/watch?resourceVersion=0")
+	assert.NoError(t, err)
+	events = readWatchEvents(response.Body)
+	for version := uint64(1); version <= 5; version++ {
+		assert.Equal(t, version, nextWatchEvent(t, events).ResourceVersion)
+	}
+	_ = response.Body.Close()
+
+	// 清理后过旧的版本返回410
+	assert.NoError(t, dao.RemoveClassificationEventsBefore(time.Now().Add(time.Hour)))
+	response, err = http.Get(baseUrl + "/classification/watch?resourceVersion=3")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusGone, response.StatusCode)
+	_ = response.Body.Close()
+	response, err = http.Get(baseUrl + "/classification/watch?resourceVersion=4")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	_ = response.Body.Close()
+
+	response, err = http.Get(baseUrl + "/classification/watch?resourceVersion=abc")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	_ = response.Body.Close()
+
+	// 关闭服务器时结束watch，而不是等待到超时
+	response, err = http.Get(baseUrl + "/classification/watch")
+	assert.NoError(t, err)
+	events = readWatchEvents(response.Body)
+	// 客户端预先建立但没有使用的连接会使Shutdown等待5秒
+	http.DefaultClient.CloseIdleConnections()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, srv.Shutdown(ctx))
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "watch连接没有结束")
+	}
+}
+
+func TestServerImpl_ClassificationSnapshot_Empty(t *testing.T) {
+	s := &serverImpl{
+		config:  &ServerConfig{},
+		dao:     NewMemoryDao(),
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+	recorder := httptest.NewRecorder()
+	s.buildServer().Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/classification", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"resourceVersion":0,"centers":[],"apps":[],"pins":[]}`, recorder.Body.String())
+}
+
+func TestServerImpl_ClassificationWatch_Pins(t *testing.T) {
+	dao := NewMemoryDao()
+	s := &serverImpl{
+		config:  &ServerConfig{},
+		dao:     dao,
+		logger:  log.New(os.Stdout, "", 0),
+		metrics: newServerMetrics(),
+	}
+	saveTestPatternCenters(t, dao)
+	app := server.AppName{Name: "app", Namespace: "test"}
+	east := server.AppName{Name: "app", Namespace: "test", Cluster: "east"}
+	assert.NoError(t, dao.SaveAppClass(&server.AppClass{AppName: app, ClassId: 1, CpuMax: 2, MemMax: 2}))
+	assert.NoError(t, s.pinApp(&server.AppPin{AppName: app, ClassId: 2, Reason: "test", CreatedBy: "admin"}))
+
+	srv := s.buildServer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	defer func() {
+		_ = srv.Close()
+	}()
+	baseUrl := "http://" + listener.Addr().String()
+
+	// 快照包含固定分类
+	response, err := http.Get(baseUrl + "/classification")
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, "请求快照出错")
+	}
+	snapshot := &server.ClassificationSnapshot{}
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(snapshot))
+	_ = response.Body.Close()
+	if assert.Equal(t, 1, len(snapshot.Pins)) {
+		assert.Equal(t, app, snapshot.Pins[0].AppName)
+		assert.Equal(t, uint(2), snapshot.Pins[0].ClassId)
+	}
+
+	// 固定与取消固定都推送事件，指定集群时不推送其他集群的固定分类
+	response, err = http.Get(fmt.Sprintf("%s/classification/watch?resourceVersion=%d&cluster=east", baseUrl, snapshot.ResourceVersion))
+	assert.NoError(t, err)
+	events := readWatchEvents(response.Body)
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	assert.NoError(t, s.unpinApp(app, "test", "admin"))
+	profile := make([]*core.SectionData, core.NumSections)
+	for i := range profile {
+		profile[i] = &core.SectionData{CpuMax: 10, MemMax: 20}
+	}
+	assert.NoError(t, s.pinApp(&server.AppPin{AppName: east, Profile: profile, Reason: "test", CreatedBy: "admin"}))
+	event := nextWatchEvent(t, events)
+	assert.Equal(t, server.EventTypeModified, event.Type)
+	assert.Equal(t, server.EventKindAppPin, event.Kind)
+	assert.Equal(t, snapshot.ResourceVersion+2, event.ResourceVersion)
+	assert.Equal(t, east, event.AppPin.AppName)
+	assert.Equal(t, core.NumSections, len(event.AppPin.Profile))
+
+	assert.NoError(t, s.unpinApp(east, "test", "admin"))
+	event = nextWatchEvent(t, events)
+	assert.Equal(t, server.EventTypeDeleted, event.Type)
+	assert.Equal(t, server.EventKindAppPin, event.Kind)
+	assert.Equal(t, east, event.AppPin.AppName)
+}
@@ -0,0 +1,160 @@
+package sketch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// 分位数的相对误差上限
+const RelativeAccuracy = 0.01
+
+// 小于此值的数据视为0
+const minIndexableValue = 1e-9
+
+var (
+	gamma        = (1 + RelativeAccuracy) / (1 - RelativeAccuracy)
+	logGamma     = math.Log(gamma)
+	errCorrupted = fmt.Errorf("分位数草图数据已损坏")
+)
+
+// 可合并的分位数草图。数据按对数划分到桶中，每个桶只记录数量，因此占用空间与数据量无关，
+// 且两个草图合并的结果与将两份数据放在一起构造的草图完全相同。返回的分位数相对误差不超过RelativeAccuracy。
+type Sketch struct {
+	zeroCount uint64
+	bins      map[int32]uint64
+	count     uint64
+}
+
+func New() *Sketch {
+	return &Sketch{bins: make(map[int32]uint64)}
+}
+
+func index(v float64) int32 {
+	return int32(math.Ceil(math.Log(v) / logGamma))
+}
+
+// 桶(gamma^(i-1), gamma^i]的代表值，与桶内任意值的相对误差不超过RelativeAccuracy
+func value(i int32) float64 {
+	return 2 * math.Pow(gamma, float64(i)) / (gamma + 1)
+}
+
+// 加入一个数据。负数与NaN视为0
+func (s *Sketch) Add(v float64) {
+	s.count++
+	if !(v > minIndexableValue) {
+		s.zeroCount++
+		return
+	}
+	s.bins[index(v)]++
+}
+
+func (s *Sketch) Merge(other *Sketch) {
+	s.count += other.count
+	s.zeroCount += other.zeroCount
+	for i, c := range other.bins {
+		s.bins[i] += c
+	}
+}
+
+func (s *Sketch) Count() uint64 {
+	return s.count
+}
+
+// 返回第q分位数，其排名与对排序后的数组取arr[count*q]一致。没有数据时返回NaN
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 || q < 0 || q > 1 {
+		return math.NaN()
+	}
+	rank := uint64(float64(s.count) * q)
+	if rank >= s.count {
+		rank = s.count - 1
+	}
+	if rank < s.zeroCount {
+		return 0
+	}
+
+	indexes := s.sortedIndexes()
+	seen := s.zeroCount
+	for _, i := range indexes {
+		seen += s.bins[i]
+		if seen > rank {
+			return value(i)
+		}
+	}
+	return value(indexes[len(indexes)-1])
+}
+
+func (s *Sketch) sortedIndexes() []int32 {
+	indexes := make([]int32, 0, len(s.bins))
+	for i := range s.bins {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(a, b int) bool {
+		return indexes[a] < indexes[b]
+	})
+	return indexes
+}
+
+// 编码格式为：数据总数、0的数量、桶数量，然后按桶序号递增依次为序号与数量，均为varint
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 3*binary.MaxVarintLen64+len(s.bins)*(binary.MaxVarintLen32+binary.MaxVarintLen64))
+	buf = appendUvarint(buf, s.count)
+	buf = appendUvarint(buf, s.zeroCount)
+	buf = appendUvarint(buf, uint64(len(s.bins)))
+	for _, i := range s.sortedIndexes() {
+		buf = appendVarint(buf, int64(i))
+		buf = appendUvarint(buf, s.bins[i])
+	}
+	return buf, nil
+}
+
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	var fields [3]uint64
+	for i := range fields {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errCorrupted
+		}
+		fields[i] = v
+		data = data[n:]
+	}
+
+	bins := make(map[int32]uint64, fields[2])
+	total := fields[1]
+	for j := uint64(0); j < fields[2]; j++ {
+		i, n := binary.Varint(data)
+		if n <= 0 || i < math.MinInt32 || i > math.MaxInt32 {
+			return errCorrupted
+		}
+		data = data[n:]
+		c, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errCorrupted
+		}
+		data = data[n:]
+		bins[int32(i)] += c
+		total += c
+	}
+	if len(data) != 0 || total != fields[0] {
+		return errCorrupted
+	}
+
+	s.count = fields[0]
+	s.zeroCount = fields[1]
+	s.bins = bins
+	return nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
@@ -0,0 +1,84 @@
+package sketch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func exactQuantile(sorted []float64, q float64) float64 {
+	return sorted[int(float64(len(sorted))*q)]
+}
+
+func TestSketch_Quantile(t *testing.T) {
+	s := New()
+	assert.True(t, math.IsNaN(s.Quantile(0.5)))
+
+	data := make([]float64, 10000)
+	for i := range data {
+		data[i] = rand.ExpFloat64() * 100
+		s.Add(data[i])
+	}
+	sort.Float64s(data)
+
+	assert.Equal(t, uint64(len(data)), s.Count())
+	for _, q := range []float64{0, 0.5, 0.9, 0.99} {
+		expected := exactQuantile(data, q)
+		actual := s.Quantile(q)
+		assert.InDelta(t, expected, actual, expected*RelativeAccuracy+1e-9, "第%f分位数误差过大", q)
+	}
+	assert.InDelta(t, data[len(data)-1], s.Quantile(1), data[len(data)-1]*RelativeAccuracy)
+}
+
+func TestSketch_Zero(t *testing.T) {
+	s := New()
+	s.Add(0)
+	s.Add(-1)
+	s.Add(math.NaN())
+	s.Add(10)
+	assert.Equal(t, float64(0), s.Quantile(0.5))
+	assert.InDelta(t, 10, s.Quantile(0.99), 10*RelativeAccuracy)
+}
+
+func TestSketch_Merge(t *testing.T) {
+	all := New()
+	a := New()
+	b := New()
+	for i := 0; i < 1000; i++ {
+		v := rand.Float64() * 10
+		all.Add(v)
+		if i%3 == 0 {
+			a.Add(v)
+		} else {
+			b.Add(v)
+		}
+	}
+	a.Merge(b)
+	assert.Equal(t, all, a)
+}
+
+func TestSketch_MarshalBinary(t *testing.T) {
+	s := New()
+	for i := 0; i < 100; i++ {
+		s.Add(rand.Float64() * 1000)
+	}
+	s.Add(0)
+
+	data, err := s.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := New()
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, s, decoded)
+
+	empty, _ := New().MarshalBinary()
+	decoded = New()
+	assert.NoError(t, decoded.UnmarshalBinary(empty))
+	assert.Equal(t, uint64(0), decoded.Count())
+
+	// 损坏的数据
+	assert.Error(t, decoded.UnmarshalBinary(nil))
+	assert.Error(t, decoded.UnmarshalBinary(data[:len(data)-1]))
+	assert.Error(t, decoded.UnmarshalBinary(append(data, 1)))
+}
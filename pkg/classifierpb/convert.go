@@ -0,0 +1,460 @@
+package classifierpb
+
+import (
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"time"
+)
+
+// 本文件在pkg/server中的类型与protobuf消息之间转换。From开头的函数转换为消息，To开头的函数转换为pkg/server中的类型
+
+// 零值时间转换为空
+func fromTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return &timestamppb.Timestamp{Seconds: t.Unix(), Nanos: int32(t.Nanosecond())}
+}
+
+func toTime(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Unix(t.Seconds, int64(t.Nanos))
+}
+
+func fromFloat64Ptr(value *float64) *wrapperspb.DoubleValue {
+	if value == nil {
+		return nil
+	}
+	return &wrapperspb.DoubleValue{Value: *value}
+}
+
+func toFloat64Ptr(value *wrapperspb.DoubleValue) *float64 {
+	if value == nil {
+		return nil
+	}
+	v := value.Value
+	return &v
+}
+
+func FromAppName(name server.AppName) *AppName {
+	return &AppName{Name: name.Name, Namespace: name.Namespace, Cluster: name.Cluster}
+}
+
+func ToAppName(name *AppName) server.AppName {
+	return server.AppName{Name: name.GetName(), Namespace: name.GetNamespace(), Cluster: name.GetCluster()}
+}
+
+// nil转换为空消息
+func FromSectionData(data *core.SectionData) *SectionData {
+	if data == nil {
+		return &SectionData{}
+	}
+	return &SectionData{
+		CpuAvg: data.CpuAvg,
+		CpuMax: data.CpuMax,
+		CpuMin: data.CpuMin,
+		CpuP50: data.CpuP50,
+		CpuP90: data.CpuP90,
+		CpuP99: data.CpuP99,
+		MemAvg: data.MemAvg,
+		MemMax: data.MemMax,
+		MemMin: data.MemMin,
+		MemP50: data.MemP50,
+		MemP90: data.MemP90,
+		MemP99: data.MemP99,
+	}
+}
+
+func ToSectionData(data *SectionData) *core.SectionData {
+	return &core.SectionData{
+		CpuAvg: data.GetCpuAvg(),
+		CpuMax: data.GetCpuMax(),
+		CpuMin: data.GetCpuMin(),
+		CpuP50: data.GetCpuP50(),
+		CpuP90: data.GetCpuP90(),
+		CpuP99: data.GetCpuP99(),
+		MemAvg: data.GetMemAvg(),
+		MemMax: data.GetMemMax(),
+		MemMin: data.GetMemMin(),
+		MemP50: data.GetMemP50(),
+		MemP90: data.GetMemP90(),
+		MemP99: data.GetMemP99(),
+	}
+}
+
+func fromSectionDataList(data []*core.SectionData) []*SectionData {
+	result := make([]*SectionData, len(data))
+	for i, d := range data {
+		result[i] = FromSectionData(d)
+	}
+	return result
+}
+
+func toSectionDataList(data []*SectionData) []*core.SectionData {
+	result := make([]*core.SectionData, len(data))
+	for i, d := range data {
+		result[i] = ToSectionData(d)
+	}
+	return result
+}
+
+func FromClassMetrics(metrics *server.ClassMetrics) *ClassMetrics {
+	return &ClassMetrics{ClassId: uint32(metrics.ClassId), Data: fromSectionDataList(metrics.Data)}
+}
+
+func ToClassMetrics(metrics *ClassMetrics) *server.ClassMetrics {
+	return &server.ClassMetrics{ClassId: uint(metrics.GetClassId()), Data: toSectionDataList(metrics.GetData())}
+}
+
+func FromClassMetricsList(metrics []*server.ClassMetrics) []*ClassMetrics {
+	result := make([]*ClassMetrics, len(metrics))
+	for i, m := range metrics {
+		result[i] = FromClassMetrics(m)
+	}
+	return result
+}
+
+func ToClassMetricsList(metrics []*ClassMetrics) []*server.ClassMetrics {
+	result := make([]*server.ClassMetrics, len(metrics))
+	for i, m := range metrics {
+		result[i] = ToClassMetrics(m)
+	}
+	return result
+}
+
+func FromAppCharacteristics(c *server.AppCharacteristics) *AppCharacteristics {
+	return &AppCharacteristics{
+		App:             FromAppName(c.AppName),
+		SectionData:     fromSectionDataList(c.SectionData),
+		ClassId:         uint32(c.ClassId),
+		Distance:        c.Distance,
+		RunnerUpClassId: uint32(c.RunnerUpClassId),
+		Confidence:      fromFloat64Ptr(c.Confidence),
+		Provisional:     c.Provisional,
+		Pinned:          c.Pinned,
+		PinStale:        c.PinStale,
+	}
+}
+
+func ToAppCharacteristics(c *AppCharacteristics) *server.AppCharacteristics {
+	return &server.AppCharacteristics{
+		AppName:         ToAppName(c.GetApp()),
+		SectionData:     toSectionDataList(c.GetSectionData()),
+		ClassId:         uint(c.GetClassId()),
+		Distance:        c.GetDistance(),
+		RunnerUpClassId: uint(c.GetRunnerUpClassId()),
+		Confidence:      toFloat64Ptr(c.GetConfidence()),
+		Provisional:     c.GetProvisional(),
+		Pinned:          c.GetPinned(),
+		PinStale:        c.GetPinStale(),
+	}
+}
+
+// 没有数据的Section转换为空消息
+func FromAppProfile(p *server.AppProfile) *AppProfile {
+	return &AppProfile{
+		App:         FromAppName(p.AppName),
+		SectionData: fromSectionDataList(p.SectionData),
+		SampleCount: p.SampleCount,
+		Coverage:    p.Coverage,
+		Classified:  p.Classified,
+		ClassId:     uint32(p.ClassId),
+		Distance:    fromFloat64Ptr(p.Distance),
+	}
+}
+
+// 样本数量为0的Section还原为nil
+func ToAppProfile(p *AppProfile) *server.AppProfile {
+	sectionData := toSectionDataList(p.GetSectionData())
+	sampleCount := p.GetSampleCount()
+	for i := range sectionData {
+		if i < len(sampleCount) && sampleCount[i] == 0 {
+			sectionData[i] = nil
+		}
+	}
+	return &server.AppProfile{
+		AppName:     ToAppName(p.GetApp()),
+		SectionData: sectionData,
+		SampleCount: sampleCount,
+		Coverage:    p.GetCoverage(),
+		Classified:  p.GetClassified(),
+		ClassId:     uint(p.GetClassId()),
+		Distance:    toFloat64Ptr(p.GetDistance()),
+	}
+}
+
+func FromAppClassHistory(h *server.AppClassHistory) *AppClassHistory {
+	return &AppClassHistory{
+		App:             FromAppName(h.AppName),
+		ClassId:         uint32(h.ClassId),
+		PreviousClassId: uint32(h.PreviousClassId),
+		Distance:        h.Distance,
+		Confidence:      fromFloat64Ptr(h.Confidence),
+		Provisional:     h.Provisional,
+		Time:            fromTime(h.Time),
+	}
+}
+
+func ToAppClassHistory(h *AppClassHistory) *server.AppClassHistory {
+	return &server.AppClassHistory{
+		AppName:         ToAppName(h.GetApp()),
+		ClassId:         uint(h.GetClassId()),
+		PreviousClassId: uint(h.GetPreviousClassId()),
+		Distance:        h.GetDistance(),
+		Confidence:      toFloat64Ptr(h.GetConfidence()),
+		Provisional:     h.GetProvisional(),
+		Time:            toTime(h.GetTime()),
+	}
+}
+
+func FromReClusterSchedule(s *server.ReClusterSchedule) *ReClusterSchedule {
+	return &ReClusterSchedule{Schedule: s.Schedule, TimeZone: s.TimeZone, Next: fromTime(s.Next)}
+}
+
+func ToReClusterSchedule(s *ReClusterSchedule) *server.ReClusterSchedule {
+	return &server.ReClusterSchedule{Schedule: s.GetSchedule(), TimeZone: s.GetTimeZone(), Next: toTime(s.GetNext())}
+}
+
+func FromReClusterSummary(s *server.ReClusterSummary) *ReClusterSummary {
+	if s == nil {
+		return nil
+	}
+	ineligible := make(map[string]int32, len(s.Ineligible))
+	for reason, count := range s.Ineligible {
+		ineligible[reason] = int32(count)
+	}
+	return &ReClusterSummary{
+		StartedAt:    fromTime(s.StartedAt),
+		FinishedAt:   fromTime(s.FinishedAt),
+		NumApps:      int32(s.NumApps),
+		NumClustered: int32(s.NumClustered),
+		Ineligible:   ineligible,
+		Inertia:      s.Inertia,
+		MeanDistance: s.MeanDistance,
+		NumChanged:   int32(s.NumChanged),
+	}
+}
+
+func ToReClusterSummary(s *ReClusterSummary) *server.ReClusterSummary {
+	if s == nil {
+		return nil
+	}
+	ineligible := make(map[string]int, len(s.Ineligible))
+	for reason, count := range s.Ineligible {
+		ineligible[reason] = int(count)
+	}
+	return &server.ReClusterSummary{
+		StartedAt:    toTime(s.StartedAt),
+		FinishedAt:   toTime(s.FinishedAt),
+		NumApps:      int(s.NumApps),
+		NumClustered: int(s.NumClustered),
+		Ineligible:   ineligible,
+		Inertia:      s.Inertia,
+		MeanDistance: s.MeanDistance,
+		NumChanged:   int(s.NumChanged),
+	}
+}
+
+func FromReClusterParams(p *server.ReClusterParams) *ReClusterParams {
+	return &ReClusterParams{NumClass: uint32(p.NumClass), NumRound: uint32(p.NumRound)}
+}
+
+func ToReClusterParams(p *ReClusterParams) *server.ReClusterParams {
+	return &server.ReClusterParams{NumClass: uint(p.GetNumClass()), NumRound: uint(p.GetNumRound())}
+}
+
+func FromReClusterDryRun(d *server.ReClusterDryRun) *ReClusterDryRun {
+	shifts := make([]*CenterShift, len(d.CenterShifts))
+	for i, shift := range d.CenterShifts {
+		shifts[i] = &CenterShift{
+			ClassId:        uint32(shift.ClassId),
+			MatchedClassId: uint32(shift.MatchedClassId),
+			Distance:       shift.Distance,
+			NumApps:        int32(shift.NumApps),
+		}
+	}
+	removed := make([]uint32, len(d.RemovedClasses))
+	for i, classId := range d.RemovedClasses {
+		removed[i] = uint32(classId)
+	}
+	changes := make([]*AppClassChange, len(d.Changes))
+	for i, change := range d.Changes {
+		changes[i] = &AppClassChange{
+			App:             FromAppName(change.AppName),
+			CurrentClassId:  uint32(change.CurrentClassId),
+			ProposedClassId: uint32(change.ProposedClassId),
+			MatchedClassId:  uint32(change.MatchedClassId),
+			Provisional:     change.Provisional,
+			Pinned:          change.Pinned,
+		}
+	}
+	return &ReClusterDryRun{
+		Params:         FromReClusterParams(&d.Params),
+		Summary:        FromReClusterSummary(d.Summary),
+		Centers:        FromClassMetricsList(d.Centers),
+		CenterShifts:   shifts,
+		RemovedClasses: removed,
+		Changes:        changes,
+		NumUnchanged:   int32(d.NumUnchanged),
+		NumNewApps:     int32(d.NumNewApps),
+	}
+}
+
+func ToReClusterDryRun(d *ReClusterDryRun) *server.ReClusterDryRun {
+	shifts := make([]*server.CenterShift, len(d.GetCenterShifts()))
+	for i, shift := range d.GetCenterShifts() {
+		shifts[i] = &server.CenterShift{
+			ClassId:        uint(shift.GetClassId()),
+			MatchedClassId: uint(shift.GetMatchedClassId()),
+			Distance:       shift.GetDistance(),
+			NumApps:        int(shift.GetNumApps()),
+		}
+	}
+	removed := make([]uint, len(d.GetRemovedClasses()))
+	for i, classId := range d.GetRemovedClasses() {
+		removed[i] = uint(classId)
+	}
+	changes := make([]*server.AppClassChange, len(d.GetChanges()))
+	for i, change := range d.GetChanges() {
+		changes[i] = &server.AppClassChange{
+			AppName:         ToAppName(change.GetApp()),
+			CurrentClassId:  uint(change.GetCurrentClassId()),
+			ProposedClassId: uint(change.GetProposedClassId()),
+			MatchedClassId:  uint(change.GetMatchedClassId()),
+			Provisional:     change.GetProvisional(),
+			Pinned:          change.GetPinned(),
+		}
+	}
+	return &server.ReClusterDryRun{
+		Params:         *ToReClusterParams(d.GetParams()),
+		Summary:        ToReClusterSummary(d.GetSummary()),
+		Centers:        ToClassMetricsList(d.GetCenters()),
+		CenterShifts:   shifts,
+		RemovedClasses: removed,
+		Changes:        changes,
+		NumUnchanged:   int(d.GetNumUnchanged()),
+		NumNewApps:     int(d.GetNumNewApps()),
+	}
+}
+
+func FromAppAssignment(a *server.AppAssignment) *AppAssignment {
+	return &AppAssignment{
+		App:             FromAppName(a.AppName),
+		ClassId:         uint32(a.ClassId),
+		CpuMax:          a.CpuMax,
+		MemMax:          a.MemMax,
+		Distance:        a.Distance,
+		RunnerUpClassId: uint32(a.RunnerUpClassId),
+		Confidence:      fromFloat64Ptr(a.Confidence),
+		Provisional:     a.Provisional,
+	}
+}
+
+func ToAppAssignment(a *AppAssignment) *server.AppAssignment {
+	return &server.AppAssignment{
+		AppName:         ToAppName(a.GetApp()),
+		ClassId:         uint(a.GetClassId()),
+		CpuMax:          a.GetCpuMax(),
+		MemMax:          a.GetMemMax(),
+		Distance:        a.GetDistance(),
+		RunnerUpClassId: uint(a.GetRunnerUpClassId()),
+		Confidence:      toFloat64Ptr(a.GetConfidence()),
+		Provisional:     a.GetProvisional(),
+	}
+}
+
+func FromAppPin(pin *server.AppPin) *AppPin {
+	result := &AppPin{
+		App:       FromAppName(pin.AppName),
+		ClassId:   uint32(pin.ClassId),
+		Reason:    pin.Reason,
+		CreatedBy: pin.CreatedBy,
+		CreatedAt: fromTime(pin.CreatedAt),
+	}
+	if pin.Profile != nil {
+		result.Profile = fromSectionDataList(pin.Profile)
+	}
+	return result
+}
+
+// 没有自定义运行特征时Profile为nil，与固定到类别的server.AppPin相同
+func ToAppPin(pin *AppPin) *server.AppPin {
+	result := &server.AppPin{
+		AppName:   ToAppName(pin.GetApp()),
+		ClassId:   uint(pin.GetClassId()),
+		Reason:    pin.GetReason(),
+		CreatedBy: pin.GetCreatedBy(),
+		CreatedAt: toTime(pin.GetCreatedAt()),
+	}
+	if len(pin.GetProfile()) > 0 {
+		result.Profile = toSectionDataList(pin.GetProfile())
+	}
+	return result
+}
+
+func FromClassificationSnapshot(s *server.ClassificationSnapshot) *ClassificationSnapshot {
+	apps := make([]*AppAssignment, len(s.Apps))
+	for i, app := range s.Apps {
+		apps[i] = FromAppAssignment(app)
+	}
+	pins := make([]*AppPin, len(s.Pins))
+	for i, pin := range s.Pins {
+		pins[i] = FromAppPin(pin)
+	}
+	return &ClassificationSnapshot{
+		ResourceVersion: s.ResourceVersion,
+		Centers:         FromClassMetricsList(s.Centers),
+		Apps:            apps,
+		Pins:            pins,
+	}
+}
+
+func ToClassificationSnapshot(s *ClassificationSnapshot) *server.ClassificationSnapshot {
+	apps := make([]*server.AppAssignment, len(s.GetApps()))
+	for i, app := range s.GetApps() {
+		apps[i] = ToAppAssignment(app)
+	}
+	pins := make([]*server.AppPin, len(s.GetPins()))
+	for i, pin := range s.GetPins() {
+		pins[i] = ToAppPin(pin)
+	}
+	return &server.ClassificationSnapshot{
+		ResourceVersion: s.GetResourceVersion(),
+		Centers:         ToClassMetricsList(s.GetCenters()),
+		Apps:            apps,
+		Pins:            pins,
+	}
+}
+
+// ERROR事件不通过gRPC发送，Code与Message被忽略
+func FromClassificationEvent(e *server.ClassificationEvent) *ClassificationEvent {
+	event := &ClassificationEvent{Type: e.Type, Kind: e.Kind, ResourceVersion: e.ResourceVersion}
+	if e.ClassMetrics != nil {
+		event.ClassMetrics = FromClassMetrics(e.ClassMetrics)
+	}
+	if e.AppAssignment != nil {
+		event.AppAssignment = FromAppAssignment(e.AppAssignment)
+	}
+	if e.AppPin != nil {
+		event.AppPin = FromAppPin(e.AppPin)
+	}
+	return event
+}
+
+func ToClassificationEvent(e *ClassificationEvent) *server.ClassificationEvent {
+	event := &server.ClassificationEvent{Type: e.GetType(), Kind: e.GetKind(), ResourceVersion: e.GetResourceVersion()}
+	if e.GetClassMetrics() != nil {
+		event.ClassMetrics = ToClassMetrics(e.GetClassMetrics())
+	}
+	if e.GetAppAssignment() != nil {
+		event.AppAssignment = ToAppAssignment(e.GetAppAssignment())
+	}
+	if e.GetAppPin() != nil {
+		event.AppPin = ToAppPin(e.GetAppPin())
+	}
+	return event
+}
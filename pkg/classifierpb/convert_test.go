@@ -0,0 +1,66 @@
+package classifierpb
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAppProfile_RoundTrip(t *testing.T) {
+	distance := 0.5
+	profile := &server.AppProfile{
+		AppName:     server.AppName{Name: "app", Namespace: "test", Cluster: "east"},
+		SectionData: []*core.SectionData{{CpuAvg: 1, MemP99: 2}, nil},
+		SampleCount: []uint64{10, 0},
+		Coverage:    []float64{1, 0},
+		Classified:  true,
+		ClassId:     3,
+		Distance:    &distance,
+	}
+
+	// 经过序列化，没有数据的Section还原为nil
+	marshal, err := proto.Marshal(FromAppProfile(profile))
+	assert.NoError(t, err)
+	message := &AppProfile{}
+	assert.NoError(t, proto.Unmarshal(marshal, message))
+	assert.Equal(t, profile, ToAppProfile(message))
+}
+
+func TestReClusterSummary_RoundTrip(t *testing.T) {
+	summary := &server.ReClusterSummary{
+		StartedAt:  time.Unix(1600000000, 123),
+		FinishedAt: time.Unix(1600000060, 0),
+		NumApps:    10,
+		Ineligible: map[string]int{server.IneligibleSampleCount: 2},
+		Inertia:    1.5,
+	}
+	marshal, err := proto.Marshal(FromReClusterSummary(summary))
+	assert.NoError(t, err)
+	message := &ReClusterSummary{}
+	assert.NoError(t, proto.Unmarshal(marshal, message))
+	assert.Equal(t, summary, ToReClusterSummary(message))
+
+	// 零值时间不发送
+	schedule := FromReClusterSchedule(&server.ReClusterSchedule{Schedule: "@daily"})
+	assert.Nil(t, schedule.Next)
+	assert.True(t, ToReClusterSchedule(schedule).Next.IsZero())
+}
+
+func TestAppPin_RoundTrip(t *testing.T) {
+	for _, pin := range []*server.AppPin{
+		{AppName: server.AppName{Name: "app", Namespace: "test"}, ClassId: 2, Reason: "固定到类别", CreatedBy: "ops",
+			CreatedAt: time.Unix(1600000000, 0)},
+		{AppName: server.AppName{Name: "app", Namespace: "test", Cluster: "east"}, Reason: "自定义",
+			Profile: []*core.SectionData{{CpuAvg: 1}, {MemP99: 2}}},
+	} {
+		marshal, err := proto.Marshal(FromAppPin(pin))
+		assert.NoError(t, err)
+		message := &AppPin{}
+		assert.NoError(t, proto.Unmarshal(marshal, message))
+		// 固定到类别时Profile保持为nil
+		assert.Equal(t, pin, ToAppPin(message))
+	}
+}
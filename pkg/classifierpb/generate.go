@@ -0,0 +1,7 @@
+// Package classifierpb是负载分类服务器的gRPC接口，classifier.pb.go与classifier_grpc.pb.go由classifier.proto生成，不要手动修改。
+//
+// 修改classifier.proto后在仓库根目录执行make generate重新生成，需要protoc（或兼容protoc命令行的goprotoc）、
+// protoc-gen-go v1.24.0与protoc-gen-go-grpc v0.0.0-20200709232328-d8193ee9cc3e，生成的代码需要与go.mod中的gRPC v1.27兼容。
+package classifierpb
+
+//go:generate protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. classifier.proto
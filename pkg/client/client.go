@@ -1,28 +1,55 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/packagewjx/workload-classifier/pkg/server"
 	"github.com/pkg/errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 )
 
 const defaultApiHostBaseUrl = "http://workload-classifier.workload-classifier"
 
 func NewApiClient() server.API {
-	return &apiClient{}
+	return &apiClient{baseUrl: defaultApiHostBaseUrl, client: http.DefaultClient}
+}
+
+// 使用config中的地址与凭据访问服务器
+func NewApiClientWithConfig(config *ClientConfig) (server.API, error) {
+	client, err := config.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	return &apiClient{baseUrl: config.baseUrl(), client: client, cluster: config.Cluster}, nil
 }
 
 var _ server.API = &apiClient{}
 
 type apiClient struct {
+	baseUrl string
+	client  *http.Client
+	cluster string
+}
+
+// 查询单个应用的地址，应用不在服务器所在的集群时带上cluster参数
+func (a *apiClient) appUrl(resource string, appName server.AppName) string {
+	u := fmt.Sprintf("%s/namespaces/%s/%s/%s", a.baseUrl, appName.Namespace, resource, appName.Name)
+	return u + clusterQuery("?", appName.Cluster)
+}
+
+// cluster为空时返回空字符串
+func clusterQuery(separator, cluster string) string {
+	if cluster == "" {
+		return ""
+	}
+	return separator + "cluster=" + url.QueryEscape(cluster)
 }
 
 func (a *apiClient) QueryAppCharacteristics(appName server.AppName) (*server.AppCharacteristics, error) {
-	response, err := http.Get(fmt.Sprintf("%s/namespaces/%s/appcharacteristics/%s",
-		defaultApiHostBaseUrl, appName.Namespace, appName.Name))
+	response, err := a.client.Get(a.appUrl("appcharacteristics", appName))
 	if err != nil {
 		return nil, errors.Wrap(err, "请求时出现异常")
 	}
@@ -41,6 +68,187 @@ func (a *apiClient) QueryAppCharacteristics(appName server.AppName) (*server.App
 	return dest, nil
 }
 
-func (a *apiClient) ReCluster() {
-	_, _ = http.Get(defaultApiHostBaseUrl + "/recluster")
+func (a *apiClient) QueryAppProfile(appName server.AppName) (*server.AppProfile, error) {
+	response, err := a.client.Get(a.appUrl("appprofile", appName))
+	if err != nil {
+		return nil, errors.Wrap(err, "请求时出现异常")
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取时出现异常")
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return nil, server.ErrAppNotFound
+	} else if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求失败，状态码为%d，响应为%s", response.StatusCode, string(body))
+	}
+
+	dest := &server.AppProfile{}
+	err = json.Unmarshal(body, dest)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("解析json异常，json为\n%s", string(body)))
+	}
+
+	return dest, nil
+}
+
+func (a *apiClient) QueryAppClassHistory(appName server.AppName) ([]*server.AppClassHistory, error) {
+	response, err := a.client.Get(a.appUrl("appclasshistory", appName))
+	if err != nil {
+		return nil, errors.Wrap(err, "请求时出现异常")
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取时出现异常")
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return nil, server.ErrAppNotFound
+	} else if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求失败，状态码为%d，响应为%s", response.StatusCode, string(body))
+	}
+
+	dest := make([]*server.AppClassHistory, 0)
+	err = json.Unmarshal(body, &dest)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("解析json异常，json为\n%s", string(body)))
+	}
+
+	return dest, nil
+}
+
+func (a *apiClient) ReCluster() error {
+	response, err := a.client.Get(a.baseUrl + "/recluster")
+	if err != nil {
+		return errors.Wrap(err, "请求时出现异常")
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return errors.Wrap(err, "读取时出现异常")
+	}
+	switch response.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusServiceUnavailable:
+		return server.ErrNotLeader
+	case http.StatusConflict:
+		return server.ErrReClusterBusy
+	default:
+		return fmt.Errorf("请求失败，状态码为%d，响应为%s", response.StatusCode, string(body))
+	}
+}
+
+func (a *apiClient) QueryReClusterSchedule() (*server.ReClusterSchedule, error) {
+	response, err := a.client.Get(a.baseUrl + "/recluster/schedule")
+	if err != nil {
+		return nil, errors.Wrap(err, "请求时出现异常")
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取时出现异常")
+	}
+
+	dest := &server.ReClusterSchedule{}
+	err = json.Unmarshal(body, dest)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("解析json异常，json为\n%s", string(body)))
+	}
+
+	return dest, nil
+}
+
+func (a *apiClient) QueryReClusterSummary() (*server.ReClusterSummary, error) {
+	response, err := a.client.Get(a.baseUrl + "/recluster/summary")
+	if err != nil {
+		return nil, errors.Wrap(err, "请求时出现异常")
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取时出现异常")
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return nil, server.ErrReClusterNotRun
+	} else if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求失败，状态码为%d，响应为%s", response.StatusCode, string(body))
+	}
+
+	dest := &server.ReClusterSummary{}
+	err = json.Unmarshal(body, dest)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("解析json异常，json为\n%s", string(body)))
+	}
+
+	return dest, nil
+}
+
+func (a *apiClient) ReClusterDryRun(params *server.ReClusterParams) (*server.ReClusterDryRun, error) {
+	marshal, err := json.Marshal(params)
+	if err != nil {
+		return nil, errors.Wrap(err, "序列化问题")
+	}
+	response, err := a.client.Post(a.baseUrl+"/recluster/dryrun", "application/json", bytes.NewReader(marshal))
+	if err != nil {
+		return nil, errors.Wrap(err, "请求时出现异常")
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取时出现异常")
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求失败，状态码为%d，响应为%s", response.StatusCode, string(body))
+	}
+
+	dest := &server.ReClusterDryRun{}
+	err = json.Unmarshal(body, dest)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("解析json异常，json为\n%s", string(body)))
+	}
+
+	return dest, nil
+}
+
+func (a *apiClient) QueryClassificationSnapshot() (*server.ClassificationSnapshot, error) {
+	response, err := a.client.Get(a.baseUrl + "/classification" + clusterQuery("?", a.cluster))
+	if err != nil {
+		return nil, errors.Wrap(err, "请求时出现异常")
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取时出现异常")
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求失败，状态码为%d，响应为%s", response.StatusCode, string(body))
+	}
+
+	dest := &server.ClassificationSnapshot{}
+	err = json.Unmarshal(body, dest)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("解析json异常，json为\n%s", string(body)))
+	}
+
+	return dest, nil
 }
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// 访问服务器的地址与凭据。零值表示使用集群内的默认地址，不使用TLS也不认证
+type ClientConfig struct {
+	BaseUrl string // HTTP API的地址，如https://workload-classifier.workload-classifier:2000。为空则使用集群内的默认地址
+
+	Token     string // bearer token
+	TokenFile string // 每次请求时读取的token文件，适用于会轮换的ServiceAccount token，设置后忽略Token
+
+	TLS                bool   // gRPC连接是否使用TLS，HTTP连接由BaseUrl的协议决定
+	CAFile             string // 验证服务器证书所用的CA证书文件，为空则使用系统的CA
+	InsecureSkipVerify bool   // 不验证服务器证书，仅用于测试
+
+	Cluster string // 查询分类快照与watch时只包含该集群的应用，为空时包含所有集群
+}
+
+func (c *ClientConfig) baseUrl() string {
+	if c.BaseUrl == "" {
+		return defaultApiHostBaseUrl
+	}
+	return strings.TrimSuffix(c.BaseUrl, "/")
+}
+
+// 没有配置token时返回空字符串
+func (c *ClientConfig) token() (string, error) {
+	if c.TokenFile == "" {
+		return c.Token, nil
+	}
+	content, err := ioutil.ReadFile(c.TokenFile)
+	if err != nil {
+		return "", errors.Wrap(err, "读取token文件出错")
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func (c *ClientConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		content, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "读取CA证书文件出错")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("CA证书文件%s中没有PEM格式的证书", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+func (c *ClientConfig) httpClient() (*http.Client, error) {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: &tokenTransport{config: c, base: transport}}, nil
+}
+
+// 在每个请求上添加Authorization头
+type tokenTransport struct {
+	config *ClientConfig
+	base   http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	token, err := t.config.token()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		// RoundTripper不应修改原请求
+		request = request.Clone(request.Context())
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return t.base.RoundTrip(request)
+}
+
+// 为每个gRPC请求添加authorization元数据
+type tokenCredentials struct {
+	config *ClientConfig
+}
+
+func (t *tokenCredentials) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	token, err := t.config.token()
+	if err != nil || token == "" {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// 服务器可能不使用TLS，与HTTP API一致，允许在非加密的连接上发送token
+func (t *tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// 返回按config连接gRPC API所需的选项，如grpc.Dial(client.DefaultGrpcTarget, options...)
+func GrpcDialOptions(config *ClientConfig) ([]grpc.DialOption, error) {
+	options := []grpc.DialOption{grpc.WithPerRPCCredentials(&tokenCredentials{config: config})}
+	if config.TLS {
+		tlsConfig, err := config.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		options = append(options, grpc.WithInsecure())
+	}
+	return options, nil
+}
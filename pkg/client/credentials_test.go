@@ -0,0 +1,59 @@
+package client
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewApiClientWithConfig(t *testing.T) {
+	tokens := make([]string, 0)
+	apiServer := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		tokens = append(tokens, request.Header.Get("Authorization"))
+		_ = json.NewEncoder(writer).Encode(&server.ClassificationSnapshot{ResourceVersion: 1})
+	}))
+	defer apiServer.Close()
+
+	dir, err := ioutil.TempDir("", "client")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	caFile := filepath.Join(dir, "ca.crt")
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(caFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: apiServer.Certificate().Raw}), 0600))
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("token-1\n"), 0600))
+
+	// 每次请求时重新读取token文件
+	api, err := NewApiClientWithConfig(&ClientConfig{BaseUrl: apiServer.URL + "/", TokenFile: tokenFile, CAFile: caFile})
+	assert.NoError(t, err)
+	snapshot, err := api.QueryClassificationSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), snapshot.ResourceVersion)
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("token-2"), 0600))
+	_, err = api.QueryClassificationSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, tokens)
+
+	// 没有token时不发送Authorization头，CA不匹配时无法连接
+	api, err = NewApiClientWithConfig(&ClientConfig{BaseUrl: apiServer.URL, InsecureSkipVerify: true})
+	assert.NoError(t, err)
+	_, err = api.QueryClassificationSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, "", tokens[2])
+	api, err = NewApiClientWithConfig(&ClientConfig{BaseUrl: apiServer.URL})
+	assert.NoError(t, err)
+	_, err = api.QueryClassificationSnapshot()
+	assert.Error(t, err)
+
+	_, err = NewApiClientWithConfig(&ClientConfig{CAFile: tokenFile})
+	assert.Error(t, err)
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"github.com/packagewjx/workload-classifier/pkg/classifierpb"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io"
+)
+
+// 集群内gRPC API的地址，可用于grpc.Dial
+const DefaultGrpcTarget = "workload-classifier.workload-classifier:2001"
+
+// 通过gRPC访问服务器，除server.API外还提供批量查询、查询所有类别与watch
+type GrpcApiClient struct {
+	client  classifierpb.WorkloadClassifierClient
+	cluster string
+}
+
+var _ server.API = &GrpcApiClient{}
+
+// conn由调用者创建与关闭，如grpc.Dial(DefaultGrpcTarget, grpc.WithInsecure())
+func NewGrpcApiClient(conn grpc.ClientConnInterface) *GrpcApiClient {
+	return &GrpcApiClient{client: classifierpb.NewWorkloadClassifierClient(conn)}
+}
+
+// 返回查询分类快照与watch时只包含cluster中的应用的客户端，其他查询不受影响
+func (g *GrpcApiClient) ForCluster(cluster string) *GrpcApiClient {
+	return &GrpcApiClient{client: g.client, cluster: cluster}
+}
+
+// 将gRPC状态转换为server包中对应的错误，NotFound转换为notFound
+func fromGrpcError(err error, notFound error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		if notFound != nil {
+			return notFound
+		}
+	case codes.FailedPrecondition:
+		return server.ErrAppNotClassified
+	case codes.OutOfRange:
+		return server.ErrResourceVersionExpired
+	}
+	return errors.Wrap(err, "请求时出现异常")
+}
+
+func (g *GrpcApiClient) QueryAppCharacteristics(appName server.AppName) (*server.AppCharacteristics, error) {
+	characteristics, err := g.client.QueryAppCharacteristics(context.Background(), classifierpb.FromAppName(appName))
+	if err != nil {
+		return nil, fromGrpcError(err, server.ErrAppNotFound)
+	}
+	return classifierpb.ToAppCharacteristics(characteristics), nil
+}
+
+// 批量查询的单个结果，Err为nil时Characteristics有值
+type BatchQueryResult struct {
+	AppName         server.AppName
+	Characteristics *server.AppCharacteristics
+	Err             error // 与QueryAppCharacteristics返回的错误相同
+}
+
+// 一次请求查询多个应用，结果与appNames的顺序相同
+func (g *GrpcApiClient) BatchQueryAppCharacteristics(ctx context.Context, appNames []server.AppName) ([]*BatchQueryResult, error) {
+	request := &classifierpb.BatchQueryRequest{Apps: make([]*classifierpb.AppName, len(appNames))}
+	for i, appName := range appNames {
+		request.Apps[i] = classifierpb.FromAppName(appName)
+	}
+	response, err := g.client.BatchQueryAppCharacteristics(ctx, request)
+	if err != nil {
+		return nil, fromGrpcError(err, nil)
+	}
+
+	results := make([]*BatchQueryResult, len(response.Results))
+	for i, r := range response.Results {
+		result := &BatchQueryResult{AppName: classifierpb.ToAppName(r.App)}
+		if r.Code != int32(codes.OK) {
+			result.Err = fromGrpcError(status.Error(codes.Code(r.Code), r.Message), server.ErrAppNotFound)
+		} else {
+			result.Characteristics = classifierpb.ToAppCharacteristics(r.Characteristics)
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+func (g *GrpcApiClient) QueryAppProfile(appName server.AppName) (*server.AppProfile, error) {
+	profile, err := g.client.QueryAppProfile(context.Background(), classifierpb.FromAppName(appName))
+	if err != nil {
+		return nil, fromGrpcError(err, server.ErrAppNotFound)
+	}
+	return classifierpb.ToAppProfile(profile), nil
+}
+
+func (g *GrpcApiClient) QueryAppClassHistory(appName server.AppName) ([]*server.AppClassHistory, error) {
+	list, err := g.client.QueryAppClassHistory(context.Background(), classifierpb.FromAppName(appName))
+	if err != nil {
+		return nil, fromGrpcError(err, server.ErrAppNotFound)
+	}
+	history := make([]*server.AppClassHistory, len(list.History))
+	for i, h := range list.History {
+		history[i] = classifierpb.ToAppClassHistory(h)
+	}
+	return history, nil
+}
+
+// 返回所有类别中心，按ClassId排序
+func (g *GrpcApiClient) ListClasses(ctx context.Context) ([]*server.ClassMetrics, error) {
+	list, err := g.client.ListClasses(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, fromGrpcError(err, nil)
+	}
+	return classifierpb.ToClassMetricsList(list.Classes), nil
+}
+
+func (g *GrpcApiClient) ReCluster() error {
+	_, err := g.client.ReCluster(context.Background(), &emptypb.Empty{})
+	switch st := status.Convert(err); {
+	case err == nil:
+		return nil
+	case st.Code() == codes.Unavailable && st.Message() == server.ErrNotLeader.Error():
+		return server.ErrNotLeader
+	case st.Code() == codes.Aborted:
+		return server.ErrReClusterBusy
+	}
+	return fromGrpcError(err, nil)
+}
+
+func (g *GrpcApiClient) QueryReClusterSchedule() (*server.ReClusterSchedule, error) {
+	schedule, err := g.client.QueryReClusterSchedule(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		return nil, fromGrpcError(err, nil)
+	}
+	return classifierpb.ToReClusterSchedule(schedule), nil
+}
+
+func (g *GrpcApiClient) QueryReClusterSummary() (*server.ReClusterSummary, error) {
+	summary, err := g.client.QueryReClusterSummary(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		return nil, fromGrpcError(err, server.ErrReClusterNotRun)
+	}
+	return classifierpb.ToReClusterSummary(summary), nil
+}
+
+func (g *GrpcApiClient) ReClusterDryRun(params *server.ReClusterParams) (*server.ReClusterDryRun, error) {
+	dryRun, err := g.client.ReClusterDryRun(context.Background(), classifierpb.FromReClusterParams(params))
+	if err != nil {
+		return nil, fromGrpcError(err, nil)
+	}
+	return classifierpb.ToReClusterDryRun(dryRun), nil
+}
+
+func (g *GrpcApiClient) QueryClassificationSnapshot() (*server.ClassificationSnapshot, error) {
+	snapshot, err := g.client.QueryClassificationSnapshot(context.Background(),
+		&classifierpb.ClassificationSnapshotRequest{Cluster: g.cluster})
+	if err != nil {
+		return nil, fromGrpcError(err, nil)
+	}
+	return classifierpb.ToClassificationSnapshot(snapshot), nil
+}
+
+// 从resourceVersion开始watch分类变化，对每个事件调用handler，直到服务器结束本次watch、ctx结束或者handler返回错误。
+// resourceVersion为nil时只接收之后的变化。resourceVersion过期时返回server.ErrResourceVersionExpired
+func (g *GrpcApiClient) Watch(ctx context.Context, resourceVersion *uint64, handler func(event *server.ClassificationEvent) error) error {
+	request := &classifierpb.WatchRequest{Cluster: g.cluster}
+	if resourceVersion != nil {
+		request.ResourceVersion = &wrapperspb.UInt64Value{Value: *resourceVersion}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := g.client.Watch(ctx, request)
+	if err != nil {
+		return fromGrpcError(err, nil)
+	}
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fromGrpcError(err, nil)
+		}
+		if err := handler(classifierpb.ToClassificationEvent(event)); err != nil {
+			return err
+		}
+	}
+}
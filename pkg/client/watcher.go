@@ -0,0 +1,339 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	watchRetryInterval    = time.Second // 连接出错后重试前的等待时间，连续出错时加倍
+	maxWatchRetryInterval = time.Minute
+)
+
+// 在本地维护类别中心与应用分类的副本。先获取快照，再从快照的ResourceVersion开始watch，
+// 连接断开后从最新的ResourceVersion继续，ResourceVersion过期时重新获取快照。
+// 副本包含手动固定的分类
+type ClassificationWatcher struct {
+	baseUrl string
+	client  *http.Client
+	cluster string
+
+	lock            sync.RWMutex
+	synced          bool
+	resourceVersion uint64
+	centers         map[uint]*server.ClassMetrics
+	apps            map[server.AppName]*server.AppAssignment
+	pins            map[server.AppName]*server.AppPin
+	lastErr         error
+}
+
+func NewClassificationWatcher() *ClassificationWatcher {
+	return newClassificationWatcher(defaultApiHostBaseUrl)
+}
+
+// 使用config中的地址与凭据访问服务器
+func NewClassificationWatcherWithConfig(config *ClientConfig) (*ClassificationWatcher, error) {
+	client, err := config.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	watcher := newClassificationWatcher(config.baseUrl())
+	watcher.client = client
+	watcher.cluster = config.Cluster
+	return watcher, nil
+}
+
+func newClassificationWatcher(baseUrl string) *ClassificationWatcher {
+	return &ClassificationWatcher{
+		baseUrl: baseUrl,
+		client:  &http.Client{},
+		centers: make(map[uint]*server.ClassMetrics),
+		apps:    make(map[server.AppName]*server.AppAssignment),
+		pins:    make(map[server.AppName]*server.AppPin),
+	}
+}
+
+// 持续同步直到ctx结束
+func (w *ClassificationWatcher) Run(ctx context.Context) {
+	retryInterval := watchRetryInterval
+	needList := true
+	for ctx.Err() == nil {
+		var err error
+		if needList {
+			err = w.list(ctx)
+		}
+		if err == nil {
+			needList = false
+			err = w.watch(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		w.setLastErr(err)
+		if err == nil {
+			// 服务器正常结束了本次watch
+			retryInterval = watchRetryInterval
+			continue
+		} else if err == server.ErrResourceVersionExpired {
+			needList = true
+			continue
+		}
+
+		timer := time.NewTimer(retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		retryInterval *= 2
+		if retryInterval > maxWatchRetryInterval {
+			retryInterval = maxWatchRetryInterval
+		}
+	}
+}
+
+// 是否已经获取过快照
+func (w *ClassificationWatcher) HasSynced() bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.synced
+}
+
+// 副本对应的ResourceVersion，随着变化单调递增
+func (w *ClassificationWatcher) ResourceVersion() uint64 {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.resourceVersion
+}
+
+// 最近一次同步出现的错误，同步正常时为nil
+func (w *ClassificationWatcher) LastError() error {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.lastErr
+}
+
+func (w *ClassificationWatcher) AppAssignment(appName server.AppName) (*server.AppAssignment, bool) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	assignment, ok := w.apps[appName]
+	if !ok {
+		return nil, false
+	}
+	copied := *assignment
+	return &copied, true
+}
+
+// 应用手动固定的分类，没有被固定时返回false
+func (w *ClassificationWatcher) AppPin(appName server.AppName) (*server.AppPin, bool) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	pin, ok := w.pins[appName]
+	if !ok {
+		return nil, false
+	}
+	copied := *pin
+	if pin.Profile != nil {
+		copied.Profile = copyProfile(pin.Profile)
+	}
+	return &copied, true
+}
+
+// 使用副本计算应用的运行特征，语义与server.API的QueryAppCharacteristics相同。
+// 固定的类别已不存在时按应用当前的分类返回，并设置PinStale
+func (w *ClassificationWatcher) QueryAppCharacteristics(appName server.AppName) (*server.AppCharacteristics, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	pin, pinned := w.pins[appName]
+	if pinned && pin.Profile != nil {
+		return pinnedCharacteristics(appName, pin, copyProfile(pin.Profile)), nil
+	}
+	assignment, ok := w.apps[appName]
+	if !ok {
+		return nil, server.ErrAppNotFound
+	}
+	if !pinned {
+		return w.classifiedCharacteristics(appName, assignment)
+	}
+	center, ok := w.centers[pin.ClassId]
+	if !ok {
+		characteristics, err := w.classifiedCharacteristics(appName, assignment)
+		if err != nil {
+			return nil, err
+		}
+		characteristics.PinStale = true
+		return characteristics, nil
+	}
+	return pinnedCharacteristics(appName, pin, server.ScaleClassMetrics(center, assignment.CpuMax, assignment.MemMax)), nil
+}
+
+func pinnedCharacteristics(appName server.AppName, pin *server.AppPin, sectionData []*core.SectionData) *server.AppCharacteristics {
+	confidence := float64(1)
+	return &server.AppCharacteristics{
+		AppName:     appName,
+		SectionData: sectionData,
+		ClassId:     pin.ClassId,
+		Confidence:  &confidence,
+		Pinned:      true,
+	}
+}
+
+func (w *ClassificationWatcher) classifiedCharacteristics(appName server.AppName, assignment *server.AppAssignment) (*server.AppCharacteristics, error) {
+	center, ok := w.centers[assignment.ClassId]
+	if !ok {
+		return nil, server.ErrAppNotClassified
+	}
+	return &server.AppCharacteristics{
+		AppName:         appName,
+		SectionData:     server.ScaleClassMetrics(center, assignment.CpuMax, assignment.MemMax),
+		ClassId:         assignment.ClassId,
+		Distance:        assignment.Distance,
+		RunnerUpClassId: assignment.RunnerUpClassId,
+		Confidence:      assignment.Confidence,
+		Provisional:     assignment.Provisional,
+	}, nil
+}
+
+func (w *ClassificationWatcher) setLastErr(err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.lastErr = err
+}
+
+func copyProfile(profile []*core.SectionData) []*core.SectionData {
+	result := make([]*core.SectionData, len(profile))
+	for i, datum := range profile {
+		copied := *datum
+		result[i] = &copied
+	}
+	return result
+}
+
+// 获取快照并替换副本
+func (w *ClassificationWatcher) list(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, w.baseUrl+"/classification"+clusterQuery("?", w.cluster), nil)
+	if err != nil {
+		return errors.Wrap(err, "创建请求出错")
+	}
+	response, err := w.client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "请求时出现异常")
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return errors.Wrap(err, "读取时出现异常")
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求失败，状态码为%d，响应为%s", response.StatusCode, string(body))
+	}
+
+	snapshot := &server.ClassificationSnapshot{}
+	err = json.Unmarshal(body, snapshot)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("解析json异常，json为\n%s", string(body)))
+	}
+
+	centers := make(map[uint]*server.ClassMetrics, len(snapshot.Centers))
+	for _, center := range snapshot.Centers {
+		centers[center.ClassId] = center
+	}
+	apps := make(map[server.AppName]*server.AppAssignment, len(snapshot.Apps))
+	for _, app := range snapshot.Apps {
+		apps[app.AppName] = app
+	}
+	pins := make(map[server.AppName]*server.AppPin, len(snapshot.Pins))
+	for _, pin := range snapshot.Pins {
+		pins[pin.AppName] = pin
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.centers = centers
+	w.apps = apps
+	w.pins = pins
+	w.resourceVersion = snapshot.ResourceVersion
+	w.synced = true
+	return nil
+}
+
+// 从当前的ResourceVersion开始watch，直到连接结束
+func (w *ClassificationWatcher) watch(ctx context.Context) error {
+	url := fmt.Sprintf("%s/classification/watch?resourceVersion=%d%s", w.baseUrl, w.ResourceVersion(),
+		clusterQuery("&", w.cluster))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "创建请求出错")
+	}
+	response, err := w.client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "请求时出现异常")
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode == http.StatusGone {
+		return server.ErrResourceVersionExpired
+	} else if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("请求失败，状态码为%d，响应为%s", response.StatusCode, string(body))
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		event := &server.ClassificationEvent{}
+		err := decoder.Decode(event)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "读取watch事件出错")
+		}
+		if event.Type == server.EventTypeError {
+			if event.Code == http.StatusGone {
+				return server.ErrResourceVersionExpired
+			}
+			return fmt.Errorf("watch出错，状态码为%d：%s", event.Code, event.Message)
+		}
+		w.apply(event)
+	}
+}
+
+func (w *ClassificationWatcher) apply(event *server.ClassificationEvent) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	switch {
+	case event.Kind == server.EventKindClassMetrics && event.ClassMetrics != nil:
+		if event.Type == server.EventTypeDeleted {
+			delete(w.centers, event.ClassMetrics.ClassId)
+		} else {
+			w.centers[event.ClassMetrics.ClassId] = event.ClassMetrics
+		}
+	case event.Kind == server.EventKindAppAssignment && event.AppAssignment != nil:
+		if event.Type == server.EventTypeDeleted {
+			delete(w.apps, event.AppAssignment.AppName)
+		} else {
+			w.apps[event.AppAssignment.AppName] = event.AppAssignment
+		}
+	case event.Kind == server.EventKindAppPin && event.AppPin != nil:
+		if event.Type == server.EventTypeDeleted {
+			delete(w.pins, event.AppPin.AppName)
+		} else {
+			w.pins[event.AppPin.AppName] = event.AppPin
+		}
+	}
+	w.resourceVersion = event.ResourceVersion
+}
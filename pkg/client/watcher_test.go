@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/packagewjx/workload-classifier/pkg/core"
+	"github.com/packagewjx/workload-classifier/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testCenter(classId uint, value float32) *server.ClassMetrics {
+	center := &server.ClassMetrics{ClassId: classId, Data: make([]*core.SectionData, core.NumSections)}
+	for i := range center.Data {
+		center.Data[i] = &core.SectionData{CpuAvg: value, MemAvg: value}
+	}
+	return center
+}
+
+func TestClassificationWatcher(t *testing.T) {
+	app := server.AppName{Name: "app", Namespace: "test"}
+	pinned := server.AppName{Name: "pinned", Namespace: "test"}
+	lock := sync.Mutex{}
+	lists := 0
+	watchVersions := make([]string, 0)
+	handler := http.NewServeMux()
+	handler.HandleFunc("/classification", func(writer http.ResponseWriter, request *http.Request) {
+		lock.Lock()
+		lists++
+		version := uint64(2)
+		if lists > 1 {
+			version = 10
+		}
+		lock.Unlock()
+		_ = json.NewEncoder(writer).Encode(&server.ClassificationSnapshot{
+			ResourceVersion: version,
+			Centers:         []*server.ClassMetrics{testCenter(1, 1)},
+			Apps:            []*server.AppAssignment{{AppName: app, ClassId: 1, CpuMax: 2, MemMax: 4}},
+			Pins:            []*server.AppPin{{AppName: pinned, ClassId: 1, Reason: "test"}},
+		})
+	})
+	handler.HandleFunc("/classification/watch", func(writer http.ResponseWriter, request *http.Request) {
+		version := request.URL.Query().Get("resourceVersion")
+		lock.Lock()
+		watchVersions = append(watchVersions, version)
+		lock.Unlock()
+		switch version {
+		case "2":
+			encoder := json.NewEncoder(writer)
+			_ = encoder.Encode(&server.ClassificationEvent{Type: server.EventTypeModified, Kind: server.EventKindClassMetrics,
+				ResourceVersion: 3, ClassMetrics: testCenter(2, 0.5)})
+			_ = encoder.Encode(&server.ClassificationEvent{Type: server.EventTypeModified, Kind: server.EventKindAppAssignment,
+				ResourceVersion: 4, AppAssignment: &server.AppAssignment{AppName: app, ClassId: 2, CpuMax: 2, MemMax: 4}})
+			_ = encoder.Encode(&server.ClassificationEvent{Type: server.EventTypeDeleted, Kind: server.EventKindClassMetrics,
+				ResourceVersion: 5, ClassMetrics: &server.ClassMetrics{ClassId: 1}})
+			_ = encoder.Encode(&server.ClassificationEvent{Type: server.EventTypeBookmark, ResourceVersion: 6})
+		case "6":
+			http.Error(writer, server.ErrResourceVersionExpired.Error(), http.StatusGone)
+		default:
+			<-request.Context().Done()
+		}
+	})
+	apiServer := httptest.NewServer(handler)
+	defer apiServer.Close()
+
+	watcher := newClassificationWatcher(apiServer.URL)
+	assert.False(t, watcher.HasSynced())
+	_, err := watcher.QueryAppCharacteristics(app)
+	assert.Equal(t, server.ErrAppNotFound, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.Run(ctx)
+	}()
+
+	// 依次应用事件，版本过期后重新获取快照
+	assert.Eventually(t, func() bool {
+		return watcher.ResourceVersion() == 10
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, watcher.HasSynced())
+	lock.Lock()
+	assert.Equal(t, 2, lists)
+	assert.Equal(t, []string{"2", "6"}, watchVersions[:2])
+	lock.Unlock()
+
+	characteristics, err := watcher.QueryAppCharacteristics(app)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), characteristics.ClassId)
+	assert.Equal(t, float32(2), characteristics.SectionData[0].CpuAvg)
+	assert.Equal(t, float32(4), characteristics.SectionData[0].MemAvg)
+	_, ok := watcher.AppPin(pinned)
+	assert.True(t, ok)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "watcher没有退出")
+	}
+}
+
+func TestClassificationWatcher_Apply(t *testing.T) {
+	app := server.AppName{Name: "app", Namespace: "test"}
+	watcher := newClassificationWatcher("")
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeModified, Kind: server.EventKindClassMetrics,
+		ResourceVersion: 1, ClassMetrics: testCenter(1, 1)})
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeModified, Kind: server.EventKindAppAssignment,
+		ResourceVersion: 2, AppAssignment: &server.AppAssignment{AppName: app, ClassId: 1, CpuMax: 3, MemMax: 1}})
+
+	characteristics, err := watcher.QueryAppCharacteristics(app)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(3), characteristics.SectionData[0].CpuAvg)
+	assignment, ok := watcher.AppAssignment(app)
+	assert.True(t, ok)
+	assert.Equal(t, uint(1), assignment.ClassId)
+
+	// 类别被删除后应用视为未分类
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeDeleted, Kind: server.EventKindClassMetrics,
+		ResourceVersion: 3, ClassMetrics: &server.ClassMetrics{ClassId: 1}})
+	_, err = watcher.QueryAppCharacteristics(app)
+	assert.Equal(t, server.ErrAppNotClassified, err)
+	assert.Equal(t, uint64(3), watcher.ResourceVersion())
+	_, ok = watcher.AppAssignment(server.AppName{Name: "other", Namespace: "test"})
+	assert.False(t, ok)
+}
+
+func TestClassificationWatcher_Pins(t *testing.T) {
+	app := server.AppName{Name: "app", Namespace: "test"}
+	custom := server.AppName{Name: "custom", Namespace: "test"}
+	watcher := newClassificationWatcher("")
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeModified, Kind: server.EventKindClassMetrics,
+		ResourceVersion: 1, ClassMetrics: testCenter(1, 1)})
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeModified, Kind: server.EventKindClassMetrics,
+		ResourceVersion: 2, ClassMetrics: testCenter(2, 0.5)})
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeModified, Kind: server.EventKindAppAssignment,
+		ResourceVersion: 3, AppAssignment: &server.AppAssignment{AppName: app, ClassId: 1, CpuMax: 2, MemMax: 4}})
+
+	// 固定到类别时按应用的CpuMax与MemMax换算固定的类别中心
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeModified, Kind: server.EventKindAppPin,
+		ResourceVersion: 4, AppPin: &server.AppPin{AppName: app, ClassId: 2, Reason: "test"}})
+	characteristics, err := watcher.QueryAppCharacteristics(app)
+	assert.NoError(t, err)
+	assert.True(t, characteristics.Pinned)
+	assert.False(t, characteristics.PinStale)
+	assert.Equal(t, uint(2), characteristics.ClassId)
+	assert.Equal(t, float32(1), characteristics.SectionData[0].CpuAvg)
+	assert.Equal(t, float64(1), *characteristics.Confidence)
+	pin, ok := watcher.AppPin(app)
+	assert.True(t, ok)
+	assert.Equal(t, "test", pin.Reason)
+
+	// 固定的类别被删除后按当前分类返回
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeDeleted, Kind: server.EventKindClassMetrics,
+		ResourceVersion: 5, ClassMetrics: &server.ClassMetrics{ClassId: 2}})
+	characteristics, err = watcher.QueryAppCharacteristics(app)
+	assert.NoError(t, err)
+	assert.False(t, characteristics.Pinned)
+	assert.True(t, characteristics.PinStale)
+	assert.Equal(t, uint(1), characteristics.ClassId)
+	assert.Equal(t, float32(2), characteristics.SectionData[0].CpuAvg)
+
+	// 自定义运行特征不需要应用已被分类
+	profile := testCenter(0, 7).Data
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeModified, Kind: server.EventKindAppPin,
+		ResourceVersion: 6, AppPin: &server.AppPin{AppName: custom, Profile: profile, Reason: "test"}})
+	characteristics, err = watcher.QueryAppCharacteristics(custom)
+	assert.NoError(t, err)
+	assert.True(t, characteristics.Pinned)
+	assert.Equal(t, uint(0), characteristics.ClassId)
+	assert.Equal(t, profile, characteristics.SectionData)
+
+	// 取消固定后恢复为聚类结果
+	watcher.apply(&server.ClassificationEvent{Type: server.EventTypeDeleted, Kind: server.EventKindAppPin,
+		ResourceVersion: 7, AppPin: &server.AppPin{AppName: app}})
+	characteristics, err = watcher.QueryAppCharacteristics(app)
+	assert.NoError(t, err)
+	assert.False(t, characteristics.Pinned)
+	assert.False(t, characteristics.PinStale)
+	_, ok = watcher.AppPin(app)
+	assert.False(t, ok)
+	assert.Equal(t, uint64(7), watcher.ResourceVersion())
+}
@@ -10,6 +10,7 @@ import (
 	server2 "github.com/packagewjx/workload-classifier/pkg/server"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
 	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
 	"time"
 )
@@ -19,10 +20,22 @@ var _ framework.ScorePlugin = &featureAwarePlugin{}
 
 const PluginName = "FeatureAware"
 
+// 分类置信度低于此值时，应用的运行特征不可靠，不使用其空闲资源
+const MinConfidence = 0.3
+
+// 插件参数。应用识别策略应与负载分类服务器的app-identity与app-identity-key一致
+type Args struct {
+	AppIdentity    string `json:"appIdentity,omitempty"`
+	AppIdentityKey string `json:"appIdentityKey,omitempty"`
+	Cluster        string `json:"cluster,omitempty"` // 调度器所在集群在负载分类服务器中的名称，为空表示服务器所在的集群
+}
+
 type featureAwarePlugin struct {
 	handle        framework.FrameworkHandle
 	client        server2.API
 	metricsClient metricsclient.Client
+	identifier    server.AppIdentifier
+	cluster       string
 }
 
 func (f *featureAwarePlugin) Score(_ context.Context, _ *framework.CycleState, _ *corev1.Pod, nodeName string) (int64, *framework.Status) {
@@ -44,11 +57,21 @@ func (f *featureAwarePlugin) ScoreExtensions() framework.ScoreExtensions {
 	return nil
 }
 
-func New(_ runtime.Object, handle framework.FrameworkHandle) (framework.Plugin, error) {
+func New(configuration runtime.Object, handle framework.FrameworkHandle) (framework.Plugin, error) {
+	args := &Args{}
+	if err := frameworkruntime.DecodeInto(configuration, args); err != nil {
+		return nil, err
+	}
+	identifier, err := server.NewAppIdentifier(args.AppIdentity, args.AppIdentityKey)
+	if err != nil {
+		return nil, err
+	}
 	return &featureAwarePlugin{
 		client:        client.NewApiClient(),
 		handle:        handle,
 		metricsClient: metricsclient.NewHttpMetricsClient(metricsclient.DefaultKubeApiServerBaseUrl),
+		identifier:    identifier,
+		cluster:       args.Cluster,
 	}, nil
 }
 
@@ -72,26 +95,28 @@ func (f *featureAwarePlugin) Filter(_ context.Context, _ *framework.CycleState,
 				continue
 			}
 
-			appName := p.Name
-			for _, reference := range p.OwnerReferences {
-				switch reference.Kind {
-				case server.KindDaemonSet, server.KindStatefulSet, server.KindReplicaSet:
-					appName = reference.Name
-				default:
-					continue
-				}
-				break
+			appName, ok := f.identifier.Identify(p)
+			if !ok {
+				appName = server2.AppName{Name: p.Name, Namespace: p.Namespace}
 			}
+			appName.Cluster = f.cluster
 
-			characteristics, err := f.client.QueryAppCharacteristics(server2.AppName{
-				Name:      appName,
-				Namespace: p.Namespace,
-			})
+			characteristics, err := f.client.QueryAppCharacteristics(appName)
 			if err != nil {
 				fmt.Printf("获取%s名称空间的%s的Pod的分类信息失败：%v\n", p.Namespace, p.Name, err)
 				continue
 			}
 
+			if characteristics.Provisional {
+				fmt.Printf("%s名称空间的%s的Pod的数据不足，分类是暂定的，不使用其空闲资源\n", p.Namespace, p.Name)
+				continue
+			}
+			if characteristics.Confidence != nil && *characteristics.Confidence < MinConfidence {
+				fmt.Printf("%s名称空间的%s的Pod的分类置信度为%f，低于%f，不使用其空闲资源\n",
+					p.Namespace, p.Name, *characteristics.Confidence, MinConfidence)
+				continue
+			}
+
 			cpu, mem := podTotalRequest(p)
 
 			// 可能没有赋值，此时将会默认为节点的总值
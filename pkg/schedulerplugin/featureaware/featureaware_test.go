@@ -11,6 +11,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -163,6 +164,70 @@ func TestFilterWithIdleResource(t *testing.T) {
 	println(result.Message())
 }
 
+func TestFilterWithLowConfidence(t *testing.T) {
+	requestList := []requirement{
+		{cpu: 1, mem: 1000},
+		{cpu: 1, mem: 300},
+		{cpu: 1, mem: 200},
+	}
+	actualUseList := []requirement{
+		{cpu: 0.5, mem: 800},
+		{cpu: 0.2, mem: 200},
+		{cpu: 0.8, mem: 200},
+	}
+	requirementMap := make(map[string]requirement)
+	nodePods := makePods(requestList)
+	for i := 0; i < len(nodePods); i++ {
+		requirementMap[nodePods[i].Pod.Name] = actualUseList[i]
+	}
+
+	// 空闲资源足够时可以调度
+	schedulePod := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "to-be-schedule",
+			Namespace: namespaceTest,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    *resource.NewQuantity(2, resource.DecimalSI),
+							corev1.ResourceMemory: *resource.NewQuantity(800, resource.BinarySI),
+						},
+					},
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			QOSClass: corev1.PodQOSBestEffort,
+		},
+	}
+	nodeInfo := makeNodeInfo(nodePods, nodeCpuCapacity, nodeMemCapacity)
+
+	plugin, _ := New(nil, nil)
+	featureAware := plugin.(*featureAwarePlugin)
+	api := &fakeApi{requirementMap: requirementMap, confidenceMap: map[string]float64{
+		nodePods[0].Pod.Name: 0.9,
+		nodePods[1].Pod.Name: 0.9,
+		nodePods[2].Pod.Name: 0.9,
+	}}
+	featureAware.client = api
+	result := featureAware.Filter(context.Background(), framework.NewCycleState(), schedulePod, nodeInfo)
+	assert.Equal(t, framework.Success, result.Code())
+
+	// 第一个应用的置信度过低，不使用其空闲的内存，内存不足
+	api.confidenceMap[nodePods[0].Pod.Name] = MinConfidence / 2
+	result = featureAware.Filter(context.Background(), framework.NewCycleState(), schedulePod, nodeInfo)
+	assert.Equal(t, framework.Unschedulable, result.Code())
+
+	// 暂定分类同样不使用其空闲资源
+	api.confidenceMap[nodePods[0].Pod.Name] = 0.9
+	api.provisionalSet = map[string]bool{nodePods[0].Pod.Name: true}
+	result = featureAware.Filter(context.Background(), framework.NewCycleState(), schedulePod, nodeInfo)
+	assert.Equal(t, framework.Unschedulable, result.Code())
+}
+
 func makePods(requestList []requirement) []*framework.PodInfo {
 	nodePods := make([]*framework.PodInfo, 0, len(requestList))
 	for i, s := range requestList {
@@ -293,6 +358,8 @@ type requirement struct {
 
 type fakeApi struct {
 	requirementMap map[string]requirement
+	confidenceMap  map[string]float64
+	provisionalSet map[string]bool
 }
 
 func (f *fakeApi) QueryAppCharacteristics(appName server2.AppName) (*server2.AppCharacteristics, error) {
@@ -314,13 +381,42 @@ func (f *fakeApi) QueryAppCharacteristics(appName server2.AppName) (*server2.App
 			MemP99: req.mem,
 		}
 	}
-	return &server2.AppCharacteristics{
+	result := &server2.AppCharacteristics{
 		AppName:     appName,
 		SectionData: sectionData,
-	}, nil
+	}
+	if confidence, ok := f.confidenceMap[appName.Name]; ok {
+		result.Confidence = &confidence
+	}
+	result.Provisional = f.provisionalSet[appName.Name]
+	return result, nil
+}
+
+func (f *fakeApi) QueryAppProfile(appName server2.AppName) (*server2.AppProfile, error) {
+	return nil, server2.ErrAppNotFound
 }
 
-func (f *fakeApi) ReCluster() {
+func (f *fakeApi) ReCluster() error {
+	panic("implement me")
+}
+
+func (f *fakeApi) QueryReClusterSchedule() (*server2.ReClusterSchedule, error) {
+	panic("implement me")
+}
+
+func (f *fakeApi) QueryReClusterSummary() (*server2.ReClusterSummary, error) {
+	panic("implement me")
+}
+
+func (f *fakeApi) QueryAppClassHistory(appName server2.AppName) ([]*server2.AppClassHistory, error) {
+	panic("implement me")
+}
+
+func (f *fakeApi) ReClusterDryRun(params *server2.ReClusterParams) (*server2.ReClusterDryRun, error) {
+	panic("implement me")
+}
+
+func (f *fakeApi) QueryClassificationSnapshot() (*server2.ClassificationSnapshot, error) {
 	panic("implement me")
 }
 
@@ -403,3 +499,20 @@ func (f *fakeNodeInfoLister) Get(nodeName string) (*framework.NodeInfo, error) {
 	res.SetNode(f.node)
 	return res, nil
 }
+
+func TestNewWithArgs(t *testing.T) {
+	plugin, err := New(&runtime.Unknown{Raw: []byte(`{"appIdentity":"label","appIdentityKey":"app.kubernetes.io/name","cluster":"east"}`)}, nil)
+	if assert.NoError(t, err) {
+		appName, ok := plugin.(*featureAwarePlugin).identifier.Identify(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      "shop-frontend-1",
+			Namespace: namespaceTest,
+			Labels:    map[string]string{"app.kubernetes.io/name": "shop"},
+		}})
+		assert.True(t, ok)
+		assert.Equal(t, server2.AppName{Name: "shop", Namespace: namespaceTest}, appName)
+		assert.Equal(t, "east", plugin.(*featureAwarePlugin).cluster)
+	}
+
+	_, err = New(&runtime.Unknown{Raw: []byte(`{"appIdentity":"label"}`)}, nil)
+	assert.Error(t, err)
+}
@@ -3,7 +3,9 @@ package server
 import (
 	"fmt"
 	"github.com/packagewjx/workload-classifier/pkg/core"
+	"reflect"
 	"strings"
+	"time"
 )
 
 type AppPodMetrics struct {
@@ -18,6 +20,12 @@ type AppClass struct {
 	ClassId uint
 	CpuMax  float32 // 本应用CPU最大值。由于类数据是标准化后的数据，无法得知实际使用了多少CPU。CPU最大值代表类数据为1的时候的实际使用量
 	MemMax  float32 // 本应用内存最大值
+
+	Distance         float64  // 预处理后的数据与所属类别中心的欧氏距离
+	RunnerUpClassId  uint     // 除所属类别外距离最近的类别，只有一个类别时为0
+	RunnerUpDistance float64  // 与RunnerUpClassId类别中心的欧氏距离
+	Confidence       *float64 // 分类置信度，范围为0到1，越大表示越靠近所属类别而远离其他类别。旧版本保存的分类没有此数据，为nil
+	Provisional      bool     // 数据不足，没有参与计算类别中心，只是暂时分到最近的类别。此时置信度为0
 }
 
 type ClassMetrics struct {
@@ -28,9 +36,14 @@ type ClassMetrics struct {
 type AppName struct {
 	Name      string `gorm:"uniqueIndex:app;type:VARCHAR(256)"`
 	Namespace string `gorm:"uniqueIndex:app;type:VARCHAR(256)"`
+	// 应用所在的集群，为空表示服务器所在的集群。不同集群的应用共用类别中心
+	Cluster string `json:",omitempty" gorm:"uniqueIndex:app;type:VARCHAR(64);not null;default:''"`
 }
 
 func (name AppName) ContainerId() string {
+	if name.Cluster != "" {
+		return name.Cluster + NamespaceSplit + name.Namespace + NamespaceSplit + name.Name
+	}
 	return name.Namespace + NamespaceSplit + name.Name
 }
 
@@ -38,12 +51,30 @@ const NamespaceSplit = "::"
 
 func AppNameFromContainerId(containerId string) AppName {
 	split := strings.Split(containerId, NamespaceSplit)
+	if len(split) == 3 {
+		return AppName{
+			Name:      split[2],
+			Namespace: split[1],
+			Cluster:   split[0],
+		}
+	}
 	return AppName{
 		Name:      split[1],
 		Namespace: split[0],
 	}
 }
 
+// 依次按集群、名称空间与名称比较
+func (name AppName) Less(other AppName) bool {
+	if name.Cluster != other.Cluster {
+		return name.Cluster < other.Cluster
+	}
+	if name.Namespace != other.Namespace {
+		return name.Namespace < other.Namespace
+	}
+	return name.Name < other.Name
+}
+
 var ErrAppNotFound = fmt.Errorf("不存在本应用")
 
 var ErrAppNotClassified = fmt.Errorf("尚未对App分类")
@@ -52,10 +83,256 @@ type AppCharacteristics struct {
 	AppName `json:",inline"`
 
 	SectionData []*core.SectionData `json:"sectionData"`
+
+	ClassId         uint     `json:"classId"`
+	Distance        float64  `json:"distance"`
+	RunnerUpClassId uint     `json:"runnerUpClassId,omitempty"`
+	Confidence      *float64 `json:"confidence,omitempty"`  // 分类置信度，为空表示分类结果没有置信度数据
+	Provisional     bool     `json:"provisional,omitempty"` // 应用数据不足，分类是暂定的
+	Pinned          bool     `json:"pinned,omitempty"`      // 分类由运维人员手动固定，不受再聚类影响
+	PinStale        bool     `json:"pinStale,omitempty"`    // 应用固定的类别已不存在，固定分类没有生效，结果为应用当前的分类
+}
+
+// 运维人员手动固定的应用分类，优先于聚类结果，再聚类不会修改
+type AppPin struct {
+	AppName `json:",inline"`
+
+	ClassId   uint                `json:"classId,omitempty"` // 固定到的类别，与Profile二选一
+	Profile   []*core.SectionData `json:"profile,omitempty"` // 自定义的运行特征，单位与AppCharacteristics相同，长度为core.NumSections
+	Reason    string              `json:"reason"`
+	CreatedBy string              `json:"createdBy"`
+	CreatedAt time.Time           `json:"createdAt"`
+}
+
+// 固定分类的操作
+const (
+	PinActionPin   = "pin"
+	PinActionUnpin = "unpin"
+)
+
+// 固定分类的审计记录
+type AppPinAudit struct {
+	AppName `json:",inline"`
+
+	Action  string    `json:"action"`            // PinActionPin或PinActionUnpin
+	ClassId uint      `json:"classId,omitempty"` // 固定到的类别，固定为自定义运行特征或取消固定时为0
+	Custom  bool      `json:"custom,omitempty"`  // 是否固定为自定义运行特征
+	Reason  string    `json:"reason"`
+	Actor   string    `json:"actor"`
+	Time    time.Time `json:"time"`
+}
+
+var ErrAppNotPinned = fmt.Errorf("应用的分类没有被固定")
+
+var ErrClassNotFound = fmt.Errorf("类别不存在")
+
+// 应用自身的运行画像，由保留时间内的监控数据计算得到，用于排查分类问题
+type AppProfile struct {
+	AppName `json:",inline"`
+
+	SectionData []*core.SectionData `json:"sectionData"` // 各Section的实测数据，没有数据的Section为null
+	SampleCount []uint64            `json:"sampleCount"` // 各Section的样本数量
+	Coverage    []float64           `json:"coverage"`    // 各Section的样本数量占按获取间隔应有数量的比例，最大为1
+
+	Classified bool     `json:"classified"`
+	ClassId    uint     `json:"classId,omitempty"`
+	Distance   *float64 `json:"distance,omitempty"` // 预处理后的画像与所属类别中心的欧氏距离。未分类或没有数据时为空
+}
+
+type ReClusterSchedule struct {
+	Schedule string    `json:"schedule"` // 再聚类调度计划的描述，为cron表达式或者@every形式的固定间隔
+	TimeZone string    `json:"timeZone"`
+	Next     time.Time `json:"next"` // 下一次计划执行再聚类的时间。若尚未计算出，则为零值
+}
+
+// 应用数据不足、不参与计算类别中心的原因
+const (
+	IneligibleObservedDays    = "observedDays"    // 有数据的天数不足
+	IneligibleSectionCoverage = "sectionCoverage" // 有数据的Section比例不足
+	IneligibleSampleCount     = "sampleCount"     // 样本数量不足
+)
+
+// 一次再聚类的概况
+type ReClusterSummary struct {
+	StartedAt    time.Time      `json:"startedAt"`
+	FinishedAt   time.Time      `json:"finishedAt"`
+	NumApps      int            `json:"numApps"`      // 保留时间内有数据的应用数量
+	NumClustered int            `json:"numClustered"` // 参与计算类别中心的应用数量，其余应用只暂定分类
+	Ineligible   map[string]int `json:"ineligible"`   // 按原因统计的不参与计算类别中心的应用数量，键为Ineligible开头的常量
+	Inertia      float64        `json:"inertia"`      // 参与计算的应用到所属类别中心距离的平方和
+	MeanDistance float64        `json:"meanDistance"` // 参与计算的应用到所属类别中心的平均距离
+	NumChanged   int            `json:"numChanged"`   // 分类发生变化的应用数量，不包括首次分类的应用
+}
+
+var ErrReClusterNotRun = fmt.Errorf("本实例尚未完成过再聚类")
+
+// 再聚类只能由leader执行，请求发送到非leader的实例时返回
+var ErrNotLeader = fmt.Errorf("本实例不是leader，无法执行再聚类")
+
+// leader正在再聚类时不接受新的再聚类请求
+var ErrReClusterBusy = fmt.Errorf("正在再聚类，请稍后再试")
+
+// 应用分类的一次变化，由再聚类写入，只追加不修改
+type AppClassHistory struct {
+	AppName `json:",inline"`
+
+	ClassId         uint      `json:"classId"`
+	PreviousClassId uint      `json:"previousClassId"` // 变化前的类别，首次分类时为0
+	Distance        float64   `json:"distance"`
+	Confidence      *float64  `json:"confidence,omitempty"`
+	Provisional     bool      `json:"provisional,omitempty"`
+	Time            time.Time `json:"time"`
+}
+
+// 再聚类后发送到webhook的通知，只包含分类发生变化的应用
+type ClassChangeNotification struct {
+	ReClusterTime time.Time          `json:"reClusterTime"`
+	Changes       []*AppClassHistory `json:"changes"`
 }
 
+// 试运行再聚类的参数，为0时使用服务器的配置
+type ReClusterParams struct {
+	NumClass uint `json:"numClass,omitempty"`
+	NumRound uint `json:"numRound,omitempty"`
+}
+
+// 提议的类别中心相对当前类别中心的移动。提议的类别与当前类别按中心距离一一对应，距离最近的优先
+type CenterShift struct {
+	ClassId        uint    `json:"classId"`                  // 提议的类别
+	MatchedClassId uint    `json:"matchedClassId,omitempty"` // 对应的当前类别，为0表示新增的类别
+	Distance       float64 `json:"distance"`                 // 与对应的当前类别中心的欧氏距离，新增的类别为0
+	NumApps        int     `json:"numApps"`                  // 提议分到此类别的应用数量
+}
+
+// 试运行中分类会发生变化的应用
+type AppClassChange struct {
+	AppName `json:",inline"`
+
+	CurrentClassId  uint `json:"currentClassId"`
+	ProposedClassId uint `json:"proposedClassId"`
+	MatchedClassId  uint `json:"matchedClassId,omitempty"` // 提议的类别对应的当前类别
+	Provisional     bool `json:"provisional,omitempty"`    // 提议的分类是暂定的
+	Pinned          bool `json:"pinned,omitempty"`         // 应用已被固定分类，实际查询结果不受影响
+}
+
+// 试运行再聚类的结果，数据库不会被修改
+type ReClusterDryRun struct {
+	Params         ReClusterParams   `json:"params"`  // 实际使用的参数
+	Summary        *ReClusterSummary `json:"summary"` // 聚类质量
+	Centers        []*ClassMetrics   `json:"centers"` // 提议的类别中心
+	CenterShifts   []*CenterShift    `json:"centerShifts"`
+	RemovedClasses []uint            `json:"removedClasses"` // 没有对应的提议类别、将被删除的当前类别
+	Changes        []*AppClassChange `json:"changes"`        // 分类会发生变化的应用，按集群、名称空间与名称排序
+	NumUnchanged   int               `json:"numUnchanged"`   // 分类不变的应用数量
+	NumNewApps     int               `json:"numNewApps"`     // 当前尚未分类的应用数量
+}
+
+// 按应用的CPU与内存最大值还原标准化的类别数据，得到应用的运行特征
+func ScaleClassMetrics(metric *ClassMetrics, cpuMax, memMax float32) []*core.SectionData {
+	result := make([]*core.SectionData, len(metric.Data))
+	typ := reflect.TypeOf(core.SectionData{})
+	for i, datum := range metric.Data {
+		classVal := reflect.ValueOf(datum).Elem()
+		sectionData := &core.SectionData{}
+		appVal := reflect.ValueOf(sectionData).Elem()
+
+		for fi := 0; fi < classVal.NumField(); fi++ {
+			field := typ.Field(fi)
+			if field.Name[:3] == "Cpu" {
+				appVal.FieldByName(field.Name).SetFloat(classVal.FieldByName(field.Name).Float() * float64(cpuMax))
+			} else /*Mem*/ {
+				appVal.FieldByName(field.Name).SetFloat(classVal.FieldByName(field.Name).Float() * float64(memMax))
+			}
+		}
+
+		result[i] = sectionData
+	}
+	return result
+}
+
+// 应用当前的分类，是watch推送的应用分类对象
+type AppAssignment struct {
+	AppName `json:",inline"`
+
+	ClassId         uint     `json:"classId"`
+	CpuMax          float32  `json:"cpuMax"`
+	MemMax          float32  `json:"memMax"`
+	Distance        float64  `json:"distance"`
+	RunnerUpClassId uint     `json:"runnerUpClassId,omitempty"`
+	Confidence      *float64 `json:"confidence,omitempty"`
+	Provisional     bool     `json:"provisional,omitempty"`
+}
+
+func NewAppAssignment(a *AppClass) *AppAssignment {
+	return &AppAssignment{
+		AppName:         a.AppName,
+		ClassId:         a.ClassId,
+		CpuMax:          a.CpuMax,
+		MemMax:          a.MemMax,
+		Distance:        a.Distance,
+		RunnerUpClassId: a.RunnerUpClassId,
+		Confidence:      a.Confidence,
+		Provisional:     a.Provisional,
+	}
+}
+
+// 某一版本的全部类别中心与应用分类。ResourceVersion之后的变化可以通过watch获取
+type ClassificationSnapshot struct {
+	ResourceVersion uint64           `json:"resourceVersion"`
+	Centers         []*ClassMetrics  `json:"centers"` // 按ClassId排序
+	Apps            []*AppAssignment `json:"apps"`    // 按应用的集群、名称空间与名称排序
+	Pins            []*AppPin        `json:"pins"`    // 手动固定的分类，按应用的集群、名称空间与名称排序
+}
+
+// watch事件的类型，与Kubernetes的watch相同
+const (
+	EventTypeModified = "MODIFIED" // 对象被创建或更新
+	EventTypeDeleted  = "DELETED"
+	EventTypeBookmark = "BOOKMARK" // 没有数据变化，只告知最新的ResourceVersion，同时用于保持连接
+	EventTypeError    = "ERROR"    // watch无法继续，连接随后关闭
+)
+
+// watch事件中对象的种类
+const (
+	EventKindClassMetrics  = "ClassMetrics"
+	EventKindAppAssignment = "AppAssignment"
+	EventKindAppPin        = "AppPin"
+)
+
+// 分类数据的一次变化。ResourceVersion随变化单调递增
+type ClassificationEvent struct {
+	Type            string `json:"type"`
+	Kind            string `json:"kind,omitempty"`
+	ResourceVersion uint64 `json:"resourceVersion"`
+
+	ClassMetrics  *ClassMetrics  `json:"classMetrics,omitempty"`  // Kind为EventKindClassMetrics时有值，删除时只有ClassId
+	AppAssignment *AppAssignment `json:"appAssignment,omitempty"` // Kind为EventKindAppAssignment时有值
+	AppPin        *AppPin        `json:"appPin,omitempty"`        // Kind为EventKindAppPin时有值，删除时只有AppName
+
+	Code    int    `json:"code,omitempty"` // Type为EventTypeError时的HTTP状态码
+	Message string `json:"message,omitempty"`
+}
+
+// 请求的ResourceVersion之后的部分变化已被清理，需要重新获取快照
+var ErrResourceVersionExpired = fmt.Errorf("resourceVersion已过期，需要重新获取快照")
+
 type API interface {
 	QueryAppCharacteristics(appName AppName) (*AppCharacteristics, error)
 
-	ReCluster()
+	QueryAppProfile(appName AppName) (*AppProfile, error)
+
+	// 返回应用分类的变化历史，按时间先后排序
+	QueryAppClassHistory(appName AppName) ([]*AppClassHistory, error)
+
+	// 请求立即再聚类，再聚类在后台执行。本实例不是leader时返回ErrNotLeader，正在再聚类时返回ErrReClusterBusy
+	ReCluster() error
+
+	QueryReClusterSchedule() (*ReClusterSchedule, error)
+
+	QueryReClusterSummary() (*ReClusterSummary, error)
+
+	ReClusterDryRun(params *ReClusterParams) (*ReClusterDryRun, error)
+
+	// 返回当前的全部类别中心与应用分类，以及对应的ResourceVersion
+	QueryClassificationSnapshot() (*ClassificationSnapshot, error)
 }